@@ -6,6 +6,7 @@ import (
 	"encoding/binary"
 	"fmt"
 	"io"
+	"math"
 
 	"github.com/pkg/errors"
 )
@@ -16,6 +17,7 @@ import (
 type Argument interface {
 	io.WriterTo
 
+	AppendTo(dst []byte) []byte
 	Bytes() []byte
 	Equal(Argument) bool
 	ReadInt32() (int32, error)
@@ -23,6 +25,7 @@ type Argument interface {
 	ReadBool() (bool, error)
 	ReadString() (string, error)
 	ReadBlob() ([]byte, error)
+	Size() int
 	String() string
 	Typetag() byte
 }
@@ -41,6 +44,11 @@ func ReadArguments(typetags, data []byte) ([]Argument, error) {
 		if err != nil {
 			return nil, errors.Wrapf(err, "read argument %d", i)
 		}
+		// ReadString pads strings that are not null-terminated past the end of the data.
+		if idx > int64(len(data)) {
+			err := errors.Wrapf(ErrParse, "argument needs %d bytes, only %d left", idx, len(data))
+			return nil, errors.Wrapf(err, "read argument %d", i)
+		}
 		args = append(args, arg)
 		data = data[idx:]
 	}
@@ -54,6 +62,8 @@ func ReadArgument(tt byte, data []byte) (Argument, int64, error) {
 		return ReadIntFrom(data)
 	case TypetagFloat:
 		return ReadFloatFrom(data)
+	case TypetagDouble:
+		return ReadDoubleFrom(data)
 	case TypetagTrue:
 		return Bool(true), 0, nil
 	case TypetagFalse:
@@ -80,14 +90,14 @@ func ReadIntFrom(data []byte) (Argument, int64, error) {
 	return i, 4, nil
 }
 
+// AppendTo appends the binary representation of the arg to dst.
+func (i Int) AppendTo(dst []byte) []byte {
+	return byteOrder.AppendUint32(dst, uint32(i))
+}
+
 // Bytes converts the arg to a byte slice suitable for adding to the binary representation of an OSC message.
 func (i Int) Bytes() []byte {
-	return []byte{
-		byte(int32(i) >> 24),
-		byte(int32(i) >> 16),
-		byte((int32(i) >> 8)),
-		byte(i),
-	}
+	return i.AppendTo(make([]byte, 0, 4))
 }
 
 // Equal returns true if the argument equals the other one, false otherwise.
@@ -114,15 +124,19 @@ func (i Int) ReadString() (string, error) { return "", ErrInvalidTypeTag }
 // ReadBlob reads a slice of bytes from the arg.
 func (i Int) ReadBlob() ([]byte, error) { return nil, ErrInvalidTypeTag }
 
+// Size returns the number of bytes in the binary representation of the arg.
+func (i Int) Size() int { return 4 }
+
 // String converts the arg to a string.
 func (i Int) String() string { return fmt.Sprintf("Int(%d)", i) }
 
 // Typetag returns the argument's type tag.
 func (i Int) Typetag() byte { return TypetagInt }
 
-// WriteTo writes the arg to an io.Writer.
+// WriteTo writes the binary representation of the arg to an io.Writer.
 func (i Int) WriteTo(w io.Writer) (int64, error) {
-	written, err := fmt.Fprintf(w, "%d", i)
+	var buf [4]byte
+	written, err := w.Write(i.AppendTo(buf[:0]))
 	return int64(written), err
 }
 
@@ -138,13 +152,14 @@ func ReadFloatFrom(data []byte) (Argument, int64, error) {
 	return f, 4, nil
 }
 
+// AppendTo appends the binary representation of the arg to dst.
+func (f Float) AppendTo(dst []byte) []byte {
+	return byteOrder.AppendUint32(dst, math.Float32bits(float32(f)))
+}
+
 // Bytes converts the arg to a byte slice suitable for adding to the binary representation of an OSC message.
 func (f Float) Bytes() []byte {
-	var (
-		buf = &bytes.Buffer{}
-		_   = binary.Write(buf, byteOrder, float32(f)) // Never fails
-	)
-	return buf.Bytes()
+	return f.AppendTo(make([]byte, 0, 4))
 }
 
 // Equal returns true if the argument equals the other one, false otherwise.
@@ -171,21 +186,94 @@ func (f Float) ReadString() (string, error) { return "", ErrInvalidTypeTag }
 // ReadBlob reads a slice of bytes from the arg.
 func (f Float) ReadBlob() ([]byte, error) { return nil, ErrInvalidTypeTag }
 
+// Size returns the number of bytes in the binary representation of the arg.
+func (f Float) Size() int { return 4 }
+
 // String converts the arg to a string.
 func (f Float) String() string { return fmt.Sprintf("Float(%f)", f) }
 
 // Typetag returns the argument's type tag.
 func (f Float) Typetag() byte { return TypetagFloat }
 
-// WriteTo writes the arg to an io.Writer.
+// WriteTo writes the binary representation of the arg to an io.Writer.
 func (f Float) WriteTo(w io.Writer) (int64, error) {
-	written, err := fmt.Fprintf(w, "%f", f)
+	var buf [4]byte
+	written, err := w.Write(f.AppendTo(buf[:0]))
+	return int64(written), err
+}
+
+// Double represents a 64-bit float.
+// SuperCollider uses doubles for sample rates, e.g. in /status.reply.
+type Double float64
+
+// ReadDoubleFrom reads a 64-bit float from a byte slice.
+func ReadDoubleFrom(data []byte) (Argument, int64, error) {
+	var d Double
+	if err := binary.Read(bytes.NewReader(data), byteOrder, &d); err != nil {
+		return nil, 0, errors.Wrap(err, "read double argument")
+	}
+	return d, 8, nil
+}
+
+// AppendTo appends the binary representation of the arg to dst.
+func (d Double) AppendTo(dst []byte) []byte {
+	return byteOrder.AppendUint64(dst, math.Float64bits(float64(d)))
+}
+
+// Bytes converts the arg to a byte slice suitable for adding to the binary representation of an OSC message.
+func (d Double) Bytes() []byte {
+	return d.AppendTo(make([]byte, 0, 8))
+}
+
+// Equal returns true if the argument equals the other one, false otherwise.
+func (d Double) Equal(other Argument) bool {
+	if other.Typetag() != TypetagDouble {
+		return false
+	}
+	d2 := other.(Double)
+	return d == d2
+}
+
+// ReadInt32 reads a 32-bit integer from the arg.
+func (d Double) ReadInt32() (int32, error) { return 0, ErrInvalidTypeTag }
+
+// ReadFloat32 reads a 32-bit float from the arg.
+func (d Double) ReadFloat32() (float32, error) { return 0, ErrInvalidTypeTag }
+
+// ReadBool bool reads a boolean from the arg.
+func (d Double) ReadBool() (bool, error) { return false, ErrInvalidTypeTag }
+
+// ReadString string reads a string from the arg.
+func (d Double) ReadString() (string, error) { return "", ErrInvalidTypeTag }
+
+// ReadBlob reads a slice of bytes from the arg.
+func (d Double) ReadBlob() ([]byte, error) { return nil, ErrInvalidTypeTag }
+
+// Size returns the number of bytes in the binary representation of the arg.
+func (d Double) Size() int { return 8 }
+
+// String converts the arg to a string.
+func (d Double) String() string { return fmt.Sprintf("Double(%f)", d) }
+
+// Typetag returns the argument's type tag.
+func (d Double) Typetag() byte { return TypetagDouble }
+
+// WriteTo writes the binary representation of the arg to an io.Writer.
+func (d Double) WriteTo(w io.Writer) (int64, error) {
+	var buf [8]byte
+	written, err := w.Write(d.AppendTo(buf[:0]))
 	return int64(written), err
 }
 
 // Bool represents a boolean value.
 type Bool bool
 
+// AppendTo appends the binary representation of the arg to dst.
+// Booleans are encoded entirely in the typetag, so dst is returned unchanged.
+func (b Bool) AppendTo(dst []byte) []byte {
+	return dst
+}
+
 // Bytes converts the arg to a byte slice suitable for adding to the binary representation of an OSC message.
 func (b Bool) Bytes() []byte {
 	return []byte{}
@@ -215,6 +303,9 @@ func (b Bool) ReadString() (string, error) { return "", ErrInvalidTypeTag }
 // ReadBlob reads a slice of bytes from the arg.
 func (b Bool) ReadBlob() ([]byte, error) { return nil, ErrInvalidTypeTag }
 
+// Size returns the number of bytes in the binary representation of the arg.
+func (b Bool) Size() int { return 0 }
+
 // String converts the arg to a string.
 func (b Bool) String() string { return fmt.Sprintf("Bool(%t)", b) }
 
@@ -226,15 +317,20 @@ func (b Bool) Typetag() byte {
 	return TypetagFalse
 }
 
-// WriteTo writes the arg to an io.Writer.
+// WriteTo writes the binary representation of the arg to an io.Writer.
+// Booleans are encoded entirely in the typetag, so nothing is written.
 func (b Bool) WriteTo(w io.Writer) (int64, error) {
-	written, err := fmt.Fprintf(w, "%t", b)
-	return int64(written), err
+	return 0, nil
 }
 
 // String is a string.
 type String string
 
+// AppendTo appends the binary representation of the arg to dst.
+func (s String) AppendTo(dst []byte) []byte {
+	return AppendString(dst, string(s))
+}
+
 // Bytes converts the arg to a byte slice suitable for adding to the binary representation of an OSC message.
 func (s String) Bytes() []byte {
 	return ToBytes(string(s))
@@ -264,15 +360,18 @@ func (s String) ReadString() (string, error) { return string(s), nil }
 // ReadBlob reads a slice of bytes from the arg.
 func (s String) ReadBlob() ([]byte, error) { return nil, ErrInvalidTypeTag }
 
+// Size returns the number of bytes in the binary representation of the arg.
+func (s String) Size() int { return StringSize(string(s)) }
+
 // String converts the arg to a string.
 func (s String) String() string { return string(s) }
 
 // Typetag returns the argument's type tag.
 func (s String) Typetag() byte { return TypetagString }
 
-// WriteTo writes the arg to an io.Writer.
+// WriteTo writes the binary representation of the arg to an io.Writer.
 func (s String) WriteTo(w io.Writer) (int64, error) {
-	written, err := fmt.Fprintf(w, "%s", s)
+	written, err := w.Write(s.Bytes())
 	return int64(written), err
 }
 
@@ -280,21 +379,34 @@ func (s String) WriteTo(w io.Writer) (int64, error) {
 type Blob []byte
 
 // ReadBlobFrom reads a binary blob from the provided data.
+// The blob has the length it was written with, without its padding.
+// A length that is negative or larger than the data is a parse error.
 func ReadBlobFrom(data []byte) (Argument, int64, error) {
 	var length int32
 	if err := binary.Read(bytes.NewReader(data), byteOrder, &length); err != nil {
 		return nil, 0, errors.Wrap(err, "read blob argument")
 	}
+	if length < 0 {
+		return nil, 0, errors.Wrapf(ErrParse, "blob length %d is negative", length)
+	}
+	if int64(length) > int64(len(data)-4) {
+		return nil, 0, errors.Wrapf(ErrParse, "blob length %d is greater than data length %d", length, len(data)-4)
+	}
 	b, bl := ReadBlob(length, data[4:])
-	return Blob(b), bl + 4, nil
+	return Blob(b[:length]), bl + 4, nil
+}
+
+// AppendTo appends the binary representation of the arg to dst.
+func (b Blob) AppendTo(dst []byte) []byte {
+	start := len(dst)
+	dst = byteOrder.AppendUint32(dst, uint32(len(b)))
+	dst = append(dst, b...)
+	return appendPad(dst, start)
 }
 
 // Bytes converts the arg to a byte slice suitable for adding to the binary representation of an OSC message.
 func (b Blob) Bytes() []byte {
-	return Pad(bytes.Join([][]byte{
-		Int(len(b)).Bytes(),
-		[]byte(b),
-	}, []byte{}))
+	return b.AppendTo(make([]byte, 0, b.Size()))
 }
 
 // Equal returns true if the argument equals the other one, false otherwise.
@@ -324,15 +436,18 @@ func (b Blob) ReadString() (string, error) { return "", ErrInvalidTypeTag }
 // ReadBlob reads a slice of bytes from the arg.
 func (b Blob) ReadBlob() ([]byte, error) { return []byte(b), nil }
 
+// Size returns the number of bytes in the binary representation of the arg.
+func (b Blob) Size() int { return 4 + padSize(len(b)) }
+
 // String converts the arg to a string.
 func (b Blob) String() string { return base64.StdEncoding.EncodeToString([]byte(b)) }
 
 // Typetag returns the argument's type tag.
 func (b Blob) Typetag() byte { return TypetagBlob }
 
-// WriteTo writes the arg to an io.Writer.
+// WriteTo writes the binary representation of the arg to an io.Writer.
 func (b Blob) WriteTo(w io.Writer) (int64, error) {
-	written, err := w.Write([]byte(b))
+	written, err := w.Write(b.Bytes())
 	return int64(written), err
 }
 
@@ -166,6 +166,52 @@ func TestFloatWriteTo(t *testing.T) {
 	}
 }
 
+func TestDoubleBytes(t *testing.T) {
+	if expected, got := []byte{0x40, 0xe7, 0x70, 0, 0, 0, 0, 0}, Double(48000).Bytes(); !bytes.Equal(expected, got) {
+		t.Fatalf("expected %x, got %x", expected, got)
+	}
+}
+
+func TestDoubleEqual(t *testing.T) {
+	equalTest{
+		arg:      Double(0.5),
+		equal:    []Argument{Double(0.5)},
+		notEqual: []Argument{Double(3.14), Float(0.5), String("foo")},
+	}.run(t)
+}
+
+func TestDoubleReadOther(t *testing.T) {
+	d := Double(0)
+	if _, err := d.ReadInt32(); err != ErrInvalidTypeTag {
+		t.Fatalf("expected ErrInvalidTypeTag, got %+v", err)
+	}
+	if _, err := d.ReadFloat32(); err != ErrInvalidTypeTag {
+		t.Fatalf("expected ErrInvalidTypeTag, got %+v", err)
+	}
+	if _, err := d.ReadBool(); err != ErrInvalidTypeTag {
+		t.Fatalf("expected ErrInvalidTypeTag, got %+v", err)
+	}
+	if _, err := d.ReadString(); err != ErrInvalidTypeTag {
+		t.Fatalf("expected ErrInvalidTypeTag, got %+v", err)
+	}
+	if _, err := d.ReadBlob(); err != ErrInvalidTypeTag {
+		t.Fatalf("expected ErrInvalidTypeTag, got %+v", err)
+	}
+}
+
+func TestDoubleString(t *testing.T) {
+	arg := Double(0)
+	if expected, got := "Double(0.000000)", arg.String(); expected != got {
+		t.Fatalf("expected %s to equal %s", expected, got)
+	}
+	if expected, got := TypetagDouble, arg.Typetag(); expected != got {
+		t.Fatalf("expected %c, got %c", expected, got)
+	}
+	if _, err := arg.WriteTo(ioutil.Discard); err != nil {
+		t.Fatal(err)
+	}
+}
+
 func TestBoolBytes(t *testing.T) {
 	arg := Bool(false)
 	if expected, got := []byte{}, arg.Bytes(); !bytes.Equal(expected, got) {
@@ -307,6 +353,19 @@ func TestBlobBytes(t *testing.T) {
 	}
 }
 
+func TestReadBlobFrom(t *testing.T) {
+	arg, n, err := ReadBlobFrom(Blob("foo").Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := int64(8), n; expected != got {
+		t.Fatalf("expected %d, got %d", expected, got)
+	}
+	if expected, got := Blob("foo"), arg; !expected.Equal(got) {
+		t.Fatalf("expected %q, got %q", expected, got)
+	}
+}
+
 func TestBlobEqual(t *testing.T) {
 	arg := Blob([]byte{'f', 'o', 'o'})
 	if other := Blob([]byte{'f', 'o', 'o'}); !arg.Equal(other) {
@@ -402,6 +461,14 @@ func TestReadArgument(t *testing.T) {
 			Input:    Input{tt: TypetagFloat, data: []byte{}},
 			Expected: Output{Err: errors.New("read float argument: EOF")},
 		},
+		{
+			Input:    Input{tt: TypetagDouble, data: []byte{0x40, 0xe7, 0x70, 0, 0, 0, 0, 0}},
+			Expected: Output{Argument: Double(48000), Consumed: 8},
+		},
+		{
+			Input:    Input{tt: TypetagDouble, data: []byte{0, 0, 0, 0}},
+			Expected: Output{Err: errors.New("read double argument: unexpected EOF")},
+		},
 		{
 			Input:    Input{tt: TypetagTrue},
 			Expected: Output{Argument: Bool(true)},
@@ -417,12 +484,24 @@ func TestReadArgument(t *testing.T) {
 		{
 			// Length followed by blob
 			Input:    Input{tt: TypetagBlob, data: []byte{0, 0, 0, 5, 'a', 'b', 'c', 'd', 'e'}},
-			Expected: Output{Argument: Blob([]byte{'a', 'b', 'c', 'd', 'e', 0, 0, 0}), Consumed: 12},
+			Expected: Output{Argument: Blob([]byte{'a', 'b', 'c', 'd', 'e'}), Consumed: 12},
 		},
 		{
 			Input:    Input{tt: TypetagBlob, data: []byte{}},
 			Expected: Output{Err: errors.New("read blob argument: EOF")},
 		},
+		{
+			Input:    Input{tt: TypetagBlob, data: []byte{0x8a, '0', '0', '0'}},
+			Expected: Output{Err: errors.New("blob length -1976553424 is negative: error parsing message")},
+		},
+		{
+			Input:    Input{tt: TypetagBlob, data: []byte{0xff, 0xff, 0xff, 0xff}},
+			Expected: Output{Err: errors.New("blob length -1 is negative: error parsing message")},
+		},
+		{
+			Input:    Input{tt: TypetagBlob, data: []byte{0, 0, 0, 5, 'a', 'b', 'c', 'd'}},
+			Expected: Output{Err: errors.New("blob length 5 is greater than data length 4: error parsing message")},
+		},
 		{
 			Input:    Input{tt: 'Q'},
 			Expected: Output{Err: errors.Wrap(ErrInvalidTypeTag, `typetag "Q"`)},
@@ -473,13 +552,17 @@ func TestReadArguments(t *testing.T) {
 			Expected: Output{Arguments: []Argument{Int(1)}},
 		},
 		{
-			Input: Input{Typetags: []byte{TypetagBlob}, Data: []byte{0, 0, 1, 1, 4, 5, 6, 7}},
+			Input: Input{Typetags: []byte{TypetagBlob}, Data: []byte{0, 0, 0, 4, 4, 5, 6, 7}},
 			Expected: Output{
 				Arguments: []Argument{
 					Blob([]byte{4, 5, 6, 7}),
 				},
 			},
 		},
+		{
+			Input:    Input{Typetags: []byte{TypetagBlob}, Data: []byte{0, 0, 1, 1, 4, 5, 6, 7}},
+			Expected: Output{Err: errors.New("read argument 0: blob length 257 is greater than data length 4: error parsing message")},
+		},
 	} {
 		args, err := ReadArguments(testcase.Input.Typetags, testcase.Input.Data)
 
@@ -502,3 +585,50 @@ func TestReadArguments(t *testing.T) {
 		}
 	}
 }
+
+func TestArgumentAppendTo(t *testing.T) {
+	prefix := []byte{1, 2, 3}
+
+	for i, arg := range []Argument{
+		Int(-7),
+		Float(3.14),
+		Bool(true),
+		Bool(false),
+		String(""),
+		String("foo"),
+		String("foob"),
+		Blob{},
+		Blob("foo"),
+		Blob("foob"),
+	} {
+		expected := append(append([]byte{}, prefix...), arg.Bytes()...)
+		if got := arg.AppendTo(append([]byte{}, prefix...)); !bytes.Equal(expected, got) {
+			t.Fatalf("(argument %d) expected %q, got %q", i, expected, got)
+		}
+		if expected, got := len(arg.Bytes()), arg.Size(); expected != got {
+			t.Fatalf("(argument %d) expected size %d, got %d", i, expected, got)
+		}
+	}
+}
+
+func TestArgumentWriteTo(t *testing.T) {
+	for i, arg := range []Argument{
+		Int(-7),
+		Float(3.14),
+		Bool(true),
+		String("foo"),
+		Blob("foo"),
+	} {
+		buf := &bytes.Buffer{}
+		n, err := arg.WriteTo(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected, got := arg.Bytes(), buf.Bytes(); !bytes.Equal(expected, got) {
+			t.Fatalf("(argument %d) expected %q, got %q", i, expected, got)
+		}
+		if expected, got := int64(len(arg.Bytes())), n; expected != got {
+			t.Fatalf("(argument %d) expected %d, got %d", i, expected, got)
+		}
+	}
+}
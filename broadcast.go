@@ -0,0 +1,282 @@
+package osc
+
+import (
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Common errors.
+var (
+	ErrDuplicateDestination = errors.New("duplicate destination")
+	ErrUnknownDestination   = errors.New("unknown destination")
+)
+
+// Broadcaster sends every packet to a set of destinations, which can be
+// reached over different transports. Destinations can be added and removed
+// at any time, and it is safe to call Send from multiple goroutines.
+type Broadcaster struct {
+	mu           sync.RWMutex
+	destinations map[string]*destination
+	framing      Framing
+	timeout      time.Duration
+}
+
+// destination is a connection that a Broadcaster sends to.
+type destination struct {
+	mu      sync.Mutex // Keeps concurrently sent frames from interleaving.
+	conn    net.Conn
+	stream  bool
+	framing Framing
+}
+
+// messageConn is implemented by connections that send every Write in a message
+// of its own, so that packets don't need framing even over a stream network.
+type messageConn interface {
+	net.Conn
+	preservesMessages()
+}
+
+// BroadcastError is returned by Broadcaster.Send when sending to
+// some of the destinations failed.
+type BroadcastError struct {
+	// Errors holds the error of every destination that failed, by address.
+	Errors map[string]error
+}
+
+// Error returns a description of the errors, sorted by destination.
+func (e *BroadcastError) Error() string {
+	addrs := make([]string, 0, len(e.Errors))
+	for addr := range e.Errors {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+
+	errs := make([]string, len(addrs))
+	for i, addr := range addrs {
+		errs[i] = "send to " + addr + ": " + e.Errors[addr].Error()
+	}
+	return strings.Join(errs, "; ")
+}
+
+// NewBroadcaster returns a broadcaster without any destinations.
+// Packets are sent over stream connections with LengthPrefixed framing,
+// see SetFraming.
+func NewBroadcaster() *Broadcaster {
+	return &Broadcaster{destinations: map[string]*destination{}}
+}
+
+// Add connects to address on network and adds it to the destinations.
+// network can be udp, udp4, udp6, unixgram, tcp, tcp4 or tcp6,
+// with unix being the same as unixgram.
+// The address is the name of the destination for Remove and in errors.
+func (b *Broadcaster) Add(network, address string) error {
+	switch network {
+	case "udp", "udp4", "udp6", "tcp", "tcp4", "tcp6", "unixgram":
+	case "unix":
+		network = "unixgram"
+	default:
+		return errors.Errorf("unsupported network %q", network)
+	}
+	b.mu.RLock()
+	_, exists := b.destinations[address]
+	b.mu.RUnlock()
+
+	if exists {
+		return errors.Wrapf(ErrDuplicateDestination, "add %s", address)
+	}
+	conn, err := net.Dial(network, address)
+	if err != nil {
+		return err
+	}
+	if err := b.add(address, conn); err != nil {
+		_ = conn.Close()
+		return err
+	}
+	return nil
+}
+
+// AddConn adds a connection to the destinations.
+// Its remote address is the name of the destination for Remove and in errors.
+// TCP and unix stream connections are framed, other connections are expected
+// to be datagram or WebSocket connections that preserve packet boundaries.
+// Connections without a remote address, like the ones returned by
+// net.ListenUDP, can't be added.
+// The broadcaster closes conn when it is removed.
+func (b *Broadcaster) AddConn(conn net.Conn) error {
+	addr := conn.RemoteAddr()
+	if addr == nil {
+		return errors.New("add conn: no remote address")
+	}
+	return b.add(addr.String(), conn)
+}
+
+// add adds conn to the destinations with the given name.
+func (b *Broadcaster) add(name string, conn net.Conn) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, exists := b.destinations[name]; exists {
+		return errors.Wrapf(ErrDuplicateDestination, "add %s", name)
+	}
+	var stream bool
+	if _, ok := conn.(messageConn); !ok {
+		switch conn.RemoteAddr().Network() {
+		case "tcp", "tcp4", "tcp6", "unix":
+			stream = true
+		}
+	}
+	b.destinations[name] = &destination{conn: conn, stream: stream, framing: b.framing}
+	return nil
+}
+
+// Remove removes a destination and closes its connection.
+func (b *Broadcaster) Remove(address string) error {
+	b.mu.Lock()
+	d, ok := b.destinations[address]
+	delete(b.destinations, address)
+	b.mu.Unlock()
+
+	if !ok {
+		return errors.Wrapf(ErrUnknownDestination, "remove %s", address)
+	}
+	return d.conn.Close()
+}
+
+// Destinations returns the sorted addresses of the destinations.
+func (b *Broadcaster) Destinations() []string {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	addrs := make([]string, 0, len(b.destinations))
+	for addr := range b.destinations {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+	return addrs
+}
+
+// SetFraming sets the framing of the stream destinations that are added from now on.
+func (b *Broadcaster) SetFraming(framing Framing) error {
+	switch framing {
+	case LengthPrefixed, SLIP:
+	default:
+		return errors.Wrapf(ErrInvalidFraming, "framing %d", framing)
+	}
+	b.mu.Lock()
+	b.framing = framing
+	b.mu.Unlock()
+	return nil
+}
+
+// SetWriteTimeout limits the time that sending to a single destination can take,
+// so that a destination that stopped reading can't block Send. Zero means no limit.
+func (b *Broadcaster) SetWriteTimeout(timeout time.Duration) {
+	b.mu.Lock()
+	b.timeout = timeout
+	b.mu.Unlock()
+}
+
+// Send encodes p once and sends it to every destination concurrently.
+// If sending to any of the destinations fails, the error is a *BroadcastError
+// with the error of every destination that failed.
+func (b *Broadcaster) Send(p Packet) error {
+	b.mu.RLock()
+	var (
+		timeout = b.timeout
+		names   = make([]string, 0, len(b.destinations))
+		dests   = make([]*destination, 0, len(b.destinations))
+	)
+	for name, d := range b.destinations {
+		names, dests = append(names, name), append(dests, d)
+	}
+	b.mu.RUnlock()
+
+	if len(dests) == 0 {
+		return nil
+	}
+	buf := encodePacket(p)
+	defer bufPool.Put(buf)
+
+	// Every frame is encoded once, before the sends start.
+	frames := map[Framing][]byte{}
+	for _, d := range dests {
+		if _, ok := frames[d.framing]; d.stream && !ok {
+			frames[d.framing] = frame(*buf, d.framing)
+		}
+	}
+	var (
+		errs = make([]error, len(dests))
+		wg   sync.WaitGroup
+	)
+	for i, d := range dests {
+		data := *buf
+		if d.stream {
+			data = frames[d.framing]
+		}
+		wg.Add(1)
+		go func(i int, d *destination, data []byte) {
+			defer wg.Done()
+			errs[i] = d.write(data, timeout)
+		}(i, d, data)
+	}
+	wg.Wait()
+
+	var berr *BroadcastError
+	for i, err := range errs {
+		if err == nil {
+			continue
+		}
+		if berr == nil {
+			berr = &BroadcastError{Errors: map[string]error{}}
+		}
+		berr.Errors[names[i]] = err
+	}
+	if berr != nil {
+		return berr
+	}
+	return nil
+}
+
+// Close removes every destination and closes their connections.
+func (b *Broadcaster) Close() error {
+	b.mu.Lock()
+	dests := b.destinations
+	b.destinations = map[string]*destination{}
+	b.mu.Unlock()
+
+	var err error
+	for _, d := range dests {
+		if cerr := d.conn.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// write writes data to the destination.
+func (d *destination) write(data []byte, timeout time.Duration) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if timeout > 0 {
+		if err := d.conn.SetWriteDeadline(time.Now().Add(timeout)); err != nil {
+			return err
+		}
+	}
+	_, err := d.conn.Write(data)
+	return err
+}
+
+// frame returns the frame of an encoded packet.
+func frame(data []byte, framing Framing) []byte {
+	if framing == SLIP {
+		return appendSLIP(nil, data)
+	}
+	framed := byteOrder.AppendUint32(make([]byte, 0, 4+len(data)), uint32(len(data)))
+	return append(framed, data...)
+}
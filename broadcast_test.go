@@ -0,0 +1,166 @@
+package osc
+
+import (
+	"net"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestBroadcaster(t *testing.T) {
+	var (
+		b    = NewBroadcaster()
+		pc1  = testPacketConn(t)
+		pc2  = testPacketConn(t)
+		msg  = Message{Address: "/cue", Arguments: Arguments{Int(12)}}
+		accs = make(chan net.Conn, 1)
+	)
+	defer func() { _ = b.Close() }()
+	defer func() { _ = pc1.Close() }()
+	defer func() { _ = pc2.Close() }()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = ln.Close() }()
+
+	go func() {
+		conn, err := ln.Accept()
+		if err == nil {
+			accs <- conn
+		}
+	}()
+	if err := b.Add("udp", pc1.LocalAddr().String()); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Add("udp", pc2.LocalAddr().String()); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.SetFraming(SLIP); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Add("tcp", ln.Addr().String()); err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := 3, len(b.Destinations()); expected != got {
+		t.Fatalf("expected %d destinations, got %d", expected, got)
+	}
+	if err := b.Send(msg); err != nil {
+		t.Fatal(err)
+	}
+	for _, pc := range []net.PacketConn{pc1, pc2} {
+		if got := receivePacket(t, pc, time.Second); got == nil || !msg.Equal(got) {
+			t.Fatalf("expected %s, got %v", msg, got)
+		}
+	}
+	conn := <-accs
+	defer func() { _ = conn.Close() }()
+
+	if err := conn.SetReadDeadline(time.Now().Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	got, err := NewDecoder(conn, SLIP).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !msg.Equal(got) {
+		t.Fatalf("expected %s, got %s", msg, got)
+	}
+
+	// Removed destinations don't receive packets anymore.
+	if err := b.Remove(pc2.LocalAddr().String()); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{ln.Addr().String(), pc1.LocalAddr().String()}
+	sort.Strings(expected)
+	if got := b.Destinations(); strings.Join(expected, " ") != strings.Join(got, " ") {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+	if err := b.Send(msg); err != nil {
+		t.Fatal(err)
+	}
+	if got := receivePacket(t, pc1, time.Second); got == nil {
+		t.Fatal("expected a packet, got nil")
+	}
+	if got := receivePacket(t, pc2, 50*time.Millisecond); got != nil {
+		t.Fatalf("expected no packet after removing the destination, got %s", got)
+	}
+}
+
+func TestBroadcasterErrors(t *testing.T) {
+	var (
+		b  = NewBroadcaster()
+		pc = testPacketConn(t)
+	)
+	defer func() { _ = b.Close() }()
+	defer func() { _ = pc.Close() }()
+
+	if err := b.Send(Message{Address: "/foo"}); err != nil {
+		t.Fatalf("expected no error without destinations, got %s", err)
+	}
+	if err := b.Add("udp", pc.LocalAddr().String()); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Add("udp", pc.LocalAddr().String()); errors.Cause(err) != ErrDuplicateDestination {
+		t.Fatalf("expected %s, got %+v", ErrDuplicateDestination, err)
+	}
+	if err := b.Add("ip", "127.0.0.1"); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	if err := b.Remove("127.0.0.1:1"); errors.Cause(err) != ErrUnknownDestination {
+		t.Fatalf("expected %s, got %+v", ErrUnknownDestination, err)
+	}
+	if err := b.SetFraming(Framing(9)); errors.Cause(err) != ErrInvalidFraming {
+		t.Fatalf("expected %s, got %+v", ErrInvalidFraming, err)
+	}
+	unconnected, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = unconnected.Close() }()
+
+	if err := b.AddConn(unconnected); err == nil {
+		t.Fatal("expected error for a conn without a remote address, got nil")
+	}
+
+	// A closed connection fails, but the other destinations still get the packet.
+	closed, err := net.Dial("udp", "127.0.0.1:9")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = closed.Close()
+
+	if err := b.AddConn(closed); err != nil {
+		t.Fatal(err)
+	}
+	b.SetWriteTimeout(time.Second)
+
+	err = b.Send(Message{Address: "/foo"})
+	berr, ok := err.(*BroadcastError)
+	if !ok {
+		t.Fatalf("expected a *BroadcastError, got %+v", err)
+	}
+	if _, ok := berr.Errors["127.0.0.1:9"]; !ok || len(berr.Errors) != 1 {
+		t.Fatalf("expected an error for 127.0.0.1:9, got %v", berr.Errors)
+	}
+	if expected, got := "send to 127.0.0.1:9: ", berr.Error(); !strings.HasPrefix(got, expected) {
+		t.Fatalf("expected %s to start with %s", got, expected)
+	}
+	if got := receivePacket(t, pc, time.Second); got == nil {
+		t.Fatal("expected a packet, got nil")
+	}
+}
+
+// testPacketConn returns a UDP socket on the loopback interface.
+// The caller closes it.
+func testPacketConn(t *testing.T) net.PacketConn {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pc
+}
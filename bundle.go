@@ -3,6 +3,7 @@ package osc
 import (
 	"bytes"
 	"encoding/binary"
+	"io"
 	"net"
 
 	"github.com/pkg/errors"
@@ -38,7 +39,7 @@ func ParseBundle(data []byte, sender net.Addr) (Bundle, error) {
 // It will stop after reading limit bytes.
 // If you wish to have it consume as many bytes as possible, pass -1 as the limit.
 func parseBundle(data []byte, sender net.Addr, limit int32) (Bundle, error) {
-	b := Bundle{}
+	b := Bundle{Sender: sender}
 
 	// If 0 <= limit < 16 this is an error.
 	// We have to be able to read at least the bundle tag and a timetag.
@@ -73,20 +74,41 @@ func parseBundle(data []byte, sender net.Addr, limit int32) (Bundle, error) {
 	return b, nil
 }
 
+// AppendTo appends the contents of the bundle to dst and returns the extended slice.
+// If dst has enough capacity no allocations are made.
+func (b Bundle) AppendTo(dst []byte) []byte {
+	dst = AppendString(dst, BundleTag)
+	dst = b.Timetag.AppendTo(dst)
+	for _, p := range b.Packets {
+		// Reserve space for the element size and fill it in once the element has been appended.
+		start := len(dst)
+		dst = appendPacket(append(dst, 0, 0, 0, 0), p)
+		byteOrder.PutUint32(dst[start:], uint32(len(dst)-start-4))
+	}
+	return dst
+}
+
 // Bytes returns the contents of the bundle as a slice of bytes.
 func (b Bundle) Bytes() []byte {
-	bss := [][]byte{
-		ToBytes(BundleTag),
-		b.Timetag.Bytes(),
-	}
-	for _, p := range b.Packets {
-		var (
-			bs     = p.Bytes()
-			length = Int(int32(len(bs)))
-		)
-		bss = append(bss, length.Bytes(), p.Bytes())
+	return b.AppendTo(make([]byte, 0, b.Size()))
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler.
+// It returns the same bytes as Bytes.
+func (b Bundle) MarshalBinary() ([]byte, error) {
+	return b.Bytes(), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+// The bundle's Sender is left unchanged.
+func (b *Bundle) UnmarshalBinary(data []byte) error {
+	// Parsed blobs refer to the data they were parsed from, so make a copy.
+	bundle, err := ParseBundle(append([]byte{}, data...), b.Sender)
+	if err != nil {
+		return err
 	}
-	return bytes.Join(bss, []byte{})
+	*b = bundle
+	return nil
 }
 
 // Equal returns true if one bundle equals another, and false otherwise.
@@ -109,6 +131,41 @@ func (b Bundle) Equal(other Packet) bool {
 	return true
 }
 
+// Size returns the number of bytes in the binary representation of the bundle.
+func (b Bundle) Size() int {
+	size := StringSize(BundleTag) + TimetagSize
+	for _, p := range b.Packets {
+		size += 4 + packetSize(p)
+	}
+	return size
+}
+
+// WriteTo writes the binary representation of the bundle to an io.Writer.
+// The written bytes are exactly the ones returned by Bytes.
+func (b Bundle) WriteTo(w io.Writer) (int64, error) {
+	buf := bufPool.Get().(*[]byte)
+	defer bufPool.Put(buf)
+
+	*buf = b.Timetag.AppendTo(AppendString((*buf)[:0], BundleTag))
+
+	nw, err := w.Write(*buf)
+	bytesWritten := int64(nw)
+	if err != nil {
+		return bytesWritten, err
+	}
+	for _, p := range b.Packets {
+		*buf = appendPacket(append((*buf)[:0], 0, 0, 0, 0), p)
+		byteOrder.PutUint32(*buf, uint32(len(*buf)-4))
+
+		nw, err := w.Write(*buf)
+		bytesWritten += int64(nw)
+		if err != nil {
+			return bytesWritten, err
+		}
+	}
+	return bytesWritten, nil
+}
+
 // sliceBundleTag slices the bundle tag off the data.
 // If the bundle tag is not present or is not correct, an error is returned.
 func sliceBundleTag(data []byte) ([]byte, error) {
@@ -170,23 +227,23 @@ func readPacket(data []byte, sender net.Addr) (Packet, int32, error) {
 
 	data = data[4:]
 
+	if l < 0 {
+		return nil, 0, errors.Errorf("packet length %d is negative", l)
+	}
 	if int32(len(data)) < l {
 		return nil, 0, errors.Errorf("packet length %d is greater than data length %d", l, len(data))
 	}
-
-	switch data[0] {
-	case MessageChar:
-		msg, err := ParseMessage(data, sender)
-		if err != nil {
-			return nil, 0, errors.Wrap(err, "parse message from packet")
-		}
-		return msg, l, nil // The returned length includes the packet length integer.
-	case BundleTag[0]:
-		bundle, err := parseBundle(data, sender, l)
-		if err != nil {
-			return nil, 0, errors.Wrap(err, "parse bundle from packet")
-		}
-		return bundle, l, nil // The returned length includes the packet length integer.
+	// Don't let nested bundles read the packets that follow them.
+	data = data[:l]
+
+	p, err := ParsePacket(data, sender)
+	switch {
+	case err == nil:
+		return p, l, nil // The returned length includes the packet length integer.
+	case data[0] == MessageChar:
+		return nil, 0, errors.Wrap(err, "parse message from packet")
+	case data[0] == BundleTag[0]:
+		return nil, 0, errors.Wrap(err, "parse bundle from packet")
 	default:
 		return nil, 0, errors.Errorf("packet should never start with %c", data[0])
 	}
@@ -2,6 +2,7 @@ package osc
 
 import (
 	"bytes"
+	"net"
 	"testing"
 
 	"github.com/pkg/errors"
@@ -91,6 +92,69 @@ func TestBundleBytes(t *testing.T) {
 	}
 }
 
+func TestBundleAppendTo(t *testing.T) {
+	b := Bundle{
+		Timetag: 10,
+		Packets: []Packet{
+			Bundle{
+				Timetag: 20,
+				Packets: []Packet{
+					Message{Address: "/foobar", Arguments: Arguments{Float(1)}},
+				},
+			},
+			Message{Address: "/foo", Arguments: Arguments{Int(2), String("bar")}},
+			badPacket{},
+		},
+	}
+	if expected, got := b.Bytes(), b.AppendTo([]byte{}); !bytes.Equal(expected, got) {
+		t.Fatalf("expected %q, got %q", expected, got)
+	}
+	if expected, got := len(b.Bytes()), b.Size(); expected != got {
+		t.Fatalf("expected %d, got %d", expected, got)
+	}
+}
+
+func TestBundleWriteTo(t *testing.T) {
+	b := Bundle{
+		Timetag: 10,
+		Packets: []Packet{
+			Message{Address: "/foo", Arguments: Arguments{Int(2), String("bar")}},
+			badPacket{},
+		},
+	}
+	buf := &bytes.Buffer{}
+	if _, err := b.WriteTo(buf); err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := b.Bytes(), buf.Bytes(); !bytes.Equal(expected, got) {
+		t.Fatalf("expected %q, got %q", expected, got)
+	}
+	for _, erridx := range []int{1, 2, 3} {
+		if _, err := b.WriteTo(&errWriter{erridx: erridx}); err == nil {
+			t.Fatalf("(erridx %d) expected error, got nil", erridx)
+		}
+	}
+}
+
+func BenchmarkBundleAppendTo(b *testing.B) {
+	var (
+		bundle = Bundle{
+			Timetag: Immediately,
+			Packets: []Packet{
+				Message{Address: "/n_set", Arguments: Arguments{Int(1000), String("freq"), Float(440)}},
+				Message{Address: "/n_set", Arguments: Arguments{Int(1001), String("amp"), Float(0.5)}},
+			},
+		}
+		buf = make([]byte, 0, bundle.Size())
+	)
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		buf = bundle.AppendTo(buf[:0])
+	}
+}
+
 func TestBundleEqual(t *testing.T) {
 	for _, testcase := range []struct {
 		b  Bundle
@@ -390,3 +454,48 @@ func TestParseBundleLimit(t *testing.T) {
 		t.Fatalf("expected %s, got %s", expected, got)
 	}
 }
+
+func TestParseBundleLengths(t *testing.T) {
+	nested := Bundle{
+		Timetag: Immediately,
+		Packets: []Packet{
+			Bundle{Timetag: Immediately, Packets: []Packet{Message{Address: "/a"}}},
+			Message{Address: "/b"},
+		},
+	}
+	b, err := ParseBundle(nested.Bytes(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !nested.Equal(b) {
+		t.Fatalf("expected %q, got %q", nested, b)
+	}
+	negative := bytes.Join([][]byte{
+		ToBytes(BundleTag),
+		{0, 0, 0, 0, 0, 0, 0, 1}, // Timetag
+		{0xFF, 0xFF, 0xFF, 0xFC}, // Length of first bundle element
+		{'/', 'a', 0, 0, ',', 0, 0, 0},
+	}, []byte{})
+	_, err = ParseBundle(negative, nil)
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	if expected, got := "read packets: read packet: packet length -4 is negative", err.Error(); expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+}
+
+func TestParseBundleSender(t *testing.T) {
+	sender := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 5000}
+
+	b, err := ParseBundle(Bundle{Timetag: Immediately, Packets: []Packet{Message{Address: "/foo"}}}.Bytes(), sender)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := sender, b.Sender; expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+	if expected, got := sender, b.Packets[0].(Message).Sender; expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+}
@@ -0,0 +1,266 @@
+package osc
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Common errors.
+var (
+	ErrClientClosed = errors.New("client closed")
+)
+
+// Client sends requests over a Conn and waits for the messages that answer them,
+// like /synced after /sync or /status.reply after /status.
+// Replies are matched to requests by functions like the ones returned by MatchReply,
+// so many requests can be outstanding at the same time.
+//
+// The client reads every packet that arrives on the Conn, so the Conn
+// should not be served by anything else.
+type Client struct {
+	conn Conn
+
+	mu            sync.Mutex
+	calls         []*call
+	dispatcher    Dispatcher
+	err           error
+	timeout       time.Duration
+	retries       int
+	retryInterval time.Duration
+
+	done      chan struct{}
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// call is a request that is waiting for its reply.
+type call struct {
+	match func(Message) bool
+	reply chan Message
+}
+
+// DefaultClientTimeout is how long a Client waits for a reply by default.
+const DefaultClientTimeout = 5 * time.Second
+
+// NewClient returns a client that sends requests over conn,
+// which should be connected to the server with DialUDP or DialUnix.
+// The client starts reading from conn right away.
+func NewClient(conn Conn) *Client {
+	c := &Client{
+		conn:    conn,
+		timeout: DefaultClientTimeout,
+		done:    make(chan struct{}),
+	}
+	go c.readLoop()
+	return c
+}
+
+// MatchReply returns a function that matches messages with the given address
+// whose first arguments are equal to args. For example, the reply to /sync 1
+// is matched by MatchReply("/synced", Int(1)), and the reply to /b_alloc 3 by
+// MatchReply("/done", String("/b_alloc"), Int(3)).
+func MatchReply(address string, args ...Argument) func(Message) bool {
+	return func(msg Message) bool {
+		if msg.Address != address || len(msg.Arguments) < len(args) {
+			return false
+		}
+		for i, arg := range args {
+			if !arg.Equal(msg.Arguments[i]) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// SetDispatcher sets a dispatcher for the messages that are not a reply
+// to any outstanding request, like notifications from a server.
+// Without a dispatcher these messages are dropped.
+// The messages of a bundle are invoked one by one,
+// and errors returned by the dispatcher are ignored.
+func (c *Client) SetDispatcher(dispatcher Dispatcher) {
+	c.mu.Lock()
+	c.dispatcher = dispatcher
+	c.mu.Unlock()
+}
+
+// SetTimeout sets how long Call waits for a reply if its context doesn't
+// expire earlier. Zero or less waits as long as the context allows.
+func (c *Client) SetTimeout(timeout time.Duration) {
+	c.mu.Lock()
+	c.timeout = timeout
+	c.mu.Unlock()
+}
+
+// SetRetry makes Call send a request again if no reply has arrived within interval,
+// at most retries times, to recover from lost UDP packets.
+// Only requests that can safely be repeated should be retried,
+// which is why retries are disabled by default.
+func (c *Client) SetRetry(retries int, interval time.Duration) {
+	c.mu.Lock()
+	c.retries, c.retryInterval = retries, interval
+	c.mu.Unlock()
+}
+
+// Call sends msg and returns the first message that arrives after it
+// for which matchReply returns true.
+// It returns an error if the client's timeout passes or ctx is done
+// before the reply arrives.
+func (c *Client) Call(ctx context.Context, msg Message, matchReply func(Message) bool) (Message, error) {
+	if matchReply == nil {
+		return Message{}, errors.New("call needs a function to match the reply")
+	}
+	c.mu.Lock()
+	if c.err != nil {
+		err := c.err
+		c.mu.Unlock()
+		return Message{}, err
+	}
+	var (
+		cl            = &call{match: matchReply, reply: make(chan Message, 1)}
+		timeout       = c.timeout
+		retries       = c.retries
+		retryInterval = c.retryInterval
+	)
+	c.calls = append(c.calls, cl)
+	c.mu.Unlock()
+
+	defer c.remove(cl)
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	var retry <-chan time.Time
+	if retries > 0 && retryInterval > 0 {
+		ticker := time.NewTicker(retryInterval)
+		defer ticker.Stop()
+		retry = ticker.C
+	}
+	if err := c.conn.Send(msg); err != nil {
+		return Message{}, errors.Wrapf(err, "send %s", msg.Address)
+	}
+	for {
+		select {
+		case reply := <-cl.reply:
+			return reply, nil
+		case <-c.done:
+			// A reply might have arrived right before the client stopped.
+			select {
+			case reply := <-cl.reply:
+				return reply, nil
+			default:
+			}
+			c.mu.Lock()
+			err := c.err
+			c.mu.Unlock()
+			return Message{}, err
+		case <-ctx.Done():
+			return Message{}, errors.Wrapf(ctx.Err(), "wait for reply to %s", msg.Address)
+		case <-retry:
+			if retries == 0 {
+				retry = nil
+				continue
+			}
+			retries--
+			if err := c.conn.Send(msg); err != nil {
+				return Message{}, errors.Wrapf(err, "send %s", msg.Address)
+			}
+		}
+	}
+}
+
+// Close closes the connection.
+// Outstanding calls return ErrClientClosed.
+// It is safe to call Close more than once.
+func (c *Client) Close() error {
+	c.closeOnce.Do(func() {
+		c.stop(ErrClientClosed)
+		c.closeErr = c.conn.Close()
+	})
+	return c.closeErr
+}
+
+// remove removes a call from the outstanding calls.
+func (c *Client) remove(cl *call) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i, other := range c.calls {
+		if other == cl {
+			c.calls = append(c.calls[:i], c.calls[i+1:]...)
+			return
+		}
+	}
+}
+
+// stop makes outstanding and future calls fail with err.
+func (c *Client) stop(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.err != nil {
+		return
+	}
+	c.err = err
+	close(c.done)
+}
+
+// readLoop reads packets until the connection is closed.
+// Packets that can't be parsed are dropped.
+func (c *Client) readLoop() {
+	buf := make([]byte, bufSize)
+	for {
+		n, err := c.conn.Read(buf)
+		if err != nil {
+			// Connected UDP sockets report when a request could not be delivered,
+			// which is not a reason to stop reading.
+			if errors.Is(err, syscall.ECONNREFUSED) {
+				continue
+			}
+			if strings.Contains(err.Error(), "use of closed network connection") {
+				err = ErrClientClosed
+			}
+			c.stop(errors.Wrap(err, "read reply"))
+			return
+		}
+		p, err := ParsePacket(buf[:n], c.conn.RemoteAddr())
+		if err != nil {
+			continue
+		}
+		c.deliver(p)
+	}
+}
+
+// deliver passes the messages of p to the calls they answer,
+// or to the dispatcher if they don't answer any call.
+func (c *Client) deliver(p Packet) {
+	switch x := p.(type) {
+	case Message:
+		c.mu.Lock()
+		for i, cl := range c.calls {
+			if cl.match(x) {
+				c.calls = append(c.calls[:i], c.calls[i+1:]...)
+				c.mu.Unlock()
+				cl.reply <- x
+				return
+			}
+		}
+		dispatcher := c.dispatcher
+		c.mu.Unlock()
+
+		if dispatcher != nil {
+			_ = dispatcher.Invoke(x, false)
+		}
+	case Bundle:
+		for _, p := range x.Packets {
+			c.deliver(p)
+		}
+	}
+}
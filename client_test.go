@@ -0,0 +1,215 @@
+package osc
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// testReplyServer answers /sync ID with /synced ID from a UDP socket.
+// drop is called with every request and the request is ignored if it returns true.
+// Before every reply the server sends a /notify message.
+// The server stops when the caller closes the socket.
+func testReplyServer(t *testing.T, drop func(Message) bool) net.PacketConn {
+	pc := testPacketConn(t)
+
+	go func() {
+		buf := make([]byte, bufSize)
+		for {
+			n, addr, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			msg, err := ParseMessage(buf[:n], addr)
+			if err != nil || msg.Address != "/sync" || drop(msg) {
+				continue
+			}
+			_, _ = pc.WriteTo(Message{Address: "/notify"}.Bytes(), addr)
+			_, _ = pc.WriteTo(Message{Address: "/synced", Arguments: msg.Arguments}.Bytes(), addr)
+		}
+	}()
+	return pc
+}
+
+// testClient returns a client that is connected to the server.
+// The caller closes it.
+func testClient(t *testing.T, server net.PacketConn) *Client {
+	conn, err := DialUDP("udp", nil, server.LocalAddr().(*net.UDPAddr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewClient(conn)
+}
+
+func TestMatchReply(t *testing.T) {
+	match := MatchReply("/done", String("/b_alloc"), Int(3))
+
+	for i, testcase := range []struct {
+		Msg      Message
+		Expected bool
+	}{
+		{Msg: Message{Address: "/done", Arguments: Arguments{String("/b_alloc"), Int(3)}}, Expected: true},
+		{Msg: Message{Address: "/done", Arguments: Arguments{String("/b_alloc"), Int(3), Int(0)}}, Expected: true},
+		{Msg: Message{Address: "/done", Arguments: Arguments{String("/b_alloc"), Int(4)}}, Expected: false},
+		{Msg: Message{Address: "/done", Arguments: Arguments{String("/b_alloc")}}, Expected: false},
+		{Msg: Message{Address: "/fail", Arguments: Arguments{String("/b_alloc"), Int(3)}}, Expected: false},
+	} {
+		if expected, got := testcase.Expected, match(testcase.Msg); expected != got {
+			t.Fatalf("(testcase %d) expected %t, got %t", i, expected, got)
+		}
+	}
+}
+
+func TestClientConcurrentCalls(t *testing.T) {
+	var (
+		server = testReplyServer(t, func(Message) bool { return false })
+		c      = testClient(t, server)
+		wg     sync.WaitGroup
+		errs   = make(chan error, 20)
+
+		mu       sync.Mutex
+		notified int
+	)
+	defer func() { _ = server.Close() }()
+	defer func() { _ = c.Close() }()
+
+	c.SetDispatcher(PatternMatching{
+		"/notify": Method(func(Message) error {
+			mu.Lock()
+			notified++
+			mu.Unlock()
+			return nil
+		}),
+	})
+	for i := 0; i < cap(errs); i++ {
+		wg.Add(1)
+		go func(id Int) {
+			defer wg.Done()
+
+			reply, err := c.Call(context.Background(), Message{Address: "/sync", Arguments: Arguments{id}}, MatchReply("/synced", id))
+			if err != nil {
+				errs <- err
+				return
+			}
+			if expected := (Message{Address: "/synced", Arguments: Arguments{id}}); !expected.Equal(reply) {
+				errs <- errors.Errorf("expected %s, got %s", expected, reply)
+			}
+		}(Int(i))
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if expected, got := cap(errs), notified; expected != got {
+		t.Fatalf("expected %d notifications, got %d", expected, got)
+	}
+}
+
+func TestClientRetry(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		dropped = map[int32]bool{}
+		server  = testReplyServer(t, func(msg Message) bool {
+			mu.Lock()
+			defer mu.Unlock()
+
+			// Drop the first request with every ID.
+			id, _ := msg.Arguments[0].ReadInt32()
+			if dropped[id] {
+				return false
+			}
+			dropped[id] = true
+			return true
+		})
+		c = testClient(t, server)
+	)
+	defer func() { _ = server.Close() }()
+	defer func() { _ = c.Close() }()
+
+	c.SetTimeout(100 * time.Millisecond)
+
+	if _, err := c.Call(context.Background(), Message{Address: "/sync", Arguments: Arguments{Int(1)}}, MatchReply("/synced", Int(1))); errors.Cause(err) != context.DeadlineExceeded {
+		t.Fatalf("expected %s, got %+v", context.DeadlineExceeded, err)
+	}
+	c.SetTimeout(time.Second)
+	c.SetRetry(3, 20*time.Millisecond)
+
+	if _, err := c.Call(context.Background(), Message{Address: "/sync", Arguments: Arguments{Int(2)}}, MatchReply("/synced", Int(2))); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClientMalformedReply(t *testing.T) {
+	// The server answers with malformed packets before the reply.
+	server := testPacketConn(t)
+	defer func() { _ = server.Close() }()
+
+	go func() {
+		buf := make([]byte, bufSize)
+		n, addr, err := server.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		msg, err := ParseMessage(buf[:n], addr)
+		if err != nil {
+			return
+		}
+		_, _ = server.WriteTo([]byte("/synced\x00,b\x00\x00\x8a000"), addr)
+		_, _ = server.WriteTo([]byte("/synced\x00,s\x00\x00abc"), addr)
+		_, _ = server.WriteTo(Message{Address: "/synced", Arguments: msg.Arguments}.Bytes(), addr)
+	}()
+	c := testClient(t, server)
+	defer func() { _ = c.Close() }()
+
+	reply, err := c.Call(context.Background(), Message{Address: "/sync", Arguments: Arguments{Int(1)}}, MatchReply("/synced", Int(1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := "/synced ,i 1", Format(reply); expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	var (
+		server = testReplyServer(t, func(Message) bool { return true })
+		c      = testClient(t, server)
+		msg    = Message{Address: "/sync", Arguments: Arguments{Int(1)}}
+	)
+	defer func() { _ = server.Close() }()
+	defer func() { _ = c.Close() }()
+
+	if _, err := c.Call(context.Background(), msg, nil); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := c.Call(ctx, msg, MatchReply("/synced")); errors.Cause(err) != context.Canceled {
+		t.Fatalf("expected %s, got %+v", context.Canceled, err)
+	}
+	errs := make(chan error)
+	go func() {
+		_, err := c.Call(context.Background(), msg, MatchReply("/synced"))
+		errs <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-errs; err != ErrClientClosed {
+		t.Fatalf("expected %s, got %+v", ErrClientClosed, err)
+	}
+	if _, err := c.Call(context.Background(), msg, MatchReply("/synced")); err != ErrClientClosed {
+		t.Fatalf("expected %s, got %+v", ErrClientClosed, err)
+	}
+}
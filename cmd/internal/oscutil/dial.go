@@ -0,0 +1,121 @@
+// Package oscutil has helpers that are shared by the commands.
+package oscutil
+
+import (
+	"net"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/scgolang/osc"
+)
+
+// Client sends packets and receives replies.
+type Client interface {
+	Send(osc.Packet) error
+	Receive(timeout time.Duration) (osc.Packet, error)
+	Close() error
+}
+
+// Dial connects to addr on the network, which is udp, tcp or unix.
+// framing is the name of the framing method for tcp, see osc.ParseFraming.
+// Unix sockets are datagram sockets, which are only bound to a local path
+// if replies are expected.
+func Dial(network, addr, framing string, replies bool) (Client, error) {
+	switch network {
+	case "udp", "udp4", "udp6":
+		raddr, err := net.ResolveUDPAddr(network, addr)
+		if err != nil {
+			return nil, err
+		}
+		conn, err := osc.DialUDP(network, nil, raddr)
+		if err != nil {
+			return nil, err
+		}
+		return datagramClient{Conn: conn}, nil
+	case "unix", "unixgram":
+		raddr := &net.UnixAddr{Name: addr, Net: "unixgram"}
+
+		// Replies can only be received on a socket that is bound to a path.
+		var laddr *net.UnixAddr
+		if replies {
+			laddr = &net.UnixAddr{Name: osc.TempSocket(), Net: "unixgram"}
+		}
+		conn, err := osc.DialUnix("unixgram", laddr, raddr)
+		if err != nil {
+			return nil, err
+		}
+		c := datagramClient{Conn: conn}
+		if laddr != nil {
+			c.socket = laddr.Name
+		}
+		return c, nil
+	case "tcp", "tcp4", "tcp6":
+		f, err := osc.ParseFraming(framing)
+		if err != nil {
+			return nil, err
+		}
+		conn, err := net.Dial(network, addr)
+		if err != nil {
+			return nil, err
+		}
+		return streamClient{
+			Conn: conn,
+			enc:  osc.NewEncoder(conn, f),
+			dec:  osc.NewDecoder(conn, f),
+		}, nil
+	default:
+		return nil, errors.Errorf("unsupported network %q", network)
+	}
+}
+
+// datagramClient is a client for UDP and unix datagram sockets.
+type datagramClient struct {
+	osc.Conn
+
+	socket string // Path of the local socket, if any.
+}
+
+// Receive reads a reply.
+func (c datagramClient) Receive(timeout time.Duration) (osc.Packet, error) {
+	if err := c.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 65536)
+
+	n, err := c.Read(buf)
+	if err != nil {
+		return nil, err
+	}
+	return osc.ParsePacket(buf[:n], c.RemoteAddr())
+}
+
+// Close closes the connection and removes the local socket.
+func (c datagramClient) Close() error {
+	err := c.Conn.Close()
+	if c.socket != "" {
+		_ = os.Remove(c.socket)
+	}
+	return err
+}
+
+// streamClient is a client for TCP connections.
+type streamClient struct {
+	net.Conn
+
+	enc *osc.Encoder
+	dec *osc.Decoder
+}
+
+// Send sends a framed packet.
+func (c streamClient) Send(p osc.Packet) error {
+	return c.enc.Encode(p)
+}
+
+// Receive reads a framed reply.
+func (c streamClient) Receive(timeout time.Duration) (osc.Packet, error) {
+	if err := c.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+		return nil, err
+	}
+	return c.dec.Decode()
+}
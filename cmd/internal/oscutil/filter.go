@@ -0,0 +1,56 @@
+package oscutil
+
+import (
+	"regexp"
+
+	"github.com/pkg/errors"
+	"github.com/scgolang/osc"
+)
+
+// Filter keeps the messages whose addresses match any of a list of
+// OSC address patterns.
+type Filter []*regexp.Regexp
+
+// NewFilter returns a filter for the address patterns.
+// A filter without patterns keeps every message.
+func NewFilter(patterns []string) (Filter, error) {
+	var f Filter
+
+	for _, pattern := range patterns {
+		re, err := osc.GetRegex(pattern)
+		if err != nil {
+			return nil, errors.Wrapf(err, "pattern %s", pattern)
+		}
+		f = append(f, re)
+	}
+	return f, nil
+}
+
+// Apply returns the part of the packet that the filter keeps,
+// and false if it keeps nothing.
+// Bundles keep only their matching messages, and are left out
+// entirely if none of their messages match.
+func (f Filter) Apply(p osc.Packet) (osc.Packet, bool) {
+	if len(f) == 0 {
+		return p, true
+	}
+	switch x := p.(type) {
+	case osc.Message:
+		for _, re := range f {
+			if re.MatchString(x.Address) {
+				return x, true
+			}
+		}
+		return nil, false
+	case osc.Bundle:
+		filtered := osc.Bundle{Timetag: x.Timetag, Sender: x.Sender}
+		for _, p := range x.Packets {
+			if fp, ok := f.Apply(p); ok {
+				filtered.Packets = append(filtered.Packets, fp)
+			}
+		}
+		return filtered, len(filtered.Packets) > 0
+	default:
+		return nil, false
+	}
+}
@@ -0,0 +1,372 @@
+// Command oscdump prints the OSC packets it receives.
+//
+// Usage:
+//
+//	oscdump [flags]
+//
+// Every packet is printed on a line that starts with the time it was received
+// and its sender, followed by the packet in the text syntax of osc.Format.
+// The packets in a bundle are printed on separate, indented lines:
+//
+//	2024-01-01T12:00:00.000000Z 127.0.0.1:52311 /synth/freq ,fs 440.0 "sine"
+//	2024-01-01T12:00:01.000000Z 127.0.0.1:52311 #bundle immediately [
+//	    /n_set ,isf 1000 "freq" 220.0
+//	]
+//
+// Malformed packets are printed as a hex dump along with the reason
+// they could not be parsed.
+//
+// -match only prints messages whose addresses match an OSC address pattern,
+// it can be given more than once. Bundles are printed with only their
+// matching messages, and not at all if none of their messages match.
+//
+// -record also writes every packet that is parsed, including the ones
+// that -match leaves out, to a recording that can be replayed with oscreplay.
+// Malformed packets are printed but not recorded, since a recording holds
+// parsed packets. See osc.RecordingMagic for the file format.
+//
+// -json prints one JSON object per packet instead, see osc.ParsePacketJSON:
+//
+//	{"time":"2024-01-01T12:00:00Z","sender":"127.0.0.1:52311","packet":{"address":"/foo","arguments":[]}}
+//	{"time":"2024-01-01T12:00:01Z","sender":"127.0.0.1:52311","error":"...","data":"AQID"}
+//
+// The transport is set with -net: udp, tcp or unix.
+// TCP streams are framed according to -framing, which is length-prefixed or slip.
+// Unix sockets are datagram sockets.
+package main
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/scgolang/osc"
+	"github.com/scgolang/osc/cmd/internal/oscutil"
+)
+
+// timeFormat is the format of the time a packet was received.
+const timeFormat = "2006-01-02T15:04:05.000000Z07:00"
+
+// patterns is a flag that can be given more than once.
+type patterns []string
+
+func (p *patterns) String() string     { return strings.Join(*p, " ") }
+func (p *patterns) Set(s string) error { *p = append(*p, s); return nil }
+
+func main() {
+	var (
+		network = flag.String("net", "udp", "network: udp, tcp or unix")
+		addr    = flag.String("addr", "127.0.0.1:57120", "address to listen on, a socket path for unix")
+		framing = flag.String("framing", "length-prefixed", "framing for tcp: length-prefixed or slip")
+		asJSON  = flag.Bool("json", false, "print packets as JSON")
+		record  = flag.String("record", "", "write every well-formed packet to a recording file")
+		match   patterns
+	)
+	flag.Var(&match, "match", "only print messages that match an address pattern, can be repeated")
+	flag.Parse()
+
+	d, err := newDumper(os.Stdout, match, *asJSON)
+	if err != nil {
+		fatal(err)
+	}
+	if *record != "" {
+		f, err := os.Create(*record)
+		if err != nil {
+			fatal(err)
+		}
+		defer func() { _ = f.Close() }()
+
+		rec, err := osc.NewRecorder(d, f)
+		if err != nil {
+			fatal(err)
+		}
+		d.dispatcher = rec
+	}
+	srv, err := listen(*network, *addr, *framing)
+	if err != nil {
+		fatal(err)
+	}
+	fmt.Fprintf(os.Stderr, "oscdump: listening on %s %s\n", *network, srv.Addr())
+
+	// Close the server on interrupt so that unix sockets get removed.
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt)
+	go func() {
+		<-sig
+		_ = srv.Close()
+	}()
+	if err := srv.Serve(d); err != nil {
+		fatal(err)
+	}
+}
+
+func fatal(err error) {
+	fmt.Fprintf(os.Stderr, "oscdump: %s\n", err)
+	os.Exit(1)
+}
+
+// dumper is a catch-all dispatcher that prints every packet.
+type dumper struct {
+	mu     sync.Mutex
+	out    io.Writer
+	filter oscutil.Filter
+	json   bool
+	now    func() time.Time
+
+	// dispatcher receives the packets that are handled,
+	// it is either the dumper itself or a recorder that wraps it.
+	dispatcher osc.Dispatcher
+}
+
+// newDumper returns a dumper that prints the packets that match any of
+// the address patterns, or all packets if there are no patterns.
+func newDumper(out io.Writer, patterns []string, asJSON bool) (*dumper, error) {
+	filter, err := oscutil.NewFilter(patterns)
+	if err != nil {
+		return nil, err
+	}
+	d := &dumper{out: out, filter: filter, json: asJSON, now: time.Now}
+	d.dispatcher = d
+	return d, nil
+}
+
+// Dispatch prints a bundle.
+func (d *dumper) Dispatch(b osc.Bundle, exactMatch bool) error {
+	p, ok := d.filter.Apply(b)
+	if !ok {
+		return nil
+	}
+	return d.print(b.Sender, p)
+}
+
+// Invoke prints a message.
+func (d *dumper) Invoke(msg osc.Message, exactMatch bool) error {
+	if _, ok := d.filter.Apply(msg); !ok {
+		return nil
+	}
+	return d.print(msg.Sender, msg)
+}
+
+// handle parses the data of a packet and prints it.
+func (d *dumper) handle(data []byte, sender net.Addr) error {
+	p, err := osc.ParsePacket(data, sender)
+	if err != nil {
+		return d.malformed(sender, data, err)
+	}
+	switch x := p.(type) {
+	case osc.Bundle:
+		return d.dispatcher.Dispatch(x, false)
+	case osc.Message:
+		return d.dispatcher.Invoke(x, false)
+	}
+	return nil
+}
+
+// jsonRecord is a line of JSON output.
+type jsonRecord struct {
+	Time   time.Time  `json:"time"`
+	Sender string     `json:"sender"`
+	Packet osc.Packet `json:"packet,omitempty"`
+	Error  string     `json:"error,omitempty"`
+	Data   string     `json:"data,omitempty"`
+}
+
+// print prints a packet.
+func (d *dumper) print(sender net.Addr, p osc.Packet) error {
+	if d.json {
+		return d.writeJSON(jsonRecord{Time: d.now().UTC(), Sender: addrString(sender), Packet: p})
+	}
+	return d.write(fmt.Sprintf("%s %s %s\n", d.now().UTC().Format(timeFormat), addrString(sender), osc.FormatIndent(p, "", "    ")))
+}
+
+// malformed prints a packet that could not be parsed.
+func (d *dumper) malformed(sender net.Addr, data []byte, err error) error {
+	if d.json {
+		return d.writeJSON(jsonRecord{
+			Time:   d.now().UTC(),
+			Sender: addrString(sender),
+			Error:  err.Error(),
+			Data:   base64.StdEncoding.EncodeToString(data),
+		})
+	}
+	return d.write(fmt.Sprintf("%s %s malformed packet: %s\n%s", d.now().UTC().Format(timeFormat), addrString(sender), err, hex.Dump(data)))
+}
+
+func (d *dumper) writeJSON(record jsonRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return d.write(string(line) + "\n")
+}
+
+// write writes s to the output in one piece, packets from concurrent
+// TCP connections are never interleaved.
+func (d *dumper) write(s string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	_, err := io.WriteString(d.out, s)
+	return err
+}
+
+// addrString returns the string form of addr, or - if there is no address.
+func addrString(addr net.Addr) string {
+	if addr == nil || addr.String() == "" {
+		return "-"
+	}
+	return addr.String()
+}
+
+// server receives packets and hands them to a dumper.
+type server interface {
+	Addr() net.Addr
+	Serve(d *dumper) error
+	Close() error
+}
+
+// listen returns a server for the network.
+func listen(network, addr, framing string) (server, error) {
+	switch network {
+	case "udp", "udp4", "udp6":
+		laddr, err := net.ResolveUDPAddr(network, addr)
+		if err != nil {
+			return nil, err
+		}
+		conn, err := osc.ListenUDP(network, laddr)
+		if err != nil {
+			return nil, err
+		}
+		return &datagramServer{
+			Conn: conn,
+			read: func(b []byte) (int, net.Addr, error) {
+				n, sender, err := conn.ReadFromUDP(b)
+				if sender == nil {
+					return n, nil, err
+				}
+				return n, sender, err
+			},
+		}, nil
+	case "unix", "unixgram":
+		conn, err := osc.ListenUnix("unixgram", &net.UnixAddr{Name: addr, Net: "unixgram"})
+		if err != nil {
+			return nil, err
+		}
+		return &datagramServer{
+			Conn: conn,
+			read: func(b []byte) (int, net.Addr, error) {
+				n, sender, err := conn.ReadFromUnix(b)
+				if sender == nil {
+					return n, nil, err
+				}
+				return n, sender, err
+			},
+			socket: addr,
+		}, nil
+	case "tcp", "tcp4", "tcp6":
+		f, err := osc.ParseFraming(framing)
+		if err != nil {
+			return nil, err
+		}
+		ln, err := net.Listen(network, addr)
+		if err != nil {
+			return nil, err
+		}
+		return &streamServer{Listener: ln, framing: f}, nil
+	default:
+		return nil, errors.Errorf("unsupported network %q", network)
+	}
+}
+
+// datagramServer receives packets from UDP and unix datagram sockets.
+type datagramServer struct {
+	osc.Conn
+
+	read   func([]byte) (int, net.Addr, error)
+	socket string // Path of the unix socket, if any.
+}
+
+// Addr returns the local address.
+func (s *datagramServer) Addr() net.Addr {
+	return s.LocalAddr()
+}
+
+// Serve prints packets until the server is closed.
+func (s *datagramServer) Serve(d *dumper) error {
+	buf := make([]byte, 65536)
+
+	for {
+		n, sender, err := s.read(buf)
+		if errors.Is(err, net.ErrClosed) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := d.handle(buf[:n], sender); err != nil {
+			return err
+		}
+	}
+}
+
+// Close closes the socket and removes unix sockets.
+func (s *datagramServer) Close() error {
+	err := s.Conn.Close()
+	if s.socket != "" {
+		_ = os.Remove(s.socket)
+	}
+	return err
+}
+
+// streamServer receives packets from TCP connections.
+type streamServer struct {
+	net.Listener
+
+	framing osc.Framing
+}
+
+// Serve prints packets from every connection until the server is closed.
+func (s *streamServer) Serve(d *dumper) error {
+	for {
+		conn, err := s.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		go s.serveConn(conn, d)
+	}
+}
+
+// serveConn prints packets from a connection until it is closed.
+// Malformed packets are printed, other errors end the connection.
+func (s *streamServer) serveConn(conn net.Conn, d *dumper) {
+	defer func() { _ = conn.Close() }()
+
+	dec := osc.NewDecoder(conn, s.framing)
+	for {
+		data, err := dec.ReadFrame()
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "oscdump: %s: %s\n", conn.RemoteAddr(), err)
+			return
+		}
+		if err := d.handle(data, conn.RemoteAddr()); err != nil {
+			fmt.Fprintf(os.Stderr, "oscdump: %s\n", err)
+			return
+		}
+	}
+}
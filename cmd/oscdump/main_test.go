@@ -0,0 +1,248 @@
+package main
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/scgolang/osc"
+)
+
+// testDumper returns a dumper with a fixed clock.
+func testDumper(t *testing.T, out *bytes.Buffer, patterns []string, asJSON bool) *dumper {
+	d, err := newDumper(out, patterns, asJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.now = func() time.Time { return time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC) }
+	return d
+}
+
+func TestDumperText(t *testing.T) {
+	var (
+		out    = &bytes.Buffer{}
+		d      = testDumper(t, out, nil, false)
+		sender = &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 5000}
+	)
+	packets := []osc.Packet{
+		osc.Message{Address: "/synth/freq", Arguments: osc.Arguments{osc.Float(440), osc.String("sine")}},
+		osc.Bundle{Timetag: osc.Immediately, Packets: []osc.Packet{osc.Message{Address: "/foo", Arguments: osc.Arguments{osc.Int(1)}}}},
+	}
+	for _, p := range packets {
+		if err := d.handle(p.Bytes(), sender); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := d.handle([]byte("oops"), sender); err != nil {
+		t.Fatal(err)
+	}
+	expected := `2024-01-01T12:00:00.000000Z 127.0.0.1:5000 /synth/freq ,fs 440.0 "sine"
+2024-01-01T12:00:00.000000Z 127.0.0.1:5000 #bundle immediately [
+    /foo ,i 1
+]
+2024-01-01T12:00:00.000000Z 127.0.0.1:5000 malformed packet: packet should never start with o: error parsing message
+00000000  6f 6f 70 73                                       |oops|
+`
+	if got := out.String(); expected != got {
+		t.Fatalf("expected %q, got %q", expected, got)
+	}
+}
+
+func TestDumperMalformed(t *testing.T) {
+	for i, testcase := range []struct {
+		Data     string
+		Expected string
+	}{
+		{
+			Data:     "/a\x00\x00,b\x00\x00\x8a000",
+			Expected: "2024-01-01T12:00:00.000000Z - malformed packet: parse message: read argument 0: blob length -1976553424 is negative: error parsing message\n",
+		},
+		{
+			Data:     "/a\x00\x00,s\x00\x00abc",
+			Expected: "2024-01-01T12:00:00.000000Z - malformed packet: parse message: read argument 0: argument needs 4 bytes, only 3 left: error parsing message\n",
+		},
+	} {
+		var (
+			out = &bytes.Buffer{}
+			d   = testDumper(t, out, nil, false)
+		)
+		if err := d.handle([]byte(testcase.Data), nil); err != nil {
+			t.Fatalf("(testcase %d) %s", i, err)
+		}
+		if got := out.String(); !strings.HasPrefix(got, testcase.Expected) {
+			t.Fatalf("(testcase %d) expected %q, got %q", i, testcase.Expected, got)
+		}
+	}
+}
+
+func TestDumperJSON(t *testing.T) {
+	var (
+		out = &bytes.Buffer{}
+		d   = testDumper(t, out, nil, true)
+	)
+	if err := d.handle(osc.Message{Address: "/foo"}.Bytes(), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.handle([]byte{1, 2, 3}, nil); err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"time":"2024-01-01T12:00:00Z","sender":"-","packet":{"address":"/foo","arguments":[]}}
+{"time":"2024-01-01T12:00:00Z","sender":"-","error":"packet should never start with \u0001: error parsing message","data":"AQID"}
+`
+	if got := out.String(); expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+}
+
+func TestDumperFilter(t *testing.T) {
+	var (
+		out = &bytes.Buffer{}
+		d   = testDumper(t, out, []string{"/synth/*", "/n_{set,free}"}, false)
+	)
+	packets := []osc.Packet{
+		osc.Message{Address: "/synth/freq"},
+		osc.Message{Address: "/status"},
+		osc.Bundle{Timetag: osc.Immediately, Packets: []osc.Packet{
+			osc.Message{Address: "/n_set"},
+			osc.Message{Address: "/n_go"},
+		}},
+		osc.Bundle{Timetag: osc.Immediately, Packets: []osc.Packet{
+			osc.Message{Address: "/g_new"},
+		}},
+	}
+	for _, p := range packets {
+		if err := d.handle(p.Bytes(), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expected := `2024-01-01T12:00:00.000000Z - /synth/freq
+2024-01-01T12:00:00.000000Z - #bundle immediately [
+    /n_set
+]
+`
+	if got := out.String(); expected != got {
+		t.Fatalf("expected %q, got %q", expected, got)
+	}
+}
+
+func TestDumperRecord(t *testing.T) {
+	var (
+		out       = &bytes.Buffer{}
+		recording = &bytes.Buffer{}
+		d         = testDumper(t, out, []string{"/bar"}, false)
+	)
+	rec, err := osc.NewRecorder(d, recording)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.dispatcher = rec
+
+	for _, p := range []osc.Packet{osc.Message{Address: "/foo"}, osc.Message{Address: "/bar"}} {
+		if err := d.handle(p.Bytes(), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if expected, got := "2024-01-01T12:00:00.000000Z - /bar\n", out.String(); expected != got {
+		t.Fatalf("expected %q, got %q", expected, got)
+	}
+	rr := osc.NewRecordReader(recording)
+
+	for _, expected := range []string{"/foo", "/bar"} {
+		r, err := rr.Read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := r.Packet.(osc.Message).Address; expected != got {
+			t.Fatalf("expected %s, got %s", expected, got)
+		}
+	}
+}
+
+func TestServeUDP(t *testing.T) {
+	srv, err := listen("udp", "127.0.0.1:0", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		out  = &syncBuffer{}
+		d, _ = newDumper(out, nil, false)
+		done = make(chan error)
+	)
+	go func() { done <- srv.Serve(d) }()
+
+	conn, err := net.Dial("udp", srv.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	if _, err := conn.Write(osc.Message{Address: "/foo"}.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for !strings.Contains(out.String(), conn.LocalAddr().String()+" /foo\n") {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout, output is %q", out.String())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := srv.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestServeTCP(t *testing.T) {
+	srv, err := listen("tcp", "127.0.0.1:0", "slip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = srv.Close() }()
+
+	var (
+		out  = &syncBuffer{}
+		d, _ = newDumper(out, nil, false)
+	)
+	go func() { _ = srv.Serve(d) }()
+
+	conn, err := net.Dial("tcp", srv.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	enc := osc.NewEncoder(conn, osc.SLIP)
+	if err := enc.Encode(osc.Message{Address: "/foo", Arguments: osc.Arguments{osc.Int(1)}}); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for !strings.Contains(out.String(), " /foo ,i 1\n") {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout, output is %q", out.String())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// syncBuffer is a bytes.Buffer that is safe for concurrent use.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
@@ -0,0 +1,252 @@
+// Command oscrelay forwards OSC packets from one or more sockets to a list of destinations.
+//
+// Usage:
+//
+//	oscrelay [flags] -listen ADDR -to ADDR...
+//
+// -listen and -to can both be given more than once. Every packet that is
+// received on a -listen address is sent to every -to address, except the
+// one it came from:
+//
+//	oscrelay -listen 127.0.0.1:9000 -to 127.0.0.1:57110 -to 192.168.1.20:9000
+//
+// -match only forwards messages whose addresses match an OSC address pattern,
+// it can be given more than once. Bundles are forwarded with their timetag and
+// only their matching messages, and not at all if none of their messages match.
+//
+// The addresses of forwarded messages can be rewritten with -strip, which removes
+// a prefix, -prefix, which adds one, and -rewrite, which replaces the matches of
+// a regular expression. They can be given more than once and are applied in the
+// order they are given:
+//
+//	oscrelay -listen :9000 -to :57110 -strip /studio -rewrite '^/track/(\d+)/=/mixer/ch$1/'
+//
+// -convert converts arguments to another type for receivers that only accept
+// some types, e.g. -convert i=f sends ints as floats. Ints, floats, doubles and
+// bools (T and F) can be converted to ints, floats, doubles and strings.
+//
+// -loop-window drops packets that are identical to a packet that was forwarded
+// within the window, to break loops between relays.
+//
+// The transport is set with -net: udp or unix. Unix sockets are datagram sockets.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/scgolang/osc"
+)
+
+// config holds the command-line flags.
+type config struct {
+	network     string
+	listen      []string
+	to          []string
+	match       []string
+	rewrites    []osc.Rewrite
+	conversions [][2]byte
+	loopWindow  time.Duration
+	workers     int
+}
+
+func main() {
+	cfg := config{}
+
+	fs := flag.NewFlagSet("oscrelay", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: oscrelay [flags] -listen ADDR -to ADDR...")
+		fs.PrintDefaults()
+	}
+	fs.StringVar(&cfg.network, "net", "udp", "network: udp or unix")
+	fs.Func("listen", "address to listen on, a socket path for unix, can be repeated", func(s string) error {
+		cfg.listen = append(cfg.listen, s)
+		return nil
+	})
+	fs.Func("to", "address to forward to, a socket path for unix, can be repeated", func(s string) error {
+		cfg.to = append(cfg.to, s)
+		return nil
+	})
+	fs.Func("match", "only forward messages that match an address pattern, can be repeated", func(s string) error {
+		cfg.match = append(cfg.match, s)
+		return nil
+	})
+	fs.Func("strip", "remove an address prefix, can be repeated", func(s string) error {
+		return cfg.addRewrite(parsePrefix(s, osc.StripPrefix))
+	})
+	fs.Func("prefix", "add an address prefix, can be repeated", func(s string) error {
+		return cfg.addRewrite(parsePrefix(s, osc.AddPrefix))
+	})
+	fs.Func("rewrite", "replace a regular expression in addresses, as REGEXP=REPLACEMENT, can be repeated", func(s string) error {
+		return cfg.addRewrite(parseRewrite(s))
+	})
+	fs.Func("convert", "convert arguments of a type to another, as i=f, can be repeated", func(s string) error {
+		c, err := parseConversion(s)
+		if err != nil {
+			return err
+		}
+		cfg.conversions = append(cfg.conversions, c)
+		return nil
+	})
+	fs.DurationVar(&cfg.loopWindow, "loop-window", 0, "drop packets identical to one forwarded within this window")
+	fs.IntVar(&cfg.workers, "workers", 1, "number of workers per listening socket")
+	_ = fs.Parse(os.Args[1:])
+
+	if len(cfg.listen) == 0 || len(cfg.to) == 0 || fs.NArg() > 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if err := run(ctx, cfg); err != nil && err != context.Canceled {
+		fmt.Fprintf(os.Stderr, "oscrelay: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+// run forwards packets until ctx is done or a listening socket fails.
+func run(ctx context.Context, cfg config) error {
+	var destinations []net.Conn
+	defer func() {
+		for _, d := range destinations {
+			_ = d.Close()
+		}
+	}()
+	for _, addr := range cfg.to {
+		d, err := dial(cfg.network, addr)
+		if err != nil {
+			return errors.Wrapf(err, "dial %s", addr)
+		}
+		destinations = append(destinations, d)
+	}
+	relay, err := newRelay(cfg, destinations)
+	if err != nil {
+		return err
+	}
+	relay.SetErrorHandler(func(err error) {
+		fmt.Fprintf(os.Stderr, "oscrelay: %s\n", err)
+	})
+	var conns []osc.Conn
+	defer func() {
+		for _, c := range conns {
+			_ = c.Close()
+		}
+	}()
+	for _, addr := range cfg.listen {
+		c, err := listen(ctx, cfg.network, addr)
+		if err != nil {
+			return errors.Wrapf(err, "listen on %s", addr)
+		}
+		conns = append(conns, c)
+	}
+	return relay.Serve(cfg.workers, conns...)
+}
+
+// newRelay returns a relay to destinations that is configured with the flags.
+func newRelay(cfg config, destinations []net.Conn) (*osc.Relay, error) {
+	relay := osc.NewRelay(destinations...)
+
+	for _, pattern := range cfg.match {
+		if err := relay.AddFilter(pattern); err != nil {
+			return nil, err
+		}
+	}
+	for _, rw := range cfg.rewrites {
+		relay.AddRewrite(rw)
+	}
+	for _, c := range cfg.conversions {
+		if err := relay.Convert(c[0], c[1]); err != nil {
+			return nil, err
+		}
+	}
+	relay.SetLoopWindow(cfg.loopWindow)
+
+	return relay, nil
+}
+
+// addRewrite adds rw to the rewrites if err is nil.
+func (cfg *config) addRewrite(rw osc.Rewrite, err error) error {
+	if err != nil {
+		return err
+	}
+	cfg.rewrites = append(cfg.rewrites, rw)
+	return nil
+}
+
+// parsePrefix returns the rewrite for the prefix of a -strip or -prefix flag.
+func parsePrefix(prefix string, rewrite func(string) osc.Rewrite) (osc.Rewrite, error) {
+	if !strings.HasPrefix(prefix, "/") {
+		return nil, errors.Errorf("prefix %q should start with /", prefix)
+	}
+	if err := osc.ValidateAddress(prefix); err != nil {
+		return nil, errors.Wrapf(err, "prefix %q", prefix)
+	}
+	return rewrite(prefix), nil
+}
+
+// parseRewrite parses the REGEXP=REPLACEMENT of a -rewrite flag.
+func parseRewrite(s string) (osc.Rewrite, error) {
+	expr, replacement, ok := strings.Cut(s, "=")
+	if !ok {
+		return nil, errors.Errorf("rewrite %q should look like REGEXP=REPLACEMENT", s)
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	return osc.RegexpRewrite(re, replacement), nil
+}
+
+// parseConversion parses the two typetags of a -convert flag.
+func parseConversion(s string) ([2]byte, error) {
+	from, to, ok := strings.Cut(s, "=")
+	if !ok || len(from) != 1 || len(to) != 1 {
+		return [2]byte{}, errors.Errorf("conversion %q should look like i=f", s)
+	}
+	return [2]byte{from[0], to[0]}, nil
+}
+
+// dial connects to a destination.
+func dial(network, addr string) (net.Conn, error) {
+	switch network {
+	case "udp", "udp4", "udp6":
+		return net.Dial(network, addr)
+	case "unix", "unixgram":
+		return net.Dial("unixgram", addr)
+	default:
+		return nil, errors.Errorf("unsupported network %q", network)
+	}
+}
+
+// listen opens a socket to receive packets on.
+func listen(ctx context.Context, network, addr string) (osc.Conn, error) {
+	switch network {
+	case "udp", "udp4", "udp6":
+		laddr, err := net.ResolveUDPAddr(network, addr)
+		if err != nil {
+			return nil, err
+		}
+		c, err := osc.ListenUDPContext(ctx, network, laddr)
+		if err != nil {
+			return nil, err
+		}
+		return c, nil
+	case "unix", "unixgram":
+		c, err := osc.ListenUnixContext(ctx, "unixgram", &net.UnixAddr{Name: addr, Net: "unixgram"})
+		if err != nil {
+			return nil, err
+		}
+		return c, nil
+	default:
+		return nil, errors.Errorf("unsupported network %q", network)
+	}
+}
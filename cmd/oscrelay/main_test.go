@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/scgolang/osc"
+)
+
+func TestConfigRewrites(t *testing.T) {
+	cfg := config{}
+
+	for _, err := range []error{
+		cfg.addRewrite(parsePrefix("/studio", osc.StripPrefix)),
+		cfg.addRewrite(parseRewrite(`^/track/(\d+)/=/mixer/ch$1/`)),
+		cfg.addRewrite(parsePrefix("/sc", osc.AddPrefix)),
+	} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	address := "/studio/track/2/volume"
+	for _, rw := range cfg.rewrites {
+		address = rw(address)
+	}
+	if expected, got := "/sc/mixer/ch2/volume", address; expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for i, err := range []error{
+		func() error { _, err := parsePrefix("studio", osc.StripPrefix); return err }(),
+		func() error { _, err := parsePrefix("/a b", osc.AddPrefix); return err }(),
+		func() error { _, err := parseRewrite("/foo"); return err }(),
+		func() error { _, err := parseRewrite("(=/foo"); return err }(),
+		func() error { _, err := parseConversion("int=float"); return err }(),
+		func() error { _, err := newRelay(config{conversions: [][2]byte{{'s', 'i'}}}, nil); return err }(),
+		func() error { _, err := newRelay(config{match: []string{"/[a-"}}, nil); return err }(),
+		run(context.Background(), config{network: "tcp", to: []string{"127.0.0.1:1"}}),
+	} {
+		if err == nil {
+			t.Fatalf("(testcase %d) expected error, got nil", i)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	dst, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = dst.Close() }()
+
+	// Find a free port for the relay to listen on.
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	listenAddr := pc.LocalAddr().String()
+	_ = pc.Close()
+
+	cfg := config{
+		network:     "udp",
+		listen:      []string{listenAddr},
+		to:          []string{dst.LocalAddr().String()},
+		match:       []string{"/synth/*"},
+		conversions: [][2]byte{{'i', 'f'}},
+		workers:     1,
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- run(ctx, cfg)
+	}()
+	client, err := net.Dial("udp", listenAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = client.Close() }()
+
+	var (
+		msg      = osc.Message{Address: "/synth/freq", Arguments: osc.Arguments{osc.Int(440)}}
+		expected = osc.Message{Address: "/synth/freq", Arguments: osc.Arguments{osc.Float(440)}}
+		buf      = make([]byte, 1024)
+	)
+	// The relay might not be listening yet, so keep sending until a packet arrives.
+	for i := 0; ; i++ {
+		if i == 50 {
+			t.Fatal("no packet was forwarded")
+		}
+		// Writes fail with connection refused until the relay is listening.
+		_, _ = client.Write(msg.Bytes())
+
+		if err := dst.SetReadDeadline(time.Now().Add(100 * time.Millisecond)); err != nil {
+			t.Fatal(err)
+		}
+		n, _, err := dst.ReadFrom(buf)
+		if err != nil {
+			continue
+		}
+		got, err := osc.ParsePacket(buf[:n], nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !expected.Equal(got) {
+			t.Fatalf("expected %s, got %s", expected, got)
+		}
+		break
+	}
+	cancel()
+
+	if err := <-errs; err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %+v", err)
+	}
+}
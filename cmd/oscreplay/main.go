@@ -0,0 +1,197 @@
+// Command oscreplay sends the packets of a recording made with oscdump -record.
+//
+// Usage:
+//
+//	oscreplay [flags] FILE
+//
+// The packets are sent with the same timing as when they were recorded.
+// -speed scales the timing, -speed 2 replays twice as fast and -speed 0
+// sends the packets as fast as possible.
+//
+// -match only sends messages whose addresses match an OSC address pattern,
+// it can be given more than once. Bundles are sent with only their matching
+// messages, and not at all if none of their messages match.
+//
+// -remap replaces the beginning of addresses, it can be given more than once
+// and the first one that applies is used:
+//
+//	oscreplay -remap /synth=/test/synth -remap /fx=/test/fx show.oscrec
+//
+// -retime moves the timetags of bundles by the time that has passed since
+// they were recorded, so that they are scheduled as they were in the recording.
+// Otherwise bundles that were scheduled in the future are likely to be late.
+//
+// The transport is set with -net: udp, tcp or unix.
+// TCP streams are framed according to -framing, which is length-prefixed or slip.
+// Unix sockets are datagram sockets.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/scgolang/osc"
+	"github.com/scgolang/osc/cmd/internal/oscutil"
+)
+
+// stringList is a flag that can be given more than once.
+type stringList []string
+
+func (l *stringList) String() string     { return strings.Join(*l, " ") }
+func (l *stringList) Set(s string) error { *l = append(*l, s); return nil }
+
+func main() {
+	var (
+		network = flag.String("net", "udp", "network: udp, tcp or unix")
+		addr    = flag.String("addr", "127.0.0.1:57110", "address to send to, a socket path for unix")
+		framing = flag.String("framing", "length-prefixed", "framing for tcp: length-prefixed or slip")
+		speed   = flag.Float64("speed", 1, "replay speed, 0 for as fast as possible")
+		retime  = flag.Bool("retime", false, "move bundle timetags to the time of the replay")
+		verbose = flag.Bool("v", false, "print packets as they are sent")
+		match   stringList
+		remap   stringList
+	)
+	flag.Var(&match, "match", "only send messages that match an address pattern, can be repeated")
+	flag.Var(&remap, "remap", "replace an address prefix, as OLD=NEW, can be repeated")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "usage: oscreplay [flags] FILE")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	rw, err := newRewriter(match, remap, *retime)
+	if err != nil {
+		fatal(err)
+	}
+	f, err := os.Open(flag.Arg(0))
+	if err != nil {
+		fatal(err)
+	}
+	defer func() { _ = f.Close() }()
+
+	c, err := oscutil.Dial(*network, *addr, *framing, false)
+	if err != nil {
+		fatal(err)
+	}
+	defer func() { _ = c.Close() }()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	var out io.Writer
+	if *verbose {
+		out = os.Stdout
+	}
+	if err := replay(ctx, osc.NewRecordReader(f), *speed, rw, c, out); err != nil && err != context.Canceled {
+		fatal(err)
+	}
+}
+
+func fatal(err error) {
+	fmt.Fprintf(os.Stderr, "oscreplay: %s\n", err)
+	os.Exit(1)
+}
+
+// sender sends packets.
+type sender interface {
+	Send(osc.Packet) error
+}
+
+// replay sends the records to s after they have been rewritten.
+// Packets are printed to out if it is not nil.
+func replay(ctx context.Context, rr *osc.RecordReader, speed float64, rw *rewriter, s sender, out io.Writer) error {
+	return osc.Replay(ctx, rr, speed, func(r osc.Record) error {
+		p, ok := rw.rewrite(r, time.Now())
+		if !ok {
+			return nil
+		}
+		if out != nil {
+			fmt.Fprintln(out, osc.Format(p))
+		}
+		return s.Send(p)
+	})
+}
+
+// remap replaces the address prefix from with to.
+type remap struct {
+	from, to string
+}
+
+// rewriter filters and changes the packets of a recording.
+type rewriter struct {
+	filter oscutil.Filter
+	remaps []remap
+	retime bool
+}
+
+// newRewriter returns a rewriter for the -match, -remap and -retime flags.
+func newRewriter(patterns, remaps []string, retime bool) (*rewriter, error) {
+	filter, err := oscutil.NewFilter(patterns)
+	if err != nil {
+		return nil, err
+	}
+	rw := &rewriter{filter: filter, retime: retime}
+
+	for _, s := range remaps {
+		from, to, ok := strings.Cut(s, "=")
+		if !ok || !strings.HasPrefix(from, "/") || !strings.HasPrefix(to, "/") {
+			return nil, errors.Errorf("remap %q should look like /old=/new", s)
+		}
+		rw.remaps = append(rw.remaps, remap{from: strings.TrimSuffix(from, "/"), to: strings.TrimSuffix(to, "/")})
+	}
+	return rw, nil
+}
+
+// rewrite returns the packet of the record as it should be replayed at now,
+// and false if it should not be replayed.
+func (rw *rewriter) rewrite(r osc.Record, now time.Time) (osc.Packet, bool) {
+	p, ok := rw.filter.Apply(r.Packet)
+	if !ok {
+		return nil, false
+	}
+	return rw.packet(p, now.Sub(r.Time)), true
+}
+
+// packet remaps the addresses in p and moves its timetags by offset if retime is set.
+func (rw *rewriter) packet(p osc.Packet, offset time.Duration) osc.Packet {
+	switch x := p.(type) {
+	case osc.Message:
+		x.Address = rw.address(x.Address)
+		return x
+	case osc.Bundle:
+		b := osc.Bundle{Timetag: x.Timetag, Sender: x.Sender}
+		if rw.retime && b.Timetag != osc.Immediately {
+			b.Timetag = osc.FromTime(b.Timetag.Time().Add(offset))
+		}
+		for _, p := range x.Packets {
+			b.Packets = append(b.Packets, rw.packet(p, offset))
+		}
+		return b
+	default:
+		return p
+	}
+}
+
+// address applies the first remap whose prefix matches whole parts of addr.
+func (rw *rewriter) address(addr string) string {
+	for _, m := range rw.remaps {
+		if addr == m.from {
+			return m.to
+		}
+		if strings.HasPrefix(addr, m.from+"/") {
+			return m.to + addr[len(m.from):]
+		}
+	}
+	return addr
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/scgolang/osc"
+)
+
+func TestRewriter(t *testing.T) {
+	rw, err := newRewriter([]string{"/synth/*", "/fx/*"}, []string{"/synth=/test/synth", "/fx/=/effects/"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		recorded = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		now      = recorded.Add(time.Hour)
+	)
+	for i, testcase := range []struct {
+		Input    osc.Packet
+		Expected osc.Packet
+	}{
+		{
+			Input:    osc.Message{Address: "/synth/freq", Arguments: osc.Arguments{osc.Float(440)}},
+			Expected: osc.Message{Address: "/test/synth/freq", Arguments: osc.Arguments{osc.Float(440)}},
+		},
+		{
+			Input:    osc.Message{Address: "/fx/reverb"},
+			Expected: osc.Message{Address: "/effects/reverb"},
+		},
+		{
+			Input: osc.Bundle{
+				Timetag: osc.FromTime(recorded.Add(time.Second)),
+				Packets: []osc.Packet{
+					osc.Message{Address: "/synth/gate"},
+					osc.Message{Address: "/status"},
+					osc.Bundle{Timetag: osc.Immediately, Packets: []osc.Packet{osc.Message{Address: "/fx/dry"}}},
+				},
+			},
+			Expected: osc.Bundle{
+				Timetag: osc.FromTime(now.Add(time.Second)),
+				Packets: []osc.Packet{
+					osc.Message{Address: "/test/synth/gate"},
+					osc.Bundle{Timetag: osc.Immediately, Packets: []osc.Packet{osc.Message{Address: "/effects/dry"}}},
+				},
+			},
+		},
+	} {
+		got, ok := rw.rewrite(osc.Record{Time: recorded, Packet: testcase.Input}, now)
+		if !ok {
+			t.Fatalf("(testcase %d) expected packet to be kept", i)
+		}
+		if !testcase.Expected.Equal(got) {
+			t.Fatalf("(testcase %d) expected %s, got %s", i, testcase.Expected, got)
+		}
+	}
+	for _, p := range []osc.Packet{
+		osc.Message{Address: "/synthesizer/freq"},
+		osc.Bundle{Timetag: osc.Immediately, Packets: []osc.Packet{osc.Message{Address: "/status"}}},
+	} {
+		if got, ok := rw.rewrite(osc.Record{Time: recorded, Packet: p}, now); ok {
+			t.Fatalf("expected %s to be left out, got %s", p, got)
+		}
+	}
+}
+
+func TestNewRewriterErrors(t *testing.T) {
+	for i, remap := range []string{"/foo", "foo=/bar", "/foo=bar"} {
+		if _, err := newRewriter(nil, []string{remap}, false); err == nil {
+			t.Fatalf("(testcase %d) expected error for %q, got nil", i, remap)
+		}
+	}
+}
+
+// sendFunc is a sender that calls a function.
+type sendFunc func(osc.Packet) error
+
+func (f sendFunc) Send(p osc.Packet) error { return f(p) }
+
+func TestReplay(t *testing.T) {
+	var (
+		recording = &bytes.Buffer{}
+		start     = time.Unix(0, 0)
+	)
+	w, err := osc.NewRecordWriter(recording)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, addr := range []string{"/synth/freq", "/status", "/synth/gate"} {
+		if err := w.Write(osc.Record{Time: start.Add(time.Duration(i) * time.Second), Packet: osc.Message{Address: addr}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rw, err := newRewriter([]string{"/synth/*"}, []string{"/synth=/s"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		sent []string
+		out  = &bytes.Buffer{}
+		s    = sendFunc(func(p osc.Packet) error {
+			sent = append(sent, p.(osc.Message).Address)
+			return nil
+		})
+	)
+	if err := replay(context.Background(), osc.NewRecordReader(recording), 0, rw, s, out); err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := "/s/freq /s/gate", sent; len(got) != 2 || expected != got[0]+" "+got[1] {
+		t.Fatalf("expected %s, got %v", expected, got)
+	}
+	if expected, got := "/s/freq\n/s/gate\n", out.String(); expected != got {
+		t.Fatalf("expected %q, got %q", expected, got)
+	}
+}
@@ -0,0 +1,236 @@
+// Command oscsend sends an OSC message or bundle.
+//
+// Usage:
+//
+//	oscsend [flags] ADDRESS [ARGUMENT...]
+//
+// The types of the arguments are inferred: integers are sent as ints,
+// other numbers as floats, true and false as booleans and everything else
+// as strings, including nan and inf. Use -t to give the typetags explicitly,
+// blobs are given in base64:
+//
+//	oscsend -addr 127.0.0.1:57110 /s_new default -1 0 0 freq 440
+//	oscsend -t fsb /synth/freq 440 sine AQID
+//
+// The T and F typetags take an argument too, which must be empty or agree
+// with the typetag:
+//
+//	oscsend -t sT /mixer/mute ch1 true
+//
+// With -text the arguments are parsed with the text syntax of osc.ParseText,
+// which can also describe bundles:
+//
+//	oscsend -text '#bundle immediately [ /foo ,i 1 /bar ,s baz ]'
+//
+// -at wraps the packet in a bundle that is scheduled in the future,
+// either after a duration such as 500ms or at an RFC 3339 time.
+//
+// -n sends the packet several times, -n 0 sends it until oscsend is killed,
+// and -interval sets the time between packets.
+//
+// -reply waits for a reply after every packet and prints it.
+//
+// The transport is set with -net: udp, tcp or unix.
+// TCP streams are framed according to -framing, which is length-prefixed or slip.
+// Unix sockets are datagram sockets.
+package main
+
+import (
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/pkg/errors"
+	"github.com/scgolang/osc"
+	"github.com/scgolang/osc/cmd/internal/oscutil"
+)
+
+// config holds the command-line flags.
+type config struct {
+	network  string
+	addr     string
+	framing  string
+	typetags string
+	text     bool
+	at       string
+	count    int
+	interval time.Duration
+	reply    bool
+	timeout  time.Duration
+}
+
+func main() {
+	var cfg config
+
+	fs := flag.NewFlagSet("oscsend", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: oscsend [flags] ADDRESS [ARGUMENT...]")
+		fs.PrintDefaults()
+	}
+	fs.StringVar(&cfg.network, "net", "udp", "network: udp, tcp or unix")
+	fs.StringVar(&cfg.addr, "addr", "127.0.0.1:57110", "address to send to, a socket path for unix")
+	fs.StringVar(&cfg.framing, "framing", "length-prefixed", "framing for tcp: length-prefixed or slip")
+	fs.StringVar(&cfg.typetags, "t", "", "typetags of the arguments, inferred if empty")
+	fs.BoolVar(&cfg.text, "text", false, "parse the arguments as the text syntax of a packet")
+	fs.StringVar(&cfg.at, "at", "", "send the packet in a bundle scheduled after a duration or at an RFC 3339 time")
+	fs.IntVar(&cfg.count, "n", 1, "number of times to send the packet, 0 for forever")
+	fs.DurationVar(&cfg.interval, "interval", time.Second, "time between packets")
+	fs.BoolVar(&cfg.reply, "reply", false, "wait for a reply after each packet and print it")
+	fs.DurationVar(&cfg.timeout, "timeout", 2*time.Second, "how long to wait for a reply")
+	_ = fs.Parse(os.Args[1:])
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if err := run(cfg, fs.Args(), os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "oscsend: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+// run sends the packet described by args.
+func run(cfg config, args []string, out io.Writer) error {
+	p, err := buildPacket(args, cfg.typetags, cfg.text)
+	if err != nil {
+		return err
+	}
+	schedule, err := parseAt(cfg.at)
+	if err != nil {
+		return err
+	}
+	c, err := oscutil.Dial(cfg.network, cfg.addr, cfg.framing, cfg.reply)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = c.Close() }()
+
+	for i := 0; cfg.count <= 0 || i < cfg.count; i++ {
+		if i > 0 {
+			time.Sleep(cfg.interval)
+		}
+		packet := p
+		if schedule != nil {
+			packet = osc.Bundle{Timetag: schedule(time.Now()), Packets: []osc.Packet{p}}
+		}
+		if err := c.Send(packet); err != nil {
+			return errors.Wrap(err, "send packet")
+		}
+		if !cfg.reply {
+			continue
+		}
+		reply, err := c.Receive(cfg.timeout)
+		if err != nil {
+			return errors.Wrap(err, "wait for reply")
+		}
+		fmt.Fprintln(out, osc.Format(reply))
+	}
+	return nil
+}
+
+// buildPacket returns the packet described by the command-line arguments.
+func buildPacket(args []string, typetags string, text bool) (osc.Packet, error) {
+	if text {
+		return osc.ParseText(strings.Join(args, " "))
+	}
+	if len(args) == 0 {
+		return nil, errors.New("missing address")
+	}
+	msg := osc.Message{Address: args[0]}
+	values := args[1:]
+
+	if typetags != "" {
+		typetags = strings.TrimPrefix(typetags, ",")
+		if len(typetags) != len(values) {
+			return nil, errors.Errorf("%d typetags for %d arguments", len(typetags), len(values))
+		}
+	}
+	for i, value := range values {
+		var (
+			arg osc.Argument
+			err error
+		)
+		if typetags == "" {
+			arg = inferArgument(value)
+		} else {
+			arg, err = parseArgument(typetags[i], value)
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "argument %d", i)
+		}
+		msg.Arguments = append(msg.Arguments, arg)
+	}
+	return msg, nil
+}
+
+// inferArgument returns an argument whose type is inferred from value.
+func inferArgument(value string) osc.Argument {
+	if i, err := strconv.ParseInt(value, 10, 32); err == nil {
+		return osc.Int(i)
+	}
+	if f, err := strconv.ParseFloat(value, 32); err == nil && isNumber(value) {
+		return osc.Float(f)
+	}
+	switch value {
+	case "true":
+		return osc.Bool(true)
+	case "false":
+		return osc.Bool(false)
+	}
+	return osc.String(value)
+}
+
+// isNumber returns true if value is written as a decimal number,
+// and not e.g. as "inf" or "nan", which strconv.ParseFloat also accepts.
+func isNumber(value string) bool {
+	return strings.IndexFunc(value, func(r rune) bool {
+		return unicode.IsLetter(r) && r != 'e' && r != 'E'
+	}) == -1
+}
+
+// parseArgument returns an argument with the given typetag.
+func parseArgument(typetag byte, value string) (osc.Argument, error) {
+	switch typetag {
+	case osc.TypetagInt:
+		i, err := strconv.ParseInt(value, 10, 32)
+		return osc.Int(i), err
+	case osc.TypetagFloat:
+		f, err := strconv.ParseFloat(value, 32)
+		return osc.Float(f), err
+	case osc.TypetagString:
+		return osc.String(value), nil
+	case osc.TypetagBlob:
+		b, err := base64.StdEncoding.DecodeString(value)
+		return osc.Blob(b), err
+	case osc.TypetagTrue, osc.TypetagFalse:
+		b := typetag == osc.TypetagTrue
+		if value != "" && value != strconv.FormatBool(b) {
+			return nil, errors.Errorf("typetag %q can not have the value %q", string(typetag), value)
+		}
+		return osc.Bool(b), nil
+	default:
+		return nil, errors.Wrapf(osc.ErrInvalidTypeTag, "typetag %q", string(typetag))
+	}
+}
+
+// parseAt returns a function that computes the timetag of a scheduled bundle,
+// or nil if at is empty.
+func parseAt(at string) (func(now time.Time) osc.Timetag, error) {
+	if at == "" {
+		return nil, nil
+	}
+	if d, err := time.ParseDuration(at); err == nil {
+		return func(now time.Time) osc.Timetag { return osc.FromTime(now.Add(d)) }, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, at)
+	if err != nil {
+		return nil, errors.Errorf("-at %q is neither a duration nor an RFC 3339 time", at)
+	}
+	return func(time.Time) osc.Timetag { return osc.FromTime(t) }, nil
+}
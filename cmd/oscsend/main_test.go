@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/scgolang/osc"
+)
+
+func TestBuildPacket(t *testing.T) {
+	for i, testcase := range []struct {
+		Args     []string
+		Typetags string
+		Text     bool
+		Expected osc.Packet
+	}{
+		{
+			Args:     []string{"/s_new", "default", "-1", "0", "0", "freq", "440.5", "true"},
+			Expected: osc.Message{Address: "/s_new", Arguments: osc.Arguments{osc.String("default"), osc.Int(-1), osc.Int(0), osc.Int(0), osc.String("freq"), osc.Float(440.5), osc.Bool(true)}},
+		},
+		{
+			Args:     []string{"/synth/freq", "440", "sine", "AQID", "123"},
+			Typetags: ",fsbs",
+			Expected: osc.Message{Address: "/synth/freq", Arguments: osc.Arguments{osc.Float(440), osc.String("sine"), osc.Blob{1, 2, 3}, osc.String("123")}},
+		},
+		{
+			Args:     []string{"/foo", "nan", "inf", "-Infinity", "1e3", "0x1p-2"},
+			Expected: osc.Message{Address: "/foo", Arguments: osc.Arguments{osc.String("nan"), osc.String("inf"), osc.String("-Infinity"), osc.Float(1000), osc.String("0x1p-2")}},
+		},
+		{
+			Args:     []string{"/mixer/mute", "ch1", "true", "", "false"},
+			Typetags: "sTFF",
+			Expected: osc.Message{Address: "/mixer/mute", Arguments: osc.Arguments{osc.String("ch1"), osc.Bool(true), osc.Bool(false), osc.Bool(false)}},
+		},
+		{
+			Args:     []string{"/foo", "010", "010"},
+			Typetags: "is",
+			Expected: osc.Message{Address: "/foo", Arguments: osc.Arguments{osc.Int(10), osc.String("010")}},
+		},
+		{
+			Args:     []string{"#bundle", "immediately", "[", "/foo", ",i", "1", "]"},
+			Text:     true,
+			Expected: osc.Bundle{Timetag: osc.Immediately, Packets: []osc.Packet{osc.Message{Address: "/foo", Arguments: osc.Arguments{osc.Int(1)}}}},
+		},
+	} {
+		p, err := buildPacket(testcase.Args, testcase.Typetags, testcase.Text)
+		if err != nil {
+			t.Fatalf("(testcase %d) %s", i, err)
+		}
+		if !testcase.Expected.Equal(p) {
+			t.Fatalf("(testcase %d) expected %s, got %s", i, testcase.Expected, p)
+		}
+	}
+}
+
+func TestBuildPacketErrors(t *testing.T) {
+	for i, testcase := range []struct {
+		Args     []string
+		Typetags string
+		Err      string
+	}{
+		{
+			Args:     []string{"/foo", "1"},
+			Typetags: "ii",
+			Err:      "2 typetags for 1 arguments",
+		},
+		{
+			Args:     []string{"/foo", "bar"},
+			Typetags: "i",
+			Err:      `argument 0: strconv.ParseInt: parsing "bar": invalid syntax`,
+		},
+		{
+			Args:     []string{"/foo", "bar"},
+			Typetags: "x",
+			Err:      `argument 0: typetag "x": invalid type tag`,
+		},
+		{
+			Args:     []string{"/mixer/mute", "false"},
+			Typetags: "T",
+			Err:      `argument 0: typetag "T" can not have the value "false"`,
+		},
+		{
+			Args:     []string{"/mixer/mute", "1"},
+			Typetags: "F",
+			Err:      `argument 0: typetag "F" can not have the value "1"`,
+		},
+	} {
+		_, err := buildPacket(testcase.Args, testcase.Typetags, false)
+		if err == nil {
+			t.Fatalf("(testcase %d) expected error, got nil", i)
+		}
+		if expected, got := testcase.Err, err.Error(); expected != got {
+			t.Fatalf("(testcase %d) expected %s, got %s", i, expected, got)
+		}
+	}
+}
+
+func TestParseAt(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	schedule, err := parseAt("500ms")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := osc.FromTime(now.Add(500*time.Millisecond)), schedule(now); expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+	schedule, err = parseAt("2024-01-01T00:00:01Z")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := osc.FromTime(now.Add(time.Second)), schedule(now); expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+	if _, err := parseAt("tomorrow"); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+}
+
+func TestRunUDPReply(t *testing.T) {
+	server, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = server.Close() }()
+
+	go func() {
+		buf := make([]byte, 65536)
+		for {
+			n, addr, err := server.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			p, err := osc.ParsePacket(buf[:n], addr)
+			if err != nil {
+				return
+			}
+			msg := p.(osc.Bundle).Packets[0].(osc.Message)
+			reply := osc.Message{Address: "/done", Arguments: osc.Arguments{osc.String(msg.Address)}}
+			_, _ = server.WriteToUDP(reply.Bytes(), addr)
+		}
+	}()
+	cfg := config{
+		network: "udp",
+		addr:    server.LocalAddr().String(),
+		at:      "10ms",
+		count:   2,
+		reply:   true,
+		timeout: 2 * time.Second,
+	}
+	out := &bytes.Buffer{}
+	if err := run(cfg, []string{"/foo", "1"}, out); err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := "/done ,s \"/foo\"\n/done ,s \"/foo\"\n", out.String(); expected != got {
+		t.Fatalf("expected %q, got %q", expected, got)
+	}
+}
+
+func TestRunTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = ln.Close() }()
+
+	received := make(chan osc.Packet, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+
+		p, err := osc.NewDecoder(conn, osc.SLIP).Decode()
+		if err != nil {
+			return
+		}
+		received <- p
+	}()
+	cfg := config{
+		network: "tcp",
+		addr:    ln.Addr().String(),
+		framing: "slip",
+		count:   1,
+	}
+	if err := run(cfg, []string{"/foo", "bar"}, &bytes.Buffer{}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case p := <-received:
+		if expected := (osc.Message{Address: "/foo", Arguments: osc.Arguments{osc.String("bar")}}); !expected.Equal(p) {
+			t.Fatalf("expected %s, got %s", expected, p)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout")
+	}
+}
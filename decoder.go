@@ -0,0 +1,208 @@
+package osc
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// Common errors.
+var (
+	ErrPacketTooLarge = errors.New("packet is too large")
+	ErrInvalidFraming = errors.New("invalid framing")
+)
+
+// DecodeError is returned by Decoder when a packet could not be decoded.
+type DecodeError struct {
+	// Offset is the position in the stream of the first byte of the packet's frame.
+	Offset int64
+
+	// Err is the reason the packet could not be decoded.
+	Err error
+}
+
+// Cause returns the underlying error.
+func (e *DecodeError) Cause() error {
+	return e.Err
+}
+
+// Error returns a description of the error.
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("decode packet at offset %d: %s", e.Offset, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// Decoder reads OSC packets from a stream of bytes.
+type Decoder struct {
+	r       *bufio.Reader
+	framing Framing
+	maxSize int
+	offset  int64
+	start   int64
+}
+
+// NewDecoder returns a decoder that reads packets from r
+// using the given framing method.
+// Packets can be at most 64KiB, see SetMaxPacketSize.
+func NewDecoder(r io.Reader, framing Framing) *Decoder {
+	return &Decoder{
+		r:       bufio.NewReader(r),
+		framing: framing,
+		maxSize: bufSize,
+	}
+}
+
+// SetMaxPacketSize sets the maximum number of bytes in a decoded packet.
+// Larger packets are skipped and cause Decode to return ErrPacketTooLarge
+// wrapped in a DecodeError.
+func (d *Decoder) SetMaxPacketSize(size int) {
+	d.maxSize = size
+}
+
+// InputOffset returns the number of bytes that have been read from the stream.
+func (d *Decoder) InputOffset() int64 {
+	return d.offset
+}
+
+// Decode reads the next packet from the stream.
+// When there are no more packets it returns io.EOF.
+// Any other error is a *DecodeError.
+// A stream that ends in the middle of a packet results in io.ErrUnexpectedEOF.
+// Decoding can continue after an error that was caused by a single
+// malformed or oversized packet.
+func (d *Decoder) Decode() (Packet, error) {
+	data, err := d.ReadFrame()
+	if err != nil {
+		return nil, err
+	}
+	p, err := ParsePacket(data, nil)
+	if err != nil {
+		return nil, &DecodeError{Offset: d.start, Err: err}
+	}
+	return p, nil
+}
+
+// ReadFrame reads the bytes of the next packet from the stream without parsing them.
+// It returns the same errors as Decode, except for parse errors.
+func (d *Decoder) ReadFrame() ([]byte, error) {
+	var (
+		data []byte
+		err  error
+	)
+	d.start = d.offset
+
+	switch d.framing {
+	case LengthPrefixed:
+		data, err = d.readLengthPrefixed()
+	case SLIP:
+		data, err = d.readSLIP()
+	default:
+		err = errors.Wrapf(ErrInvalidFraming, "framing %d", d.framing)
+	}
+	if err == io.EOF {
+		return nil, io.EOF
+	}
+	if err != nil {
+		return nil, &DecodeError{Offset: d.start, Err: err}
+	}
+	return data, nil
+}
+
+// readLengthPrefixed reads a packet that is preceded by its size.
+func (d *Decoder) readLengthPrefixed() ([]byte, error) {
+	var size [4]byte
+
+	n, err := io.ReadFull(d.r, size[:])
+	d.offset += int64(n)
+	if err != nil {
+		return nil, err
+	}
+	l := int64(byteOrder.Uint32(size[:]))
+	if l > int64(d.maxSize) {
+		// Skip the packet so that the caller can carry on with the next one.
+		discarded, err := d.r.Discard(int(l))
+		d.offset += int64(discarded)
+		if err == io.EOF {
+			return nil, io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return nil, err
+		}
+		return nil, errors.Wrapf(ErrPacketTooLarge, "%d bytes", l)
+	}
+	data := make([]byte, l)
+
+	n, err = io.ReadFull(d.r, data)
+	d.offset += int64(n)
+	if err == io.EOF {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return data, err
+}
+
+// readSLIP reads a SLIP encoded packet.
+// Empty frames, e.g. between two consecutive END characters, are skipped.
+func (d *Decoder) readSLIP() ([]byte, error) {
+	var (
+		data     []byte
+		escaped  bool
+		size     int
+		frameErr error
+	)
+	for {
+		c, err := d.r.ReadByte()
+		if err == io.EOF && size == 0 && !escaped && frameErr == nil {
+			return nil, io.EOF
+		}
+		if err == io.EOF {
+			return nil, io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return nil, err
+		}
+		d.offset++
+
+		if escaped {
+			escaped = false
+
+			switch c {
+			case slipEscEnd:
+				c = slipEnd
+			case slipEscEsc:
+				c = slipEsc
+			default:
+				if frameErr == nil {
+					frameErr = errors.Errorf("invalid SLIP escape sequence 0x%02X 0x%02X", slipEsc, c)
+				}
+			}
+		} else {
+			switch c {
+			case slipEnd:
+				if size == 0 && frameErr == nil {
+					d.start = d.offset
+					continue
+				}
+				if frameErr != nil {
+					return nil, frameErr
+				}
+				if size > d.maxSize {
+					return nil, errors.Wrapf(ErrPacketTooLarge, "%d bytes", size)
+				}
+				return data, nil
+			case slipEsc:
+				escaped = true
+				continue
+			}
+		}
+		// Keep counting bytes in oversized packets, but stop storing them.
+		if size++; size <= d.maxSize {
+			data = append(data, c)
+		}
+	}
+}
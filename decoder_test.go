@@ -0,0 +1,196 @@
+package osc
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+// lengthPrefixed returns the binary representation of p preceded by its size.
+func lengthPrefixed(p Packet) []byte {
+	return append(Int(len(p.Bytes())).Bytes(), p.Bytes()...)
+}
+
+// slipEncoded returns the double-ended SLIP encoding of data.
+func slipEncoded(data []byte) []byte {
+	return appendSLIP(nil, data)
+}
+
+func TestDecoder(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+
+	for _, framing := range []Framing{LengthPrefixed, SLIP} {
+		var (
+			packets = []Packet{}
+			stream  = []byte{}
+		)
+		// Make sure the SLIP special characters show up in the stream.
+		packets = append(packets, Message{
+			Address:   "/slip",
+			Arguments: Arguments{Blob{slipEnd, slipEsc, slipEscEnd, slipEscEsc}},
+		})
+		for i := 0; i < 100; i++ {
+			packets = append(packets, randomPacket(r, 2))
+		}
+		for _, p := range packets {
+			if framing == SLIP {
+				stream = append(stream, slipEncoded(p.Bytes())...)
+			} else {
+				stream = append(stream, lengthPrefixed(p)...)
+			}
+		}
+		dec := NewDecoder(bytes.NewReader(stream), framing)
+
+		for i, expected := range packets {
+			got, err := dec.Decode()
+			if err != nil {
+				t.Fatalf("(%s packet %d) %s", framing, i, err)
+			}
+			if !bytes.Equal(expected.Bytes(), got.Bytes()) {
+				t.Fatalf("(%s packet %d) expected %q, got %q", framing, i, expected.Bytes(), got.Bytes())
+			}
+		}
+		if _, err := dec.Decode(); err != io.EOF {
+			t.Fatalf("(%s) expected io.EOF, got %+v", framing, err)
+		}
+		if expected, got := int64(len(stream)), dec.InputOffset(); expected != got {
+			t.Fatalf("(%s) expected offset %d, got %d", framing, expected, got)
+		}
+	}
+}
+
+func TestDecoderErrors(t *testing.T) {
+	var (
+		msg      = Message{Address: "/foo", Arguments: Arguments{Int(1)}}
+		big      = Message{Address: "/big", Arguments: Arguments{Blob(make([]byte, 64))}}
+		bad      = badPacket{}.Bytes()
+		concat   = func(bss ...[]byte) []byte { return bytes.Join(bss, []byte{}) }
+		msgLen   = int64(len(lengthPrefixed(msg)))
+		msgSLIP  = int64(len(slipEncoded(msg.Bytes())))
+		truncLen = lengthPrefixed(msg)[:10]
+	)
+	for i, testcase := range []struct {
+		Framing Framing
+		Stream  []byte
+		Offset  int64 // offset of the bad packet
+		Cause   error
+		Err     string
+	}{
+		{
+			Framing: LengthPrefixed,
+			Stream:  concat(lengthPrefixed(msg), lengthPrefixed(big), lengthPrefixed(msg)),
+			Offset:  msgLen,
+			Cause:   ErrPacketTooLarge,
+			Err:     "80 bytes: packet is too large",
+		},
+		{
+			Framing: SLIP,
+			Stream:  concat(slipEncoded(msg.Bytes()), slipEncoded(big.Bytes()), slipEncoded(msg.Bytes())),
+			Offset:  msgSLIP + 1,
+			Cause:   ErrPacketTooLarge,
+			Err:     "80 bytes: packet is too large",
+		},
+		{
+			Framing: LengthPrefixed,
+			Stream:  concat(lengthPrefixed(msg), lengthPrefixed(badPacket{}), lengthPrefixed(msg)),
+			Offset:  msgLen,
+			Err:     `parse message: read argument 0: typetag "Q": invalid type tag`,
+		},
+		{
+			Framing: SLIP,
+			Stream:  concat(slipEncoded(msg.Bytes()), slipEncoded(bad), slipEncoded(msg.Bytes())),
+			Offset:  msgSLIP + 1,
+			Err:     `parse message: read argument 0: typetag "Q": invalid type tag`,
+		},
+		{
+			Framing: SLIP,
+			Stream:  concat(slipEncoded(msg.Bytes()), []byte{slipEnd, '/', slipEsc, 'x', slipEnd}, slipEncoded(msg.Bytes())),
+			Offset:  msgSLIP + 1,
+			Err:     "invalid SLIP escape sequence 0xDB 0x78",
+		},
+		{
+			Framing: LengthPrefixed,
+			Stream:  concat(lengthPrefixed(msg), truncLen),
+			Offset:  msgLen,
+			Cause:   io.ErrUnexpectedEOF,
+			Err:     "unexpected EOF",
+		},
+		{
+			Framing: SLIP,
+			Stream:  concat(slipEncoded(msg.Bytes()), []byte{slipEnd, '/', 'f'}),
+			Offset:  msgSLIP + 1,
+			Cause:   io.ErrUnexpectedEOF,
+			Err:     "unexpected EOF",
+		},
+		{
+			Framing: Framing(7),
+			Stream:  lengthPrefixed(msg),
+			Cause:   ErrInvalidFraming,
+			Err:     "framing 7: invalid framing",
+		},
+	} {
+		dec := NewDecoder(bytes.NewReader(testcase.Stream), testcase.Framing)
+		dec.SetMaxPacketSize(64)
+
+		if testcase.Offset > 0 {
+			if _, err := dec.Decode(); err != nil {
+				t.Fatalf("(testcase %d) %s", i, err)
+			}
+		}
+		_, err := dec.Decode()
+		if err == nil {
+			t.Fatalf("(testcase %d) expected error, got nil", i)
+		}
+		de, ok := err.(*DecodeError)
+		if !ok {
+			t.Fatalf("(testcase %d) expected *DecodeError, got %T", i, err)
+		}
+		if expected, got := testcase.Offset, de.Offset; expected != got {
+			t.Fatalf("(testcase %d) expected offset %d, got %d", i, expected, got)
+		}
+		if expected, got := testcase.Err, de.Err.Error(); expected != got {
+			t.Fatalf("(testcase %d) expected %s, got %s", i, expected, got)
+		}
+		if testcase.Cause != nil && errors.Cause(err) != testcase.Cause {
+			t.Fatalf("(testcase %d) expected cause %s, got %s", i, testcase.Cause, errors.Cause(err))
+		}
+		// Decoding carries on with the packet after the bad one.
+		if errors.Cause(err) == io.ErrUnexpectedEOF || errors.Cause(err) == ErrInvalidFraming {
+			continue
+		}
+		p, err := dec.Decode()
+		if err != nil {
+			t.Fatalf("(testcase %d) %s", i, err)
+		}
+		if !msg.Equal(p) {
+			t.Fatalf("(testcase %d) expected %s, got %s", i, msg, p)
+		}
+	}
+}
+
+func TestDecoderReadFrame(t *testing.T) {
+	malformed := []byte("not a packet")
+	stream := append(slipEncoded(malformed), slipEncoded(Message{Address: "/foo"}.Bytes())...)
+	dec := NewDecoder(bytes.NewReader(stream), SLIP)
+
+	data, err := dec.ReadFrame()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := malformed, data; !bytes.Equal(expected, got) {
+		t.Fatalf("expected %q, got %q", expected, got)
+	}
+	data, err = dec.ReadFrame()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := (Message{Address: "/foo"}).Bytes(), data; !bytes.Equal(expected, got) {
+		t.Fatalf("expected %q, got %q", expected, got)
+	}
+	if _, err := dec.ReadFrame(); err != io.EOF {
+		t.Fatalf("expected io.EOF, got %+v", err)
+	}
+}
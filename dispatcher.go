@@ -31,6 +31,14 @@ type Dispatcher interface {
 	Invoke(msg Message, exactMatch bool) error
 }
 
+// MessageFilter can be implemented by dispatchers that want to reject
+// messages by looking at their address, before the arguments are decoded.
+// Messages that are not accepted are dropped without being parsed,
+// so malformed arguments in those messages are never reported.
+type MessageFilter interface {
+	Accept(view MessageView, exactMatch bool) (bool, error)
+}
+
 // PatternMatching is a dispatcher that implements OSC 1.0 pattern matching.
 // See http://opensoundcontrol.org/spec-1_0 "OSC Message Dispatching and Pattern Matching"
 type PatternMatching map[string]MessageHandler
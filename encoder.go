@@ -0,0 +1,99 @@
+package osc
+
+import (
+	"bufio"
+	"io"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// FlushPolicy determines when an Encoder writes packets to the underlying writer.
+type FlushPolicy int
+
+// Flush policies.
+const (
+	// FlushEachPacket writes every packet with a single call to Write.
+	FlushEachPacket FlushPolicy = iota
+
+	// FlushWhenFull collects packets in a buffer that is written
+	// when it fills up or when Flush is called.
+	FlushWhenFull
+)
+
+// Encoder writes OSC packets to a stream of bytes.
+// It is safe to call Encode from multiple goroutines,
+// the frames of concurrently encoded packets are never interleaved.
+type Encoder struct {
+	mu      sync.Mutex
+	w       io.Writer
+	bw      *bufio.Writer
+	framing Framing
+	buf     []byte
+	scratch []byte
+}
+
+// NewEncoder returns an encoder that writes packets to w
+// using the given framing method.
+// By default every packet is written as soon as it is encoded, see SetFlushPolicy.
+func NewEncoder(w io.Writer, framing Framing) *Encoder {
+	return &Encoder{w: w, framing: framing}
+}
+
+// Encode writes the framed binary representation of p to the stream.
+func (e *Encoder) Encode(p Packet) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	switch e.framing {
+	case LengthPrefixed:
+		e.buf = appendPacket(append(e.buf[:0], 0, 0, 0, 0), p)
+		byteOrder.PutUint32(e.buf, uint32(len(e.buf)-4))
+	case SLIP:
+		e.scratch = appendPacket(e.scratch[:0], p)
+		e.buf = appendSLIP(e.buf[:0], e.scratch)
+	default:
+		return errors.Wrapf(ErrInvalidFraming, "framing %d", e.framing)
+	}
+	if e.bw != nil {
+		_, err := e.bw.Write(e.buf)
+		return err
+	}
+	_, err := e.w.Write(e.buf)
+	return err
+}
+
+// Flush writes any buffered packets to the underlying writer.
+func (e *Encoder) Flush() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.bw == nil {
+		return nil
+	}
+	return e.bw.Flush()
+}
+
+// SetFlushPolicy changes when packets are written to the underlying writer.
+// Switching to FlushEachPacket flushes any buffered packets.
+func (e *Encoder) SetFlushPolicy(policy FlushPolicy) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	switch policy {
+	case FlushEachPacket:
+		if e.bw == nil {
+			return nil
+		}
+		err := e.bw.Flush()
+		e.bw = nil
+		return err
+	case FlushWhenFull:
+		if e.bw == nil {
+			e.bw = bufio.NewWriterSize(e.w, bufSize)
+		}
+		return nil
+	default:
+		return errors.Errorf("invalid flush policy %d", policy)
+	}
+}
@@ -0,0 +1,154 @@
+package osc
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"sync"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestEncoder(t *testing.T) {
+	r := rand.New(rand.NewSource(3))
+
+	for _, framing := range []Framing{LengthPrefixed, SLIP} {
+		var (
+			buf     = &bytes.Buffer{}
+			enc     = NewEncoder(buf, framing)
+			packets = []Packet{}
+		)
+		for i := 0; i < 100; i++ {
+			p := randomPacket(r, 2)
+			if err := enc.Encode(p); err != nil {
+				t.Fatalf("(%s packet %d) %s", framing, i, err)
+			}
+			packets = append(packets, p)
+		}
+		dec := NewDecoder(buf, framing)
+
+		for i, expected := range packets {
+			got, err := dec.Decode()
+			if err != nil {
+				t.Fatalf("(%s packet %d) %s", framing, i, err)
+			}
+			if !bytes.Equal(expected.Bytes(), got.Bytes()) {
+				t.Fatalf("(%s packet %d) expected %q, got %q", framing, i, expected.Bytes(), got.Bytes())
+			}
+		}
+		if _, err := dec.Decode(); err != io.EOF {
+			t.Fatalf("(%s) expected io.EOF, got %+v", framing, err)
+		}
+	}
+}
+
+func TestEncoderFraming(t *testing.T) {
+	msg := Message{Address: "/foo", Arguments: Arguments{Blob{slipEnd, slipEsc}}}
+
+	for _, testcase := range []struct {
+		Framing  Framing
+		Expected []byte
+	}{
+		{
+			Framing: LengthPrefixed,
+			Expected: bytes.Join([][]byte{
+				{0, 0, 0, 20},
+				msg.Bytes(),
+			}, []byte{}),
+		},
+		{
+			Framing: SLIP,
+			Expected: bytes.Join([][]byte{
+				{slipEnd},
+				{'/', 'f', 'o', 'o', 0, 0, 0, 0},
+				{TypetagPrefix, TypetagBlob, 0, 0},
+				{0, 0, 0, 2},
+				{slipEsc, slipEscEnd, slipEsc, slipEscEsc, 0, 0},
+				{slipEnd},
+			}, []byte{}),
+		},
+	} {
+		buf := &bytes.Buffer{}
+		if err := NewEncoder(buf, testcase.Framing).Encode(msg); err != nil {
+			t.Fatal(err)
+		}
+		if expected, got := testcase.Expected, buf.Bytes(); !bytes.Equal(expected, got) {
+			t.Fatalf("(%s) expected %q, got %q", testcase.Framing, expected, got)
+		}
+	}
+	if err := NewEncoder(&bytes.Buffer{}, Framing(7)).Encode(msg); errors.Cause(err) != ErrInvalidFraming {
+		t.Fatalf("expected ErrInvalidFraming, got %+v", err)
+	}
+}
+
+func TestEncoderFlushPolicy(t *testing.T) {
+	var (
+		buf = &bytes.Buffer{}
+		enc = NewEncoder(buf, LengthPrefixed)
+		msg = Message{Address: "/foo"}
+	)
+	if err := enc.SetFlushPolicy(FlushWhenFull); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Encode(msg); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() != 0 {
+		t.Fatalf("expected nothing to be written before Flush, got %q", buf.Bytes())
+	}
+	if err := enc.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := lengthPrefixed(msg), buf.Bytes(); !bytes.Equal(expected, got) {
+		t.Fatalf("expected %q, got %q", expected, got)
+	}
+	if err := enc.Encode(msg); err != nil {
+		t.Fatal(err)
+	}
+	// Switching back flushes the buffer.
+	if err := enc.SetFlushPolicy(FlushEachPacket); err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := 2*len(lengthPrefixed(msg)), buf.Len(); expected != got {
+		t.Fatalf("expected %d bytes, got %d", expected, got)
+	}
+	if err := enc.SetFlushPolicy(FlushPolicy(7)); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+}
+
+func TestEncoderConcurrent(t *testing.T) {
+	var (
+		buf = &bytes.Buffer{}
+		enc = NewEncoder(buf, SLIP)
+		wg  sync.WaitGroup
+	)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				msg := Message{
+					Address:   "/worker",
+					Arguments: Arguments{Int(i), Int(j), String("abcdefghijklmnopqrstuvwxyz")},
+				}
+				if err := enc.Encode(msg); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	dec := NewDecoder(buf, SLIP)
+	for i := 0; i < 8*50; i++ {
+		if _, err := dec.Decode(); err != nil {
+			t.Fatalf("(packet %d) %s", i, err)
+		}
+	}
+	if _, err := dec.Decode(); err != io.EOF {
+		t.Fatalf("expected io.EOF, got %+v", err)
+	}
+}
@@ -0,0 +1,72 @@
+package osc
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Framing is a way of delimiting OSC packets in a stream of bytes.
+// Packet oriented transports like UDP don't need any framing,
+// but stream oriented transports like TCP, serial lines and files do.
+type Framing int
+
+// Framing methods.
+const (
+	// LengthPrefixed precedes every packet with its size as a 32-bit big-endian integer.
+	// This is the framing described by the OSC 1.0 spec.
+	LengthPrefixed Framing = iota
+
+	// SLIP encodes every packet with the double-ended SLIP method of RFC 1055.
+	// This is the framing described by the OSC 1.1 spec.
+	SLIP
+)
+
+// SLIP special characters, see RFC 1055.
+const (
+	slipEnd    byte = 0xC0
+	slipEsc    byte = 0xDB
+	slipEscEnd byte = 0xDC
+	slipEscEsc byte = 0xDD
+)
+
+// String returns the name of the framing method.
+func (f Framing) String() string {
+	switch f {
+	case LengthPrefixed:
+		return "length-prefixed"
+	case SLIP:
+		return "SLIP"
+	default:
+		return "unknown framing"
+	}
+}
+
+// ParseFraming returns the framing method with the given name.
+// Names are case-insensitive, "length-prefixed" can be shortened to "length".
+func ParseFraming(name string) (Framing, error) {
+	switch strings.ToLower(name) {
+	case "length-prefixed", "length":
+		return LengthPrefixed, nil
+	case "slip":
+		return SLIP, nil
+	default:
+		return 0, errors.Wrapf(ErrInvalidFraming, "framing %q", name)
+	}
+}
+
+// appendSLIP appends the double-ended SLIP encoding of data to dst.
+func appendSLIP(dst, data []byte) []byte {
+	dst = append(dst, slipEnd)
+	for _, c := range data {
+		switch c {
+		case slipEnd:
+			dst = append(dst, slipEsc, slipEscEnd)
+		case slipEsc:
+			dst = append(dst, slipEsc, slipEscEsc)
+		default:
+			dst = append(dst, c)
+		}
+	}
+	return append(dst, slipEnd)
+}
@@ -0,0 +1,29 @@
+package osc
+
+import "testing"
+
+func TestParseFraming(t *testing.T) {
+	for i, testcase := range []struct {
+		Name     string
+		Expected Framing
+	}{
+		{Name: "length-prefixed", Expected: LengthPrefixed},
+		{Name: "length", Expected: LengthPrefixed},
+		{Name: "SLIP", Expected: SLIP},
+		{Name: "slip", Expected: SLIP},
+	} {
+		got, err := ParseFraming(testcase.Name)
+		if err != nil {
+			t.Fatalf("(testcase %d) %s", i, err)
+		}
+		if expected := testcase.Expected; expected != got {
+			t.Fatalf("(testcase %d) expected %s, got %s", i, expected, got)
+		}
+		if parsed, _ := ParseFraming(got.String()); parsed != got {
+			t.Fatalf("(testcase %d) expected %s to parse its own name", i, got)
+		}
+	}
+	if _, err := ParseFraming("cobs"); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+}
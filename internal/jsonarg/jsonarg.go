@@ -0,0 +1,178 @@
+// Package jsonarg converts plain JSON values to OSC arguments, for the packages
+// of this module that take arguments from JSON written by people, like the
+// bodies of oschttp requests and the payloads of oscmqtt messages.
+//
+// Values are converted like this:
+//
+//	integers that fit in 32 bits  int (i)
+//	other numbers                 float (f)
+//	strings                       string (s)
+//	true and false                bool (T and F)
+//	typed objects                 the argument they represent, e.g. {"type":"d","value":0.5}
+package jsonarg
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"math"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"github.com/scgolang/osc"
+)
+
+// Values returns the values of a JSON array, the value if data is a single
+// JSON value, or no values if data is empty.
+func Values(data []byte) ([]json.RawMessage, error) {
+	switch data = bytes.TrimSpace(data); {
+	case len(data) == 0:
+		return nil, nil
+	case data[0] == '[':
+		var values []json.RawMessage
+		if err := json.Unmarshal(data, &values); err != nil {
+			return nil, err
+		}
+		return values, nil
+	default:
+		if !json.Valid(data) {
+			return nil, errors.New("invalid JSON")
+		}
+		return []json.RawMessage{data}, nil
+	}
+}
+
+// Convert converts a JSON value to the argument of its type.
+func Convert(raw json.RawMessage) (osc.Argument, error) {
+	v, err := decode(raw)
+	if err != nil {
+		return nil, err
+	}
+	switch x := v.(type) {
+	case json.Number:
+		if i, err := strconv.ParseInt(string(x), 10, 32); err == nil {
+			return osc.Int(i), nil
+		}
+		f, err := strconv.ParseFloat(string(x), 32)
+		if err != nil {
+			return nil, errors.Errorf("can not convert %s to a float", x)
+		}
+		return osc.Float(f), nil
+	case string:
+		return osc.String(x), nil
+	case bool:
+		return osc.Bool(x), nil
+	default:
+		return osc.ParseArgumentJSON(raw)
+	}
+}
+
+// ConvertTo converts a JSON value to an argument with a typetag.
+// Numbers and numeric strings can be converted to i, f and d, any value but
+// objects to s, base64 strings to b, and bools and the strings "true" and
+// "false" to T or F. Typed objects must have the same type, and are the only
+// way to send NaN and infinities, e.g. {"type":"f","value":"NaN"}.
+func ConvertTo(tt byte, raw json.RawMessage) (osc.Argument, error) {
+	v, err := decode(raw)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := v.(map[string]interface{}); ok {
+		arg, err := osc.ParseArgumentJSON(raw)
+		if err != nil {
+			return nil, err
+		}
+		if !sameType(tt, arg.Typetag()) {
+			return nil, errors.Errorf("expected typetag %c, got %c", tt, arg.Typetag())
+		}
+		return arg, nil
+	}
+	var (
+		s       string
+		numeric bool
+	)
+	switch x := v.(type) {
+	case json.Number:
+		s, numeric = string(x), true
+	case string:
+		s = x
+	case bool:
+		s = strconv.FormatBool(x)
+	}
+	switch tt {
+	case osc.TypetagInt:
+		if i, err := strconv.ParseInt(s, 10, 32); err == nil {
+			return osc.Int(i), nil
+		}
+		return nil, errors.Errorf("can not convert %s to an int", raw)
+	case osc.TypetagFloat:
+		if f, err := parseFinite(s, 32); err == nil {
+			return osc.Float(f), nil
+		}
+		return nil, errors.Errorf("can not convert %s to a float", raw)
+	case osc.TypetagDouble:
+		if d, err := parseFinite(s, 64); err == nil {
+			return osc.Double(d), nil
+		}
+		return nil, errors.Errorf("can not convert %s to a double", raw)
+	case osc.TypetagString:
+		return osc.String(s), nil
+	case osc.TypetagBlob:
+		if b, err := base64.StdEncoding.DecodeString(s); err == nil && !numeric {
+			return osc.Blob(b), nil
+		}
+		return nil, errors.Errorf("can not convert %s to a blob, expected a base64 string", raw)
+	case osc.TypetagTrue, osc.TypetagFalse:
+		if s == "true" || s == "false" {
+			return osc.Bool(s == "true"), nil
+		}
+		return nil, errors.Errorf("can not convert %s to a bool", raw)
+	default:
+		return nil, errors.Errorf("unsupported typetag %c", tt)
+	}
+}
+
+// parseFinite parses a float that is not NaN or infinite, which strconv.ParseFloat
+// accepts as "nan", "inf" and "infinity".
+func parseFinite(s string, bitSize int) (float64, error) {
+	f, err := strconv.ParseFloat(s, bitSize)
+	if err != nil {
+		return 0, err
+	}
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, errors.Errorf("%s is not finite", s)
+	}
+	return f, nil
+}
+
+// decode decodes a JSON value, with numbers as json.Number.
+// Arrays and null are not arguments.
+func decode(raw json.RawMessage) (interface{}, error) {
+	var (
+		v   interface{}
+		dec = json.NewDecoder(bytes.NewReader(raw))
+	)
+	dec.UseNumber()
+
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	switch v.(type) {
+	case nil:
+		return nil, errors.New("null is not an argument")
+	case []interface{}:
+		return nil, errors.New("arrays are not arguments")
+	}
+	return v, nil
+}
+
+// sameType returns true if two typetags are the same type, i.e. T and F are the same.
+func sameType(a, b byte) bool {
+	if a == osc.TypetagFalse {
+		a = osc.TypetagTrue
+	}
+	if b == osc.TypetagFalse {
+		b = osc.TypetagTrue
+	}
+	return a == b
+}
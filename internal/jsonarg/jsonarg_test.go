@@ -0,0 +1,46 @@
+package jsonarg
+
+import (
+	"testing"
+
+	"github.com/scgolang/osc"
+)
+
+func TestValues(t *testing.T) {
+	for i, testcase := range []struct {
+		Data     string
+		Expected string
+		Err      string
+	}{
+		{Data: " ", Expected: "/a"},
+		{Data: " 440 ", Expected: "/a ,i 440"},
+		{Data: `[440, 0.5, "sine", true, {"type":"d","value":0.25}]`, Expected: `/a ,ifsTd 440 0.5 "sine" true 0.25`},
+		{Data: "[1,", Err: "unexpected end of JSON input"},
+		{Data: "sine", Err: "invalid JSON"},
+	} {
+		values, err := Values([]byte(testcase.Data))
+		if testcase.Err != "" {
+			if err == nil {
+				t.Fatalf("(testcase %d) expected error, got nil", i)
+			}
+			if expected, got := testcase.Err, err.Error(); expected != got {
+				t.Fatalf("(testcase %d) expected %s, got %s", i, expected, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("(testcase %d) %s", i, err)
+		}
+		msg := osc.Message{Address: "/a"}
+		for _, raw := range values {
+			arg, err := Convert(raw)
+			if err != nil {
+				t.Fatalf("(testcase %d) %s", i, err)
+			}
+			msg.Arguments = append(msg.Arguments, arg)
+		}
+		if expected, got := testcase.Expected, osc.Format(msg); expected != got {
+			t.Fatalf("(testcase %d) expected %s, got %s", i, expected, got)
+		}
+	}
+}
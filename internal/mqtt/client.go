@@ -0,0 +1,391 @@
+package mqtt
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// MaxPacketLen is the default limit of the remaining length of the packets that are read.
+const MaxPacketLen = 1 << 20
+
+// Common errors.
+var (
+	ErrClosed = errors.New("mqtt client closed")
+)
+
+// Options configure the connection of a client.
+type Options struct {
+	// ClientID identifies the client. If it is empty the broker assigns one.
+	ClientID string
+
+	Username string
+	Password string
+
+	// KeepAlive is the interval of the pings that keep the connection alive.
+	// The connection is closed if nothing is received for one and a half times
+	// this interval. Zero disables keep alive.
+	KeepAlive time.Duration
+}
+
+// Message is an application message that a client receives.
+type Message struct {
+	Topic   string
+	Payload []byte
+	QoS     byte
+	Retain  bool
+}
+
+// Client is a connection to a broker.
+// Sessions are always clean, and messages are not sent again if the connection is lost.
+type Client struct {
+	conn      net.Conn
+	br        *bufio.Reader
+	handler   func(Message)
+	keepAlive time.Duration
+
+	wmu sync.Mutex
+
+	mu       sync.Mutex
+	nextID   uint16
+	pending  map[uint16]chan *Packet
+	received map[uint16]struct{} // PUBLISH packets with QoS 2 that were not released.
+	closed   bool
+	err      error
+	done     chan struct{}
+}
+
+// Dial connects to a broker at a TCP address. handler is called with the messages
+// of the subscriptions of the client, one at a time and in the order they are received,
+// so it should not block.
+func Dial(ctx context.Context, addr string, opts Options, handler func(Message)) (*Client, error) {
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, errors.Wrap(err, "dial")
+	}
+	c, err := Connect(ctx, conn, opts, handler)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// Connect sends a CONNECT packet over conn and waits for the broker to accept it.
+// The client owns conn after that.
+func Connect(ctx context.Context, conn net.Conn, opts Options, handler func(Message)) (*Client, error) {
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+		defer func() { _ = conn.SetDeadline(time.Time{}) }()
+	}
+	keepAlive := opts.KeepAlive / time.Second
+	if keepAlive > 0xFFFF {
+		return nil, errors.Errorf("keep alive %s is too long", opts.KeepAlive)
+	}
+	c := &Client{
+		conn:      conn,
+		br:        bufio.NewReader(conn),
+		handler:   handler,
+		keepAlive: keepAlive * time.Second,
+		pending:   map[uint16]chan *Packet{},
+		received:  map[uint16]struct{}{},
+		done:      make(chan struct{}),
+	}
+	if err := c.write(&Packet{
+		Type:         CONNECT,
+		ClientID:     opts.ClientID,
+		CleanSession: true,
+		KeepAlive:    uint16(keepAlive),
+		Username:     opts.Username,
+		Password:     opts.Password,
+	}); err != nil {
+		return nil, errors.Wrap(err, "write CONNECT")
+	}
+	ack, err := ReadPacket(c.br, MaxPacketLen)
+	if err != nil {
+		return nil, errors.Wrap(err, "read CONNACK")
+	}
+	if ack.Type != CONNACK {
+		return nil, errors.Errorf("expected CONNACK, got packet type %d", ack.Type)
+	}
+	if ack.ReturnCode != Accepted {
+		return nil, errors.Errorf("connection refused with code %d", ack.ReturnCode)
+	}
+	go c.readLoop()
+
+	if c.keepAlive > 0 {
+		go c.pingLoop()
+	}
+	return c, nil
+}
+
+// Done returns a channel that is closed when the connection ends.
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+// Err returns the error that ended the connection, or nil if it was ended by Close
+// or has not ended.
+func (c *Client) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// Close sends a DISCONNECT packet and closes the connection.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil
+	}
+	c.closed = true
+	c.mu.Unlock()
+
+	_ = c.conn.SetWriteDeadline(time.Now().Add(time.Second))
+	_ = c.write(&Packet{Type: DISCONNECT})
+	err := c.conn.Close()
+	<-c.done
+	return err
+}
+
+// Publish publishes a message. It returns when the message is written for QoS 0,
+// and when the broker acknowledges it for QoS 1 and 2.
+func (c *Client) Publish(ctx context.Context, topic string, payload []byte, qos byte, retain bool) error {
+	if err := ValidateTopic(topic); err != nil {
+		return err
+	}
+	p := &Packet{Type: PUBLISH, Topic: topic, Payload: payload, QoS: qos, Retain: retain}
+
+	if qos == 0 {
+		return c.write(p)
+	}
+	id, acks, err := c.register()
+	if err != nil {
+		return err
+	}
+	defer c.unregister(id)
+
+	p.PacketID = id
+	if err := c.write(p); err != nil {
+		return err
+	}
+	if qos == 1 {
+		_, err := c.wait(ctx, acks, PUBACK)
+		return err
+	}
+	if _, err := c.wait(ctx, acks, PUBREC); err != nil {
+		return err
+	}
+	if err := c.write(&Packet{Type: PUBREL, PacketID: id}); err != nil {
+		return err
+	}
+	_, err = c.wait(ctx, acks, PUBCOMP)
+	return err
+}
+
+// Subscribe subscribes to a topic filter and returns the QoS that the broker granted.
+func (c *Client) Subscribe(ctx context.Context, filter string, qos byte) (byte, error) {
+	if err := ValidateFilter(filter); err != nil {
+		return 0, err
+	}
+	if qos > 2 {
+		return 0, errors.Errorf("invalid QoS %d", qos)
+	}
+	id, acks, err := c.register()
+	if err != nil {
+		return 0, err
+	}
+	defer c.unregister(id)
+
+	if err := c.write(&Packet{
+		Type:          SUBSCRIBE,
+		PacketID:      id,
+		Subscriptions: []Subscription{{Filter: filter, QoS: qos}},
+	}); err != nil {
+		return 0, err
+	}
+	ack, err := c.wait(ctx, acks, SUBACK)
+	if err != nil {
+		return 0, err
+	}
+	if len(ack.ReturnCodes) != 1 {
+		return 0, errors.Errorf("expected 1 return code, got %d", len(ack.ReturnCodes))
+	}
+	if ack.ReturnCodes[0] == SubscribeFailure {
+		return 0, errors.Errorf("subscription to %s refused", filter)
+	}
+	return ack.ReturnCodes[0], nil
+}
+
+// register returns a free packet identifier and the channel of the packets
+// that acknowledge it.
+func (c *Client) register() (uint16, chan *Packet, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return 0, nil, ErrClosed
+	}
+	if len(c.pending) == 0xFFFF {
+		return 0, nil, errors.New("no free packet identifier")
+	}
+	for {
+		if c.nextID++; c.nextID == 0 {
+			continue
+		}
+		if _, ok := c.pending[c.nextID]; !ok {
+			break
+		}
+	}
+	acks := make(chan *Packet, 1)
+	c.pending[c.nextID] = acks
+	return c.nextID, acks, nil
+}
+
+// unregister frees a packet identifier.
+func (c *Client) unregister(id uint16) {
+	c.mu.Lock()
+	delete(c.pending, id)
+	c.mu.Unlock()
+}
+
+// wait waits for a packet of a type on acks.
+func (c *Client) wait(ctx context.Context, acks chan *Packet, typ byte) (*Packet, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-c.done:
+		if err := c.Err(); err != nil {
+			return nil, err
+		}
+		return nil, ErrClosed
+	case p := <-acks:
+		if p.Type != typ {
+			return nil, errors.Errorf("expected packet type %d, got %d", typ, p.Type)
+		}
+		return p, nil
+	}
+}
+
+// write writes a packet.
+func (c *Client) write(p *Packet) error {
+	data, err := p.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+
+	_, err = c.conn.Write(data)
+	return err
+}
+
+// readLoop reads packets until the connection fails or is closed.
+func (c *Client) readLoop() {
+	err := c.read()
+
+	c.mu.Lock()
+	if !c.closed {
+		c.closed, c.err = true, err
+	}
+	c.mu.Unlock()
+
+	_ = c.conn.Close()
+	close(c.done)
+}
+
+// read reads packets and handles them.
+func (c *Client) read() error {
+	for {
+		if c.keepAlive > 0 {
+			_ = c.conn.SetReadDeadline(time.Now().Add(c.keepAlive * 3 / 2))
+		}
+		p, err := ReadPacket(c.br, MaxPacketLen)
+		if err != nil {
+			return err
+		}
+		switch p.Type {
+		case PUBLISH:
+			if err := c.handlePublish(p); err != nil {
+				return err
+			}
+		case PUBREL:
+			c.mu.Lock()
+			delete(c.received, p.PacketID)
+			c.mu.Unlock()
+
+			if err := c.write(&Packet{Type: PUBCOMP, PacketID: p.PacketID}); err != nil {
+				return err
+			}
+		case PUBACK, PUBREC, PUBCOMP, SUBACK, UNSUBACK:
+			c.mu.Lock()
+			acks, ok := c.pending[p.PacketID]
+			c.mu.Unlock()
+
+			if ok {
+				select {
+				case acks <- p:
+				default:
+				}
+			}
+		case PINGRESP:
+		default:
+			return errors.Errorf("unexpected packet type %d", p.Type)
+		}
+	}
+}
+
+// handlePublish delivers a message and acknowledges it.
+// Messages with QoS 2 are delivered once, even if the broker sends them again
+// before it releases them.
+func (c *Client) handlePublish(p *Packet) error {
+	msg := Message{Topic: p.Topic, Payload: p.Payload, QoS: p.QoS, Retain: p.Retain}
+
+	switch p.QoS {
+	case 0:
+		c.deliver(msg)
+		return nil
+	case 1:
+		c.deliver(msg)
+		return c.write(&Packet{Type: PUBACK, PacketID: p.PacketID})
+	default:
+		c.mu.Lock()
+		_, dup := c.received[p.PacketID]
+		c.received[p.PacketID] = struct{}{}
+		c.mu.Unlock()
+
+		if !dup {
+			c.deliver(msg)
+		}
+		return c.write(&Packet{Type: PUBREC, PacketID: p.PacketID})
+	}
+}
+
+// deliver calls the handler of the client.
+func (c *Client) deliver(msg Message) {
+	if c.handler != nil {
+		c.handler(msg)
+	}
+}
+
+// pingLoop sends a PINGREQ packet every keep alive interval until the connection ends.
+func (c *Client) pingLoop() {
+	ticker := time.NewTicker(c.keepAlive)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+			if err := c.write(&Packet{Type: PINGREQ}); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,496 @@
+// Package mqtt implements the parts of MQTT 3.1.1 that the packages of this module need:
+// the control packets, and a client that publishes and subscribes with QoS 0, 1 and 2.
+// Wills and persistent sessions are not supported.
+package mqtt
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Packet types.
+const (
+	CONNECT     byte = 1
+	CONNACK     byte = 2
+	PUBLISH     byte = 3
+	PUBACK      byte = 4
+	PUBREC      byte = 5
+	PUBREL      byte = 6
+	PUBCOMP     byte = 7
+	SUBSCRIBE   byte = 8
+	SUBACK      byte = 9
+	UNSUBSCRIBE byte = 10
+	UNSUBACK    byte = 11
+	PINGREQ     byte = 12
+	PINGRESP    byte = 13
+	DISCONNECT  byte = 14
+)
+
+// Return codes of CONNACK packets.
+const (
+	Accepted                   byte = 0
+	RefusedProtocolVersion     byte = 1
+	RefusedIdentifierRejected  byte = 2
+	RefusedServerUnavailable   byte = 3
+	RefusedBadUsernamePassword byte = 4
+	RefusedNotAuthorized       byte = 5
+)
+
+// SubscribeFailure is the return code of SUBACK packets for filters that are not subscribed.
+const SubscribeFailure byte = 0x80
+
+const (
+	protocolName       = "MQTT"
+	protocolLevel byte = 4
+
+	// maxRemainingLength is the largest length that fits in 4 bytes.
+	maxRemainingLength = 268435455
+)
+
+// Flags of CONNECT packets.
+const (
+	flagUsername     byte = 0x80
+	flagPassword     byte = 0x40
+	flagWillRetain   byte = 0x20
+	flagWillQoS      byte = 0x18
+	flagWill         byte = 0x04
+	flagCleanSession byte = 0x02
+	flagReserved     byte = 0x01
+)
+
+// Flags of the first byte of packets.
+const (
+	flagDup    byte = 0x08
+	flagRetain byte = 0x01
+
+	// flagsRequired are the flags that PUBREL, SUBSCRIBE and UNSUBSCRIBE packets must have.
+	flagsRequired byte = 0x02
+)
+
+// Common errors.
+var (
+	ErrMalformed = errors.New("malformed packet")
+)
+
+// Subscription is a topic filter of a SUBSCRIBE packet.
+type Subscription struct {
+	Filter string
+	QoS    byte
+}
+
+// Packet is an MQTT control packet.
+// Which fields are used depends on the type of the packet.
+type Packet struct {
+	Type byte
+
+	// PacketID identifies PUBLISH packets with a QoS greater than 0,
+	// and the packets that acknowledge them, and SUBSCRIBE and UNSUBSCRIBE packets
+	// and the packets that acknowledge them.
+	PacketID uint16
+
+	// Fields of PUBLISH packets.
+	Topic   string
+	Payload []byte
+	QoS     byte
+	Retain  bool
+	Dup     bool
+
+	// Fields of CONNECT packets.
+	// Wills are skipped when CONNECT packets are read.
+	ClientID     string
+	CleanSession bool
+	KeepAlive    uint16 // In seconds.
+	Username     string
+	Password     string
+
+	// Fields of CONNACK packets.
+	SessionPresent bool
+	ReturnCode     byte
+
+	// Subscriptions are the filters of SUBSCRIBE packets,
+	// only the filters are used by UNSUBSCRIBE packets.
+	Subscriptions []Subscription
+
+	// ReturnCodes are the granted QoS of SUBACK packets, or SubscribeFailure.
+	ReturnCodes []byte
+}
+
+// MarshalBinary returns the bytes of the packet.
+func (p *Packet) MarshalBinary() ([]byte, error) {
+	var (
+		flags byte
+		body  []byte
+		err   error
+	)
+	switch p.Type {
+	case CONNECT:
+		body, err = p.appendConnect(nil)
+	case CONNACK:
+		var ack byte
+		if p.SessionPresent {
+			ack = 1
+		}
+		body = []byte{ack, p.ReturnCode}
+	case PUBLISH:
+		if p.QoS > 2 {
+			return nil, errors.Errorf("invalid QoS %d", p.QoS)
+		}
+		flags = p.QoS << 1
+		if p.Dup {
+			flags |= flagDup
+		}
+		if p.Retain {
+			flags |= flagRetain
+		}
+		if body, err = appendString(nil, p.Topic); err != nil {
+			return nil, err
+		}
+		if p.QoS > 0 {
+			body = binary.BigEndian.AppendUint16(body, p.PacketID)
+		}
+		body = append(body, p.Payload...)
+	case PUBACK, PUBREC, PUBCOMP, UNSUBACK:
+		body = binary.BigEndian.AppendUint16(nil, p.PacketID)
+	case PUBREL:
+		flags = flagsRequired
+		body = binary.BigEndian.AppendUint16(nil, p.PacketID)
+	case SUBSCRIBE, UNSUBSCRIBE:
+		flags = flagsRequired
+		body = binary.BigEndian.AppendUint16(nil, p.PacketID)
+		for _, sub := range p.Subscriptions {
+			if body, err = appendString(body, sub.Filter); err != nil {
+				return nil, err
+			}
+			if p.Type == SUBSCRIBE {
+				body = append(body, sub.QoS)
+			}
+		}
+	case SUBACK:
+		body = binary.BigEndian.AppendUint16(nil, p.PacketID)
+		body = append(body, p.ReturnCodes...)
+	case PINGREQ, PINGRESP, DISCONNECT:
+	default:
+		return nil, errors.Errorf("unknown packet type %d", p.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > maxRemainingLength {
+		return nil, errors.New("packet too long")
+	}
+	data := []byte{p.Type<<4 | flags}
+	for n := len(body); ; {
+		b := byte(n % 128)
+		if n /= 128; n > 0 {
+			b |= 0x80
+		}
+		data = append(data, b)
+		if n == 0 {
+			break
+		}
+	}
+	return append(data, body...), nil
+}
+
+// appendConnect appends the variable header and the payload of a CONNECT packet.
+func (p *Packet) appendConnect(body []byte) ([]byte, error) {
+	var flags byte
+	if p.CleanSession {
+		flags |= flagCleanSession
+	}
+	if p.Username != "" {
+		flags |= flagUsername
+	}
+	if p.Password != "" {
+		flags |= flagPassword
+	}
+	body, _ = appendString(body, protocolName)
+	body = append(body, protocolLevel, flags)
+	body = binary.BigEndian.AppendUint16(body, p.KeepAlive)
+
+	body, err := appendString(body, p.ClientID)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range []string{p.Username, p.Password} {
+		if s == "" {
+			continue
+		}
+		if body, err = appendString(body, s); err != nil {
+			return nil, err
+		}
+	}
+	return body, nil
+}
+
+// appendString appends a string with its 16-bit length.
+func appendString(b []byte, s string) ([]byte, error) {
+	if len(s) > 0xFFFF {
+		return nil, errors.Errorf("string of %d bytes is too long", len(s))
+	}
+	b = binary.BigEndian.AppendUint16(b, uint16(len(s)))
+	return append(b, s...), nil
+}
+
+// ReadPacket reads a packet. Packets whose remaining length is more than maxLen
+// are not read, and are an error.
+func ReadPacket(r *bufio.Reader, maxLen int) (*Packet, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	var n, shift int
+	for i := 0; ; i++ {
+		if i == 4 {
+			return nil, errors.Wrap(ErrMalformed, "remaining length is longer than 4 bytes")
+		}
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, noEOF(err)
+		}
+		n |= int(b&0x7F) << shift
+		if b&0x80 == 0 {
+			break
+		}
+		shift += 7
+	}
+	if n > maxLen {
+		return nil, errors.Errorf("packet of %d bytes is longer than %d bytes", n, maxLen)
+	}
+	body := make([]byte, n)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, noEOF(err)
+	}
+	p, err := parsePacket(header, body)
+	if err != nil {
+		return nil, errors.Wrapf(err, "packet type %d", header>>4)
+	}
+	return p, nil
+}
+
+// noEOF turns EOF into io.ErrUnexpectedEOF, for packets that end early.
+func noEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// parsePacket parses a packet from its first byte and the bytes after its remaining length.
+func parsePacket(header byte, body []byte) (*Packet, error) {
+	var (
+		p     = &Packet{Type: header >> 4}
+		flags = header & 0x0F
+		r     = reader{data: body}
+	)
+	switch p.Type {
+	case PUBLISH:
+		p.Dup = flags&flagDup != 0
+		p.Retain = flags&flagRetain != 0
+		if p.QoS = flags >> 1 & 3; p.QoS > 2 {
+			return nil, errors.Wrap(ErrMalformed, "invalid QoS 3")
+		}
+	case PUBREL, SUBSCRIBE, UNSUBSCRIBE:
+		if flags != flagsRequired {
+			return nil, errors.Wrapf(ErrMalformed, "invalid flags %#x", flags)
+		}
+	default:
+		if flags != 0 {
+			return nil, errors.Wrapf(ErrMalformed, "invalid flags %#x", flags)
+		}
+	}
+	switch p.Type {
+	case CONNECT:
+		if err := p.parseConnect(&r); err != nil {
+			return nil, err
+		}
+	case CONNACK:
+		ack := r.bytes(2)
+		if r.err == nil {
+			p.SessionPresent = ack[0]&1 != 0
+			p.ReturnCode = ack[1]
+		}
+	case PUBLISH:
+		p.Topic = r.string()
+		if p.QoS > 0 {
+			p.PacketID = r.uint16()
+		}
+		p.Payload = r.rest()
+	case PUBACK, PUBREC, PUBREL, PUBCOMP, UNSUBACK:
+		p.PacketID = r.uint16()
+	case SUBSCRIBE, UNSUBSCRIBE:
+		p.PacketID = r.uint16()
+		for r.err == nil && len(r.data) > 0 {
+			sub := Subscription{Filter: r.string()}
+			if p.Type == SUBSCRIBE {
+				if b := r.bytes(1); r.err == nil {
+					sub.QoS = b[0]
+				}
+			}
+			p.Subscriptions = append(p.Subscriptions, sub)
+		}
+		if r.err == nil && len(p.Subscriptions) == 0 {
+			return nil, errors.Wrap(ErrMalformed, "no topic filters")
+		}
+	case SUBACK:
+		p.PacketID = r.uint16()
+		p.ReturnCodes = r.rest()
+	case PINGREQ, PINGRESP, DISCONNECT:
+	default:
+		return nil, errors.Errorf("unknown packet type %d", p.Type)
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	if len(r.data) > 0 {
+		return nil, errors.Wrapf(ErrMalformed, "%d extra bytes", len(r.data))
+	}
+	return p, nil
+}
+
+// parseConnect parses the variable header and the payload of a CONNECT packet.
+func (p *Packet) parseConnect(r *reader) error {
+	var (
+		name  = r.string()
+		level = r.bytes(1)
+		flags = r.bytes(1)
+	)
+	p.KeepAlive = r.uint16()
+	if r.err != nil {
+		return r.err
+	}
+	if name != protocolName {
+		return errors.Errorf("unsupported protocol %q", name)
+	}
+	if level[0] != protocolLevel {
+		return errors.Errorf("unsupported protocol level %d", level[0])
+	}
+	if flags[0]&flagReserved != 0 {
+		return errors.Wrap(ErrMalformed, "reserved connect flag is set")
+	}
+	p.CleanSession = flags[0]&flagCleanSession != 0
+	p.ClientID = r.string()
+
+	if flags[0]&flagWill != 0 {
+		_, _ = r.string(), r.string()
+	} else if flags[0]&(flagWillQoS|flagWillRetain) != 0 {
+		return errors.Wrap(ErrMalformed, "will flags without a will")
+	}
+	if flags[0]&flagUsername != 0 {
+		p.Username = r.string()
+	}
+	if flags[0]&flagPassword != 0 {
+		p.Password = r.string()
+	}
+	return r.err
+}
+
+// reader reads the fields of a packet.
+// After the first error every read returns the zero value.
+type reader struct {
+	data []byte
+	err  error
+}
+
+// bytes reads n bytes.
+func (r *reader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if len(r.data) < n {
+		r.err = errors.Wrap(ErrMalformed, "packet is too short")
+		return nil
+	}
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b
+}
+
+// uint16 reads a 16-bit integer.
+func (r *reader) uint16() uint16 {
+	b := r.bytes(2)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint16(b)
+}
+
+// string reads a string with its 16-bit length.
+func (r *reader) string() string {
+	n := r.uint16()
+	return string(r.bytes(int(n)))
+}
+
+// rest reads the bytes that are left.
+func (r *reader) rest() []byte {
+	if r.err != nil {
+		return nil
+	}
+	b := r.data
+	r.data = nil
+	return b
+}
+
+// ValidateTopic returns an error if a topic name can not be published to.
+func ValidateTopic(topic string) error {
+	switch {
+	case topic == "":
+		return errors.New("empty topic")
+	case len(topic) > 0xFFFF:
+		return errors.New("topic is too long")
+	case strings.ContainsAny(topic, "+#\x00"):
+		return errors.Errorf("topic %s contains a wildcard or a null character", strconv.Quote(topic))
+	}
+	return nil
+}
+
+// ValidateFilter returns an error if a topic filter can not be subscribed to.
+func ValidateFilter(filter string) error {
+	switch {
+	case filter == "":
+		return errors.New("empty topic filter")
+	case len(filter) > 0xFFFF:
+		return errors.New("topic filter is too long")
+	case strings.ContainsRune(filter, 0):
+		return errors.Errorf("topic filter %s contains a null character", strconv.Quote(filter))
+	}
+	levels := strings.Split(filter, "/")
+	for i, level := range levels {
+		if level == "#" && i == len(levels)-1 || level == "+" {
+			continue
+		}
+		if strings.ContainsAny(level, "+#") {
+			return errors.Errorf("invalid wildcard in topic filter %s", strconv.Quote(filter))
+		}
+	}
+	return nil
+}
+
+// Match returns true if a topic filter matches a topic name.
+// Filters that start with a wildcard do not match topics that start with $.
+func Match(filter, topic string) bool {
+	if strings.HasPrefix(topic, "$") && (strings.HasPrefix(filter, "+") || strings.HasPrefix(filter, "#")) {
+		return false
+	}
+	var (
+		fl = strings.Split(filter, "/")
+		tl = strings.Split(topic, "/")
+	)
+	for i, level := range fl {
+		if level == "#" {
+			return true
+		}
+		if i == len(tl) {
+			return false
+		}
+		if level != "+" && level != tl[i] {
+			return false
+		}
+	}
+	return len(fl) == len(tl)
+}
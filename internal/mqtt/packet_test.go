@@ -0,0 +1,123 @@
+package mqtt
+
+import (
+	"bufio"
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestPacketRoundTrip(t *testing.T) {
+	for i, testcase := range []Packet{
+		{Type: CONNECT, ClientID: "bridge", CleanSession: true, KeepAlive: 30},
+		{Type: CONNECT, Username: "user", Password: "secret"},
+		{Type: CONNACK, SessionPresent: true, ReturnCode: RefusedNotAuthorized},
+		{Type: PUBLISH, Topic: "sensors/temperature", Payload: []byte("21.5")},
+		{Type: PUBLISH, Topic: "a/b", Payload: bytes.Repeat([]byte{1}, 300), QoS: 2, PacketID: 7, Retain: true, Dup: true},
+		{Type: PUBACK, PacketID: 1},
+		{Type: PUBREC, PacketID: 2},
+		{Type: PUBREL, PacketID: 3},
+		{Type: PUBCOMP, PacketID: 4},
+		{Type: SUBSCRIBE, PacketID: 5, Subscriptions: []Subscription{{Filter: "a/#", QoS: 1}, {Filter: "+/b"}}},
+		{Type: SUBACK, PacketID: 5, ReturnCodes: []byte{1, SubscribeFailure}},
+		{Type: UNSUBSCRIBE, PacketID: 6, Subscriptions: []Subscription{{Filter: "a/#"}}},
+		{Type: UNSUBACK, PacketID: 6},
+		{Type: PINGREQ},
+		{Type: PINGRESP},
+		{Type: DISCONNECT},
+	} {
+		data, err := testcase.MarshalBinary()
+		if err != nil {
+			t.Fatalf("(testcase %d) %s", i, err)
+		}
+		p, err := ReadPacket(bufio.NewReader(bytes.NewReader(data)), MaxPacketLen)
+		if err != nil {
+			t.Fatalf("(testcase %d) %s", i, err)
+		}
+		if expected, got := testcase, *p; !reflect.DeepEqual(expected, got) {
+			t.Fatalf("(testcase %d) expected %+v, got %+v", i, expected, got)
+		}
+	}
+}
+
+func TestPacketBytes(t *testing.T) {
+	p := Packet{Type: PUBLISH, Topic: "a/b", Payload: []byte{0xFF}, QoS: 1, PacketID: 10}
+
+	data, err := p.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := []byte{0x32, 8, 0, 3, 'a', '/', 'b', 0, 10, 0xFF}, data; !bytes.Equal(expected, got) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+}
+
+func TestReadPacketErrors(t *testing.T) {
+	for i, testcase := range []struct {
+		Data []byte
+		Err  string
+	}{
+		{Data: []byte{0x30}, Err: "unexpected EOF"},
+		{Data: []byte{0x30, 0xFF, 0xFF, 0xFF, 0xFF}, Err: "remaining length is longer than 4 bytes: malformed packet"},
+		{Data: []byte{0x30, 0x80, 0x80, 0x80, 0x01}, Err: "packet of 2097152 bytes is longer than 1048576 bytes"},
+		{Data: []byte{0x30, 2, 0}, Err: "unexpected EOF"},
+		{Data: []byte{0x36, 0}, Err: "packet type 3: invalid QoS 3: malformed packet"},
+		{Data: []byte{0x80, 3, 0, 1, 0}, Err: "packet type 8: invalid flags 0x0: malformed packet"},
+		{Data: []byte{0x82, 2, 0, 1}, Err: "packet type 8: no topic filters: malformed packet"},
+		{Data: []byte{0x40, 3, 0, 1, 0}, Err: "packet type 4: 1 extra bytes: malformed packet"},
+		{Data: []byte{0x30, 3, 0, 5, 'a'}, Err: "packet type 3: packet is too short: malformed packet"},
+		{Data: []byte{0xF0, 0}, Err: "packet type 15: unknown packet type 15"},
+		{Data: []byte{0x10, 10, 0, 4, 'M', 'Q', 'T', 'T', 3, 0, 0, 0}, Err: "packet type 1: unsupported protocol level 3"},
+	} {
+		_, err := ReadPacket(bufio.NewReader(bytes.NewReader(testcase.Data)), MaxPacketLen)
+		if err == nil {
+			t.Fatalf("(testcase %d) expected error, got nil", i)
+		}
+		if expected, got := testcase.Err, err.Error(); expected != got {
+			t.Fatalf("(testcase %d) expected %s, got %s", i, expected, got)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	for i, testcase := range []struct {
+		Filter   string
+		Topic    string
+		Expected bool
+	}{
+		{Filter: "a/b", Topic: "a/b", Expected: true},
+		{Filter: "a/b", Topic: "a/c", Expected: false},
+		{Filter: "a/+", Topic: "a/b", Expected: true},
+		{Filter: "a/+", Topic: "a/b/c", Expected: false},
+		{Filter: "+/+", Topic: "a/", Expected: true},
+		{Filter: "a/#", Topic: "a", Expected: true},
+		{Filter: "a/#", Topic: "a/b/c", Expected: true},
+		{Filter: "a/#", Topic: "b/c", Expected: false},
+		{Filter: "#", Topic: "a/b", Expected: true},
+		{Filter: "#", Topic: "$SYS/uptime", Expected: false},
+		{Filter: "$SYS/#", Topic: "$SYS/uptime", Expected: true},
+	} {
+		if expected, got := testcase.Expected, Match(testcase.Filter, testcase.Topic); expected != got {
+			t.Fatalf("(testcase %d) expected %t, got %t", i, expected, got)
+		}
+	}
+}
+
+func TestValidateFilter(t *testing.T) {
+	for i, testcase := range []struct {
+		Filter string
+		Valid  bool
+	}{
+		{Filter: "a/b", Valid: true},
+		{Filter: "+/b/#", Valid: true},
+		{Filter: "#", Valid: true},
+		{Filter: "", Valid: false},
+		{Filter: "a/#/b", Valid: false},
+		{Filter: "a+/b", Valid: false},
+		{Filter: "a/b#", Valid: false},
+	} {
+		if expected, got := testcase.Valid, ValidateFilter(testcase.Filter) == nil; expected != got {
+			t.Fatalf("(testcase %d) expected %t, got %t", i, expected, got)
+		}
+	}
+}
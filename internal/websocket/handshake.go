@@ -0,0 +1,207 @@
+package websocket
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// acceptGUID is appended to the key of the client to compute the accept header.
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// IsUpgrade returns true if the request asks to switch to the WebSocket protocol.
+func IsUpgrade(r *http.Request) bool {
+	return headerContains(r.Header, "Connection", "upgrade") && headerContains(r.Header, "Upgrade", "websocket")
+}
+
+// Upgrade performs the opening handshake of a server and takes over the connection
+// of the request. If the request is not a valid WebSocket handshake,
+// Upgrade replies with an error and returns it.
+//
+// checkOrigin returns true if the request may be upgraded, and is meant to
+// check its Origin header. Without it, only requests that come from the same
+// host or that have no Origin header, like the ones of clients that are not
+// browsers, are upgraded, see SameOrigin. This keeps web pages of other sites
+// from connecting, since browsers don't restrict WebSocket connections.
+func Upgrade(w http.ResponseWriter, r *http.Request, checkOrigin func(r *http.Request) bool) (*Conn, error) {
+	if err := checkUpgrade(r); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, err
+	}
+	if checkOrigin == nil {
+		checkOrigin = SameOrigin
+	}
+	if !checkOrigin(r) {
+		err := errors.Errorf("origin %s is not allowed", r.Header.Get("Origin"))
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return nil, err
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		err := errors.New("response writer does not support hijacking")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil, err
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, errors.Wrap(err, "hijack connection")
+	}
+	response := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + acceptKey(r.Header.Get("Sec-WebSocket-Key")) + "\r\n\r\n"
+
+	if _, err := conn.Write([]byte(response)); err != nil {
+		_ = conn.Close()
+		return nil, errors.Wrap(err, "write handshake response")
+	}
+	return newConn(conn, rw.Reader, false), nil
+}
+
+// SameOrigin returns true if the request has no Origin header,
+// or if the host of its Origin is the host of the request.
+func SameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host)
+}
+
+// checkUpgrade checks the handshake request of a client.
+func checkUpgrade(r *http.Request) error {
+	if r.Method != http.MethodGet {
+		return errors.New("websocket handshake must be a GET request")
+	}
+	if !IsUpgrade(r) {
+		return errors.New("missing websocket upgrade headers")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		return errors.New("unsupported websocket version")
+	}
+	if key, err := base64.StdEncoding.DecodeString(r.Header.Get("Sec-WebSocket-Key")); err != nil || len(key) != 16 {
+		return errors.New("invalid websocket key")
+	}
+	return nil
+}
+
+// Dial opens a WebSocket connection to a ws:// or wss:// URL.
+func Dial(ctx context.Context, rawURL string) (*Conn, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse url")
+	}
+	var (
+		host        = u.Host
+		useTLS      bool
+		defaultPort string
+	)
+	switch u.Scheme {
+	case "ws":
+		defaultPort = "80"
+	case "wss":
+		defaultPort, useTLS = "443", true
+	default:
+		return nil, errors.Errorf("unsupported scheme %q", u.Scheme)
+	}
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), defaultPort)
+	}
+	var conn net.Conn
+	if useTLS {
+		conn, err = (&tls.Dialer{Config: &tls.Config{ServerName: u.Hostname()}}).DialContext(ctx, "tcp", host)
+	} else {
+		conn, err = (&net.Dialer{}).DialContext(ctx, "tcp", host)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "dial")
+	}
+	c, err := clientHandshake(ctx, conn, u)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// clientHandshake performs the opening handshake of a client on conn.
+func clientHandshake(ctx context.Context, conn net.Conn, u *url.URL) (*Conn, error) {
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+		defer func() { _ = conn.SetDeadline(time.Time{}) }()
+	}
+	var nonce [16]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		return nil, errors.Wrap(err, "generate key")
+	}
+	key := base64.StdEncoding.EncodeToString(nonce[:])
+
+	req := &http.Request{
+		Method:     http.MethodGet,
+		URL:        &url.URL{Path: u.Path, RawPath: u.RawPath, RawQuery: u.RawQuery},
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header: http.Header{
+			"Upgrade":               {"websocket"},
+			"Connection":            {"Upgrade"},
+			"Sec-WebSocket-Key":     {key},
+			"Sec-WebSocket-Version": {"13"},
+		},
+		Host: u.Host,
+	}
+	if req.URL.Path == "" {
+		req.URL.Path = "/"
+	}
+	if err := req.Write(conn); err != nil {
+		return nil, errors.Wrap(err, "write handshake request")
+	}
+	br := bufio.NewReader(conn)
+
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		return nil, errors.Wrap(err, "read handshake response")
+	}
+	_ = resp.Body.Close()
+
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		return nil, errors.Errorf("handshake failed with status %s", resp.Status)
+	}
+	if resp.Header.Get("Sec-WebSocket-Accept") != acceptKey(key) {
+		return nil, errors.New("invalid Sec-WebSocket-Accept header")
+	}
+	return newConn(conn, br, true), nil
+}
+
+// acceptKey returns the Sec-WebSocket-Accept header for the key of a client.
+func acceptKey(key string) string {
+	h := sha1.Sum([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(h[:])
+}
+
+// headerContains returns true if a comma separated header contains the token,
+// ignoring case.
+func headerContains(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
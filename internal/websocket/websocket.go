@@ -0,0 +1,328 @@
+// Package websocket implements the parts of the WebSocket protocol, RFC 6455,
+// that the packages of this module need: the opening handshake for servers
+// and clients, and text and binary messages.
+// Extensions and subprotocols are not supported.
+package websocket
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// MessageType is the type of a message.
+type MessageType byte
+
+// Message types.
+const (
+	TextMessage   MessageType = 1
+	BinaryMessage MessageType = 2
+)
+
+// Opcodes of frames, besides the message types.
+const (
+	opContinuation byte = 0
+	opClose        byte = 8
+	opPing         byte = 9
+	opPong         byte = 10
+)
+
+// Close codes.
+const (
+	CloseNormal        = 1000
+	CloseProtocolError = 1002
+	CloseMessageTooBig = 1009
+
+	// closeNoStatus is reported when a close frame has no code, it is never sent.
+	closeNoStatus = 1005
+)
+
+const (
+	maxControlPayload    = 125
+	defaultMaxMessageLen = 1 << 20
+)
+
+// Common errors.
+var (
+	ErrClosed = errors.New("websocket closed")
+)
+
+// CloseError is returned by ReadMessage when the peer closes the connection.
+type CloseError struct {
+	Code   int
+	Reason string
+}
+
+// Error returns the close code and the reason.
+func (e *CloseError) Error() string {
+	if e.Reason == "" {
+		return "websocket closed with code " + strconv.Itoa(e.Code)
+	}
+	return "websocket closed with code " + strconv.Itoa(e.Code) + ": " + e.Reason
+}
+
+// Conn is a WebSocket connection.
+// ReadMessage must not be called concurrently, WriteMessage can.
+type Conn struct {
+	conn   net.Conn
+	br     *bufio.Reader
+	client bool // Clients mask the frames they send.
+
+	maxMessageLen int64
+
+	wmu       sync.Mutex
+	closeOnce sync.Once
+	closeSent bool
+}
+
+// newConn returns a connection that reads from br, which reads from conn.
+func newConn(conn net.Conn, br *bufio.Reader, client bool) *Conn {
+	if br == nil {
+		br = bufio.NewReader(conn)
+	}
+	return &Conn{conn: conn, br: br, client: client, maxMessageLen: defaultMaxMessageLen}
+}
+
+// LocalAddr returns the local network address.
+func (c *Conn) LocalAddr() net.Addr {
+	return c.conn.LocalAddr()
+}
+
+// RemoteAddr returns the remote network address.
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+// SetMaxMessageLen sets the maximum length of the messages that are read.
+// Longer messages close the connection. The default is 1 MiB.
+func (c *Conn) SetMaxMessageLen(n int64) {
+	c.maxMessageLen = n
+}
+
+// SetReadDeadline sets the deadline of reads.
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	return c.conn.SetReadDeadline(t)
+}
+
+// SetWriteDeadline sets the deadline of writes.
+func (c *Conn) SetWriteDeadline(t time.Time) error {
+	return c.conn.SetWriteDeadline(t)
+}
+
+// ReadMessage reads the next text or binary message.
+// Pings are answered while waiting for it.
+// If the peer closes the connection the error is a *CloseError.
+func (c *Conn) ReadMessage() (MessageType, []byte, error) {
+	var (
+		typ  MessageType
+		data []byte
+	)
+	for {
+		fin, op, payload, err := c.readFrame()
+		if err != nil {
+			return 0, nil, err
+		}
+		switch op {
+		case opPing:
+			if err := c.writeFrame(opPong, payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case opPong:
+			continue
+		case opClose:
+			return 0, nil, c.handleClose(payload)
+		case opContinuation:
+			if typ == 0 {
+				return 0, nil, c.fail(CloseProtocolError, "unexpected continuation frame")
+			}
+		case byte(TextMessage), byte(BinaryMessage):
+			if typ != 0 {
+				return 0, nil, c.fail(CloseProtocolError, "expected a continuation frame")
+			}
+			typ = MessageType(op)
+		default:
+			return 0, nil, c.fail(CloseProtocolError, "unknown opcode "+strconv.Itoa(int(op)))
+		}
+		if int64(len(data)+len(payload)) > c.maxMessageLen {
+			return 0, nil, c.fail(CloseMessageTooBig, "message too big")
+		}
+		data = append(data, payload...)
+
+		if fin {
+			return typ, data, nil
+		}
+	}
+}
+
+// WriteMessage writes a text or binary message in a single frame.
+func (c *Conn) WriteMessage(typ MessageType, data []byte) error {
+	if typ != TextMessage && typ != BinaryMessage {
+		return errors.Errorf("invalid message type %d", typ)
+	}
+	return c.writeFrame(byte(typ), data)
+}
+
+// Close sends a close frame, if none was sent, and closes the connection.
+func (c *Conn) Close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		_ = c.conn.SetWriteDeadline(time.Now().Add(time.Second))
+		_ = c.writeClose(CloseNormal, "")
+		err = c.conn.Close()
+	})
+	return err
+}
+
+// handleClose answers a close frame from the peer and returns the error for ReadMessage.
+func (c *Conn) handleClose(payload []byte) error {
+	ce := &CloseError{Code: closeNoStatus}
+	if len(payload) >= 2 {
+		ce.Code = int(binary.BigEndian.Uint16(payload))
+		ce.Reason = string(payload[2:])
+	}
+	_ = c.writeClose(ce.Code, "")
+	_ = c.conn.Close()
+	return ce
+}
+
+// fail closes the connection because of a protocol error and returns the error.
+func (c *Conn) fail(code int, reason string) error {
+	_ = c.writeClose(code, reason)
+	_ = c.conn.Close()
+	return errors.New("websocket: " + reason)
+}
+
+// writeClose writes a close frame, once.
+func (c *Conn) writeClose(code int, reason string) error {
+	var payload []byte
+	if code != closeNoStatus {
+		payload = append(binary.BigEndian.AppendUint16(nil, uint16(code)), reason...)
+	}
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+
+	if c.closeSent {
+		return nil
+	}
+	c.closeSent = true
+	return c.write(opClose, payload)
+}
+
+// readFrame reads a frame and unmasks its payload.
+func (c *Conn) readFrame() (fin bool, op byte, payload []byte, err error) {
+	var header [2]byte
+	if _, err := io.ReadFull(c.br, header[:]); err != nil {
+		return false, 0, nil, err
+	}
+	fin, op = header[0]&0x80 != 0, header[0]&0x0f
+
+	if header[0]&0x70 != 0 {
+		return false, 0, nil, c.fail(CloseProtocolError, "reserved bits are set")
+	}
+	if masked := header[1]&0x80 != 0; masked == c.client {
+		return false, 0, nil, c.fail(CloseProtocolError, "invalid masking")
+	}
+	length := int64(header[1] & 0x7f)
+
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = int64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = int64(binary.BigEndian.Uint64(ext[:]) & (1<<63 - 1))
+	}
+	if op >= opClose && (length > maxControlPayload || !fin) {
+		return false, 0, nil, c.fail(CloseProtocolError, "invalid control frame")
+	}
+	if length > c.maxMessageLen {
+		return false, 0, nil, c.fail(CloseMessageTooBig, "message too big")
+	}
+	var mask [4]byte
+	if !c.client {
+		if _, err := io.ReadFull(c.br, mask[:]); err != nil {
+			return false, 0, nil, err
+		}
+	}
+	payload = make([]byte, length)
+	if _, err := io.ReadFull(c.br, payload); err != nil {
+		return false, 0, nil, err
+	}
+	if !c.client {
+		maskBytes(mask, payload)
+	}
+	return fin, op, payload, nil
+}
+
+// writeFrame writes a frame, unless a close frame was sent.
+func (c *Conn) writeFrame(op byte, payload []byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+
+	if c.closeSent {
+		return ErrClosed
+	}
+	return c.write(op, payload)
+}
+
+// write writes a frame with the fin bit set. The caller must hold c.wmu.
+func (c *Conn) write(op byte, payload []byte) error {
+	return c.writeRaw(0x80|op, payload)
+}
+
+// writeRaw writes a frame that starts with the fin bit, the reserved bits and the opcode
+// in the first byte. The caller must hold c.wmu.
+func (c *Conn) writeRaw(first byte, payload []byte) error {
+	frame := make([]byte, 0, 14+len(payload))
+	frame = append(frame, first)
+
+	var maskBit byte
+	if c.client {
+		maskBit = 0x80
+	}
+	switch n := len(payload); {
+	case n < 126:
+		frame = append(frame, maskBit|byte(n))
+	case n <= 0xffff:
+		frame = append(frame, maskBit|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(n))
+	default:
+		frame = append(frame, maskBit|127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(n))
+	}
+	if c.client {
+		var mask [4]byte
+		if _, err := rand.Read(mask[:]); err != nil {
+			return errors.Wrap(err, "generate mask")
+		}
+		frame = append(frame, mask[:]...)
+		start := len(frame)
+		frame = append(frame, payload...)
+		maskBytes(mask, frame[start:])
+	} else {
+		frame = append(frame, payload...)
+	}
+	_, err := c.conn.Write(frame)
+	return err
+}
+
+// maskBytes masks or unmasks data with the mask key.
+func maskBytes(mask [4]byte, data []byte) {
+	for i := range data {
+		data[i] ^= mask[i%4]
+	}
+}
@@ -0,0 +1,298 @@
+package websocket
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testServer starts an HTTP server that upgrades every request
+// and passes the connection to handle. The caller closes it.
+func testServer(handle func(*Conn)) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer func() { _ = c.Close() }()
+		handle(c)
+	}))
+}
+
+// echo writes back every message it reads.
+func echo(c *Conn) {
+	for {
+		typ, data, err := c.ReadMessage()
+		if err != nil {
+			return
+		}
+		if err := c.WriteMessage(typ, data); err != nil {
+			return
+		}
+	}
+}
+
+// testDial returns a connection to the WebSocket server srv.
+// The caller closes it.
+func testDial(t *testing.T, srv *httptest.Server) *Conn {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	c, err := Dial(ctx, "ws"+strings.TrimPrefix(srv.URL, "http"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestEcho(t *testing.T) {
+	srv := testServer(echo)
+	defer srv.Close()
+
+	c := testDial(t, srv)
+	defer func() { _ = c.Close() }()
+
+	for i, testcase := range []struct {
+		Type MessageType
+		Data []byte
+	}{
+		{Type: TextMessage, Data: []byte("hello")},
+		{Type: BinaryMessage, Data: []byte{}},
+		{Type: BinaryMessage, Data: bytes.Repeat([]byte{1, 2, 3}, 100)},
+		{Type: BinaryMessage, Data: bytes.Repeat([]byte{4}, 70000)},
+	} {
+		if err := c.WriteMessage(testcase.Type, testcase.Data); err != nil {
+			t.Fatalf("(testcase %d) %s", i, err)
+		}
+		typ, data, err := c.ReadMessage()
+		if err != nil {
+			t.Fatalf("(testcase %d) %s", i, err)
+		}
+		if typ != testcase.Type || !bytes.Equal(testcase.Data, data) {
+			t.Fatalf("(testcase %d) expected %d message of %d bytes, got %d message of %d bytes", i, testcase.Type, len(testcase.Data), typ, len(data))
+		}
+	}
+}
+
+func TestFragmentsAndPings(t *testing.T) {
+	srv := testServer(echo)
+	defer srv.Close()
+
+	c := testDial(t, srv)
+	defer func() { _ = c.Close() }()
+
+	// A fragmented text message with a ping in the middle.
+	c.wmu.Lock()
+	for _, frame := range []struct {
+		Header  byte
+		Payload string
+	}{
+		{Header: byte(TextMessage), Payload: "hel"},
+		{Header: 0x80 | opPing, Payload: "ping"},
+		{Header: opContinuation, Payload: "lo "},
+		{Header: 0x80 | opContinuation, Payload: "world"},
+	} {
+		if err := c.writeRaw(frame.Header, []byte(frame.Payload)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c.wmu.Unlock()
+
+	typ, data, err := c.ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := "hello world", string(data); typ != TextMessage || expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+}
+
+func TestClose(t *testing.T) {
+	closed := make(chan error, 1)
+
+	srv := testServer(func(c *Conn) {
+		_, _, err := c.ReadMessage()
+		closed <- err
+	})
+	defer srv.Close()
+
+	c := testDial(t, srv)
+	defer func() { _ = c.Close() }()
+
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-closed:
+		ce, ok := err.(*CloseError)
+		if !ok {
+			t.Fatalf("expected *CloseError, got %v", err)
+		}
+		if expected, got := CloseNormal, ce.Code; expected != got {
+			t.Fatalf("expected %d, got %d", expected, got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout")
+	}
+	if err := c.WriteMessage(TextMessage, nil); err != ErrClosed {
+		t.Fatalf("expected ErrClosed, got %v", err)
+	}
+}
+
+func TestMessageTooBig(t *testing.T) {
+	closed := make(chan error, 1)
+
+	srv := testServer(func(c *Conn) {
+		c.SetMaxMessageLen(10)
+		_, _, err := c.ReadMessage()
+		closed <- err
+	})
+	defer srv.Close()
+
+	c := testDial(t, srv)
+	defer func() { _ = c.Close() }()
+
+	if err := c.WriteMessage(BinaryMessage, make([]byte, 11)); err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := "websocket: message too big", (<-closed).Error(); expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+	_, _, err := c.ReadMessage()
+	ce, ok := err.(*CloseError)
+	if !ok || ce.Code != CloseMessageTooBig {
+		t.Fatalf("expected close code %d, got %v", CloseMessageTooBig, err)
+	}
+}
+
+func TestUpgradeErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = Upgrade(w, r, nil)
+	}))
+	defer srv.Close()
+
+	for i, testcase := range []struct {
+		Header http.Header
+		Err    string
+	}{
+		{
+			Header: http.Header{},
+			Err:    "missing websocket upgrade headers",
+		},
+		{
+			Header: http.Header{"Connection": {"keep-alive, Upgrade"}, "Upgrade": {"websocket"}, "Sec-Websocket-Version": {"8"}},
+			Err:    "unsupported websocket version",
+		},
+		{
+			Header: http.Header{"Connection": {"Upgrade"}, "Upgrade": {"websocket"}, "Sec-Websocket-Version": {"13"}, "Sec-Websocket-Key": {"short"}},
+			Err:    "invalid websocket key",
+		},
+	} {
+		req, err := http.NewRequest(http.MethodGet, srv.URL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header = testcase.Header
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("(testcase %d) %s", i, err)
+		}
+		buf := new(bytes.Buffer)
+		_, _ = buf.ReadFrom(resp.Body)
+		_ = resp.Body.Close()
+
+		if expected, got := http.StatusBadRequest, resp.StatusCode; expected != got {
+			t.Fatalf("(testcase %d) expected %d, got %d", i, expected, got)
+		}
+		if expected, got := testcase.Err, strings.TrimSpace(buf.String()); expected != got {
+			t.Fatalf("(testcase %d) expected %s, got %s", i, expected, got)
+		}
+	}
+}
+
+func TestUpgradeOrigin(t *testing.T) {
+	var (
+		checkOrigin func(*http.Request) bool
+		srv         = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			c, err := Upgrade(w, r, checkOrigin)
+			if err != nil {
+				return
+			}
+			_ = c.Close()
+		}))
+	)
+	defer srv.Close()
+
+	allowed := func(r *http.Request) bool {
+		return r.Header.Get("Origin") == "https://example.com"
+	}
+	for i, testcase := range []struct {
+		Origin      string
+		CheckOrigin func(*http.Request) bool
+		Expected    int
+	}{
+		{Origin: "", Expected: http.StatusSwitchingProtocols},
+		{Origin: srv.URL, Expected: http.StatusSwitchingProtocols},
+		{Origin: "https://example.com", Expected: http.StatusForbidden},
+		{Origin: "https://example.com", CheckOrigin: allowed, Expected: http.StatusSwitchingProtocols},
+		{Origin: srv.URL, CheckOrigin: allowed, Expected: http.StatusForbidden},
+	} {
+		checkOrigin = testcase.CheckOrigin
+
+		req, err := http.NewRequest(http.MethodGet, srv.URL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header = http.Header{
+			"Connection":            {"Upgrade"},
+			"Upgrade":               {"websocket"},
+			"Sec-Websocket-Version": {"13"},
+			"Sec-Websocket-Key":     {"dGhlIHNhbXBsZSBub25jZQ=="},
+		}
+		if testcase.Origin != "" {
+			req.Header.Set("Origin", testcase.Origin)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("(testcase %d) %s", i, err)
+		}
+		_ = resp.Body.Close()
+
+		if expected, got := testcase.Expected, resp.StatusCode; expected != got {
+			t.Fatalf("(testcase %d) expected %d, got %d", i, expected, got)
+		}
+	}
+}
+
+func TestAcceptKey(t *testing.T) {
+	// The example of RFC 6455.
+	if expected, got := "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=", acceptKey("dGhlIHNhbXBsZSBub25jZQ=="); expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+}
+
+func TestUnmaskedClientFrame(t *testing.T) {
+	errc := make(chan error, 1)
+	srv := testServer(func(c *Conn) {
+		_, _, err := c.ReadMessage()
+		errc <- err
+	})
+	defer srv.Close()
+
+	c := testDial(t, srv)
+	defer func() { _ = c.Close() }()
+
+	// Pretend to be a server, which does not mask its frames.
+	c.client = false
+	if err := c.WriteMessage(TextMessage, []byte("hi")); err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := "websocket: invalid masking", (<-errc).Error(); expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+}
@@ -0,0 +1,401 @@
+package osc
+
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// JSON representation of OSC packets.
+//
+// A message is an object with an address and an array of typed arguments:
+//
+//	{"address":"/synth/freq","arguments":[{"type":"i","value":1000},{"type":"f","value":440}]}
+//
+// Every argument is an object with the argument's typetag and its value:
+//
+//	{"type":"i","value":3}
+//	{"type":"f","value":0.5}
+//	{"type":"d","value":48000}
+//	{"type":"s","value":"sine"}
+//	{"type":"b","value":"AQID"}  (base64)
+//	{"type":"T","value":true}
+//	{"type":"F","value":false}
+//
+// JSON numbers can not be NaN or infinite, so these floats and doubles
+// have the strings "NaN", "Infinity" and "-Infinity" as their value:
+//
+//	{"type":"f","value":"NaN"}
+//
+// A bundle is an object with a timetag and an array of packets, which can be
+// messages or bundles. The timetag is the 64-bit NTP timestamp as a decimal string,
+// since JSON numbers are often read as 64-bit floats, which can not hold it:
+//
+//	{"timetag":"1","packets":[{"address":"/foo","arguments":[]}]}
+//
+// Timetags are also parsed from numbers and from hexadecimal strings, like "0x1".
+
+// jsonArgument is the JSON representation of an argument.
+type jsonArgument struct {
+	Type  string          `json:"type"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// jsonPacket is the JSON representation of a message or a bundle.
+type jsonPacket struct {
+	Address   *string           `json:"address,omitempty"`
+	Arguments []json.RawMessage `json:"arguments,omitempty"`
+	Timetag   *jsonTimetag      `json:"timetag,omitempty"`
+	Packets   []json.RawMessage `json:"packets,omitempty"`
+}
+
+// jsonTimetag is the JSON representation of a timetag.
+type jsonTimetag Timetag
+
+// MarshalJSON returns the timetag as a decimal string.
+func (tt jsonTimetag) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + strconv.FormatUint(uint64(tt), 10) + `"`), nil
+}
+
+// UnmarshalJSON parses a timetag from a number, a decimal string or a hexadecimal string.
+func (tt *jsonTimetag) UnmarshalJSON(data []byte) error {
+	var (
+		s    = string(data)
+		base = 10
+	)
+	if len(s) > 0 && s[0] == '"' {
+		unquoted, err := strconv.Unquote(s)
+		if err != nil {
+			return errors.Wrap(err, "timetag")
+		}
+		s = unquoted
+		if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X") {
+			s, base = s[2:], 16
+		}
+	}
+	v, err := strconv.ParseUint(s, base, 64)
+	if err != nil {
+		return errors.Errorf("invalid timetag %s", data)
+	}
+	*tt = jsonTimetag(v)
+	return nil
+}
+
+// ParsePacketJSON parses the JSON representation of a message or a bundle.
+// Objects with an address are parsed as messages, objects with a timetag as bundles.
+func ParsePacketJSON(data []byte) (Packet, error) {
+	var jp jsonPacket
+	if err := json.Unmarshal(data, &jp); err != nil {
+		return nil, err
+	}
+	return jp.packet()
+}
+
+// packet converts the JSON representation to a message or a bundle.
+func (jp jsonPacket) packet() (Packet, error) {
+	switch {
+	case jp.Address != nil && jp.Timetag == nil:
+		msg := Message{Address: *jp.Address}
+		for i, raw := range jp.Arguments {
+			arg, err := ParseArgumentJSON(raw)
+			if err != nil {
+				return nil, errors.Wrapf(err, "argument %d", i)
+			}
+			msg.Arguments = append(msg.Arguments, arg)
+		}
+		return msg, nil
+	case jp.Timetag != nil && jp.Address == nil:
+		b := Bundle{Timetag: Timetag(*jp.Timetag)}
+		for i, raw := range jp.Packets {
+			p, err := ParsePacketJSON(raw)
+			if err != nil {
+				return nil, errors.Wrapf(err, "packet %d", i)
+			}
+			b.Packets = append(b.Packets, p)
+		}
+		return b, nil
+	default:
+		return nil, errors.Wrap(ErrParse, "a packet must have either an address or a timetag")
+	}
+}
+
+// ParseArgumentJSON parses the JSON representation of an argument.
+func ParseArgumentJSON(data []byte) (Argument, error) {
+	var ja jsonArgument
+	if err := json.Unmarshal(data, &ja); err != nil {
+		return nil, err
+	}
+	if len(ja.Type) != 1 {
+		return nil, errors.Wrapf(ErrInvalidTypeTag, "typetag %q", ja.Type)
+	}
+	switch tt := ja.Type[0]; tt {
+	case TypetagInt:
+		var i int32
+		err := ja.value(&i)
+		return Int(i), err
+	case TypetagFloat:
+		if f, ok := ja.nonFinite(); ok {
+			return Float(f), nil
+		}
+		var f float32
+		err := ja.value(&f)
+		return Float(f), err
+	case TypetagDouble:
+		if d, ok := ja.nonFinite(); ok {
+			return Double(d), nil
+		}
+		var d float64
+		err := ja.value(&d)
+		return Double(d), err
+	case TypetagString:
+		var s string
+		err := ja.value(&s)
+		return String(s), err
+	case TypetagBlob:
+		var b []byte
+		err := ja.value(&b)
+		return Blob(b), err
+	case TypetagTrue, TypetagFalse:
+		// The value is optional, the typetag is all we need.
+		if len(ja.Value) == 0 {
+			return Bool(tt == TypetagTrue), nil
+		}
+		var b bool
+		if err := ja.value(&b); err != nil {
+			return nil, err
+		}
+		if b != (tt == TypetagTrue) {
+			return nil, errors.Errorf("typetag %q does not match value %t", ja.Type, b)
+		}
+		return Bool(b), nil
+	default:
+		return nil, errors.Wrapf(ErrInvalidTypeTag, "typetag %q", ja.Type)
+	}
+}
+
+// value unmarshals the argument's value into v.
+func (ja jsonArgument) value(v interface{}) error {
+	if len(ja.Value) == 0 {
+		return errors.Errorf("typetag %q: missing value", ja.Type)
+	}
+	return errors.Wrapf(json.Unmarshal(ja.Value, v), "typetag %q", ja.Type)
+}
+
+// nonFinite returns the value of a float or a double that is NaN or infinite,
+// and false if the value is not one of their strings.
+func (ja jsonArgument) nonFinite() (float64, bool) {
+	switch string(ja.Value) {
+	case `"NaN"`:
+		return math.NaN(), true
+	case `"Infinity"`:
+		return math.Inf(1), true
+	case `"-Infinity"`:
+		return math.Inf(-1), true
+	}
+	return 0, false
+}
+
+// floatValue returns the JSON value of a float or a double, see nonFinite.
+func floatValue(f float64) interface{} {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "Infinity"
+	case math.IsInf(f, -1):
+		return "-Infinity"
+	}
+	return nil
+}
+
+// marshalArgumentJSON returns the JSON representation of an argument with the given value.
+func marshalArgumentJSON(typetag byte, value interface{}) ([]byte, error) {
+	v, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(jsonArgument{Type: string(typetag), Value: v})
+}
+
+// unmarshalArgumentJSON parses the JSON representation of an argument
+// and checks that it has the expected typetag.
+func unmarshalArgumentJSON(data []byte, expected Argument) (Argument, error) {
+	arg, err := ParseArgumentJSON(data)
+	if err != nil {
+		return nil, err
+	}
+	tt := arg.Typetag()
+	if tt == TypetagFalse {
+		tt = TypetagTrue
+	}
+	ett := expected.Typetag()
+	if ett == TypetagFalse {
+		ett = TypetagTrue
+	}
+	if tt != ett {
+		return nil, errors.Errorf("expected typetag %q, got %q", string(expected.Typetag()), string(arg.Typetag()))
+	}
+	return arg, nil
+}
+
+// MarshalJSON returns the JSON representation of the arg.
+func (i Int) MarshalJSON() ([]byte, error) { return marshalArgumentJSON(TypetagInt, int32(i)) }
+
+// UnmarshalJSON parses the JSON representation of the arg.
+func (i *Int) UnmarshalJSON(data []byte) error {
+	arg, err := unmarshalArgumentJSON(data, Int(0))
+	if err != nil {
+		return err
+	}
+	*i = arg.(Int)
+	return nil
+}
+
+// MarshalJSON returns the JSON representation of the arg.
+func (f Float) MarshalJSON() ([]byte, error) {
+	if v := floatValue(float64(f)); v != nil {
+		return marshalArgumentJSON(TypetagFloat, v)
+	}
+	return marshalArgumentJSON(TypetagFloat, float32(f))
+}
+
+// UnmarshalJSON parses the JSON representation of the arg.
+func (f *Float) UnmarshalJSON(data []byte) error {
+	arg, err := unmarshalArgumentJSON(data, Float(0))
+	if err != nil {
+		return err
+	}
+	*f = arg.(Float)
+	return nil
+}
+
+// MarshalJSON returns the JSON representation of the arg.
+func (d Double) MarshalJSON() ([]byte, error) {
+	if v := floatValue(float64(d)); v != nil {
+		return marshalArgumentJSON(TypetagDouble, v)
+	}
+	return marshalArgumentJSON(TypetagDouble, float64(d))
+}
+
+// UnmarshalJSON parses the JSON representation of the arg.
+func (d *Double) UnmarshalJSON(data []byte) error {
+	arg, err := unmarshalArgumentJSON(data, Double(0))
+	if err != nil {
+		return err
+	}
+	*d = arg.(Double)
+	return nil
+}
+
+// MarshalJSON returns the JSON representation of the arg.
+func (b Bool) MarshalJSON() ([]byte, error) { return marshalArgumentJSON(b.Typetag(), bool(b)) }
+
+// UnmarshalJSON parses the JSON representation of the arg.
+func (b *Bool) UnmarshalJSON(data []byte) error {
+	arg, err := unmarshalArgumentJSON(data, Bool(false))
+	if err != nil {
+		return err
+	}
+	*b = arg.(Bool)
+	return nil
+}
+
+// MarshalJSON returns the JSON representation of the arg.
+func (s String) MarshalJSON() ([]byte, error) { return marshalArgumentJSON(TypetagString, string(s)) }
+
+// UnmarshalJSON parses the JSON representation of the arg.
+func (s *String) UnmarshalJSON(data []byte) error {
+	arg, err := unmarshalArgumentJSON(data, String(""))
+	if err != nil {
+		return err
+	}
+	*s = arg.(String)
+	return nil
+}
+
+// MarshalJSON returns the JSON representation of the arg.
+func (b Blob) MarshalJSON() ([]byte, error) { return marshalArgumentJSON(TypetagBlob, []byte(b)) }
+
+// UnmarshalJSON parses the JSON representation of the arg.
+func (b *Blob) UnmarshalJSON(data []byte) error {
+	arg, err := unmarshalArgumentJSON(data, Blob(nil))
+	if err != nil {
+		return err
+	}
+	*b = arg.(Blob)
+	return nil
+}
+
+// MarshalJSON returns the JSON representation of the message.
+// The message's Sender is not included.
+func (msg Message) MarshalJSON() ([]byte, error) {
+	args := make([]json.RawMessage, len(msg.Arguments))
+	for i, a := range msg.Arguments {
+		raw, err := json.Marshal(a)
+		if err != nil {
+			return nil, errors.Wrapf(err, "argument %d", i)
+		}
+		args[i] = raw
+	}
+	return json.Marshal(struct {
+		Address   string            `json:"address"`
+		Arguments []json.RawMessage `json:"arguments"`
+	}{msg.Address, args})
+}
+
+// UnmarshalJSON parses the JSON representation of a message.
+// The message's Sender is left unchanged.
+func (msg *Message) UnmarshalJSON(data []byte) error {
+	p, err := ParsePacketJSON(data)
+	if err != nil {
+		return err
+	}
+	m, ok := p.(Message)
+	if !ok {
+		return errors.Wrap(ErrParse, "expected a message, got a bundle")
+	}
+	m.Sender = msg.Sender
+	*msg = m
+	return nil
+}
+
+// MarshalJSON returns the JSON representation of the bundle.
+// The bundle's Sender is not included.
+func (b Bundle) MarshalJSON() ([]byte, error) {
+	packets := make([]json.RawMessage, len(b.Packets))
+	for i, p := range b.Packets {
+		switch p.(type) {
+		case Message, Bundle:
+		default:
+			return nil, errors.Errorf("packet %d: unsupported packet type %T", i, p)
+		}
+		raw, err := json.Marshal(p)
+		if err != nil {
+			return nil, errors.Wrapf(err, "packet %d", i)
+		}
+		packets[i] = raw
+	}
+	return json.Marshal(struct {
+		Timetag jsonTimetag       `json:"timetag"`
+		Packets []json.RawMessage `json:"packets"`
+	}{jsonTimetag(b.Timetag), packets})
+}
+
+// UnmarshalJSON parses the JSON representation of a bundle.
+// The bundle's Sender is left unchanged.
+func (b *Bundle) UnmarshalJSON(data []byte) error {
+	p, err := ParsePacketJSON(data)
+	if err != nil {
+		return err
+	}
+	bundle, ok := p.(Bundle)
+	if !ok {
+		return errors.Wrap(ErrParse, "expected a bundle, got a message")
+	}
+	bundle.Sender = b.Sender
+	*b = bundle
+	return nil
+}
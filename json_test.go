@@ -0,0 +1,267 @@
+package osc
+
+import (
+	"encoding/json"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestMessageJSON(t *testing.T) {
+	msg := Message{
+		Address: "/synth/freq",
+		Arguments: Arguments{
+			Int(1000),
+			Float(0.5),
+			String("sine"),
+			Blob{1, 2, 3},
+			Bool(true),
+			Bool(false),
+		},
+	}
+	data, err := json.Marshal(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"address":"/synth/freq","arguments":[` +
+		`{"type":"i","value":1000},` +
+		`{"type":"f","value":0.5},` +
+		`{"type":"s","value":"sine"},` +
+		`{"type":"b","value":"AQID"},` +
+		`{"type":"T","value":true},` +
+		`{"type":"F","value":false}]}`
+	if got := string(data); expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+	var got Message
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !msg.Equal(got) {
+		t.Fatalf("expected %s, got %s", msg, got)
+	}
+}
+
+func TestBundleJSON(t *testing.T) {
+	b := Bundle{
+		Timetag: Immediately,
+		Packets: []Packet{
+			Message{Address: "/foo"},
+			Bundle{Timetag: 5},
+		},
+	}
+	data, err := json.Marshal(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"timetag":"1","packets":[{"address":"/foo","arguments":[]},{"timetag":"5","packets":[]}]}`
+	if got := string(data); expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+	var got Bundle
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !b.Equal(got) {
+		t.Fatalf("expected %s, got %s", b, got)
+	}
+	if _, err := json.Marshal(Bundle{Packets: []Packet{badPacket{}}}); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+}
+
+func TestBundleJSONTimetag(t *testing.T) {
+	for i, testcase := range []struct {
+		Input    string
+		Expected Timetag
+	}{
+		{Input: `{"timetag":"16818445063891320832"}`, Expected: 16818445063891320832},
+		{Input: `{"timetag":"0xE9668A3B00000000"}`, Expected: 0xE9668A3B00000000},
+		{Input: `{"timetag":16818445063891320832}`, Expected: 16818445063891320832},
+		{Input: `{"timetag":"010"}`, Expected: 10},
+	} {
+		var b Bundle
+		if err := json.Unmarshal([]byte(testcase.Input), &b); err != nil {
+			t.Fatalf("(testcase %d) %s", i, err)
+		}
+		if expected, got := testcase.Expected, b.Timetag; expected != got {
+			t.Fatalf("(testcase %d) expected %d, got %d", i, expected, got)
+		}
+	}
+	data, err := json.Marshal(Bundle{Timetag: 16818445063891320832})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := `{"timetag":"16818445063891320832","packets":[]}`, string(data); expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+}
+
+func TestPacketJSONRandom(t *testing.T) {
+	r := rand.New(rand.NewSource(5))
+
+	for i := 0; i < 200; i++ {
+		p := randomPacket(r, 3)
+		data, err := json.Marshal(p)
+		if err != nil {
+			t.Fatalf("(packet %d) %s", i, err)
+		}
+		got, err := ParsePacketJSON(data)
+		if err != nil {
+			t.Fatalf("(packet %d) %s", i, err)
+		}
+		if !p.Equal(got) {
+			t.Fatalf("(packet %d) expected %s, got %s", i, p, got)
+		}
+	}
+}
+
+func TestArgumentJSON(t *testing.T) {
+	var (
+		i Int
+		f Float
+		d Double
+		b Bool
+		s String
+		o Blob
+	)
+	for _, testcase := range []struct {
+		Input    string
+		Value    json.Unmarshaler
+		Expected Argument
+	}{
+		{Input: `{"type":"i","value":-3}`, Value: &i, Expected: Int(-3)},
+		{Input: `{"type":"f","value":1.5}`, Value: &f, Expected: Float(1.5)},
+		{Input: `{"type":"d","value":48000}`, Value: &d, Expected: Double(48000)},
+		{Input: `{"type":"T"}`, Value: &b, Expected: Bool(true)},
+		{Input: `{"type":"s","value":"foo"}`, Value: &s, Expected: String("foo")},
+		{Input: `{"type":"b","value":"Zm9v"}`, Value: &o, Expected: Blob("foo")},
+	} {
+		if err := json.Unmarshal([]byte(testcase.Input), testcase.Value); err != nil {
+			t.Fatal(err)
+		}
+		got := reflectArgument(testcase.Value)
+		if !testcase.Expected.Equal(got) {
+			t.Fatalf("expected %s, got %s", testcase.Expected, got)
+		}
+	}
+}
+
+func TestArgumentJSONNonFinite(t *testing.T) {
+	for i, testcase := range []struct {
+		Argument Argument
+		Expected string
+	}{
+		{Argument: Float(math.NaN()), Expected: `{"type":"f","value":"NaN"}`},
+		{Argument: Float(math.Inf(1)), Expected: `{"type":"f","value":"Infinity"}`},
+		{Argument: Double(math.Inf(-1)), Expected: `{"type":"d","value":"-Infinity"}`},
+	} {
+		data, err := json.Marshal(testcase.Argument)
+		if err != nil {
+			t.Fatalf("(testcase %d) %s", i, err)
+		}
+		if expected, got := testcase.Expected, string(data); expected != got {
+			t.Fatalf("(testcase %d) expected %s, got %s", i, expected, got)
+		}
+		arg, err := ParseArgumentJSON(data)
+		if err != nil {
+			t.Fatalf("(testcase %d) %s", i, err)
+		}
+		// NaN is not equal to itself, so compare the JSON again.
+		data, err = json.Marshal(arg)
+		if err != nil {
+			t.Fatalf("(testcase %d) %s", i, err)
+		}
+		if expected, got := testcase.Expected, string(data); expected != got {
+			t.Fatalf("(testcase %d) expected %s, got %s", i, expected, got)
+		}
+	}
+	if _, err := ParseArgumentJSON([]byte(`{"type":"f","value":"nan"}`)); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+}
+
+// reflectArgument dereferences a pointer to an argument.
+func reflectArgument(v interface{}) Argument {
+	switch x := v.(type) {
+	case *Int:
+		return *x
+	case *Float:
+		return *x
+	case *Double:
+		return *x
+	case *Bool:
+		return *x
+	case *String:
+		return *x
+	default:
+		return *x.(*Blob)
+	}
+}
+
+func TestJSONErrors(t *testing.T) {
+	for i, testcase := range []struct {
+		Input string
+		Value interface{}
+		Err   string
+	}{
+		{
+			Input: `{"address":"/foo","arguments":[{"type":"Q","value":1}]}`,
+			Value: &Message{},
+			Err:   `argument 0: typetag "Q": invalid type tag`,
+		},
+		{
+			Input: `{"address":"/foo","arguments":[{"type":"i"}]}`,
+			Value: &Message{},
+			Err:   `argument 0: typetag "i": missing value`,
+		},
+		{
+			Input: `{"address":"/foo","arguments":[{"type":"i","value":"x"}]}`,
+			Value: &Message{},
+			Err:   `argument 0: typetag "i": json: cannot unmarshal string into Go value of type int32`,
+		},
+		{
+			Input: `{"address":"/foo","arguments":[{"type":"T","value":false}]}`,
+			Value: &Message{},
+			Err:   `argument 0: typetag "T" does not match value false`,
+		},
+		{
+			Input: `{"timetag":1}`,
+			Value: &Message{},
+			Err:   "expected a message, got a bundle: error parsing message",
+		},
+		{
+			Input: `{"address":"/foo"}`,
+			Value: &Bundle{},
+			Err:   "expected a bundle, got a message: error parsing message",
+		},
+		{
+			Input: `{"address":"/foo","timetag":1}`,
+			Value: &Bundle{},
+			Err:   "a packet must have either an address or a timetag: error parsing message",
+		},
+		{
+			Input: `{"timetag":1,"packets":[{}]}`,
+			Value: &Bundle{},
+			Err:   "packet 0: a packet must have either an address or a timetag: error parsing message",
+		},
+		{
+			Input: `{"timetag":"soon","packets":[]}`,
+			Value: &Bundle{},
+			Err:   `invalid timetag "soon"`,
+		},
+		{
+			Input: `{"type":"f","value":1}`,
+			Value: new(Int),
+			Err:   `expected typetag "i", got "f"`,
+		},
+	} {
+		err := json.Unmarshal([]byte(testcase.Input), testcase.Value)
+		if err == nil {
+			t.Fatalf("(testcase %d) expected error, got nil", i)
+		}
+		if expected, got := testcase.Err, err.Error(); expected != got {
+			t.Fatalf("(testcase %d) expected %s, got %s", i, expected, got)
+		}
+	}
+}
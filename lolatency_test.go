@@ -175,3 +175,38 @@ func BenchmarkUDPSendExactMatch(b *testing.B) {
 		<-ch
 	}
 }
+
+// BenchmarkUDPSendAllocs measures the allocations made by Send.
+// Nobody reads from the server, so this only exercises the sending side.
+func BenchmarkUDPSendAllocs(b *testing.B) {
+	laddr, err := net.ResolveUDPAddr("udp", "127.0.0.1:0")
+	if err != nil {
+		b.Fatal(err)
+	}
+	srv, err := osc.ListenUDP("udp", laddr)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer func() { _ = srv.Close() }() // Best effort.
+
+	raddr, err := net.ResolveUDPAddr("udp", srv.LocalAddr().String())
+	if err != nil {
+		b.Fatal(err)
+	}
+	conn, err := osc.DialUDP("udp", nil, raddr)
+	if err != nil {
+		b.Fatal(err)
+	}
+	var p osc.Packet = osc.Message{
+		Address:   "/synth/freq",
+		Arguments: osc.Arguments{osc.Int(1000), osc.Float(440)},
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if err := conn.Send(p); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,372 @@
+package osc
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Marshal returns a message with the given address whose arguments
+// are the exported fields of the struct v, in the order they are declared.
+// v can be a struct or a pointer to a struct.
+//
+// Fields are converted to arguments according to their type:
+//
+//	int, int8, ..., uint, uint8, ...  Int
+//	float32, float64                  Float
+//	string                            String
+//	[]byte                            Blob
+//	bool                              Bool
+//	Argument                          the argument itself
+//
+// The `osc` struct tag can override the argument type of a field
+// or exclude it from the message:
+//
+//	// Encoded as a Float.
+//	Freq int `osc:",float"`
+//
+//	// Encoded as an Int, the fractional part is discarded.
+//	Gain float64 `osc:",int"`
+//
+//	// Encoded as a Blob.
+//	Name string `osc:",blob"`
+//
+//	// Ignored.
+//	Cache []float64 `osc:"-"`
+//
+// The name part of the tag is optional and is only used in error messages.
+// Fields of embedded structs are treated as if they were in the outer struct.
+func Marshal(address string, v interface{}) (Message, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return Message{}, errors.New("marshal nil pointer")
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return Message{}, errors.Errorf("marshal %T: expected a struct", v)
+	}
+	fields, err := structFields(rv.Type())
+	if err != nil {
+		return Message{}, err
+	}
+	msg := Message{
+		Address:   address,
+		Arguments: make(Arguments, len(fields)),
+	}
+	for i, f := range fields {
+		arg, err := f.marshal(rv.FieldByIndex(f.index))
+		if err != nil {
+			return Message{}, err
+		}
+		msg.Arguments[i] = arg
+	}
+	return msg, nil
+}
+
+// Unmarshal stores the arguments of msg in the exported fields of the struct
+// that v points to, in the order they are declared.
+// The message must have exactly one argument for each field and the argument
+// types must match the ones that Marshal would use for the fields.
+// See Marshal for how field types are mapped to argument types.
+func Unmarshal(msg Message, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return errors.Errorf("unmarshal %T: expected a non-nil pointer to a struct", v)
+	}
+	rv = rv.Elem()
+	if rv.Kind() != reflect.Struct {
+		return errors.Errorf("unmarshal %T: expected a non-nil pointer to a struct", v)
+	}
+	fields, err := structFields(rv.Type())
+	if err != nil {
+		return err
+	}
+	if len(msg.Arguments) != len(fields) {
+		return errors.Errorf("unmarshal %s: %s has %d fields, message has %d arguments", msg.Address, rv.Type(), len(fields), len(msg.Arguments))
+	}
+	for i, f := range fields {
+		if err := f.unmarshal(msg.Arguments[i], rv.FieldByIndex(f.index)); err != nil {
+			return errors.Wrapf(err, "unmarshal %s: argument %d", msg.Address, i)
+		}
+	}
+	return nil
+}
+
+// MarshalTypeError describes a struct field that can't be converted to
+// or from an argument with a particular type tag.
+type MarshalTypeError struct {
+	Field   string
+	Type    reflect.Type
+	Typetag byte
+}
+
+// Error returns a description of the error.
+// A zero Typetag means that the argument was nil.
+func (e *MarshalTypeError) Error() string {
+	if e.Typetag == 0 {
+		return fmt.Sprintf("field %s of type %s can not be converted from a nil argument", e.Field, e.Type)
+	}
+	return fmt.Sprintf("field %s of type %s can not be converted to or from typetag %q", e.Field, e.Type, string(e.Typetag))
+}
+
+var argumentType = reflect.TypeOf((*Argument)(nil)).Elem()
+
+// structField describes how a struct field maps to an argument.
+type structField struct {
+	name    string
+	index   []int
+	typ     reflect.Type
+	typetag byte // The typetag that the field is encoded as, or 0 for Argument fields.
+}
+
+// fieldCache maps a reflect.Type to its []structField.
+var fieldCache sync.Map
+
+// structFields returns the fields of the struct type t that are mapped to arguments.
+func structFields(t reflect.Type) ([]structField, error) {
+	if fields, ok := fieldCache.Load(t); ok {
+		return fields.([]structField), nil
+	}
+	fields, err := appendStructFields(nil, t, nil)
+	if err != nil {
+		return nil, err
+	}
+	fieldCache.Store(t, fields)
+	return fields, nil
+}
+
+// appendStructFields appends the fields of the struct type t to fields.
+// index is the index sequence of t in the outermost struct.
+func appendStructFields(fields []structField, t reflect.Type, index []int) ([]structField, error) {
+	for i := 0; i < t.NumField(); i++ {
+		var (
+			sf      = t.Field(i)
+			tag, ok = sf.Tag.Lookup("osc")
+		)
+		if tag == "-" {
+			continue
+		}
+		fieldIndex := append(append([]int{}, index...), i)
+
+		if sf.Anonymous && !ok && sf.Type.Kind() == reflect.Struct {
+			var err error
+			if fields, err = appendStructFields(fields, sf.Type, fieldIndex); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if sf.PkgPath != "" { // Unexported
+			continue
+		}
+		f := structField{name: sf.Name, index: fieldIndex, typ: sf.Type}
+
+		name, opt := tag, ""
+		if idx := strings.Index(tag, ","); idx != -1 {
+			name, opt = tag[:idx], tag[idx+1:]
+		}
+		if name != "" {
+			f.name = name
+		}
+		typetag, err := fieldTypetag(f, opt)
+		if err != nil {
+			return nil, err
+		}
+		f.typetag = typetag
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+// fieldTypetag returns the typetag of the field given the option from its struct tag.
+func fieldTypetag(f structField, opt string) (byte, error) {
+	if f.typ.Implements(argumentType) && opt == "" {
+		return 0, nil
+	}
+	var tt byte
+
+	switch opt {
+	case "":
+		switch {
+		case isInt(f.typ.Kind()) || isUint(f.typ.Kind()):
+			tt = TypetagInt
+		case isFloat(f.typ.Kind()):
+			tt = TypetagFloat
+		case f.typ.Kind() == reflect.String:
+			tt = TypetagString
+		case f.typ.Kind() == reflect.Bool:
+			tt = TypetagTrue
+		case isBytes(f.typ):
+			tt = TypetagBlob
+		default:
+			return 0, errors.Errorf("field %s: unsupported type %s", f.name, f.typ)
+		}
+		return tt, nil
+	case "int":
+		tt = TypetagInt
+	case "float":
+		tt = TypetagFloat
+	case "string":
+		tt = TypetagString
+	case "blob":
+		tt = TypetagBlob
+	case "bool":
+		tt = TypetagTrue
+	default:
+		return 0, errors.Errorf("field %s: unknown osc tag option %q", f.name, opt)
+	}
+	var (
+		k  = f.typ.Kind()
+		ok bool
+	)
+	switch tt {
+	case TypetagInt, TypetagFloat:
+		ok = isInt(k) || isUint(k) || isFloat(k)
+	case TypetagString:
+		ok = k == reflect.String
+	case TypetagBlob:
+		ok = k == reflect.String || isBytes(f.typ)
+	case TypetagTrue:
+		ok = k == reflect.Bool
+	}
+	if !ok {
+		return 0, &MarshalTypeError{Field: f.name, Type: f.typ, Typetag: tt}
+	}
+	return tt, nil
+}
+
+// marshal converts the value of the field to an argument.
+func (f structField) marshal(v reflect.Value) (Argument, error) {
+	if f.typetag == 0 {
+		if v.Kind() == reflect.Interface && v.IsNil() {
+			return nil, errors.Errorf("field %s: nil argument", f.name)
+		}
+		return v.Interface().(Argument), nil
+	}
+	k := v.Kind()
+
+	switch f.typetag {
+	case TypetagInt:
+		var i int64
+		switch {
+		case isInt(k):
+			i = v.Int()
+		case isUint(k):
+			if v.Uint() > math.MaxInt32 {
+				return nil, errors.Errorf("field %s: %d overflows a 32-bit integer", f.name, v.Uint())
+			}
+			i = int64(v.Uint())
+		default:
+			i = int64(v.Float())
+		}
+		if i < math.MinInt32 || i > math.MaxInt32 {
+			return nil, errors.Errorf("field %s: %d overflows a 32-bit integer", f.name, i)
+		}
+		return Int(i), nil
+	case TypetagFloat:
+		switch {
+		case isInt(k):
+			return Float(v.Int()), nil
+		case isUint(k):
+			return Float(v.Uint()), nil
+		default:
+			return Float(v.Float()), nil
+		}
+	case TypetagString:
+		return String(v.String()), nil
+	case TypetagBlob:
+		if k == reflect.String {
+			return Blob(v.String()), nil
+		}
+		return Blob(v.Bytes()), nil
+	default:
+		return Bool(v.Bool()), nil
+	}
+}
+
+// unmarshal stores the argument in the field.
+func (f structField) unmarshal(arg Argument, v reflect.Value) error {
+	if arg == nil {
+		return &MarshalTypeError{Field: f.name, Type: f.typ}
+	}
+	if f.typetag == 0 {
+		av := reflect.ValueOf(arg)
+		if !av.Type().AssignableTo(f.typ) {
+			return &MarshalTypeError{Field: f.name, Type: f.typ, Typetag: arg.Typetag()}
+		}
+		v.Set(av)
+		return nil
+	}
+	tt := arg.Typetag()
+	if tt == TypetagFalse {
+		tt = TypetagTrue
+	}
+	if tt != f.typetag {
+		return &MarshalTypeError{Field: f.name, Type: f.typ, Typetag: arg.Typetag()}
+	}
+	k := v.Kind()
+
+	switch tt {
+	case TypetagInt:
+		i, _ := arg.ReadInt32()
+		return setNumber(f, v, float64(i), int64(i))
+	case TypetagFloat:
+		fl, _ := arg.ReadFloat32()
+		return setNumber(f, v, float64(fl), int64(fl))
+	case TypetagString:
+		s, _ := arg.ReadString()
+		v.SetString(s)
+	case TypetagBlob:
+		b, _ := arg.ReadBlob()
+		if k == reflect.String {
+			v.SetString(string(b))
+		} else {
+			v.SetBytes(append([]byte{}, b...))
+		}
+	default:
+		b, _ := arg.ReadBool()
+		v.SetBool(b)
+	}
+	return nil
+}
+
+// setNumber stores a number in a numeric field.
+// Integer fields are given i, float fields are given fl.
+func setNumber(f structField, v reflect.Value, fl float64, i int64) error {
+	switch k := v.Kind(); {
+	case isInt(k):
+		if v.OverflowInt(i) {
+			return errors.Errorf("field %s: %d overflows %s", f.name, i, f.typ)
+		}
+		v.SetInt(i)
+	case isUint(k):
+		if i < 0 || v.OverflowUint(uint64(i)) {
+			return errors.Errorf("field %s: %d overflows %s", f.name, i, f.typ)
+		}
+		v.SetUint(uint64(i))
+	default:
+		v.SetFloat(fl)
+	}
+	return nil
+}
+
+func isInt(k reflect.Kind) bool {
+	return k >= reflect.Int && k <= reflect.Int64
+}
+
+func isUint(k reflect.Kind) bool {
+	return k >= reflect.Uint && k <= reflect.Uintptr
+}
+
+func isFloat(k reflect.Kind) bool {
+	return k == reflect.Float32 || k == reflect.Float64
+}
+
+func isBytes(t reflect.Type) bool {
+	return t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8
+}
@@ -0,0 +1,211 @@
+package osc
+
+import (
+	"bytes"
+	"testing"
+)
+
+type synthParams struct {
+	Node    int32
+	Freq    float64
+	Wave    string
+	Data    []byte
+	Gate    bool
+	Channel uint8   `osc:"chan,float"`
+	Gain    float32 `osc:",int"`
+	Label   string  `osc:",blob"`
+	Extra   Argument
+	Cache   []float64 `osc:"-"`
+	private int
+
+	embeddedParams
+}
+
+type embeddedParams struct {
+	Bus int
+}
+
+func TestMarshal(t *testing.T) {
+	params := synthParams{
+		Node:    1000,
+		Freq:    440,
+		Wave:    "sine",
+		Data:    []byte{1, 2, 3, 4},
+		Gate:    true,
+		Channel: 2,
+		Gain:    3.7,
+		Label:   "abcd",
+		Extra:   String("extra"),
+		Cache:   []float64{1, 2},
+		private: 3,
+
+		embeddedParams: embeddedParams{Bus: 16},
+	}
+	expected := Message{
+		Address: "/synth",
+		Arguments: Arguments{
+			Int(1000),
+			Float(440),
+			String("sine"),
+			Blob{1, 2, 3, 4},
+			Bool(true),
+			Float(2),
+			Int(3),
+			Blob("abcd"),
+			String("extra"),
+			Int(16),
+		},
+	}
+	for _, v := range []interface{}{params, &params} {
+		msg, err := Marshal("/synth", v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !expected.Equal(msg) {
+			t.Fatalf("expected %s, got %s", expected, msg)
+		}
+	}
+	var got synthParams
+	if err := Unmarshal(expected, &got); err != nil {
+		t.Fatal(err)
+	}
+	params.Gain, params.Cache, params.private = 3, nil, 0
+
+	if expected, got := params.Node, got.Node; expected != got {
+		t.Fatalf("expected %d, got %d", expected, got)
+	}
+	if expected, got := params.Freq, got.Freq; expected != got {
+		t.Fatalf("expected %f, got %f", expected, got)
+	}
+	if expected, got := params.Data, got.Data; !bytes.Equal(expected, got) {
+		t.Fatalf("expected %q, got %q", expected, got)
+	}
+	if got.Wave != params.Wave || got.Gate != params.Gate || got.Channel != params.Channel ||
+		got.Gain != params.Gain || got.Label != params.Label || !got.Extra.Equal(params.Extra) ||
+		got.Bus != params.Bus || got.Cache != nil || got.private != 0 {
+		t.Fatalf("expected %+v, got %+v", params, got)
+	}
+}
+
+func TestMarshalErrors(t *testing.T) {
+	var nilPtr *synthParams
+
+	for i, testcase := range []struct {
+		Input interface{}
+		Err   string
+	}{
+		{
+			Input: 3,
+			Err:   "marshal int: expected a struct",
+		},
+		{
+			Input: nilPtr,
+			Err:   "marshal nil pointer",
+		},
+		{
+			Input: struct{ C chan int }{},
+			Err:   "field C: unsupported type chan int",
+		},
+		{
+			Input: struct {
+				S string `osc:",int"`
+			}{},
+			Err: `field S of type string can not be converted to or from typetag "i"`,
+		},
+		{
+			Input: struct {
+				S string `osc:",nope"`
+			}{},
+			Err: `field S: unknown osc tag option "nope"`,
+		},
+		{
+			Input: struct{ I int64 }{I: 1 << 40},
+			Err:   "field I: 1099511627776 overflows a 32-bit integer",
+		},
+		{
+			Input: struct{ U uint }{U: 1 << 40},
+			Err:   "field U: 1099511627776 overflows a 32-bit integer",
+		},
+		{
+			Input: struct{ A Argument }{},
+			Err:   "field A: nil argument",
+		},
+	} {
+		_, err := Marshal("/foo", testcase.Input)
+		if err == nil {
+			t.Fatalf("(testcase %d) expected error, got nil", i)
+		}
+		if expected, got := testcase.Err, err.Error(); expected != got {
+			t.Fatalf("(testcase %d) expected %s, got %s", i, expected, got)
+		}
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	type ints struct {
+		I int8
+		U uint
+	}
+	type arg struct {
+		I Int
+	}
+	for i, testcase := range []struct {
+		Message Message
+		Output  interface{}
+		Err     string
+	}{
+		{
+			Message: Message{Address: "/foo"},
+			Output:  ints{},
+			Err:     "unmarshal osc.ints: expected a non-nil pointer to a struct",
+		},
+		{
+			Message: Message{Address: "/foo"},
+			Output:  new(int),
+			Err:     "unmarshal *int: expected a non-nil pointer to a struct",
+		},
+		{
+			Message: Message{Address: "/foo", Arguments: Arguments{Int(1)}},
+			Output:  &ints{},
+			Err:     "unmarshal /foo: osc.ints has 2 fields, message has 1 arguments",
+		},
+		{
+			Message: Message{Address: "/foo", Arguments: Arguments{Int(1), Float(2)}},
+			Output:  &ints{},
+			Err:     `unmarshal /foo: argument 1: field U of type uint can not be converted to or from typetag "f"`,
+		},
+		{
+			Message: Message{Address: "/foo", Arguments: Arguments{Int(300), Int(2)}},
+			Output:  &ints{},
+			Err:     "unmarshal /foo: argument 0: field I: 300 overflows int8",
+		},
+		{
+			Message: Message{Address: "/foo", Arguments: Arguments{Int(3), Int(-2)}},
+			Output:  &ints{},
+			Err:     "unmarshal /foo: argument 1: field U: -2 overflows uint",
+		},
+		{
+			Message: Message{Address: "/foo", Arguments: Arguments{String("s")}},
+			Output:  &arg{},
+			Err:     `unmarshal /foo: argument 0: field I of type osc.Int can not be converted to or from typetag "s"`,
+		},
+		{
+			Message: Message{Address: "/foo", Arguments: Arguments{nil}},
+			Output:  &arg{},
+			Err:     "unmarshal /foo: argument 0: field I of type osc.Int can not be converted from a nil argument",
+		},
+		{
+			Message: Message{Address: "/foo", Arguments: Arguments{Int(1), nil}},
+			Output:  &ints{},
+			Err:     "unmarshal /foo: argument 1: field U of type uint can not be converted from a nil argument",
+		},
+	} {
+		err := Unmarshal(testcase.Message, testcase.Output)
+		if err == nil {
+			t.Fatalf("(testcase %d) expected error, got nil", i)
+		}
+		if expected, got := testcase.Err, err.Error(); expected != got {
+			t.Fatalf("(testcase %d) expected %s, got %s", i, expected, got)
+		}
+	}
+}
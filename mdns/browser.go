@@ -0,0 +1,224 @@
+package mdns
+
+import (
+	"context"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// pollInterval is how often a browser checks if its context is done.
+const pollInterval = 50 * time.Millisecond
+
+// Browser finds services by sending queries and collecting the answers.
+type Browser struct {
+	conn  net.PacketConn
+	group net.Addr
+}
+
+// NewBrowser returns a browser that sends queries over conn and reads the answers.
+// If conn does not use the mDNS port, e.g. it was returned by net.ListenUDP("udp4", nil),
+// responders answer it directly instead of to the mDNS group.
+// Queries are sent to GroupAddr.
+func NewBrowser(conn net.PacketConn) *Browser {
+	return &Browser{conn: conn, group: GroupAddr}
+}
+
+// SetGroup sets the address that queries are sent to.
+func (b *Browser) SetGroup(addr net.Addr) {
+	b.group = addr
+}
+
+// Browse returns the instances of a service type, e.g. ServiceUDP, that answer
+// until ctx is done. Instances whose host address is not known are left out.
+// The services are sorted by instance name.
+func (b *Browser) Browse(ctx context.Context, serviceType string) ([]Service, error) {
+	var (
+		buf   = make([]byte, 9000)
+		found = newBrowseState(serviceType)
+	)
+	if err := b.query(question{Name: found.typeName, Type: typePTR, Class: classIN}); err != nil {
+		return nil, err
+	}
+	defer func() { _ = b.conn.SetReadDeadline(time.Time{}) }() // Best effort.
+
+	for ctx.Err() == nil {
+		if err := b.conn.SetReadDeadline(time.Now().Add(pollInterval)); err != nil {
+			return nil, errors.Wrap(err, "set read deadline")
+		}
+		n, _, err := b.conn.ReadFrom(buf)
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Timeout() {
+				continue
+			}
+			return nil, errors.Wrap(err, "read answer")
+		}
+		m, err := parseMessage(buf[:n])
+		if err != nil || m.Flags&flagResponse == 0 {
+			continue
+		}
+		found.add(m.Answers)
+		found.add(m.Additionals)
+
+		// Ask for what the answers left out.
+		for _, q := range found.questions() {
+			if err := b.query(q); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return found.services(), nil
+}
+
+// query sends a question.
+func (b *Browser) query(q question) error {
+	data, err := message{Questions: []question{q}}.marshal()
+	if err != nil {
+		return errors.Wrap(err, "encode query")
+	}
+	if _, err := b.conn.WriteTo(data, b.group); err != nil {
+		return errors.Wrap(err, "send query")
+	}
+	return nil
+}
+
+// Browse returns the addresses of the instances of a service type, e.g. ServiceUDP,
+// that answer on the local network until ctx is done.
+// The addresses are *net.UDPAddr for UDP services and *net.TCPAddr for TCP services.
+func Browse(ctx context.Context, serviceType string) ([]net.Addr, error) {
+	conn, err := net.ListenUDP("udp4", nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "listen udp")
+	}
+	defer func() { _ = conn.Close() }() // Best effort.
+
+	services, err := NewBrowser(conn).Browse(ctx, serviceType)
+	if err != nil {
+		return nil, err
+	}
+	addrs := make([]net.Addr, len(services))
+	for i, s := range services {
+		addrs[i] = s.Addr()
+	}
+	return addrs, nil
+}
+
+// browseState collects the records of the instances of a service type.
+// Names are lower case.
+type browseState struct {
+	serviceType string
+	typeName    string
+	instances   map[string]string // instance name to instance
+	srvs        map[string]record
+	texts       map[string][]string
+	hosts       map[string][]net.IP
+	asked       map[question]bool
+}
+
+// newBrowseState returns the state of browsing a service type.
+func newBrowseState(serviceType string) *browseState {
+	return &browseState{
+		serviceType: serviceType,
+		typeName:    serviceType + "." + domain,
+		instances:   map[string]string{},
+		srvs:        map[string]record{},
+		texts:       map[string][]string{},
+		hosts:       map[string][]net.IP{},
+		asked:       map[question]bool{},
+	}
+}
+
+// add adds records. PTR records with a TTL of 0 remove their instance.
+func (s *browseState) add(records []record) {
+	for _, rr := range records {
+		name := strings.ToLower(rr.Name)
+
+		switch rr.Type {
+		case typePTR:
+			if name != strings.ToLower(s.typeName) {
+				continue
+			}
+			target := strings.ToLower(rr.Target)
+			if rr.TTL == 0 {
+				delete(s.instances, target)
+				continue
+			}
+			if labels := splitName(rr.Target); len(labels) > 0 {
+				s.instances[target] = labels[0]
+			}
+		case typeSRV:
+			s.srvs[name] = rr
+		case typeTXT:
+			s.texts[name] = rr.Text
+		case typeA, typeAAAA:
+			if !containsIP(s.hosts[name], rr.IP) {
+				s.hosts[name] = append(s.hosts[name], rr.IP)
+			}
+		}
+	}
+}
+
+// questions returns the questions about the instances that were not asked yet:
+// their SRV and TXT records, and the addresses of their hosts.
+func (s *browseState) questions() []question {
+	var qs []question
+	ask := func(q question) {
+		if !s.asked[q] {
+			s.asked[q] = true
+			qs = append(qs, q)
+		}
+	}
+	for name := range s.instances {
+		srv, ok := s.srvs[name]
+		if !ok {
+			ask(question{Name: name, Type: typeSRV, Class: classIN})
+			ask(question{Name: name, Type: typeTXT, Class: classIN})
+			continue
+		}
+		if host := strings.ToLower(srv.Target); len(s.hosts[host]) == 0 {
+			ask(question{Name: host, Type: typeA, Class: classIN})
+			ask(question{Name: host, Type: typeAAAA, Class: classIN})
+		}
+	}
+	return qs
+}
+
+// services returns the instances whose SRV record and host addresses are known.
+func (s *browseState) services() []Service {
+	services := []Service{}
+	for name, instance := range s.instances {
+		srv, ok := s.srvs[name]
+		if !ok {
+			continue
+		}
+		ips := s.hosts[strings.ToLower(srv.Target)]
+		if len(ips) == 0 {
+			continue
+		}
+		services = append(services, Service{
+			Instance: instance,
+			Type:     s.serviceType,
+			Host:     srv.Target,
+			Port:     int(srv.Port),
+			IPs:      ips,
+			Text:     s.texts[name],
+		})
+	}
+	sort.Slice(services, func(i, j int) bool {
+		return services[i].Instance < services[j].Instance
+	})
+	return services
+}
+
+// containsIP returns true if ips contains ip.
+func containsIP(ips []net.IP, ip net.IP) bool {
+	for _, x := range ips {
+		if x.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,357 @@
+package mdns
+
+import (
+	"encoding/binary"
+	"net"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Resource record types.
+const (
+	typeA    = 1
+	typePTR  = 12
+	typeTXT  = 16
+	typeAAAA = 28
+	typeSRV  = 33
+	typeANY  = 255
+)
+
+// Classes. In mDNS the top bit of the class is the unicast response bit of questions,
+// and the cache flush bit of records.
+const (
+	classIN     = 1
+	classANY    = 255
+	classMask   = 0x7FFF
+	classTopBit = 0x8000
+)
+
+// Header flags.
+const (
+	flagResponse  = 0x8000
+	flagAuthority = 0x0400
+)
+
+const (
+	headerLen = 12
+
+	// maxPointerJump limits the compression pointers of a name, so loops are detected.
+	maxPointerJump = 16
+)
+
+// question is a question of a DNS message.
+type question struct {
+	Name  string
+	Type  uint16
+	Class uint16
+}
+
+// record is a resource record of a DNS message.
+// Only the fields of its type are used.
+type record struct {
+	Name  string
+	Type  uint16
+	Class uint16
+	TTL   uint32
+
+	Target string   // PTR and SRV
+	Port   uint16   // SRV
+	Text   []string // TXT
+	IP     net.IP   // A and AAAA
+}
+
+// message is a DNS message. Authority records are parsed as additional records.
+type message struct {
+	ID          uint16
+	Flags       uint16
+	Questions   []question
+	Answers     []record
+	Additionals []record
+}
+
+// marshal encodes the message, without name compression.
+func (m message) marshal() ([]byte, error) {
+	b := make([]byte, headerLen, 512)
+	binary.BigEndian.PutUint16(b[0:], m.ID)
+	binary.BigEndian.PutUint16(b[2:], m.Flags)
+	binary.BigEndian.PutUint16(b[4:], uint16(len(m.Questions)))
+	binary.BigEndian.PutUint16(b[6:], uint16(len(m.Answers)))
+	binary.BigEndian.PutUint16(b[10:], uint16(len(m.Additionals)))
+
+	var err error
+	for _, q := range m.Questions {
+		if b, err = appendName(b, q.Name); err != nil {
+			return nil, err
+		}
+		b = binary.BigEndian.AppendUint16(b, q.Type)
+		b = binary.BigEndian.AppendUint16(b, q.Class)
+	}
+	for _, records := range [][]record{m.Answers, m.Additionals} {
+		for _, rr := range records {
+			if b, err = appendRecord(b, rr); err != nil {
+				return nil, errors.Wrap(err, rr.Name)
+			}
+		}
+	}
+	return b, nil
+}
+
+// appendRecord appends a resource record.
+func appendRecord(b []byte, rr record) ([]byte, error) {
+	b, err := appendName(b, rr.Name)
+	if err != nil {
+		return nil, err
+	}
+	b = binary.BigEndian.AppendUint16(b, rr.Type)
+	b = binary.BigEndian.AppendUint16(b, rr.Class)
+	b = binary.BigEndian.AppendUint32(b, rr.TTL)
+
+	// The length of the data is written when it is known.
+	start := len(b)
+	b = append(b, 0, 0)
+
+	switch rr.Type {
+	case typeA:
+		ip := rr.IP.To4()
+		if ip == nil {
+			return nil, errors.Errorf("%s is not an IPv4 address", rr.IP)
+		}
+		b = append(b, ip...)
+	case typeAAAA:
+		if len(rr.IP) != net.IPv6len {
+			return nil, errors.Errorf("%s is not an IPv6 address", rr.IP)
+		}
+		b = append(b, rr.IP...)
+	case typePTR:
+		if b, err = appendName(b, rr.Target); err != nil {
+			return nil, err
+		}
+	case typeSRV:
+		b = append(b, 0, 0, 0, 0) // priority and weight
+		b = binary.BigEndian.AppendUint16(b, rr.Port)
+		if b, err = appendName(b, rr.Target); err != nil {
+			return nil, err
+		}
+	case typeTXT:
+		if len(rr.Text) == 0 {
+			// A TXT record has at least one string.
+			b = append(b, 0)
+		}
+		for _, s := range rr.Text {
+			if len(s) > 255 {
+				return nil, errors.Errorf("TXT string is %d bytes, at most 255 are allowed", len(s))
+			}
+			b = append(b, byte(len(s)))
+			b = append(b, s...)
+		}
+	default:
+		return nil, errors.Errorf("unsupported record type %d", rr.Type)
+	}
+	binary.BigEndian.PutUint16(b[start:], uint16(len(b)-start-2))
+
+	return b, nil
+}
+
+// appendName appends a domain name, whose labels are separated by unescaped dots.
+func appendName(b []byte, name string) ([]byte, error) {
+	for _, label := range splitName(name) {
+		if len(label) == 0 {
+			return nil, errors.Errorf("empty label in %q", name)
+		}
+		if len(label) > 63 {
+			return nil, errors.Errorf("label %q is longer than 63 bytes", label)
+		}
+		b = append(b, byte(len(label)))
+		b = append(b, label...)
+	}
+	return append(b, 0), nil
+}
+
+// splitName returns the unescaped labels of a domain name.
+func splitName(name string) []string {
+	var (
+		labels []string
+		label  []byte
+	)
+	name = strings.TrimSuffix(name, ".")
+	if name == "" {
+		return nil
+	}
+	for i := 0; i < len(name); i++ {
+		switch c := name[i]; {
+		case c == '\\' && i+1 < len(name):
+			i++
+			label = append(label, name[i])
+		case c == '.':
+			labels = append(labels, string(label))
+			label = label[:0]
+		default:
+			label = append(label, c)
+		}
+	}
+	return append(labels, string(label))
+}
+
+// escapeLabel escapes the dots and backslashes of a label.
+func escapeLabel(label string) string {
+	return strings.NewReplacer(`\`, `\\`, `.`, `\.`).Replace(label)
+}
+
+// parseMessage decodes a DNS message.
+// Records of unsupported types are skipped.
+func parseMessage(data []byte) (message, error) {
+	if len(data) < headerLen {
+		return message{}, errors.New("message is shorter than its header")
+	}
+	var (
+		m = message{
+			ID:    binary.BigEndian.Uint16(data[0:]),
+			Flags: binary.BigEndian.Uint16(data[2:]),
+		}
+		qdcount = int(binary.BigEndian.Uint16(data[4:]))
+		ancount = int(binary.BigEndian.Uint16(data[6:]))
+		rrcount = ancount + int(binary.BigEndian.Uint16(data[8:])) + int(binary.BigEndian.Uint16(data[10:]))
+		off     = headerLen
+	)
+	for i := 0; i < qdcount; i++ {
+		name, n, err := readName(data, off)
+		if err != nil {
+			return message{}, errors.Wrapf(err, "question %d", i)
+		}
+		off = n
+		if off+4 > len(data) {
+			return message{}, errors.Errorf("question %d: unexpected end of message", i)
+		}
+		m.Questions = append(m.Questions, question{
+			Name:  name,
+			Type:  binary.BigEndian.Uint16(data[off:]),
+			Class: binary.BigEndian.Uint16(data[off+2:]),
+		})
+		off += 4
+	}
+	for i := 0; i < rrcount; i++ {
+		rr, n, err := readRecord(data, off)
+		if err != nil {
+			return message{}, errors.Wrapf(err, "record %d", i)
+		}
+		off = n
+		if rr.Type == 0 {
+			continue
+		}
+		if i < ancount {
+			m.Answers = append(m.Answers, rr)
+		} else {
+			m.Additionals = append(m.Additionals, rr)
+		}
+	}
+	return m, nil
+}
+
+// readRecord reads a resource record at off, and returns the offset after it.
+// The type of records with an unsupported type is 0.
+func readRecord(data []byte, off int) (record, int, error) {
+	name, off, err := readName(data, off)
+	if err != nil {
+		return record{}, 0, err
+	}
+	if off+10 > len(data) {
+		return record{}, 0, errors.New("unexpected end of message")
+	}
+	rr := record{
+		Name:  name,
+		Type:  binary.BigEndian.Uint16(data[off:]),
+		Class: binary.BigEndian.Uint16(data[off+2:]),
+		TTL:   binary.BigEndian.Uint32(data[off+4:]),
+	}
+	rdlen := int(binary.BigEndian.Uint16(data[off+8:]))
+	off += 10
+
+	end := off + rdlen
+	if end > len(data) {
+		return record{}, 0, errors.New("unexpected end of message")
+	}
+	rdata := data[off:end]
+
+	switch rr.Type {
+	case typeA:
+		if rdlen != net.IPv4len {
+			return record{}, 0, errors.Errorf("A record has %d bytes", rdlen)
+		}
+		rr.IP = net.IP(append([]byte{}, rdata...))
+	case typeAAAA:
+		if rdlen != net.IPv6len {
+			return record{}, 0, errors.Errorf("AAAA record has %d bytes", rdlen)
+		}
+		rr.IP = net.IP(append([]byte{}, rdata...))
+	case typePTR:
+		if rr.Target, _, err = readName(data[:end], off); err != nil {
+			return record{}, 0, err
+		}
+	case typeSRV:
+		if rdlen < 7 {
+			return record{}, 0, errors.Errorf("SRV record has %d bytes", rdlen)
+		}
+		rr.Port = binary.BigEndian.Uint16(rdata[4:])
+		if rr.Target, _, err = readName(data[:end], off+6); err != nil {
+			return record{}, 0, err
+		}
+	case typeTXT:
+		for i := 0; i < len(rdata); {
+			n := int(rdata[i])
+			if i+1+n > len(rdata) {
+				return record{}, 0, errors.New("TXT string is longer than its record")
+			}
+			if n > 0 {
+				rr.Text = append(rr.Text, string(rdata[i+1:i+1+n]))
+			}
+			i += 1 + n
+		}
+	default:
+		rr.Type = 0
+	}
+	return rr, end, nil
+}
+
+// readName reads a possibly compressed domain name at off,
+// and returns the offset after it. Dots and backslashes in labels are escaped.
+func readName(data []byte, off int) (string, int, error) {
+	var (
+		labels []string
+		next   = -1
+	)
+	for jumps := 0; ; {
+		if off >= len(data) {
+			return "", 0, errors.New("unexpected end of name")
+		}
+		n := int(data[off])
+
+		switch n & 0xC0 {
+		case 0x00:
+			if n == 0 {
+				if next < 0 {
+					next = off + 1
+				}
+				return strings.Join(labels, ".") + ".", next, nil
+			}
+			if off+1+n > len(data) {
+				return "", 0, errors.New("unexpected end of name")
+			}
+			labels = append(labels, escapeLabel(string(data[off+1:off+1+n])))
+			off += 1 + n
+		case 0xC0:
+			if off+1 >= len(data) {
+				return "", 0, errors.New("unexpected end of name")
+			}
+			if jumps++; jumps > maxPointerJump {
+				return "", 0, errors.New("too many compression pointers")
+			}
+			if next < 0 {
+				next = off + 2
+			}
+			off = int(binary.BigEndian.Uint16(data[off:]) & 0x3FFF)
+		default:
+			return "", 0, errors.Errorf("invalid label length %#x", n)
+		}
+	}
+}
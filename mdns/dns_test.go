@@ -0,0 +1,105 @@
+package mdns
+
+import (
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestMessageRoundTrip(t *testing.T) {
+	m := message{
+		ID:        7,
+		Flags:     flagResponse | flagAuthority,
+		Questions: []question{{Name: "_osc._udp.local.", Type: typePTR, Class: classIN | classTopBit}},
+		Answers: []record{
+			{Name: "_osc._udp.local.", Type: typePTR, Class: classIN, TTL: 4500, Target: `Synth 2\.0._osc._udp.local.`},
+		},
+		Additionals: []record{
+			{Name: `Synth 2\.0._osc._udp.local.`, Type: typeSRV, Class: classIN | classTopBit, TTL: 120, Target: "studio.local.", Port: 9000},
+			{Name: `Synth 2\.0._osc._udp.local.`, Type: typeTXT, Class: classIN, TTL: 4500, Text: []string{"version=1", "txtvers=1"}},
+			{Name: "studio.local.", Type: typeA, Class: classIN, TTL: 120, IP: net.IPv4(192, 168, 1, 2).To4()},
+			{Name: "studio.local.", Type: typeAAAA, Class: classIN, TTL: 120, IP: net.ParseIP("fe80::1")},
+		},
+	}
+	data, err := m.marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := parseMessage(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(m, got) {
+		t.Fatalf("expected %#v, got %#v", m, got)
+	}
+	if expected, got := []string{"Synth 2.0", "_osc", "_udp", "local"}, splitName(m.Answers[0].Target); !reflect.DeepEqual(expected, got) {
+		t.Fatalf("expected %q, got %q", expected, got)
+	}
+}
+
+func TestParseMessageCompression(t *testing.T) {
+	data := []byte{
+		0, 0, 0x84, 0, 0, 0, 0, 1, 0, 0, 0, 0,
+		// _osc._udp.local. PTR
+		4, '_', 'o', 's', 'c', 4, '_', 'u', 'd', 'p', 5, 'l', 'o', 'c', 'a', 'l', 0,
+		0, typePTR, 0, classIN, 0, 0, 0x11, 0x94, 0, 8,
+		// synth + pointer to _osc._udp.local.
+		5, 's', 'y', 'n', 't', 'h', 0xC0, 12,
+	}
+	m, err := parseMessage(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := 1, len(m.Answers); expected != got {
+		t.Fatalf("expected %d answers, got %d", expected, got)
+	}
+	if expected, got := "synth._osc._udp.local.", m.Answers[0].Target; expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+}
+
+func TestParseMessageErrors(t *testing.T) {
+	header := []byte{0, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0}
+
+	for i, testcase := range []struct {
+		Data []byte
+		Err  string
+	}{
+		{
+			Data: []byte{0, 0, 0},
+			Err:  "message is shorter than its header",
+		},
+		{
+			Data: append(header, 5, 'l', 'o'),
+			Err:  "question 0: unexpected end of name",
+		},
+		{
+			Data: append(header, 0xC0, 12),
+			Err:  "question 0: too many compression pointers",
+		},
+		{
+			Data: append(header, 0x80),
+			Err:  "question 0: invalid label length 0x80",
+		},
+		{
+			Data: append(header, 0, 0, typePTR),
+			Err:  "question 0: unexpected end of message",
+		},
+		{
+			Data: []byte{0, 0, 0x84, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, typeA, 0, classIN, 0, 0, 0, 0, 0, 2, 1, 2},
+			Err:  "record 0: A record has 2 bytes",
+		},
+		{
+			Data: []byte{0, 0, 0x84, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, typeTXT, 0, classIN, 0, 0, 0, 0, 0, 2, 5, 'a'},
+			Err:  "record 0: TXT string is longer than its record",
+		},
+	} {
+		_, err := parseMessage(testcase.Data)
+		if err == nil {
+			t.Fatalf("(testcase %d) expected error, got nil", i)
+		}
+		if expected, got := testcase.Err, err.Error(); expected != got {
+			t.Fatalf("(testcase %d) expected %s, got %s", i, expected, got)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+// Package mdns advertises and browses OSC services with DNS service discovery
+// over multicast DNS (Zeroconf, Bonjour), see RFC 6762 and RFC 6763.
+//
+// A Responder advertises the address of a UDPConn as an _osc._udp service:
+//
+//	conn, err := osc.ListenUDP("udp", &net.UDPAddr{Port: 9000})
+//	service, err := mdns.NewService("My Synth", conn.LocalAddr())
+//	mconn, err := mdns.Listen()
+//	responder := mdns.NewResponder(mconn)
+//	err = responder.Advertise(service)
+//	go func() { _ = responder.Serve() }()
+//
+// A Browser finds the services on the local network:
+//
+//	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+//	addrs, err := mdns.Browse(ctx, mdns.ServiceUDP)
+//	conn, err := osc.DialUDP("udp", nil, addrs[0].(*net.UDPAddr))
+//
+// Both use a net.PacketConn, so they can be tested with any packet connection.
+// Only the parts of the protocols needed for browsing services are implemented:
+// there is no probing for conflicting names, and known answers are not suppressed.
+package mdns
+
+import (
+	"net"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Service types.
+const (
+	ServiceUDP = "_osc._udp"
+	ServiceTCP = "_osc._tcp"
+)
+
+// Port is the mDNS port.
+const Port = 5353
+
+// GroupAddr is the IPv4 mDNS multicast group.
+var GroupAddr = &net.UDPAddr{IP: net.IPv4(224, 0, 0, 251), Port: Port}
+
+// Domain names.
+const (
+	domain       = "local."
+	servicesName = "_services._dns-sd._udp.local."
+)
+
+// TTLs in seconds, as recommended by RFC 6762.
+const (
+	ttlHost    = 120
+	ttlService = 4500
+	ttlLegacy  = 10
+)
+
+// Service is an instance of a service.
+type Service struct {
+	// Instance is the name of the service instance, e.g. "My Synth".
+	Instance string
+
+	// Type is the service type, e.g. ServiceUDP.
+	Type string
+
+	// Host is the host name, e.g. "studio.local.".
+	Host string
+
+	// Port is the port of the service.
+	Port int
+
+	// IPs are the addresses of the host.
+	IPs []net.IP
+
+	// Text are the strings of the TXT record, e.g. "version=1".
+	Text []string
+}
+
+// NewService returns the service of a *net.UDPAddr or *net.TCPAddr, e.g. the local address
+// of a UDPConn. The host is the host name of the machine. If the address has no IP
+// the IPs of the network interfaces are used.
+func NewService(instance string, addr net.Addr) (Service, error) {
+	s := Service{Instance: instance}
+
+	var ip net.IP
+	switch a := addr.(type) {
+	case *net.UDPAddr:
+		s.Type, s.Port, ip = ServiceUDP, a.Port, a.IP
+	case *net.TCPAddr:
+		s.Type, s.Port, ip = ServiceTCP, a.Port, a.IP
+	default:
+		return Service{}, errors.Errorf("unsupported address type %T", addr)
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		return Service{}, errors.Wrap(err, "get hostname")
+	}
+	s.Host = strings.SplitN(hostname, ".", 2)[0] + "." + domain
+
+	if ip != nil && !ip.IsUnspecified() {
+		s.IPs = []net.IP{ip}
+		return s, s.validate()
+	}
+	if s.IPs, err = interfaceIPs(); err != nil {
+		return Service{}, err
+	}
+	return s, s.validate()
+}
+
+// Addr returns the address of the service, a *net.UDPAddr for UDP services
+// and a *net.TCPAddr for TCP services. IPv4 addresses are preferred.
+func (s Service) Addr() net.Addr {
+	var ip net.IP
+	for _, addr := range s.IPs {
+		if ip == nil || (ip.To4() == nil && addr.To4() != nil) {
+			ip = addr
+		}
+	}
+	if strings.HasSuffix(s.Type, "._tcp") {
+		return &net.TCPAddr{IP: ip, Port: s.Port}
+	}
+	return &net.UDPAddr{IP: ip, Port: s.Port}
+}
+
+// name returns the domain name of the service instance.
+func (s Service) name() string {
+	return escapeLabel(s.Instance) + "." + s.Type + "." + domain
+}
+
+// validate returns an error if the service can not be advertised.
+func (s Service) validate() error {
+	switch {
+	case s.Instance == "" || len(s.Instance) > 63:
+		return errors.Errorf("instance name %q should have 1 to 63 bytes", s.Instance)
+	case !strings.HasPrefix(s.Type, "_") || !(strings.HasSuffix(s.Type, "._udp") || strings.HasSuffix(s.Type, "._tcp")):
+		return errors.Errorf("invalid service type %q", s.Type)
+	case s.Port <= 0 || s.Port > 65535:
+		return errors.Errorf("invalid port %d", s.Port)
+	case s.Host == "":
+		return errors.New("empty host name")
+	case len(s.IPs) == 0:
+		return errors.New("no IP addresses")
+	}
+	return nil
+}
+
+// Listen listens to the IPv4 mDNS group on the default interface.
+func Listen() (net.PacketConn, error) {
+	conn, err := net.ListenMulticastUDP("udp4", nil, GroupAddr)
+	if err != nil {
+		return nil, errors.Wrap(err, "listen multicast")
+	}
+	return conn, nil
+}
+
+// interfaceIPs returns the unicast IPs of the network interfaces,
+// or the loopback IPs if there are no others.
+func interfaceIPs() ([]net.IP, error) {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return nil, errors.Wrap(err, "get interface addresses")
+	}
+	var ips, loopback []net.IP
+	for _, addr := range addrs {
+		ipnet, ok := addr.(*net.IPNet)
+		if !ok {
+			continue
+		}
+		switch ip := ipnet.IP; {
+		case ip.IsLoopback():
+			loopback = append(loopback, ip)
+		case ip.IsGlobalUnicast():
+			ips = append(ips, ip)
+		}
+	}
+	if len(ips) == 0 {
+		ips = loopback
+	}
+	if len(ips) == 0 {
+		return nil, errors.New("no IP addresses")
+	}
+	return ips, nil
+}
@@ -0,0 +1,362 @@
+package mdns
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// listenLoopback returns a UDP connection on loopback.
+// The caller closes it.
+func listenLoopback(t *testing.T) *net.UDPConn {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
+
+// testResponder returns a responder on loopback, that sends announcements to group,
+// and a channel that emits the error returned from its Serve method.
+// The caller stops it with closeResponder.
+func testResponder(t *testing.T, group net.Addr) (*Responder, chan error) {
+	r := NewResponder(listenLoopback(t))
+	r.SetGroup(group)
+
+	errs := make(chan error, 1)
+	go func() { errs <- r.Serve() }()
+
+	return r, errs
+}
+
+// closeResponder closes a responder and checks the error returned from its Serve method.
+func closeResponder(t *testing.T, r *Responder, errs chan error) {
+	if err := r.Close(); err != nil {
+		t.Error(err)
+	}
+	if err := <-errs; err != nil {
+		t.Error(err)
+	}
+}
+
+// readMessage reads a DNS message from conn.
+func readMessage(t *testing.T, conn net.PacketConn) (message, net.Addr) {
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	buf := make([]byte, 9000)
+	n, addr, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := parseMessage(buf[:n])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m, addr
+}
+
+var testServices = []Service{
+	{
+		Instance: "Synth 2.0",
+		Type:     ServiceUDP,
+		Host:     "studio.local.",
+		Port:     9000,
+		IPs:      []net.IP{net.ParseIP("::1"), net.IPv4(127, 0, 0, 1)},
+		Text:     []string{"version=1"},
+	},
+	{
+		Instance: "Mixer",
+		Type:     ServiceTCP,
+		Host:     "studio.local",
+		Port:     9001,
+		IPs:      []net.IP{net.IPv4(127, 0, 0, 1)},
+	},
+}
+
+func TestBrowse(t *testing.T) {
+	var (
+		group   = listenLoopback(t)
+		r, errs = testResponder(t, group.LocalAddr())
+	)
+	defer func() { _ = group.Close() }()
+	defer closeResponder(t, r, errs)
+
+	for _, s := range testServices {
+		if err := r.Advertise(s); err != nil {
+			t.Fatal(err)
+		}
+		m, _ := readMessage(t, group)
+		if expected, got := 5, len(m.Answers); s.Type == ServiceUDP && expected != got {
+			t.Fatalf("expected %d records in the announcement, got %d", expected, got)
+		}
+	}
+	conn := listenLoopback(t)
+	defer func() { _ = conn.Close() }()
+
+	b := NewBrowser(conn)
+	b.SetGroup(r.conn.LocalAddr())
+
+	for i, testcase := range []struct {
+		Type string
+		Addr string
+	}{
+		{Type: ServiceUDP, Addr: "udp 127.0.0.1:9000"},
+		{Type: ServiceTCP, Addr: "tcp 127.0.0.1:9001"},
+	} {
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		services, err := b.Browse(ctx, testcase.Type)
+		cancel()
+		if err != nil {
+			t.Fatalf("(testcase %d) %s", i, err)
+		}
+		if expected, got := 1, len(services); expected != got {
+			t.Fatalf("(testcase %d) expected %d services, got %d", i, expected, got)
+		}
+		s := services[0]
+		if expected, got := testServices[i].Instance, s.Instance; expected != got {
+			t.Fatalf("(testcase %d) expected %s, got %s", i, expected, got)
+		}
+		if expected, got := testServices[i].Text, s.Text; !reflect.DeepEqual(expected, got) {
+			t.Fatalf("(testcase %d) expected %q, got %q", i, expected, got)
+		}
+		if expected, got := testcase.Addr, s.Addr().Network()+" "+s.Addr().String(); expected != got {
+			t.Fatalf("(testcase %d) expected %s, got %s", i, expected, got)
+		}
+	}
+	if err := r.Withdraw(testServices[0]); err != nil {
+		t.Fatal(err)
+	}
+	m, _ := readMessage(t, group)
+	if expected, got := uint32(0), m.Answers[0].TTL; len(m.Answers) != 1 || expected != got {
+		t.Fatalf("expected a goodbye record, got %#v", m.Answers)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	services, err := b.Browse(ctx, ServiceUDP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := 0, len(services); expected != got {
+		t.Fatalf("expected %d services, got %d", expected, got)
+	}
+	if err := r.Withdraw(testServices[0]); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+}
+
+func TestResponderAnswers(t *testing.T) {
+	var (
+		group   = listenLoopback(t)
+		r, errs = testResponder(t, group.LocalAddr())
+		conn    = listenLoopback(t)
+	)
+	defer func() { _ = group.Close() }()
+	defer closeResponder(t, r, errs)
+	defer func() { _ = conn.Close() }()
+
+	for _, s := range testServices {
+		if err := r.Advertise(s); err != nil {
+			t.Fatal(err)
+		}
+		_, _ = readMessage(t, group)
+	}
+	for i, testcase := range []struct {
+		Question question
+		Answers  []string
+	}{
+		{
+			Question: question{Name: servicesName, Type: typePTR, Class: classIN},
+			Answers:  []string{"_osc._tcp.local.", "_osc._udp.local."},
+		},
+		{
+			Question: question{Name: `SYNTH 2\.0._osc._udp.local.`, Type: typeSRV, Class: classIN},
+			Answers:  []string{"studio.local."},
+		},
+		{
+			Question: question{Name: "studio.local.", Type: typeA, Class: classIN},
+			Answers:  []string{"127.0.0.1"},
+		},
+		{
+			Question: question{Name: "studio.local.", Type: typeAAAA, Class: classIN},
+			Answers:  []string{"::1"},
+		},
+	} {
+		data, err := message{ID: uint16(i), Questions: []question{testcase.Question}}.marshal()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := conn.WriteTo(data, r.conn.LocalAddr()); err != nil {
+			t.Fatal(err)
+		}
+		// The answer is unicast since the querier does not use the mDNS port.
+		m, _ := readMessage(t, conn)
+		if expected, got := uint16(i), m.ID; expected != got {
+			t.Fatalf("(testcase %d) expected ID %d, got %d", i, expected, got)
+		}
+		var answers []string
+		for _, rr := range m.Answers {
+			if rr.TTL > ttlLegacy {
+				t.Fatalf("(testcase %d) expected a TTL of at most %d, got %d", i, ttlLegacy, rr.TTL)
+			}
+			switch rr.Type {
+			case typeA, typeAAAA:
+				answers = append(answers, rr.IP.String())
+			default:
+				answers = append(answers, rr.Target)
+			}
+		}
+		if expected, got := testcase.Answers, answers; !reflect.DeepEqual(expected, got) {
+			t.Fatalf("(testcase %d) expected %q, got %q", i, expected, got)
+		}
+	}
+}
+
+// failConn is a connection whose writes to one address fail.
+type failConn struct {
+	net.PacketConn
+	fail net.Addr
+}
+
+func (c failConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	if addr.String() == c.fail.String() {
+		return 0, errors.New("oops")
+	}
+	return c.PacketConn.WriteTo(b, addr)
+}
+
+func TestResponderSendError(t *testing.T) {
+	var (
+		bad  = listenLoopback(t)
+		good = listenLoopback(t)
+		conn = listenLoopback(t)
+		r    = NewResponder(failConn{PacketConn: conn, fail: bad.LocalAddr()})
+		errs = make(chan error, 1)
+		done = make(chan error, 1)
+	)
+	defer func() { _ = bad.Close() }()
+	defer func() { _ = good.Close() }()
+	defer func() { _ = conn.Close() }()
+
+	r.SetGroup(good.LocalAddr())
+	r.SetErrorHandler(func(err error) { errs <- err })
+
+	if err := r.Advertise(testServices[0]); err != nil {
+		t.Fatal(err)
+	}
+	_, _ = readMessage(t, good)
+
+	go func() { done <- r.Serve() }()
+
+	data, err := message{Questions: []question{{Name: "studio.local.", Type: typeA, Class: classIN}}}.marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The failed answer is passed to the error handler, and the next query is still answered.
+	if _, err := bad.WriteTo(data, conn.LocalAddr()); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for the error")
+	case err := <-errs:
+		if expected, got := "answer "+bad.LocalAddr().String()+": send message: oops", err.Error(); expected != got {
+			t.Fatalf("expected %s, got %s", expected, got)
+		}
+	}
+	if _, err := good.WriteTo(data, conn.LocalAddr()); err != nil {
+		t.Fatal(err)
+	}
+	if m, _ := readMessage(t, good); len(m.Answers) != 1 {
+		t.Fatalf("expected 1 answer, got %d", len(m.Answers))
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBrowseFollowUp(t *testing.T) {
+	var (
+		responder = listenLoopback(t)
+		conn      = listenLoopback(t)
+		b         = NewBrowser(conn)
+	)
+	defer func() { _ = responder.Close() }()
+	defer func() { _ = conn.Close() }()
+
+	b.SetGroup(responder.LocalAddr())
+
+	// The responder answers with one record at a time, so the browser has to ask for the others.
+	go func() {
+		for _, answer := range []record{
+			{Name: "_osc._udp.local.", Type: typePTR, Class: classIN, TTL: 4500, Target: "synth._osc._udp.local."},
+			{Name: "synth._osc._udp.local.", Type: typeSRV, Class: classIN, TTL: 120, Target: "studio.local.", Port: 9000},
+			{Name: "studio.local.", Type: typeA, Class: classIN, TTL: 120, IP: net.IPv4(127, 0, 0, 1).To4()},
+		} {
+			for {
+				buf := make([]byte, 9000)
+				n, addr, err := responder.ReadFrom(buf)
+				if err != nil {
+					return
+				}
+				m, err := parseMessage(buf[:n])
+				if err != nil || len(m.Questions) != 1 || m.Questions[0].Type != answer.Type {
+					continue
+				}
+				data, _ := message{Flags: flagResponse, Answers: []record{answer}}.marshal()
+				_, _ = responder.WriteTo(data, addr)
+				break
+			}
+		}
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+
+	services, err := b.Browse(ctx, ServiceUDP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := 1, len(services); expected != got {
+		t.Fatalf("expected %d services, got %d", expected, got)
+	}
+	if expected, got := "127.0.0.1:9000", services[0].Addr().String(); expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+}
+
+func TestNewService(t *testing.T) {
+	s, err := NewService("Synth", &net.UDPAddr{IP: net.IPv4(192, 168, 1, 2), Port: 9000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := ServiceUDP, s.Type; expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+	if expected, got := "192.168.1.2:9000", s.Addr().String(); expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+	for i, testcase := range []struct {
+		Instance string
+		Addr     net.Addr
+		Err      string
+	}{
+		{Instance: "Synth", Addr: &net.UnixAddr{Name: "/tmp/osc.sock", Net: "unixgram"}, Err: "unsupported address type *net.UnixAddr"},
+		{Instance: "", Addr: &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 9000}, Err: `instance name "" should have 1 to 63 bytes`},
+		{Instance: "Synth", Addr: &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)}, Err: "invalid port 0"},
+	} {
+		_, err := NewService(testcase.Instance, testcase.Addr)
+		if err == nil {
+			t.Fatalf("(testcase %d) expected error, got nil", i)
+		}
+		if expected, got := testcase.Err, err.Error(); expected != got {
+			t.Fatalf("(testcase %d) expected %s, got %s", i, expected, got)
+		}
+	}
+}
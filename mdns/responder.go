@@ -0,0 +1,314 @@
+package mdns
+
+import (
+	"net"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Responder advertises services and answers the queries for them.
+type Responder struct {
+	conn  net.PacketConn
+	group net.Addr
+
+	mu           sync.Mutex
+	closed       bool
+	services     map[string]Service
+	errorHandler func(error)
+}
+
+// NewResponder returns a responder that reads queries from conn,
+// e.g. a connection returned by Listen. Announcements are sent to GroupAddr.
+func NewResponder(conn net.PacketConn) *Responder {
+	return &Responder{
+		conn:     conn,
+		group:    GroupAddr,
+		services: map[string]Service{},
+	}
+}
+
+// SetGroup sets the address that announcements and multicast answers are sent to.
+func (r *Responder) SetGroup(addr net.Addr) {
+	r.mu.Lock()
+	r.group = addr
+	r.mu.Unlock()
+}
+
+// SetErrorHandler sets a function that is called with the errors of answers
+// that could not be sent. Serve keeps answering queries after them,
+// and they are ignored by default.
+func (r *Responder) SetErrorHandler(handler func(error)) {
+	r.mu.Lock()
+	r.errorHandler = handler
+	r.mu.Unlock()
+}
+
+// Advertise adds a service and announces it.
+// A service with the same instance name and type is replaced.
+func (r *Responder) Advertise(s Service) error {
+	if err := s.validate(); err != nil {
+		return err
+	}
+	s.Host = strings.TrimSuffix(s.Host, ".") + "."
+
+	r.mu.Lock()
+	r.services[strings.ToLower(s.name())] = s
+	group := r.group
+	r.mu.Unlock()
+
+	ptr, srv, txt, addrs := serviceRecords(s, ttlService, ttlHost)
+	return r.send(group, message{
+		Flags:   flagResponse | flagAuthority,
+		Answers: append([]record{ptr, srv, txt}, addrs...),
+	})
+}
+
+// Withdraw removes a service and announces that it is gone.
+func (r *Responder) Withdraw(s Service) error {
+	key := strings.ToLower(s.name())
+
+	r.mu.Lock()
+	s, ok := r.services[key]
+	delete(r.services, key)
+	group := r.group
+	r.mu.Unlock()
+
+	if !ok {
+		return errors.Errorf("service %s is not advertised", key)
+	}
+	return r.send(group, goodbye(s))
+}
+
+// Serve answers queries until the connection is closed.
+// It returns nil if the responder was closed. Answers that can't be sent
+// don't stop it, see SetErrorHandler.
+func (r *Responder) Serve() error {
+	buf := make([]byte, 9000)
+	for {
+		n, src, err := r.conn.ReadFrom(buf)
+		if err != nil {
+			r.mu.Lock()
+			closed := r.closed
+			r.mu.Unlock()
+
+			if closed {
+				return nil
+			}
+			return errors.Wrap(err, "read query")
+		}
+		m, err := parseMessage(buf[:n])
+		if err != nil || m.Flags&flagResponse != 0 {
+			continue
+		}
+		if err := r.respond(m, src); err != nil {
+			r.handleError(errors.Wrapf(err, "answer %s", src))
+		}
+	}
+}
+
+// handleError passes an error to the error handler, if there is one.
+func (r *Responder) handleError(err error) {
+	r.mu.Lock()
+	handler := r.errorHandler
+	r.mu.Unlock()
+
+	if handler != nil {
+		handler(err)
+	}
+}
+
+// Close announces that the services are gone, and closes the connection.
+func (r *Responder) Close() error {
+	r.mu.Lock()
+	services, group := r.services, r.group
+	r.services, r.closed = map[string]Service{}, true
+	r.mu.Unlock()
+
+	for _, s := range services {
+		_ = r.send(group, goodbye(s)) // Best effort.
+	}
+	return r.conn.Close()
+}
+
+// respond answers a query. Queriers that do not use the mDNS port are legacy
+// unicast DNS resolvers, which get a unicast answer, see RFC 6762 section 6.7.
+// So do questions with the unicast response bit. Other answers are multicast.
+func (r *Responder) respond(query message, src net.Addr) error {
+	var (
+		resp    = message{Flags: flagResponse | flagAuthority}
+		legacy  = port(src) != Port
+		unicast = legacy
+	)
+	r.mu.Lock()
+	for _, q := range query.Questions {
+		answers, additionals := r.answer(q)
+		if len(answers) == 0 {
+			continue
+		}
+		if q.Class&classTopBit != 0 {
+			unicast = true
+		}
+		resp.Answers = appendUnique(resp.Answers, answers...)
+		resp.Additionals = appendUnique(resp.Additionals, additionals...)
+	}
+	group := r.group
+	r.mu.Unlock()
+
+	if len(resp.Answers) == 0 {
+		return nil
+	}
+	resp.Additionals = removeAnswers(resp.Additionals, resp.Answers)
+
+	if legacy {
+		resp.ID, resp.Questions = query.ID, query.Questions
+		for _, records := range [][]record{resp.Answers, resp.Additionals} {
+			for i := range records {
+				records[i].Class &= classMask
+				if records[i].TTL > ttlLegacy {
+					records[i].TTL = ttlLegacy
+				}
+			}
+		}
+	}
+	if unicast {
+		return r.send(src, resp)
+	}
+	return r.send(group, resp)
+}
+
+// answer returns the answers to a question, and the additional records that come with them.
+// It must be called with r.mu held.
+func (r *Responder) answer(q question) (answers, additionals []record) {
+	var (
+		name  = q.Name
+		qtype = q.Type
+		ptr   = qtype == typePTR || qtype == typeANY
+		srv   = qtype == typeSRV || qtype == typeANY
+		txt   = qtype == typeTXT || qtype == typeANY
+		host  = qtype == typeA || qtype == typeAAAA || qtype == typeANY
+		types = map[string]bool{}
+	)
+	if class := q.Class & classMask; class != classIN && class != classANY {
+		return nil, nil
+	}
+	for _, s := range r.sortedServices() {
+		ptrRecord, srvRecord, txtRecord, addrRecords := serviceRecords(s, ttlService, ttlHost)
+
+		switch {
+		case ptr && strings.EqualFold(name, servicesName):
+			if !types[s.Type] {
+				types[s.Type] = true
+				answers = append(answers, record{Name: servicesName, Type: typePTR, Class: classIN, TTL: ttlService, Target: s.Type + "." + domain})
+			}
+		case ptr && strings.EqualFold(name, s.Type+"."+domain):
+			answers = append(answers, ptrRecord)
+			additionals = append(additionals, srvRecord, txtRecord)
+			additionals = append(additionals, addrRecords...)
+		case (srv || txt) && strings.EqualFold(name, s.name()):
+			if srv {
+				answers = append(answers, srvRecord)
+				additionals = append(additionals, addrRecords...)
+			}
+			if txt {
+				answers = append(answers, txtRecord)
+			}
+		case host && strings.EqualFold(name, s.Host):
+			for _, rr := range addrRecords {
+				if qtype == typeANY || qtype == rr.Type {
+					answers = append(answers, rr)
+				}
+			}
+		}
+	}
+	return answers, additionals
+}
+
+// sortedServices returns the services in the order of their names.
+// It must be called with r.mu held.
+func (r *Responder) sortedServices() []Service {
+	services := make([]Service, 0, len(r.services))
+	for _, s := range r.services {
+		services = append(services, s)
+	}
+	sort.Slice(services, func(i, j int) bool {
+		return services[i].name() < services[j].name()
+	})
+	return services
+}
+
+// send sends a message to addr.
+func (r *Responder) send(addr net.Addr, m message) error {
+	data, err := m.marshal()
+	if err != nil {
+		return errors.Wrap(err, "encode message")
+	}
+	if _, err := r.conn.WriteTo(data, addr); err != nil {
+		return errors.Wrap(err, "send message")
+	}
+	return nil
+}
+
+// serviceRecords returns the PTR, SRV and TXT records of a service, and the address records of its host.
+func serviceRecords(s Service, ttl, hostTTL uint32) (ptr, srv, txt record, addrs []record) {
+	ptr = record{Name: s.Type + "." + domain, Type: typePTR, Class: classIN, TTL: ttl, Target: s.name()}
+	srv = record{Name: s.name(), Type: typeSRV, Class: classIN | classTopBit, TTL: hostTTL, Target: s.Host, Port: uint16(s.Port)}
+	txt = record{Name: s.name(), Type: typeTXT, Class: classIN | classTopBit, TTL: ttl, Text: s.Text}
+
+	for _, ip := range s.IPs {
+		rr := record{Name: s.Host, Type: typeAAAA, Class: classIN | classTopBit, TTL: hostTTL, IP: ip.To16()}
+		if ip4 := ip.To4(); ip4 != nil {
+			rr.Type, rr.IP = typeA, ip4
+		}
+		addrs = append(addrs, rr)
+	}
+	return ptr, srv, txt, addrs
+}
+
+// goodbye returns the announcement that a service is gone, its PTR record with a TTL of 0.
+func goodbye(s Service) message {
+	ptr, _, _, _ := serviceRecords(s, 0, 0)
+	return message{Flags: flagResponse | flagAuthority, Answers: []record{ptr}}
+}
+
+// appendUnique appends the records that are not in records yet.
+func appendUnique(records []record, rrs ...record) []record {
+	for _, rr := range rrs {
+		if !containsRecord(records, rr) {
+			records = append(records, rr)
+		}
+	}
+	return records
+}
+
+// removeAnswers removes the records that are answers from the additional records.
+func removeAnswers(additionals, answers []record) []record {
+	var records []record
+	for _, rr := range additionals {
+		if !containsRecord(answers, rr) {
+			records = append(records, rr)
+		}
+	}
+	return records
+}
+
+// containsRecord returns true if records contains rr.
+func containsRecord(records []record, rr record) bool {
+	for _, x := range records {
+		if strings.EqualFold(x.Name, rr.Name) && x.Type == rr.Type && strings.EqualFold(x.Target, rr.Target) &&
+			x.Port == rr.Port && x.IP.Equal(rr.IP) && strings.Join(x.Text, "\x00") == strings.Join(rr.Text, "\x00") {
+			return true
+		}
+	}
+	return false
+}
+
+// port returns the port of a UDP address, or 0.
+func port(addr net.Addr) int {
+	if a, ok := addr.(*net.UDPAddr); ok {
+		return a.Port
+	}
+	return 0
+}
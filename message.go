@@ -1,8 +1,6 @@
 package osc
 
 import (
-	"bytes"
-	"fmt"
 	"io"
 	"net"
 	"regexp"
@@ -34,9 +32,9 @@ func ParseMessage(data []byte, sender net.Addr) (Message, error) {
 		Address: address,
 		Sender:  sender,
 	}
-	data = data[idx:]
+	data = data[clampIndex(idx, data):]
 	typetags, idx := ReadString(data)
-	data = data[idx:]
+	data = data[clampIndex(idx, data):]
 
 	// Read all arguments.
 	args, err := ReadArguments([]byte(typetags), data)
@@ -48,16 +46,30 @@ func ParseMessage(data []byte, sender net.Addr) (Message, error) {
 	return msg, nil
 }
 
-// Bytes returns the contents of the message as a slice of bytes.
-func (msg Message) Bytes() []byte {
-	b := [][]byte{
-		ToBytes(msg.Address),
-		msg.Typetags(),
+// clampIndex limits idx to the length of data.
+// ReadString pads strings that are not null-terminated, which can make
+// the number of bytes it consumes larger than the data it was given.
+func clampIndex(idx int64, data []byte) int64 {
+	if idx > int64(len(data)) {
+		return int64(len(data))
 	}
+	return idx
+}
+
+// AppendTo appends the contents of the message to dst and returns the extended slice.
+// If dst has enough capacity no allocations are made.
+func (msg Message) AppendTo(dst []byte) []byte {
+	dst = AppendString(dst, msg.Address)
+	dst = msg.appendTypetags(dst)
 	for _, a := range msg.Arguments {
-		b = append(b, a.Bytes())
+		dst = a.AppendTo(dst)
 	}
-	return bytes.Join(b, []byte{})
+	return dst
+}
+
+// Bytes returns the contents of the message as a slice of bytes.
+func (msg Message) Bytes() []byte {
+	return msg.AppendTo(make([]byte, 0, msg.Size()))
 }
 
 // Equal returns true if the messages are equal, false otherwise.
@@ -80,6 +92,27 @@ func (msg Message) Equal(other Packet) bool {
 	return true
 }
 
+// MarshalBinary implements encoding.BinaryMarshaler.
+// It returns the same bytes as Bytes.
+func (msg Message) MarshalBinary() ([]byte, error) {
+	return msg.Bytes(), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+// The message's Sender is left unchanged.
+func (msg *Message) UnmarshalBinary(data []byte) error {
+	if len(data) == 0 || data[0] != MessageChar {
+		return errors.Wrap(ErrParse, "message must start with /")
+	}
+	// Parsed blobs refer to the data they were parsed from, so make a copy.
+	m, err := ParseMessage(append([]byte{}, data...), msg.Sender)
+	if err != nil {
+		return err
+	}
+	*msg = m
+	return nil
+}
+
 // Match returns true if the address of the OSC Message matches the given address.
 func (msg Message) Match(address string, exactMatch bool) (bool, error) {
 	if exactMatch {
@@ -96,35 +129,51 @@ func (msg Message) Match(address string, exactMatch bool) (bool, error) {
 	return exp.MatchString(address), nil
 }
 
+// Size returns the number of bytes in the binary representation of the message.
+func (msg Message) Size() int {
+	size := StringSize(msg.Address) + padSize(len(msg.Arguments)+2)
+	for _, a := range msg.Arguments {
+		size += a.Size()
+	}
+	return size
+}
+
 // Typetags returns a padded byte slice of the message's type tags.
 func (msg Message) Typetags() []byte {
-	tt := make([]byte, len(msg.Arguments)+1)
-	tt[0] = TypetagPrefix
-	for i, a := range msg.Arguments {
-		tt[i+1] = a.Typetag()
+	return msg.appendTypetags(make([]byte, 0, padSize(len(msg.Arguments)+2)))
+}
+
+// appendTypetags appends the padded type tags of the message to dst.
+func (msg Message) appendTypetags(dst []byte) []byte {
+	start := len(dst)
+	dst = append(dst, TypetagPrefix)
+	for _, a := range msg.Arguments {
+		dst = append(dst, a.Typetag())
 	}
-	return Pad(append(tt, 0))
+	return appendPad(append(dst, 0), start)
 }
 
-// WriteTo writes the Message to an io.Writer.
+// WriteTo writes the binary representation of the Message to an io.Writer.
+// The written bytes are exactly the ones returned by Bytes.
 func (msg Message) WriteTo(w io.Writer) (int64, error) {
-	var bytesWritten int
+	buf := bufPool.Get().(*[]byte)
+	defer bufPool.Put(buf)
 
-	nw, err := fmt.Fprintf(w, "%s%s", msg.Address, msg.Typetags())
+	*buf = msg.appendTypetags(AppendString((*buf)[:0], msg.Address))
+
+	nw, err := w.Write(*buf)
+	bytesWritten := int64(nw)
 	if err != nil {
-		return 0, err
+		return bytesWritten, err
 	}
-	bytesWritten += nw
-
 	for _, a := range msg.Arguments {
 		nw, err := a.WriteTo(w)
+		bytesWritten += nw
 		if err != nil {
-			return 0, err
+			return bytesWritten, err
 		}
-		bytesWritten += int(nw)
 	}
-
-	return int64(bytesWritten), nil
+	return bytesWritten, nil
 }
 
 // GetRegex compiles and returns a regular expression object for the given address pattern.
@@ -185,6 +185,54 @@ func TestMesssageBytes(t *testing.T) {
 	}
 }
 
+func TestMessageAppendTo(t *testing.T) {
+	msg := Message{
+		Address:   "/foo/bar",
+		Arguments: []Argument{Int(1), Float(2), String("baz"), Bool(true), Blob("blob")},
+	}
+	if expected, got := msg.Bytes(), msg.AppendTo(nil); !bytes.Equal(expected, got) {
+		t.Fatalf("expected %q, got %q", expected, got)
+	}
+	if expected, got := len(msg.Bytes()), msg.Size(); expected != got {
+		t.Fatalf("expected %d, got %d", expected, got)
+	}
+	buf := make([]byte, 0, msg.Size())
+	if allocs := testing.AllocsPerRun(100, func() {
+		buf = msg.AppendTo(buf[:0])
+	}); allocs != 0 {
+		t.Fatalf("expected 0 allocations, got %f", allocs)
+	}
+}
+
+func BenchmarkMessageAppendTo(b *testing.B) {
+	var (
+		msg = Message{
+			Address:   "/synth/freq",
+			Arguments: []Argument{Int(1000), Float(440), String("sine")},
+		}
+		buf = make([]byte, 0, msg.Size())
+	)
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		buf = msg.AppendTo(buf[:0])
+	}
+}
+
+func BenchmarkMessageBytes(b *testing.B) {
+	msg := Message{
+		Address:   "/synth/freq",
+		Arguments: []Argument{Int(1000), Float(440), String("sine")},
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		_ = msg.Bytes()
+	}
+}
+
 type errWriter struct {
 	erridx int
 	curr   int
@@ -245,7 +293,7 @@ func TestParseMessage(t *testing.T) {
 					Address: "/foo",
 					Arguments: []Argument{
 						Int(1),
-						Blob([]byte{'b', 'a', 'r', 0}),
+						Blob([]byte{'b', 'a', 'r'}),
 					},
 				},
 			},
@@ -260,7 +308,31 @@ func TestParseMessage(t *testing.T) {
 					[]byte{},
 				),
 			},
-			Expected: Output{Err: errors.New(`read argument 0: typetag "Q": invalid typetag`)},
+			Expected: Output{Err: errors.New(`parse message: read argument 0: typetag "Q": invalid type tag`)},
+		},
+		{
+			// Strings that are not null-terminated are padded, but not past the end of the data.
+			Input: Input{
+				data: []byte{'/', 'f', 'o', 'o', 0, 0, 0, 0, TypetagPrefix, TypetagInt, 0, 0, 0, 0, 0, 1, '/', 'b', 'a', 'r'},
+			},
+			Expected: Output{
+				Message: Message{Address: "/foo", Arguments: []Argument{Int(1)}},
+			},
+		},
+		{
+			Input: Input{
+				data: []byte{'/', 'f', 'o', 'o'},
+			},
+			Expected: Output{
+				Message: Message{Address: "/foo"},
+			},
+		},
+		{
+			// A string argument that is not null-terminated.
+			Input: Input{
+				data: []byte("/0\x000s00\x000"),
+			},
+			Expected: Output{Err: errors.New("parse message: read argument 0: argument needs 4 bytes, only 1 left: error parsing message")},
 		},
 	} {
 		msg, err := ParseMessage(testcase.Input.data, testcase.Input.sender)
@@ -272,6 +344,12 @@ func TestParseMessage(t *testing.T) {
 				t.Fatalf("(testcase %d) expected %s, got %s", i, expected, got)
 			}
 		} else {
+			if err == nil {
+				t.Fatalf("(testcase %d) expected error, got nil", i)
+			}
+			if expected, got := testcase.Expected.Err.Error(), err.Error(); expected != got {
+				t.Fatalf("(testcase %d) expected %s, got %s", i, expected, got)
+			}
 		}
 	}
 }
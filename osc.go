@@ -6,6 +6,7 @@ import (
 	"encoding/binary"
 	"net"
 	"strings"
+	"sync"
 
 	"github.com/pkg/errors"
 )
@@ -20,6 +21,7 @@ const (
 	TypetagPrefix byte = ','
 	TypetagInt    byte = 'i'
 	TypetagFloat  byte = 'f'
+	TypetagDouble byte = 'd'
 	TypetagString byte = 's'
 	TypetagBlob   byte = 'b'
 	TypetagFalse  byte = 'F'
@@ -50,6 +52,39 @@ func ToBytes(s string) []byte {
 	return Pad(append([]byte(s), 0))
 }
 
+// AppendString appends the OSC representation of the given string to dst.
+// The appended bytes are the same as the ones returned by ToBytes.
+func AppendString(dst []byte, s string) []byte {
+	if len(s) == 0 {
+		return dst
+	}
+	start := len(dst)
+	dst = append(append(dst, s...), 0)
+	return appendPad(dst, start)
+}
+
+// StringSize returns the number of bytes in the OSC representation of the given string.
+func StringSize(s string) int {
+	if len(s) == 0 {
+		return 0
+	}
+	return padSize(len(s) + 1)
+}
+
+// appendPad appends null bytes to dst so that the number of bytes
+// following start is a multiple of 4.
+func appendPad(dst []byte, start int) []byte {
+	for i := len(dst) - start; (i % 4) != 0; i++ {
+		dst = append(dst, 0)
+	}
+	return dst
+}
+
+// padSize rounds n up to the nearest multiple of 4.
+func padSize(n int) int {
+	return (n + 3) &^ 3
+}
+
 // Pad pads a slice of bytes with null bytes so that it's length is a multiple of 4.
 func Pad(b []byte) []byte {
 	for i := len(b); (i % 4) != 0; i++ {
@@ -97,12 +132,82 @@ func ReadBlob(length int32, data []byte) ([]byte, int64) {
 	return data[:idx], int64(idx)
 }
 
+// ParsePacket parses an OSC packet from a slice of bytes.
+// The first byte of data determines whether it is parsed
+// as a Message or as a Bundle.
+func ParsePacket(data []byte, sender net.Addr) (Packet, error) {
+	if len(data) == 0 {
+		return nil, errors.Wrap(ErrParse, "empty packet")
+	}
+	switch data[0] {
+	case MessageChar:
+		msg, err := ParseMessage(data, sender)
+		if err != nil {
+			return nil, err
+		}
+		return msg, nil
+	case BundleTag[0]:
+		bundle, err := ParseBundle(data, sender)
+		if err != nil {
+			return nil, err
+		}
+		return bundle, nil
+	default:
+		return nil, errors.Wrapf(ErrParse, "packet should never start with %c", data[0])
+	}
+}
+
 // Incoming represents incoming data.
 type Incoming struct {
 	Data   []byte
 	Sender net.Addr
 }
 
+// appender is implemented by packets that can append their
+// binary representation to a byte slice without allocating.
+type appender interface {
+	AppendTo(dst []byte) []byte
+}
+
+// sizer is implemented by packets that know the size of their binary representation.
+type sizer interface {
+	Size() int
+}
+
+// appendPacket appends the binary representation of p to dst.
+// Packets that don't implement AppendTo fall back to Bytes.
+func appendPacket(dst []byte, p Packet) []byte {
+	if a, ok := p.(appender); ok {
+		return a.AppendTo(dst)
+	}
+	return append(dst, p.Bytes()...)
+}
+
+// packetSize returns the size of the binary representation of p.
+// Packets that don't implement Size fall back to Bytes.
+func packetSize(p Packet) int {
+	if s, ok := p.(sizer); ok {
+		return s.Size()
+	}
+	return len(p.Bytes())
+}
+
+// bufPool holds the buffers that are used to encode outgoing packets.
+var bufPool = sync.Pool{
+	New: func() interface{} {
+		b := make([]byte, 0, bufSize)
+		return &b
+	},
+}
+
+// encodePacket encodes p into a buffer from bufPool.
+// The caller should put the buffer back in the pool when they are done with it.
+func encodePacket(p Packet) *[]byte {
+	buf := bufPool.Get().(*[]byte)
+	*buf = appendPacket((*buf)[:0], p)
+	return buf
+}
+
 type netWriter interface {
 	SetWriteBuffer(bytes int) error
 	WriteTo([]byte, net.Addr) (int, error)
@@ -2,7 +2,12 @@ package osc
 
 import (
 	"bytes"
+	"encoding"
+	"io"
+	"math/rand"
 	"testing"
+
+	"github.com/pkg/errors"
 )
 
 func TestToBytes(t *testing.T) {
@@ -153,3 +158,185 @@ func TestReadBlob(t *testing.T) {
 		}
 	}
 }
+
+func TestAppendString(t *testing.T) {
+	for _, s := range []string{"", "a", "abc", "abcd", "abcde"} {
+		if expected, got := ToBytes(s), AppendString(nil, s); !bytes.Equal(expected, got) {
+			t.Fatalf("expected %q, got %q", expected, got)
+		}
+		if expected, got := len(ToBytes(s)), StringSize(s); expected != got {
+			t.Fatalf("expected %d, got %d", expected, got)
+		}
+	}
+}
+
+// randomArgument returns an argument of random type and value.
+func randomArgument(r *rand.Rand) Argument {
+	switch r.Intn(5) {
+	case 0:
+		return Int(r.Int31() - r.Int31())
+	case 1:
+		return Float(r.NormFloat64())
+	case 2:
+		return Bool(r.Intn(2) == 0)
+	case 3:
+		return String(randomString(r))
+	default:
+		b := make([]byte, r.Intn(16))
+		_, _ = r.Read(b)
+		return Blob(b)
+	}
+}
+
+// randomString returns a string of random printable characters.
+func randomString(r *rand.Rand) string {
+	const chars = "abcdefghijklmnopqrstuvwxyz0123456789_-"
+
+	b := make([]byte, 1+r.Intn(12))
+	for i := range b {
+		b[i] = chars[r.Intn(len(chars))]
+	}
+	return string(b)
+}
+
+// randomMessage returns a message with a random address and random arguments.
+func randomMessage(r *rand.Rand) Message {
+	msg := Message{Address: "/" + randomString(r)}
+	for i := r.Intn(3); i > 0; i-- {
+		msg.Address += "/" + randomString(r)
+	}
+	for i := r.Intn(6); i > 0; i-- {
+		msg.Arguments = append(msg.Arguments, randomArgument(r))
+	}
+	return msg
+}
+
+// randomPacket returns a random message, or a bundle of random packets
+// that nests bundles no deeper than depth.
+func randomPacket(r *rand.Rand, depth int) Packet {
+	if depth == 0 || r.Intn(3) > 0 {
+		return randomMessage(r)
+	}
+	b := Bundle{Timetag: Timetag(r.Uint64())}
+	for i := r.Intn(4); i > 0; i-- {
+		b.Packets = append(b.Packets, randomPacket(r, depth-1))
+	}
+	return b
+}
+
+func TestWriteToRandomPackets(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 500; i++ {
+		var (
+			p   = randomPacket(r, 3)
+			buf = &bytes.Buffer{}
+		)
+		n, err := p.(io.WriterTo).WriteTo(buf)
+		if err != nil {
+			t.Fatalf("(packet %d) %s", i, err)
+		}
+		if expected, got := p.Bytes(), buf.Bytes(); !bytes.Equal(expected, got) {
+			t.Fatalf("(packet %d) expected %q, got %q", i, expected, got)
+		}
+		if expected, got := int64(buf.Len()), n; expected != got {
+			t.Fatalf("(packet %d) expected %d bytes written, got %d", i, expected, got)
+		}
+	}
+}
+
+func TestParsePacket(t *testing.T) {
+	for i, testcase := range []struct {
+		Input    []byte
+		Expected Packet
+		Err      string
+		Cause    error
+	}{
+		{
+			Input:    Message{Address: "/foo", Arguments: Arguments{Int(1)}}.Bytes(),
+			Expected: Message{Address: "/foo", Arguments: Arguments{Int(1)}},
+		},
+		{
+			Input:    Bundle{Timetag: 5, Packets: []Packet{Message{Address: "/foo"}}}.Bytes(),
+			Expected: Bundle{Timetag: 5, Packets: []Packet{Message{Address: "/foo"}}},
+		},
+		{
+			Input: []byte{},
+			Err:   "empty packet: error parsing message",
+			Cause: ErrParse,
+		},
+		{
+			Input: []byte{'%', 'n', 'o', 0},
+			Err:   "packet should never start with %: error parsing message",
+			Cause: ErrParse,
+		},
+		{
+			Input: badPacket{}.Bytes(),
+			Err:   `parse message: read argument 0: typetag "Q": invalid type tag`,
+		},
+	} {
+		p, err := ParsePacket(testcase.Input, nil)
+		if testcase.Err != "" {
+			if err == nil {
+				t.Fatalf("(testcase %d) expected error, got nil", i)
+			}
+			if expected, got := testcase.Err, err.Error(); expected != got {
+				t.Fatalf("(testcase %d) expected %s, got %s", i, expected, got)
+			}
+			if testcase.Cause != nil && errors.Cause(err) != testcase.Cause {
+				t.Fatalf("(testcase %d) expected cause %s, got %s", i, testcase.Cause, errors.Cause(err))
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("(testcase %d) %s", i, err)
+		}
+		if !testcase.Expected.Equal(p) {
+			t.Fatalf("(testcase %d) expected %s, got %s", i, testcase.Expected, p)
+		}
+	}
+}
+
+func TestBinaryMarshaling(t *testing.T) {
+	var (
+		r       = rand.New(rand.NewSource(4))
+		_       = []encoding.BinaryMarshaler{Message{}, Bundle{}}
+		_       = []encoding.BinaryUnmarshaler{&Message{}, &Bundle{}}
+		msg     Message
+		bundle  Bundle
+		packets = []Packet{}
+	)
+	for i := 0; i < 100; i++ {
+		packets = append(packets, randomPacket(r, 2))
+	}
+	for i, p := range packets {
+		data, err := p.(encoding.BinaryMarshaler).MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected, got := p.Bytes(), data; !bytes.Equal(expected, got) {
+			t.Fatalf("(packet %d) expected %q, got %q", i, expected, got)
+		}
+		var got Packet
+		switch p.(type) {
+		case Message:
+			err = msg.UnmarshalBinary(data)
+			got = msg
+		case Bundle:
+			err = bundle.UnmarshalBinary(data)
+			got = bundle
+		}
+		if err != nil {
+			t.Fatalf("(packet %d) %s", i, err)
+		}
+		if !p.Equal(got) {
+			t.Fatalf("(packet %d) expected %s, got %s", i, p, got)
+		}
+	}
+	if err := msg.UnmarshalBinary(Bundle{}.Bytes()); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	if err := bundle.UnmarshalBinary(Message{Address: "/foo"}.Bytes()); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+}
@@ -0,0 +1,41 @@
+package oschttp
+
+import (
+	"strconv"
+
+	"github.com/scgolang/osc"
+	"github.com/scgolang/osc/internal/jsonarg"
+)
+
+// arguments converts the JSON body of a request to arguments.
+// If typetags is not empty the arguments are converted to them.
+func arguments(body []byte, typetags string) (osc.Arguments, *Error) {
+	values, err := jsonarg.Values(body)
+	if err != nil {
+		return nil, &Error{Code: CodeInvalidBody, Message: err.Error()}
+	}
+	if typetags != "" && len(typetags) != len(values) {
+		return nil, &Error{
+			Code:    CodeInvalidArgument,
+			Message: "expected " + strconv.Itoa(len(typetags)) + " arguments for types " + strconv.Quote(typetags) + ", got " + strconv.Itoa(len(values)),
+		}
+	}
+	args := make(osc.Arguments, len(values))
+	for i, raw := range values {
+		var (
+			arg osc.Argument
+			err error
+		)
+		if typetags == "" {
+			arg, err = jsonarg.Convert(raw)
+		} else {
+			arg, err = jsonarg.ConvertTo(typetags[i], raw)
+		}
+		if err != nil {
+			i := i
+			return nil, &Error{Code: CodeInvalidArgument, Message: err.Error(), Argument: &i}
+		}
+		args[i] = arg
+	}
+	return args, nil
+}
@@ -0,0 +1,62 @@
+package oschttp
+
+import (
+	"testing"
+
+	"github.com/scgolang/osc"
+)
+
+func TestArguments(t *testing.T) {
+	for i, testcase := range []struct {
+		Body     string
+		Typetags string
+		Expected string
+	}{
+		{Body: "", Expected: "/a"},
+		{Body: " 440 ", Expected: "/a ,i 440"},
+		{Body: `[440, 0.5, 3000000000, "sine", true, false]`, Expected: `/a ,iffsTF 440 0.5 3e+09 "sine" true false`},
+		{Body: `[{"type":"d","value":0.25}, {"type":"b","value":"AQID"}]`, Expected: `/a ,db 0.25 b"AQID"`},
+		{Body: `[440, "220", 1, 2]`, Typetags: "fdsi", Expected: `/a ,fdsi 440.0 220.0 "1" 2`},
+		{Body: `["AQID", "true", false, {"type":"T","value":true}]`, Typetags: "bTTF", Expected: `/a ,bTFT b"AQID" true false true`},
+		{Body: `"7"`, Typetags: "i", Expected: "/a ,i 7"},
+	} {
+		args, err := arguments([]byte(testcase.Body), testcase.Typetags)
+		if err != nil {
+			t.Fatalf("(testcase %d) %s", i, err)
+		}
+		if expected, got := testcase.Expected, osc.Format(osc.Message{Address: "/a", Arguments: args}); expected != got {
+			t.Fatalf("(testcase %d) expected %s, got %s", i, expected, got)
+		}
+	}
+}
+
+func TestArgumentsErrors(t *testing.T) {
+	for i, testcase := range []struct {
+		Body     string
+		Typetags string
+		Err      string
+	}{
+		{Body: "[1,", Err: "invalid_body: unexpected end of JSON input"},
+		{Body: "sine", Err: "invalid_body: invalid JSON"},
+		{Body: "[1, null]", Err: "invalid_argument: argument 1: null is not an argument"},
+		{Body: "[[1]]", Err: "invalid_argument: argument 0: arrays are not arguments"},
+		{Body: `[{"type":"x"}]`, Err: `invalid_argument: argument 0: typetag "x": invalid type tag`},
+		{Body: "[1, 2]", Typetags: "i", Err: `invalid_argument: expected 1 arguments for types "i", got 2`},
+		{Body: "[1.5]", Typetags: "i", Err: "invalid_argument: argument 0: can not convert 1.5 to an int"},
+		{Body: `["high"]`, Typetags: "f", Err: `invalid_argument: argument 0: can not convert "high" to a float`},
+		{Body: `["nan"]`, Typetags: "f", Err: `invalid_argument: argument 0: can not convert "nan" to a float`},
+		{Body: `["-Infinity"]`, Typetags: "d", Err: `invalid_argument: argument 0: can not convert "-Infinity" to a double`},
+		{Body: "[1]", Typetags: "b", Err: "invalid_argument: argument 0: can not convert 1 to a blob, expected a base64 string"},
+		{Body: "[1]", Typetags: "T", Err: "invalid_argument: argument 0: can not convert 1 to a bool"},
+		{Body: `[{"type":"i","value":1}]`, Typetags: "s", Err: "invalid_argument: argument 0: expected typetag s, got i"},
+		{Body: "[1]", Typetags: "h", Err: "invalid_argument: argument 0: unsupported typetag h"},
+	} {
+		_, err := arguments([]byte(testcase.Body), testcase.Typetags)
+		if err == nil {
+			t.Fatalf("(testcase %d) expected error, got nil", i)
+		}
+		if expected, got := testcase.Err, err.Error(); expected != got {
+			t.Fatalf("(testcase %d) expected %s, got %s", i, expected, got)
+		}
+	}
+}
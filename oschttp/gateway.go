@@ -0,0 +1,230 @@
+package oschttp
+
+import (
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/scgolang/osc"
+)
+
+const (
+	// DefaultPrefix is the path prefix of a gateway.
+	DefaultPrefix = "/osc"
+
+	// maxBodySize limits the size of request bodies.
+	maxBodySize = 1 << 20
+
+	// eventBuffer is the number of events buffered for every stream.
+	// Events are dropped when the buffer of a slow client is full.
+	eventBuffer = 64
+)
+
+// Gateway is an http.Handler that sends messages over a connection for POST requests,
+// and streams the messages it receives as an osc.Dispatcher to GET requests.
+type Gateway struct {
+	conn osc.Conn
+
+	mu      sync.Mutex
+	prefix  string
+	streams map[*stream]struct{}
+	closed  chan struct{}
+	nextID  uint64
+}
+
+// stream is a client of the Server-Sent Events.
+type stream struct {
+	pattern *regexp.Regexp
+	address string
+	events  chan []byte
+}
+
+// NewGateway returns a gateway that sends messages over conn.
+func NewGateway(conn osc.Conn) *Gateway {
+	return &Gateway{
+		conn:    conn,
+		prefix:  DefaultPrefix,
+		streams: map[*stream]struct{}{},
+		closed:  make(chan struct{}),
+	}
+}
+
+// SetPrefix sets the path prefix that is removed from request paths to get
+// OSC addresses. The default is DefaultPrefix, an empty prefix means none.
+func (g *Gateway) SetPrefix(prefix string) {
+	g.mu.Lock()
+	g.prefix = strings.TrimSuffix(prefix, "/")
+	g.mu.Unlock()
+}
+
+// Close ends the event streams.
+func (g *Gateway) Close() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	select {
+	case <-g.closed:
+	default:
+		close(g.closed)
+	}
+	return nil
+}
+
+// Dispatch streams the messages of a bundle as soon as they are received,
+// regardless of their timetag.
+func (g *Gateway) Dispatch(b osc.Bundle, exactMatch bool) error {
+	for _, p := range b.Packets {
+		switch x := p.(type) {
+		case osc.Message:
+			if err := g.Invoke(x, exactMatch); err != nil {
+				return err
+			}
+		case osc.Bundle:
+			if err := g.Dispatch(x, exactMatch); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Invoke streams a message to the clients whose pattern matches its address.
+func (g *Gateway) Invoke(msg osc.Message, exactMatch bool) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return nil // Messages that JSON can not represent are not streamed.
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.nextID++
+	event := []byte("id: " + strconv.FormatUint(g.nextID, 10) + "\nevent: message\ndata: " + string(data) + "\n\n")
+
+	for s := range g.streams {
+		if !s.match(msg.Address, exactMatch) {
+			continue
+		}
+		select {
+		case s.events <- event:
+		default:
+		}
+	}
+	return nil
+}
+
+// ServeHTTP sends a message for POST requests and streams events for GET requests.
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	g.mu.Lock()
+	prefix := g.prefix
+	g.mu.Unlock()
+
+	if r.URL.Path != prefix && !strings.HasPrefix(r.URL.Path, prefix+"/") {
+		writeError(w, &Error{Code: CodeNotFound, Message: "no such path " + r.URL.Path})
+		return
+	}
+	address := strings.TrimPrefix(r.URL.Path, prefix)
+
+	switch r.Method {
+	case http.MethodPost:
+		g.send(w, r, address)
+	case http.MethodGet:
+		g.stream(w, r, address)
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		writeError(w, &Error{Code: CodeMethodNotAllowed, Message: r.Method + " is not allowed"})
+	}
+}
+
+// send sends the message of a POST request.
+func (g *Gateway) send(w http.ResponseWriter, r *http.Request, address string) {
+	if address == "" || address == "/" || strings.Contains(address, "//") || osc.ValidateAddress(address) != nil {
+		writeError(w, &Error{Code: CodeInvalidAddress, Message: strconv.Quote(address) + " is not a valid OSC address"})
+		return
+	}
+	// Browsers send cross-origin form posts without asking, but not JSON ones,
+	// so requiring JSON keeps web pages of other sites from sending messages.
+	if mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || mediaType != "application/json" {
+		writeError(w, &Error{Code: CodeUnsupportedMediaType, Message: "the content type must be application/json"})
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+	if err != nil {
+		writeError(w, &Error{Code: CodeInvalidBody, Message: err.Error()})
+		return
+	}
+	args, e := arguments(body, r.URL.Query().Get("types"))
+	if e != nil {
+		writeError(w, e)
+		return
+	}
+	msg := osc.Message{Address: address, Arguments: args}
+
+	if err := g.conn.Send(msg); err != nil {
+		writeError(w, &Error{Code: CodeSendFailed, Message: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, msg)
+}
+
+// stream streams the messages that match the address pattern of a GET request,
+// until the client goes away or the gateway is closed.
+func (g *Gateway) stream(w http.ResponseWriter, r *http.Request, pattern string) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+	s := &stream{address: pattern, events: make(chan []byte, eventBuffer)}
+	if pattern != "" && pattern != "/" {
+		re, err := osc.GetRegex(pattern)
+		if err != nil {
+			writeError(w, &Error{Code: CodeInvalidAddress, Message: strconv.Quote(pattern) + " is not a valid OSC address pattern"})
+			return
+		}
+		s.pattern = re
+	}
+	g.mu.Lock()
+	g.streams[s] = struct{}{}
+	g.mu.Unlock()
+
+	defer func() {
+		g.mu.Lock()
+		delete(g.streams, s)
+		g.mu.Unlock()
+	}()
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-g.closed:
+			return
+		case event := <-s.events:
+			if _, err := w.Write(event); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// match returns true if the pattern of the stream matches an address.
+func (s *stream) match(address string, exactMatch bool) bool {
+	switch {
+	case s.pattern == nil:
+		return true
+	case exactMatch:
+		return s.address == address
+	default:
+		return osc.VerifyParts(s.address, address) && s.pattern.MatchString(address)
+	}
+}
@@ -0,0 +1,274 @@
+package oschttp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/scgolang/osc"
+)
+
+// testGateway returns a gateway that sends messages to a UDP server,
+// whose messages are sent to received, and a function that closes them.
+func testGateway(t *testing.T, received chan osc.Message) (*Gateway, func()) {
+	server, err := osc.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		_ = server.Serve(1, osc.PatternMatching{
+			"/synth/freq": osc.Method(func(msg osc.Message) error {
+				received <- msg
+				return nil
+			}),
+		})
+	}()
+	conn, err := osc.DialUDP("udp", nil, server.LocalAddr().(*net.UDPAddr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := NewGateway(conn)
+
+	cleanup := func() {
+		_ = g.Close()
+		_ = conn.Close()
+		_ = server.Close()
+	}
+	return g, cleanup
+}
+
+// post returns the status and the body of a POST request with a JSON body.
+func post(t *testing.T, h http.Handler, target, body string) (int, string) {
+	var (
+		rec = httptest.NewRecorder()
+		req = httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+	)
+	req.Header.Set("Content-Type", "application/json")
+	h.ServeHTTP(rec, req)
+	return rec.Code, strings.TrimSpace(rec.Body.String())
+}
+
+func TestGatewayPost(t *testing.T) {
+	var (
+		received   = make(chan osc.Message, 1)
+		g, cleanup = testGateway(t, received)
+	)
+	defer cleanup()
+
+	status, body := post(t, g, "/osc/synth/freq?types=f", "[440]")
+	if expected, got := http.StatusOK, status; expected != got {
+		t.Fatalf("expected status %d, got %d: %s", expected, got, body)
+	}
+	if expected, got := `{"address":"/synth/freq","arguments":[{"type":"f","value":440}]}`, body; expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+	select {
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for the message")
+	case msg := <-received:
+		if expected, got := "/synth/freq ,f 440.0", osc.Format(msg); expected != got {
+			t.Fatalf("expected %s, got %s", expected, got)
+		}
+	}
+}
+
+func TestGatewayErrors(t *testing.T) {
+	g, cleanup := testGateway(t, nil)
+	defer cleanup()
+
+	g.SetPrefix("/api/osc/")
+
+	for i, testcase := range []struct {
+		Method      string
+		Target      string
+		ContentType string
+		Body        string
+		Status      int
+		Error       Error
+	}{
+		{
+			Method: http.MethodPost,
+			Target: "/osc/synth/freq",
+			Status: http.StatusNotFound,
+			Error:  Error{Code: CodeNotFound, Message: "no such path /osc/synth/freq"},
+		},
+		{
+			Method: http.MethodPost,
+			Target: "/api/oscillator",
+			Status: http.StatusNotFound,
+			Error:  Error{Code: CodeNotFound, Message: "no such path /api/oscillator"},
+		},
+		{
+			Method: http.MethodDelete,
+			Target: "/api/osc/synth/freq",
+			Status: http.StatusMethodNotAllowed,
+			Error:  Error{Code: CodeMethodNotAllowed, Message: "DELETE is not allowed"},
+		},
+		{
+			Method: http.MethodPost,
+			Target: "/api/osc/synth/*",
+			Status: http.StatusBadRequest,
+			Error:  Error{Code: CodeInvalidAddress, Message: `"/synth/*" is not a valid OSC address`},
+		},
+		{
+			Method: http.MethodPost,
+			Target: "/api/osc",
+			Status: http.StatusBadRequest,
+			Error:  Error{Code: CodeInvalidAddress, Message: `"" is not a valid OSC address`},
+		},
+		{
+			Method:      http.MethodPost,
+			Target:      "/api/osc/synth/freq",
+			ContentType: "text/plain",
+			Body:        "[440]",
+			Status:      http.StatusUnsupportedMediaType,
+			Error:       Error{Code: CodeUnsupportedMediaType, Message: "the content type must be application/json"},
+		},
+		{
+			Method:      http.MethodPost,
+			Target:      "/api/osc/synth/freq",
+			ContentType: "application/x-www-form-urlencoded",
+			Status:      http.StatusUnsupportedMediaType,
+			Error:       Error{Code: CodeUnsupportedMediaType, Message: "the content type must be application/json"},
+		},
+		{
+			Method: http.MethodPost,
+			Target: "/api/osc/synth/freq",
+			Body:   "{",
+			Status: http.StatusBadRequest,
+			Error:  Error{Code: CodeInvalidBody, Message: "invalid JSON"},
+		},
+		{
+			Method: http.MethodPost,
+			Target: "/api/osc/synth/freq?types=i",
+			Body:   `["high"]`,
+			Status: http.StatusUnprocessableEntity,
+			Error:  Error{Code: CodeInvalidArgument, Message: `can not convert "high" to an int`, Argument: new(int)},
+		},
+		{
+			Method: http.MethodPost,
+			Target: "/api/osc/synth/freq?types=f",
+			Body:   `["inf"]`,
+			Status: http.StatusUnprocessableEntity,
+			Error:  Error{Code: CodeInvalidArgument, Message: `can not convert "inf" to a float`, Argument: new(int)},
+		},
+	} {
+		var (
+			rec = httptest.NewRecorder()
+			req = httptest.NewRequest(testcase.Method, testcase.Target, strings.NewReader(testcase.Body))
+		)
+		req.Header.Set("Content-Type", "application/json; charset=utf-8")
+		if testcase.ContentType != "" {
+			req.Header.Set("Content-Type", testcase.ContentType)
+		}
+		g.ServeHTTP(rec, req)
+
+		if expected, got := testcase.Status, rec.Code; expected != got {
+			t.Fatalf("(testcase %d) expected status %d, got %d", i, expected, got)
+		}
+		if expected, got := "application/json", rec.Header().Get("Content-Type"); expected != got {
+			t.Fatalf("(testcase %d) expected %s, got %s", i, expected, got)
+		}
+		var e Error
+		if err := json.Unmarshal(rec.Body.Bytes(), &e); err != nil {
+			t.Fatalf("(testcase %d) %s", i, err)
+		}
+		if expected, got := testcase.Error.Error(), e.Error(); expected != got {
+			t.Fatalf("(testcase %d) expected %s, got %s", i, expected, got)
+		}
+	}
+}
+
+func TestGatewayEvents(t *testing.T) {
+	var (
+		g, cleanup = testGateway(t, nil)
+		srv        = httptest.NewServer(g)
+	)
+	defer cleanup()
+	defer srv.Close()
+
+	// The gateway streams the messages that another application sends to it.
+	conn, err := osc.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	go func() { _ = conn.Serve(1, g) }()
+
+	sender, err := osc.DialUDP("udp", nil, conn.LocalAddr().(*net.UDPAddr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = sender.Close() }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/osc/synth/*", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if expected, got := "text/event-stream", resp.Header.Get("Content-Type"); expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(time.Millisecond) {
+		g.mu.Lock()
+		n := len(g.streams)
+		g.mu.Unlock()
+
+		if n > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("timeout waiting for the stream")
+		}
+	}
+	for _, p := range []osc.Packet{
+		osc.Message{Address: "/mixer/gain", Arguments: osc.Arguments{osc.Float(1)}},
+		osc.Bundle{
+			Timetag: osc.Immediately,
+			Packets: []osc.Packet{
+				osc.Message{Address: "/synth/freq", Arguments: osc.Arguments{osc.Int(440)}},
+				osc.Message{Address: "/synth/wave", Arguments: osc.Arguments{osc.String("saw")}},
+			},
+		},
+	} {
+		if err := sender.Send(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var (
+		lines   []string
+		scanner = bufio.NewScanner(resp.Body)
+	)
+	for len(lines) < 8 && scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	expected := strings.Join([]string{
+		"id: 2",
+		"event: message",
+		`data: {"address":"/synth/freq","arguments":[{"type":"i","value":440}]}`,
+		"",
+		"id: 3",
+		"event: message",
+		`data: {"address":"/synth/wave","arguments":[{"type":"s","value":"saw"}]}`,
+		"",
+	}, "\n")
+	if got := strings.Join(lines, "\n"); expected != got {
+		t.Fatalf("expected\n%s\ngot\n%s", expected, got)
+	}
+}
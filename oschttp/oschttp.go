@@ -0,0 +1,116 @@
+// Package oschttp is a gateway between HTTP and OSC, for web applications
+// and scripts that can not send OSC themselves.
+//
+// A POST request sends a message whose address is the path after the prefix
+// of the gateway, and whose arguments are the JSON body of the request,
+// which must have the content type application/json:
+//
+//	curl -H 'Content-Type: application/json' -d '[440, "sine"]' http://127.0.0.1:8080/osc/synth/freq
+//
+// sends a message to /synth/freq with the int 440 and the string "sine".
+// The body can be a JSON array of arguments, a single argument, or empty
+// for a message without arguments. The response is the JSON representation
+// of the message that was sent.
+//
+// Arguments are converted from JSON like this:
+//
+//	integers that fit in 32 bits  int (i)
+//	other numbers                 float (f)
+//	strings                       string (s)
+//	true and false                bool (T and F)
+//	typed objects                 the argument they represent, e.g. {"type":"d","value":0.5}
+//
+// The types query parameter sets the typetags of the arguments instead,
+// e.g. POST /osc/synth/freq?types=f with the body [440] sends a float.
+// Numbers and numeric strings can be converted to i, f and d,
+// any value but objects to s, base64 strings to b, and bools and
+// the strings "true" and "false" to T or F.
+//
+// A GET request streams the messages that the gateway receives as
+// Server-Sent Events, whose data is the JSON representation of the message.
+// The path after the prefix is an OSC address pattern that filters the messages:
+//
+//	curl http://127.0.0.1:8080/osc/synth/*
+//
+// The gateway receives messages as the osc.Dispatcher of a connection:
+//
+//	gw := oschttp.NewGateway(conn)
+//	go func() { _ = conn.Serve(1, gw) }()
+//	err := http.ListenAndServe("127.0.0.1:8080", gw)
+//
+// Errors are answered with a JSON Error and an HTTP status that depends on its Code.
+package oschttp
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+)
+
+// Error codes.
+const (
+	CodeNotFound             = "not_found"
+	CodeMethodNotAllowed     = "method_not_allowed"
+	CodeInvalidAddress       = "invalid_address"
+	CodeInvalidBody          = "invalid_body"
+	CodeInvalidArgument      = "invalid_argument"
+	CodeSendFailed           = "send_failed"
+	CodeUnsupportedMediaType = "unsupported_media_type"
+)
+
+// statuses are the HTTP statuses of the error codes.
+var statuses = map[string]int{
+	CodeNotFound:             http.StatusNotFound,
+	CodeMethodNotAllowed:     http.StatusMethodNotAllowed,
+	CodeInvalidAddress:       http.StatusBadRequest,
+	CodeInvalidBody:          http.StatusBadRequest,
+	CodeInvalidArgument:      http.StatusUnprocessableEntity,
+	CodeSendFailed:           http.StatusBadGateway,
+	CodeUnsupportedMediaType: http.StatusUnsupportedMediaType,
+}
+
+// Error is the body of error responses.
+type Error struct {
+	// Code says what went wrong, e.g. CodeInvalidArgument.
+	Code string `json:"code"`
+
+	// Message describes the error.
+	Message string `json:"message"`
+
+	// Argument is the index of the argument that could not be converted,
+	// for CodeInvalidArgument errors.
+	Argument *int `json:"argument,omitempty"`
+}
+
+// Error returns the code and the message of the error.
+func (e *Error) Error() string {
+	if e.Argument != nil {
+		return e.Code + ": argument " + strconv.Itoa(*e.Argument) + ": " + e.Message
+	}
+	return e.Code + ": " + e.Message
+}
+
+// Status returns the HTTP status of the error.
+func (e *Error) Status() int {
+	if status, ok := statuses[e.Code]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// writeError writes an error response.
+func writeError(w http.ResponseWriter, e *Error) {
+	writeJSON(w, e.Status(), e)
+}
+
+// writeJSON writes a JSON response.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write(append(data, '\n'))
+}
@@ -0,0 +1,353 @@
+package oscmqtt
+
+import (
+	"context"
+	"hash/fnv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/scgolang/osc"
+	"github.com/scgolang/osc/internal/mqtt"
+)
+
+const (
+	// DefaultKeepAlive is the keep alive interval of the connection to the broker.
+	DefaultKeepAlive = 30 * time.Second
+
+	// DefaultLoopWindow is how long the bridge remembers the messages it forwards.
+	DefaultLoopWindow = 5 * time.Second
+)
+
+// Common errors.
+var (
+	ErrConnected    = errors.New("bridge is already connected")
+	ErrNotConnected = errors.New("bridge is not connected")
+)
+
+// Bridge publishes the messages that it receives as an osc.Dispatcher to an MQTT broker,
+// and sends the messages that it receives from the broker over an OSC connection.
+//
+// The methods that configure a bridge must be called before it connects.
+type Bridge struct {
+	conn osc.Conn
+
+	encoding     Encoding
+	qos          QoS
+	retain       bool
+	prefix       string
+	options      mqtt.Options
+	errorHandler func(error)
+	loopWindow   time.Duration
+	now          func() time.Time
+
+	mu        sync.Mutex
+	client    *mqtt.Client
+	published echoes // Messages published to the broker.
+	sent      echoes // Packets sent over the connection.
+}
+
+// echoes counts the payloads that were forwarded in one direction, by their hash,
+// so that they are not forwarded back when they return.
+type echoes map[uint64]echo
+
+// echo is a payload that was forwarded.
+type echo struct {
+	n int
+	t time.Time
+}
+
+// NewBridge returns a bridge that sends the messages it receives from the broker over conn.
+func NewBridge(conn osc.Conn) *Bridge {
+	return &Bridge{
+		conn:       conn,
+		options:    mqtt.Options{KeepAlive: DefaultKeepAlive},
+		loopWindow: DefaultLoopWindow,
+		now:        time.Now,
+		published:  echoes{},
+		sent:       echoes{},
+	}
+}
+
+// SetEncoding sets the encoding of payloads in both directions. The default is EncodingJSON.
+func (b *Bridge) SetEncoding(enc Encoding) {
+	b.encoding = enc
+}
+
+// SetQoS sets the quality of service of the messages that the bridge publishes,
+// and of its subscription. The default is AtMostOnce.
+func (b *Bridge) SetQoS(qos QoS) {
+	b.qos = qos
+}
+
+// SetRetain makes the broker retain the messages that the bridge publishes.
+func (b *Bridge) SetRetain(retain bool) {
+	b.retain = retain
+}
+
+// SetTopicPrefix sets the topic levels in front of the topics of OSC addresses,
+// e.g. the prefix "studio" maps /synth/freq to studio/synth/freq.
+// The bridge only receives the messages of the topics below its prefix.
+func (b *Bridge) SetTopicPrefix(prefix string) {
+	b.prefix = strings.Trim(prefix, "/")
+}
+
+// SetClientID sets the client identifier of the bridge.
+// By default the broker assigns one.
+func (b *Bridge) SetClientID(id string) {
+	b.options.ClientID = id
+}
+
+// SetCredentials sets the user name and the password that the bridge connects with.
+func (b *Bridge) SetCredentials(username, password string) {
+	b.options.Username = username
+	b.options.Password = password
+}
+
+// SetKeepAlive sets the interval of the pings that detect when the broker goes away.
+// The default is DefaultKeepAlive, zero disables them.
+func (b *Bridge) SetKeepAlive(interval time.Duration) {
+	b.options.KeepAlive = interval
+}
+
+// SetErrorHandler sets a function that is called with the errors of the messages
+// that can not be forwarded. By default they are ignored.
+func (b *Bridge) SetErrorHandler(handler func(error)) {
+	b.errorHandler = handler
+}
+
+// SetLoopWindow sets how long the bridge remembers the messages that it forwards.
+// A message that the bridge published is dropped when it comes back from the broker
+// within the window, and a packet that it sent over the connection is dropped when
+// it comes back from the connection, e.g. from an OSC application that echoes what
+// it receives. Zero disables loop protection, which only makes sense if the bridge
+// does not receive its own messages. The default is DefaultLoopWindow.
+func (b *Bridge) SetLoopWindow(window time.Duration) {
+	b.loopWindow = window
+}
+
+// Connect connects to an MQTT broker at a TCP address and subscribes to the topics
+// below the prefix of the bridge. From then on the messages of the broker
+// are sent over the connection of the bridge.
+func (b *Bridge) Connect(ctx context.Context, addr string) error {
+	b.mu.Lock()
+	connected := b.client != nil
+	b.mu.Unlock()
+
+	if connected {
+		return ErrConnected
+	}
+	client, err := mqtt.Dial(ctx, addr, b.options, b.receive)
+	if err != nil {
+		return errors.Wrap(err, "connect to broker")
+	}
+	filter := "#"
+	if b.prefix != "" {
+		filter = b.prefix + "/#"
+	}
+	if _, err := client.Subscribe(ctx, filter, byte(b.qos)); err != nil {
+		_ = client.Close()
+		return errors.Wrapf(err, "subscribe to %s", filter)
+	}
+	b.mu.Lock()
+	b.client = client
+	b.mu.Unlock()
+
+	return nil
+}
+
+// Wait waits until the connection to the broker ends, and returns the error
+// that ended it, or nil if the bridge was closed.
+func (b *Bridge) Wait() error {
+	client, err := b.connected()
+	if err != nil {
+		return err
+	}
+	<-client.Done()
+	return client.Err()
+}
+
+// Close disconnects from the broker. It does not close the OSC connection.
+func (b *Bridge) Close() error {
+	b.mu.Lock()
+	client := b.client
+	b.mu.Unlock()
+
+	if client == nil {
+		return nil
+	}
+	return client.Close()
+}
+
+// Dispatch publishes the messages of a bundle as soon as they are received,
+// regardless of their timetag.
+func (b *Bridge) Dispatch(bundle osc.Bundle, exactMatch bool) error {
+	for _, p := range bundle.Packets {
+		switch x := p.(type) {
+		case osc.Message:
+			if err := b.Invoke(x, exactMatch); err != nil {
+				return err
+			}
+		case osc.Bundle:
+			if err := b.Dispatch(x, exactMatch); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Invoke publishes a message to the topic of its address.
+// Errors are passed to the error handler, so that they do not stop the connection
+// that the bridge dispatches for.
+func (b *Bridge) Invoke(msg osc.Message, exactMatch bool) error {
+	if b.take(b.sent, msg.Bytes()) {
+		return nil
+	}
+	if err := b.publish(msg); err != nil {
+		b.handleError(errors.Wrapf(err, "publish %s", msg.Address))
+	}
+	return nil
+}
+
+// publish publishes a message.
+func (b *Bridge) publish(msg osc.Message) error {
+	client, err := b.connected()
+	if err != nil {
+		return err
+	}
+	t, err := topic(b.prefix, msg.Address)
+	if err != nil {
+		return err
+	}
+	payload, err := encode(b.encoding, msg)
+	if err != nil {
+		return err
+	}
+	key := []byte(t + "\x00" + string(payload))
+
+	b.remember(b.published, key)
+
+	if err := client.Publish(b.conn.Context(), t, payload, byte(b.qos), b.retain); err != nil {
+		b.take(b.published, key)
+		return err
+	}
+	return nil
+}
+
+// receive sends a message from the broker over the connection.
+func (b *Bridge) receive(m mqtt.Message) {
+	if b.take(b.published, []byte(m.Topic+"\x00"+string(m.Payload))) {
+		return
+	}
+	if err := b.send(m); err != nil {
+		b.handleError(errors.Wrapf(err, "forward %s", m.Topic))
+	}
+}
+
+// send sends a message from the broker over the connection.
+func (b *Bridge) send(m mqtt.Message) error {
+	var addr string
+	if b.encoding != EncodingOSC {
+		a, err := address(b.prefix, m.Topic)
+		if err != nil {
+			return err
+		}
+		addr = a
+	}
+	p, err := decode(b.encoding, addr, m.Payload)
+	if err != nil {
+		return err
+	}
+	eachMessage(p, func(msg osc.Message) { b.remember(b.sent, msg.Bytes()) })
+
+	if err := b.conn.Send(p); err != nil {
+		eachMessage(p, func(msg osc.Message) { b.take(b.sent, msg.Bytes()) })
+		return err
+	}
+	return nil
+}
+
+// eachMessage calls f with every message of a packet.
+func eachMessage(p osc.Packet, f func(osc.Message)) {
+	switch x := p.(type) {
+	case osc.Message:
+		f(x)
+	case osc.Bundle:
+		for _, p := range x.Packets {
+			eachMessage(p, f)
+		}
+	}
+}
+
+// connected returns the client of the bridge, or ErrNotConnected.
+func (b *Bridge) connected() (*mqtt.Client, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.client == nil {
+		return nil, ErrNotConnected
+	}
+	return b.client, nil
+}
+
+// remember remembers that data was forwarded.
+func (b *Bridge) remember(forwarded echoes, data []byte) {
+	if b.loopWindow <= 0 {
+		return
+	}
+	var (
+		sum = hash(data)
+		now = b.now()
+	)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	e := forwarded[sum]
+	forwarded[sum] = echo{n: e.n + 1, t: now}
+}
+
+// take returns true if data was forwarded within the loop window,
+// and forgets one time that it was forwarded.
+func (b *Bridge) take(forwarded echoes, data []byte) bool {
+	if b.loopWindow <= 0 {
+		return false
+	}
+	var (
+		sum = hash(data)
+		now = b.now()
+	)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for k, e := range forwarded {
+		if now.Sub(e.t) > b.loopWindow {
+			delete(forwarded, k)
+		}
+	}
+	e, ok := forwarded[sum]
+	if !ok {
+		return false
+	}
+	if e.n == 1 {
+		delete(forwarded, sum)
+	} else {
+		forwarded[sum] = echo{n: e.n - 1, t: e.t}
+	}
+	return true
+}
+
+// hash returns the FNV-1a hash of data.
+func hash(data []byte) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write(data)
+	return h.Sum64()
+}
+
+// handleError passes an error to the error handler, if there is one.
+func (b *Bridge) handleError(err error) {
+	if b.errorHandler != nil {
+		b.errorHandler(err)
+	}
+}
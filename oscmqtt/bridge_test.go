@@ -0,0 +1,281 @@
+package oscmqtt
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/scgolang/osc"
+	"github.com/scgolang/osc/oscmqtt/mqtttest"
+)
+
+// testApp is an OSC application that talks to a bridge.
+type testApp struct {
+	conn     *osc.UDPConn
+	bridge   net.Addr
+	received chan osc.Message
+}
+
+// testBridge returns a bridge between a broker and an OSC application,
+// that is configured by configure before it connects to the broker,
+// and a function that closes them.
+func testBridge(t *testing.T, configure func(*Bridge)) (*Bridge, *mqtttest.Broker, *testApp, func()) {
+	broker, err := mqtttest.NewBroker()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app := &testApp{received: make(chan osc.Message, 16)}
+	if app.conn, err = osc.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)}); err != nil {
+		t.Fatal(err)
+	}
+	go func() { _ = app.conn.Serve(1, app) }()
+
+	conn, err := osc.DialUDP("udp", nil, app.conn.LocalAddr().(*net.UDPAddr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.bridge = conn.LocalAddr()
+
+	bridge := NewBridge(conn)
+	bridge.SetTopicPrefix("studio")
+	if configure != nil {
+		configure(bridge)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := bridge.Connect(ctx, broker.Addr().String()); err != nil {
+		t.Fatal(err)
+	}
+	go func() { _ = conn.Serve(1, bridge) }()
+
+	cleanup := func() {
+		_ = bridge.Close()
+		_ = conn.Close()
+		_ = app.conn.Close()
+		_ = broker.Close()
+	}
+	return bridge, broker, app, cleanup
+}
+
+// Dispatch receives the messages of a bundle.
+func (app *testApp) Dispatch(b osc.Bundle, exactMatch bool) error {
+	for _, p := range b.Packets {
+		if msg, ok := p.(osc.Message); ok {
+			app.received <- msg
+		}
+	}
+	return nil
+}
+
+// Invoke receives a message.
+func (app *testApp) Invoke(msg osc.Message, exactMatch bool) error {
+	app.received <- msg
+	return nil
+}
+
+// send sends a message from the application to the bridge.
+func (app *testApp) send(t *testing.T, msg osc.Message) {
+	if err := app.conn.SendTo(app.bridge, msg); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// receive returns the next message that the application receives from the bridge.
+func (app *testApp) receive(t *testing.T) osc.Message {
+	select {
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for a message from the bridge")
+	case msg := <-app.received:
+		return msg
+	}
+	return osc.Message{}
+}
+
+// published waits until the broker received n messages and returns them.
+func published(t *testing.T, broker *mqtttest.Broker, n int) []mqtttest.Message {
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(time.Millisecond) {
+		if messages := broker.Messages(); len(messages) >= n {
+			return messages
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for %d published messages", n)
+		}
+	}
+}
+
+func TestBridgeToMQTT(t *testing.T) {
+	_, broker, app, cleanup := testBridge(t, func(b *Bridge) {
+		b.SetQoS(AtLeastOnce)
+		b.SetRetain(true)
+	})
+	defer cleanup()
+
+	app.send(t, osc.Message{Address: "/sensors/temperature", Arguments: osc.Arguments{osc.Float(21.5)}})
+
+	msg := published(t, broker, 1)[0]
+
+	if expected, got := "studio/sensors/temperature", msg.Topic; expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+	if expected, got := `[{"type":"f","value":21.5}]`, string(msg.Payload); expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+	if expected, got := byte(AtLeastOnce), msg.QoS; expected != got {
+		t.Fatalf("expected QoS %d, got %d", expected, got)
+	}
+	if !msg.Retain {
+		t.Fatal("expected a retained message")
+	}
+	// The broker sends the message back to the bridge, which must drop it
+	// instead of sending it to the application.
+	if err := broker.Publish("studio/sensors/humidity", []byte("40")); err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := "/sensors/humidity ,i 40", osc.Format(app.receive(t)); expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+}
+
+func TestBridgeFromMQTT(t *testing.T) {
+	_, broker, app, cleanup := testBridge(t, nil)
+	defer cleanup()
+
+	for i, testcase := range []struct {
+		Topic    string
+		Payload  string
+		Expected string
+	}{
+		{Topic: "studio/synth/freq", Payload: "440", Expected: "/synth/freq ,i 440"},
+		{Topic: "studio/synth/wave", Payload: `["saw", {"type":"f","value":0.5}]`, Expected: `/synth/wave ,sf "saw" 0.5`},
+		{Topic: "studio/synth/gate", Payload: "", Expected: "/synth/gate"},
+	} {
+		if err := broker.Publish(testcase.Topic, []byte(testcase.Payload)); err != nil {
+			t.Fatalf("(testcase %d) %s", i, err)
+		}
+		msg := app.receive(t)
+
+		if expected, got := testcase.Expected, osc.Format(msg); expected != got {
+			t.Fatalf("(testcase %d) expected %s, got %s", i, expected, got)
+		}
+		// The application echoes the message, which must not be published again.
+		app.send(t, msg)
+	}
+	app.send(t, osc.Message{Address: "/synth/freq", Arguments: osc.Arguments{osc.Int(220)}})
+
+	messages := published(t, broker, 1)
+	if expected, got := 1, len(messages); expected != got {
+		t.Fatalf("expected %d message, got %d", expected, got)
+	}
+	if expected, got := `[{"type":"i","value":220}]`, string(messages[0].Payload); expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+}
+
+func TestBridgeEncodingOSC(t *testing.T) {
+	errs := make(chan error, 1)
+
+	_, broker, app, cleanup := testBridge(t, func(b *Bridge) {
+		b.SetEncoding(EncodingOSC)
+		b.SetQoS(ExactlyOnce)
+		b.SetErrorHandler(func(err error) { errs <- err })
+	})
+	defer cleanup()
+
+	msg := osc.Message{Address: "/mixer/gain", Arguments: osc.Arguments{osc.Float(0.75)}}
+	app.send(t, msg)
+
+	if expected, got := string(msg.Bytes()), string(published(t, broker, 1)[0].Payload); expected != got {
+		t.Fatalf("expected %q, got %q", expected, got)
+	}
+	bundle := osc.Bundle{
+		Timetag: osc.Immediately,
+		Packets: []osc.Packet{osc.Message{Address: "/lights/dim", Arguments: osc.Arguments{osc.Int(3)}}},
+	}
+	if err := broker.Publish("studio/lights", bundle.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := "/lights/dim ,i 3", osc.Format(app.receive(t)); expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+	// Malformed packets are passed to the error handler, and the bridge keeps going.
+	if err := broker.Publish("studio/lights", []byte("/lights/dim\x00,b\x00\x00\x8a000")); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for the error")
+	case err := <-errs:
+		if expected, got := "forward studio/lights: parse message: read argument 0: blob length -1976553424 is negative: error parsing message", err.Error(); expected != got {
+			t.Fatalf("expected %s, got %s", expected, got)
+		}
+	}
+	if err := broker.Publish("studio/lights", bundle.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := "/lights/dim ,i 3", osc.Format(app.receive(t)); expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+}
+
+func TestBridgeErrors(t *testing.T) {
+	errs := make(chan error, 1)
+
+	_, broker, app, cleanup := testBridge(t, func(b *Bridge) {
+		b.SetErrorHandler(func(err error) { errs <- err })
+	})
+	defer cleanup()
+
+	for i, testcase := range []struct {
+		Publish func() error
+		Err     string
+	}{
+		{
+			Publish: func() error { return broker.Publish("studio/synth/freq", []byte("high")) },
+			Err:     "forward studio/synth/freq: parse payload: invalid JSON",
+		},
+		{
+			Publish: func() error { return broker.Publish("studio/living room", nil) },
+			Err:     `forward studio/living room: topic "studio/living room" is not a valid OSC address`,
+		},
+		{
+			Publish: func() error {
+				app.send(t, osc.Message{Address: "/mix/+1"})
+				return nil
+			},
+			Err: `publish /mix/+1: address /mix/+1: topic "studio/mix/+1" contains a wildcard or a null character`,
+		},
+	} {
+		if err := testcase.Publish(); err != nil {
+			t.Fatalf("(testcase %d) %s", i, err)
+		}
+		select {
+		case <-time.After(5 * time.Second):
+			t.Fatalf("(testcase %d) timeout waiting for the error", i)
+		case err := <-errs:
+			if expected, got := testcase.Err, err.Error(); expected != got {
+				t.Fatalf("(testcase %d) expected %s, got %s", i, expected, got)
+			}
+		}
+	}
+}
+
+func TestBridgeWait(t *testing.T) {
+	bridge, broker, _, cleanup := testBridge(t, nil)
+	defer cleanup()
+
+	if err := bridge.Connect(context.Background(), broker.Addr().String()); err != ErrConnected {
+		t.Fatalf("expected %v, got %v", ErrConnected, err)
+	}
+	if err := bridge.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := bridge.Wait(); err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+	if err := NewBridge(nil).Wait(); err != ErrNotConnected {
+		t.Fatalf("expected %v, got %v", ErrNotConnected, err)
+	}
+}
@@ -0,0 +1,331 @@
+// Package mqtttest provides a minimal MQTT 3.1.1 broker, for testing
+// bridges of the oscmqtt package end to end without running a real broker.
+//
+// The broker accepts every client, and routes published messages to
+// the subscriptions whose topic filter matches, with QoS 0, 1 and 2.
+// It does not keep retained messages or sessions:
+//
+//	broker, err := mqtttest.NewBroker()
+//	if err != nil {
+//		t.Fatal(err)
+//	}
+//	defer broker.Close()
+//
+//	err = bridge.Connect(ctx, broker.Addr().String())
+package mqtttest
+
+import (
+	"bufio"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/scgolang/osc/internal/mqtt"
+)
+
+// Message is a message that a client published.
+type Message struct {
+	ClientID string
+	Topic    string
+	Payload  []byte
+	QoS      byte
+	Retain   bool
+}
+
+// Broker is a minimal MQTT broker that listens on a local TCP port.
+type Broker struct {
+	ln net.Listener
+	wg sync.WaitGroup
+
+	mu        sync.Mutex
+	conns     map[net.Conn]struct{}
+	sessions  map[*session]struct{}
+	published []Message
+	nextID    int
+	closed    bool
+}
+
+// session is the connection of a client.
+type session struct {
+	conn     net.Conn
+	clientID string
+
+	wmu sync.Mutex
+
+	// The fields below are guarded by the mutex of the broker.
+	subscriptions map[string]byte
+	nextID        uint16
+}
+
+// NewBroker starts a broker on a free TCP port of the loopback interface.
+func NewBroker() (*Broker, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, errors.Wrap(err, "listen")
+	}
+	b := &Broker{ln: ln, conns: map[net.Conn]struct{}{}, sessions: map[*session]struct{}{}}
+
+	b.wg.Add(1)
+	go b.accept()
+
+	return b, nil
+}
+
+// Addr returns the address that the broker listens on.
+func (b *Broker) Addr() *net.TCPAddr {
+	return b.ln.Addr().(*net.TCPAddr)
+}
+
+// Close stops the broker and closes the connections of its clients.
+func (b *Broker) Close() error {
+	err := b.ln.Close()
+
+	b.mu.Lock()
+	b.closed = true
+	for conn := range b.conns {
+		_ = conn.Close()
+	}
+	b.mu.Unlock()
+
+	b.wg.Wait()
+	return err
+}
+
+// Publish publishes a message with QoS 0 to the subscribers, as if a client
+// that is not connected to the broker published it.
+func (b *Broker) Publish(topic string, payload []byte) error {
+	if err := mqtt.ValidateTopic(topic); err != nil {
+		return err
+	}
+	b.route(&mqtt.Packet{Type: mqtt.PUBLISH, Topic: topic, Payload: payload})
+	return nil
+}
+
+// Messages returns the messages that clients published, in the order they were received.
+func (b *Broker) Messages() []Message {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return append([]Message(nil), b.published...)
+}
+
+// Subscriptions returns the sorted topic filters that the clients subscribed to.
+func (b *Broker) Subscriptions() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var filters []string
+	for s := range b.sessions {
+		for filter := range s.subscriptions {
+			filters = append(filters, filter)
+		}
+	}
+	sort.Strings(filters)
+	return filters
+}
+
+// accept serves the connections of clients until the listener is closed.
+func (b *Broker) accept() {
+	defer b.wg.Done()
+
+	for {
+		conn, err := b.ln.Accept()
+		if err != nil {
+			return
+		}
+		b.mu.Lock()
+		if b.closed {
+			b.mu.Unlock()
+			_ = conn.Close()
+			return
+		}
+		b.conns[conn] = struct{}{}
+		b.mu.Unlock()
+
+		b.wg.Add(1)
+		go func() {
+			defer b.wg.Done()
+			b.serve(conn)
+		}()
+	}
+}
+
+// serve handles the packets of a client until it disconnects
+// or sends a packet that the broker does not expect.
+func (b *Broker) serve(conn net.Conn) {
+	defer func() {
+		_ = conn.Close()
+
+		b.mu.Lock()
+		delete(b.conns, conn)
+		b.mu.Unlock()
+	}()
+
+	br := bufio.NewReader(conn)
+
+	p, err := mqtt.ReadPacket(br, mqtt.MaxPacketLen)
+	if err != nil || p.Type != mqtt.CONNECT {
+		return
+	}
+	s := &session{conn: conn, clientID: p.ClientID, subscriptions: map[string]byte{}}
+
+	b.mu.Lock()
+	if s.clientID == "" {
+		b.nextID++
+		s.clientID = "mqtttest-" + strconv.Itoa(b.nextID)
+	}
+	b.sessions[s] = struct{}{}
+	b.mu.Unlock()
+
+	defer func() {
+		b.mu.Lock()
+		delete(b.sessions, s)
+		b.mu.Unlock()
+	}()
+	if err := s.write(&mqtt.Packet{Type: mqtt.CONNACK, ReturnCode: mqtt.Accepted}); err != nil {
+		return
+	}
+	for {
+		p, err := mqtt.ReadPacket(br, mqtt.MaxPacketLen)
+		if err != nil {
+			return
+		}
+		if err := b.handle(s, p); err != nil {
+			return
+		}
+	}
+}
+
+// handle handles a packet of a client.
+func (b *Broker) handle(s *session, p *mqtt.Packet) error {
+	switch p.Type {
+	case mqtt.PUBLISH:
+		if err := mqtt.ValidateTopic(p.Topic); err != nil {
+			return err
+		}
+		b.mu.Lock()
+		b.published = append(b.published, Message{
+			ClientID: s.clientID,
+			Topic:    p.Topic,
+			Payload:  p.Payload,
+			QoS:      p.QoS,
+			Retain:   p.Retain,
+		})
+		b.mu.Unlock()
+
+		b.route(p)
+
+		switch p.QoS {
+		case 1:
+			return s.write(&mqtt.Packet{Type: mqtt.PUBACK, PacketID: p.PacketID})
+		case 2:
+			return s.write(&mqtt.Packet{Type: mqtt.PUBREC, PacketID: p.PacketID})
+		}
+	case mqtt.PUBREL:
+		return s.write(&mqtt.Packet{Type: mqtt.PUBCOMP, PacketID: p.PacketID})
+	case mqtt.PUBREC:
+		return s.write(&mqtt.Packet{Type: mqtt.PUBREL, PacketID: p.PacketID})
+	case mqtt.PUBACK, mqtt.PUBCOMP:
+		// The broker does not send messages again, so it does not track acknowledgements.
+	case mqtt.SUBSCRIBE:
+		codes := make([]byte, len(p.Subscriptions))
+
+		b.mu.Lock()
+		for i, sub := range p.Subscriptions {
+			if mqtt.ValidateFilter(sub.Filter) != nil || sub.QoS > 2 {
+				codes[i] = mqtt.SubscribeFailure
+				continue
+			}
+			s.subscriptions[sub.Filter] = sub.QoS
+			codes[i] = sub.QoS
+		}
+		b.mu.Unlock()
+
+		return s.write(&mqtt.Packet{Type: mqtt.SUBACK, PacketID: p.PacketID, ReturnCodes: codes})
+	case mqtt.UNSUBSCRIBE:
+		b.mu.Lock()
+		for _, sub := range p.Subscriptions {
+			delete(s.subscriptions, sub.Filter)
+		}
+		b.mu.Unlock()
+
+		return s.write(&mqtt.Packet{Type: mqtt.UNSUBACK, PacketID: p.PacketID})
+	case mqtt.PINGREQ:
+		return s.write(&mqtt.Packet{Type: mqtt.PINGRESP})
+	case mqtt.DISCONNECT:
+		return errors.New("disconnect")
+	default:
+		return errors.Errorf("unexpected packet type %d", p.Type)
+	}
+	return nil
+}
+
+// route sends a message to every session with a matching subscription,
+// once per session, with the lower of the QoS of the message and of the subscription.
+func (b *Broker) route(p *mqtt.Packet) {
+	type delivery struct {
+		s   *session
+		msg *mqtt.Packet
+	}
+	var deliveries []delivery
+
+	b.mu.Lock()
+	for s := range b.sessions {
+		qos, ok := s.match(p.Topic)
+		if !ok {
+			continue
+		}
+		if p.QoS < qos {
+			qos = p.QoS
+		}
+		msg := &mqtt.Packet{Type: mqtt.PUBLISH, Topic: p.Topic, Payload: p.Payload, QoS: qos}
+		if qos > 0 {
+			if s.nextID++; s.nextID == 0 {
+				s.nextID++
+			}
+			msg.PacketID = s.nextID
+		}
+		deliveries = append(deliveries, delivery{s: s, msg: msg})
+	}
+	b.mu.Unlock()
+
+	for _, d := range deliveries {
+		if err := d.s.write(d.msg); err != nil {
+			_ = d.s.conn.Close()
+		}
+	}
+}
+
+// match returns the highest QoS of the subscriptions that match a topic,
+// and false if none matches. It must be called with the mutex of the broker held.
+func (s *session) match(topic string) (byte, bool) {
+	var (
+		qos     byte
+		matched bool
+	)
+	for filter, q := range s.subscriptions {
+		if !mqtt.Match(filter, topic) {
+			continue
+		}
+		if !matched || q > qos {
+			qos = q
+		}
+		matched = true
+	}
+	return qos, matched
+}
+
+// write writes a packet to the client.
+func (s *session) write(p *mqtt.Packet) error {
+	data, err := p.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+
+	_, err = s.conn.Write(data)
+	return err
+}
@@ -0,0 +1,128 @@
+package mqtttest
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/scgolang/osc/internal/mqtt"
+)
+
+// testClient returns a client of broker whose messages are sent to received.
+// The caller closes it.
+func testClient(t *testing.T, broker *Broker, id string, received chan mqtt.Message) *mqtt.Client {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	c, err := mqtt.Dial(ctx, broker.Addr().String(), mqtt.Options{ClientID: id, KeepAlive: time.Minute}, func(msg mqtt.Message) {
+		received <- msg
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// receive returns the next message of received.
+func receive(t *testing.T, received chan mqtt.Message) mqtt.Message {
+	select {
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for a message")
+	case msg := <-received:
+		return msg
+	}
+	return mqtt.Message{}
+}
+
+func TestBroker(t *testing.T) {
+	broker, err := NewBroker()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = broker.Close() }()
+
+	var (
+		ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+		received    = make(chan mqtt.Message, 16)
+		subscriber  = testClient(t, broker, "subscriber", received)
+		publisher   = testClient(t, broker, "publisher", nil)
+	)
+	defer cancel()
+	defer func() { _ = subscriber.Close() }()
+	defer func() { _ = publisher.Close() }()
+
+	for _, sub := range []mqtt.Subscription{{Filter: "sensors/+/temperature", QoS: 1}, {Filter: "alarms/#", QoS: 2}} {
+		qos, err := subscriber.Subscribe(ctx, sub.Filter, sub.QoS)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected, got := sub.QoS, qos; expected != got {
+			t.Fatalf("expected QoS %d, got %d", expected, got)
+		}
+	}
+	if expected, got := []string{"alarms/#", "sensors/+/temperature"}, broker.Subscriptions(); !reflect.DeepEqual(expected, got) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+	for i, testcase := range []struct {
+		Topic    string
+		QoS      byte
+		Expected mqtt.Message
+	}{
+		{Topic: "sensors/kitchen/temperature", QoS: 2, Expected: mqtt.Message{Topic: "sensors/kitchen/temperature", QoS: 1}},
+		{Topic: "alarms/door", QoS: 0, Expected: mqtt.Message{Topic: "alarms/door", QoS: 0}},
+		{Topic: "alarms/window/open", QoS: 2, Expected: mqtt.Message{Topic: "alarms/window/open", QoS: 2}},
+	} {
+		if err := publisher.Publish(ctx, "sensors/kitchen/humidity", []byte("40"), testcase.QoS, false); err != nil {
+			t.Fatalf("(testcase %d) %s", i, err)
+		}
+		if err := publisher.Publish(ctx, testcase.Topic, []byte{byte(i)}, testcase.QoS, false); err != nil {
+			t.Fatalf("(testcase %d) %s", i, err)
+		}
+		testcase.Expected.Payload = []byte{byte(i)}
+
+		if expected, got := testcase.Expected, receive(t, received); !reflect.DeepEqual(expected, got) {
+			t.Fatalf("(testcase %d) expected %+v, got %+v", i, expected, got)
+		}
+	}
+	if err := broker.Publish("alarms/smoke", []byte("on")); err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := (mqtt.Message{Topic: "alarms/smoke", Payload: []byte("on")}), receive(t, received); !reflect.DeepEqual(expected, got) {
+		t.Fatalf("expected %+v, got %+v", expected, got)
+	}
+	messages := broker.Messages()
+	if expected, got := 6, len(messages); expected != got {
+		t.Fatalf("expected %d messages, got %d", expected, got)
+	}
+	if expected, got := (Message{ClientID: "publisher", Topic: "alarms/window/open", Payload: []byte{2}, QoS: 2}), messages[5]; !reflect.DeepEqual(expected, got) {
+		t.Fatalf("expected %+v, got %+v", expected, got)
+	}
+}
+
+func TestBrokerClose(t *testing.T) {
+	var (
+		broker, err = NewBroker()
+		client      *mqtt.Client
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client = testClient(t, broker, "", nil)
+	defer func() { _ = client.Close() }()
+
+	if err := broker.Close(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for the client to be disconnected")
+	case <-client.Done():
+	}
+	if client.Err() == nil {
+		t.Fatal("expected error, got nil")
+	}
+	if err := client.Publish(context.Background(), "a", nil, 1, false); err != mqtt.ErrClosed {
+		t.Fatalf("expected %v, got %v", mqtt.ErrClosed, err)
+	}
+}
@@ -0,0 +1,152 @@
+// Package oscmqtt bridges OSC and MQTT, for devices that publish and
+// subscribe to an MQTT broker instead of sending OSC.
+//
+// The bridge publishes the messages that it receives as the osc.Dispatcher
+// of a connection to the MQTT topic of their address, and sends the messages
+// that it receives from the broker over the connection to the OSC address
+// of their topic. The topic of an address is the address without its leading
+// slash, after the topic prefix of the bridge if there is one:
+//
+//	/sensors/temperature  sensors/temperature
+//	/synth/freq           studio/synth/freq, with the topic prefix "studio"
+//
+// Payloads are encoded as JSON by default, a JSON array of the arguments of
+// the message in their JSON representation, e.g. [{"type":"f","value":21.5}].
+// Payloads received from the broker can also be an array of plain JSON values,
+// a single value, or empty for a message without arguments. Values are converted
+// like the bodies of oschttp requests without types: integers that fit in 32 bits
+// to ints, other numbers to floats, and typed objects like {"type":"d","value":0.5}
+// to the argument they represent.
+//
+// With EncodingOSC the payload is the bytes of the OSC message instead, and
+// packets received from the broker are sent as they are, regardless of their topic.
+//
+// The bridge subscribes to every topic below its prefix, so it receives
+// the messages that it publishes itself. It drops them, and the messages that
+// it sent over the connection when they come back, see SetLoopWindow:
+//
+//	bridge := oscmqtt.NewBridge(conn)
+//	bridge.SetTopicPrefix("studio")
+//	bridge.SetQoS(oscmqtt.AtLeastOnce)
+//	if err := bridge.Connect(ctx, "127.0.0.1:1883"); err != nil {
+//		return err
+//	}
+//	defer bridge.Close()
+//
+//	go func() { _ = conn.Serve(1, bridge) }()
+//	err := bridge.Wait()
+package oscmqtt
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/scgolang/osc"
+	"github.com/scgolang/osc/internal/jsonarg"
+	"github.com/scgolang/osc/internal/mqtt"
+)
+
+// Encoding is the encoding of the payloads of MQTT messages.
+type Encoding int
+
+// Encodings.
+const (
+	// EncodingJSON encodes the arguments of a message as a JSON array.
+	EncodingJSON Encoding = iota
+
+	// EncodingOSC encodes messages as OSC packets.
+	EncodingOSC
+)
+
+// QoS is the quality of service of MQTT messages and subscriptions.
+type QoS byte
+
+// Qualities of service.
+const (
+	AtMostOnce  QoS = 0
+	AtLeastOnce QoS = 1
+	ExactlyOnce QoS = 2
+)
+
+// topic returns the MQTT topic of an OSC address, below prefix.
+func topic(prefix, address string) (string, error) {
+	if !strings.HasPrefix(address, "/") || len(address) == 1 {
+		return "", errors.Errorf("%s is not a valid OSC address", strconv.Quote(address))
+	}
+	t := address[1:]
+	if prefix != "" {
+		t = prefix + "/" + t
+	}
+	if err := mqtt.ValidateTopic(t); err != nil {
+		return "", errors.Wrapf(err, "address %s", address)
+	}
+	return t, nil
+}
+
+// address returns the OSC address of an MQTT topic below prefix.
+func address(prefix, topic string) (string, error) {
+	t := topic
+	if prefix != "" {
+		if !strings.HasPrefix(t, prefix+"/") {
+			return "", errors.Errorf("topic %s is not below %s", strconv.Quote(topic), strconv.Quote(prefix))
+		}
+		t = t[len(prefix):]
+	} else {
+		t = "/" + t
+	}
+	if t == "/" || strings.Contains(t, "//") || strings.HasSuffix(t, "/") || osc.ValidateAddress(t) != nil {
+		return "", errors.Errorf("topic %s is not a valid OSC address", strconv.Quote(topic))
+	}
+	return t, nil
+}
+
+// encode returns the payload of a message.
+func encode(enc Encoding, msg osc.Message) ([]byte, error) {
+	switch enc {
+	case EncodingJSON:
+		args := msg.Arguments
+		if args == nil {
+			args = osc.Arguments{}
+		}
+		return json.Marshal(args)
+	case EncodingOSC:
+		return msg.Bytes(), nil
+	default:
+		return nil, errors.Errorf("unknown encoding %d", enc)
+	}
+}
+
+// decode returns the packet of a payload, whose address is addr for EncodingJSON.
+func decode(enc Encoding, addr string, payload []byte) (osc.Packet, error) {
+	switch enc {
+	case EncodingJSON:
+		args, err := arguments(payload)
+		if err != nil {
+			return nil, err
+		}
+		return osc.Message{Address: addr, Arguments: args}, nil
+	case EncodingOSC:
+		return osc.ParsePacket(payload, nil)
+	default:
+		return nil, errors.Errorf("unknown encoding %d", enc)
+	}
+}
+
+// arguments converts a JSON payload to arguments.
+func arguments(payload []byte) (osc.Arguments, error) {
+	values, err := jsonarg.Values(payload)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse payload")
+	}
+	args := make(osc.Arguments, len(values))
+	for i, raw := range values {
+		arg, err := jsonarg.Convert(raw)
+		if err != nil {
+			return nil, errors.Wrapf(err, "argument %d", i)
+		}
+		args[i] = arg
+	}
+	return args, nil
+}
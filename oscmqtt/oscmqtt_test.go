@@ -0,0 +1,126 @@
+package oscmqtt
+
+import (
+	"testing"
+
+	"github.com/scgolang/osc"
+)
+
+func TestTopic(t *testing.T) {
+	for i, testcase := range []struct {
+		Prefix   string
+		Address  string
+		Expected string
+		Err      string
+	}{
+		{Address: "/sensors/temperature", Expected: "sensors/temperature"},
+		{Prefix: "studio", Address: "/synth/freq", Expected: "studio/synth/freq"},
+		{Address: "/", Err: `"/" is not a valid OSC address`},
+		{Address: "synth", Err: `"synth" is not a valid OSC address`},
+		{Address: "/mix/+1", Err: `address /mix/+1: topic "mix/+1" contains a wildcard or a null character`},
+	} {
+		got, err := topic(testcase.Prefix, testcase.Address)
+		if testcase.Err != "" {
+			if err == nil {
+				t.Fatalf("(testcase %d) expected error, got nil", i)
+			}
+			if expected, got := testcase.Err, err.Error(); expected != got {
+				t.Fatalf("(testcase %d) expected %s, got %s", i, expected, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("(testcase %d) %s", i, err)
+		}
+		if expected := testcase.Expected; expected != got {
+			t.Fatalf("(testcase %d) expected %s, got %s", i, expected, got)
+		}
+	}
+}
+
+func TestAddress(t *testing.T) {
+	for i, testcase := range []struct {
+		Prefix   string
+		Topic    string
+		Expected string
+		Err      string
+	}{
+		{Topic: "sensors/temperature", Expected: "/sensors/temperature"},
+		{Prefix: "studio", Topic: "studio/synth/freq", Expected: "/synth/freq"},
+		{Prefix: "studio", Topic: "studio", Err: `topic "studio" is not below "studio"`},
+		{Prefix: "studio", Topic: "studio2/synth", Err: `topic "studio2/synth" is not below "studio"`},
+		{Topic: "a//b", Err: `topic "a//b" is not a valid OSC address`},
+		{Topic: "a/b/", Err: `topic "a/b/" is not a valid OSC address`},
+		{Topic: "living room/lamp", Err: `topic "living room/lamp" is not a valid OSC address`},
+	} {
+		got, err := address(testcase.Prefix, testcase.Topic)
+		if testcase.Err != "" {
+			if err == nil {
+				t.Fatalf("(testcase %d) expected error, got nil", i)
+			}
+			if expected, got := testcase.Err, err.Error(); expected != got {
+				t.Fatalf("(testcase %d) expected %s, got %s", i, expected, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("(testcase %d) %s", i, err)
+		}
+		if expected := testcase.Expected; expected != got {
+			t.Fatalf("(testcase %d) expected %s, got %s", i, expected, got)
+		}
+	}
+}
+
+func TestEncode(t *testing.T) {
+	for i, testcase := range []struct {
+		Message  osc.Message
+		Expected string
+	}{
+		{Message: osc.Message{Address: "/a"}, Expected: "[]"},
+		{
+			Message:  osc.Message{Address: "/a", Arguments: osc.Arguments{osc.Float(21.5), osc.String("C")}},
+			Expected: `[{"type":"f","value":21.5},{"type":"s","value":"C"}]`,
+		},
+	} {
+		payload, err := encode(EncodingJSON, testcase.Message)
+		if err != nil {
+			t.Fatalf("(testcase %d) %s", i, err)
+		}
+		if expected, got := testcase.Expected, string(payload); expected != got {
+			t.Fatalf("(testcase %d) expected %s, got %s", i, expected, got)
+		}
+	}
+}
+
+func TestDecode(t *testing.T) {
+	for i, testcase := range []struct {
+		Payload  string
+		Expected string
+		Err      string
+	}{
+		{Payload: "", Expected: "/a"},
+		{Payload: "21.5", Expected: "/a ,f 21.5"},
+		{Payload: `[440, 3000000000, "on", true, {"type":"d","value":0.25}]`, Expected: `/a ,ifsTd 440 3e+09 "on" true 0.25`},
+		{Payload: "on", Err: "parse payload: invalid JSON"},
+		{Payload: "[1, null]", Err: "argument 1: null is not an argument"},
+		{Payload: "[[1]]", Err: "argument 0: arrays are not arguments"},
+	} {
+		p, err := decode(EncodingJSON, "/a", []byte(testcase.Payload))
+		if testcase.Err != "" {
+			if err == nil {
+				t.Fatalf("(testcase %d) expected error, got nil", i)
+			}
+			if expected, got := testcase.Err, err.Error(); expected != got {
+				t.Fatalf("(testcase %d) expected %s, got %s", i, expected, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("(testcase %d) %s", i, err)
+		}
+		if expected, got := testcase.Expected, osc.Format(p.(osc.Message)); expected != got {
+			t.Fatalf("(testcase %d) expected %s, got %s", i, expected, got)
+		}
+	}
+}
@@ -0,0 +1,501 @@
+package oscquery
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/scgolang/osc"
+	"github.com/scgolang/osc/internal/websocket"
+)
+
+// Common errors.
+var (
+	ErrNoConn      = errors.New("no osc connection")
+	ErrNotWritable = errors.New("method is not writable")
+)
+
+// subscriptionBuffer is the number of values a subscription buffers.
+const subscriptionBuffer = 16
+
+// Client discovers the address space of a remote server,
+// sets the values of its methods and subscribes to their changes.
+type Client struct {
+	url        *url.URL
+	httpClient *http.Client
+
+	mu        sync.Mutex
+	conn      osc.Conn
+	namespace *Node
+	ws        *websocket.Conn
+	subs      map[string]map[*Subscription]struct{}
+}
+
+// Subscription receives the values of a method when they change.
+// Values are dropped if C is full, and C is closed when the subscription
+// or its WebSocket connection is closed.
+type Subscription struct {
+	C <-chan osc.Arguments
+
+	c       chan osc.Arguments
+	address string
+	client  *Client
+}
+
+// NewClient returns a client for the server at an http:// URL, e.g. http://127.0.0.1:8080.
+func NewClient(rawURL string) (*Client, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse url")
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, errors.Errorf("unsupported scheme %q", u.Scheme)
+	}
+	return &Client{
+		url:        u,
+		httpClient: http.DefaultClient,
+		subs:       map[string]map[*Subscription]struct{}{},
+	}, nil
+}
+
+// SetHTTPClient sets the HTTP client that queries the server.
+// The default is http.DefaultClient.
+func (c *Client) SetHTTPClient(httpClient *http.Client) {
+	c.httpClient = httpClient
+}
+
+// SetConn sets the connection that messages to the methods of the server are sent over.
+func (c *Client) SetConn(conn osc.Conn) {
+	c.mu.Lock()
+	c.conn = conn
+	c.mu.Unlock()
+}
+
+// HostInfo queries the HOST_INFO of the server.
+func (c *Client) HostInfo(ctx context.Context) (HostInfo, error) {
+	var info HostInfo
+	err := c.get(ctx, "/", attrHostInfo, &info)
+	return info, err
+}
+
+// Namespace queries the whole address space of the server.
+// It is kept for Set, until the server reports that it changed.
+func (c *Client) Namespace(ctx context.Context) (*Node, error) {
+	root := &Node{}
+	if err := c.get(ctx, "/", "", root); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.namespace = root
+	c.mu.Unlock()
+
+	return root, nil
+}
+
+// Node queries the node at a path, and everything in it.
+func (c *Client) Node(ctx context.Context, path string) (*Node, error) {
+	n := &Node{}
+	if err := c.get(ctx, path, "", n); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+// Set sends a message that sets a method to values, which are converted
+// to the type of the method and checked against its range.
+// Values can be Go numbers, strings, bools and byte slices, or osc.Arguments.
+// The type comes from the address space that Namespace returned last,
+// which is queried if there is none.
+func (c *Client) Set(ctx context.Context, address string, values ...interface{}) error {
+	c.mu.Lock()
+	root, conn := c.namespace, c.conn
+	c.mu.Unlock()
+
+	if conn == nil {
+		return ErrNoConn
+	}
+	if root == nil {
+		var err error
+		if root, err = c.Namespace(ctx); err != nil {
+			return err
+		}
+	}
+	n := root.Lookup(address)
+	if n == nil || !n.IsMethod() {
+		return errors.Wrap(ErrNotFound, address)
+	}
+	if !n.Access.Writable() {
+		return errors.Wrap(ErrNotWritable, address)
+	}
+	args, err := n.arguments(values)
+	if err != nil {
+		return errors.Wrap(err, address)
+	}
+	return conn.Send(osc.Message{Address: address, Arguments: args})
+}
+
+// SetInt sends a message that sets a method to an int.
+func (c *Client) SetInt(ctx context.Context, address string, value int32) error {
+	return c.Set(ctx, address, value)
+}
+
+// SetFloat sends a message that sets a method to a float.
+func (c *Client) SetFloat(ctx context.Context, address string, value float32) error {
+	return c.Set(ctx, address, value)
+}
+
+// SetString sends a message that sets a method to a string.
+func (c *Client) SetString(ctx context.Context, address, value string) error {
+	return c.Set(ctx, address, value)
+}
+
+// SetBool sends a message that sets a method to a bool.
+func (c *Client) SetBool(ctx context.Context, address string, value bool) error {
+	return c.Set(ctx, address, value)
+}
+
+// Subscribe asks the server to stream the values of a method over WebSocket.
+// The WebSocket connection is opened by the first subscription.
+func (c *Client) Subscribe(ctx context.Context, address string) (*Subscription, error) {
+	ws, err := c.websocket(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ch := make(chan osc.Arguments, subscriptionBuffer)
+	sub := &Subscription{C: ch, c: ch, address: address, client: c}
+
+	c.mu.Lock()
+	subs, listening := c.subs[address]
+	if !listening {
+		subs = map[*Subscription]struct{}{}
+		c.subs[address] = subs
+	}
+	subs[sub] = struct{}{}
+	c.mu.Unlock()
+
+	if listening {
+		return sub, nil
+	}
+	if err := writeCommand(ws, commandListen, address); err != nil {
+		_ = sub.Close()
+		return nil, err
+	}
+	return sub, nil
+}
+
+// Close stops the subscription. The server is asked to stop streaming
+// the values of the method when it has no subscriptions left.
+func (s *Subscription) Close() error {
+	c := s.client
+
+	c.mu.Lock()
+	subs, ok := c.subs[s.address]
+	if _, subscribed := subs[s]; !ok || !subscribed {
+		c.mu.Unlock()
+		return nil
+	}
+	delete(subs, s)
+	close(s.c)
+
+	var ws *websocket.Conn
+	if len(subs) == 0 {
+		delete(c.subs, s.address)
+		ws = c.ws
+	}
+	c.mu.Unlock()
+
+	if ws == nil {
+		return nil
+	}
+	return writeCommand(ws, commandIgnore, s.address)
+}
+
+// Close closes the WebSocket connection and every subscription.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	ws := c.ws
+	c.mu.Unlock()
+
+	if ws == nil {
+		return nil
+	}
+	return ws.Close()
+}
+
+// get queries a path, or an attribute of it, and decodes the JSON response into v.
+func (c *Client) get(ctx context.Context, path, attr string, v interface{}) error {
+	u := *c.url
+	u.Path, u.RawQuery = path, attr
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return errors.Wrap(err, "create request")
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "query "+path)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return errors.Wrap(ErrNotFound, path)
+	default:
+		return errors.Errorf("query %s: unexpected status %s", path, resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return errors.Wrap(err, "decode "+path)
+	}
+	return nil
+}
+
+// websocket returns the WebSocket connection, which is opened if needed.
+// It connects to the address in HOST_INFO, or to the HTTP server.
+func (c *Client) websocket(ctx context.Context) (*websocket.Conn, error) {
+	c.mu.Lock()
+	ws := c.ws
+	c.mu.Unlock()
+
+	if ws != nil {
+		return ws, nil
+	}
+	u := *c.url
+	u.Scheme, u.Path, u.RawQuery = "ws", "/", ""
+	if c.url.Scheme == "https" {
+		u.Scheme = "wss"
+	}
+	if info, err := c.HostInfo(ctx); err == nil && info.WSPort != 0 {
+		host := info.WSIP
+		if host == "" {
+			host = c.url.Hostname()
+		}
+		u.Host = net.JoinHostPort(host, strconv.Itoa(info.WSPort))
+	}
+	ws, err := websocket.Dial(ctx, u.String())
+	if err != nil {
+		return nil, errors.Wrap(err, "open websocket")
+	}
+	c.mu.Lock()
+	if c.ws != nil {
+		// Another subscription opened it first.
+		c.mu.Unlock()
+		_ = ws.Close()
+		return c.websocket(ctx)
+	}
+	c.ws = ws
+	c.mu.Unlock()
+
+	go c.readLoop(ws)
+
+	return ws, nil
+}
+
+// readLoop delivers the values that the server streams to the subscriptions,
+// until the WebSocket connection is closed.
+func (c *Client) readLoop(ws *websocket.Conn) {
+	defer c.closeSubscriptions(ws)
+
+	for {
+		typ, data, err := ws.ReadMessage()
+		if err != nil {
+			return
+		}
+		switch typ {
+		case websocket.TextMessage:
+			// Any change of the address space invalidates the one that was queried.
+			c.mu.Lock()
+			c.namespace = nil
+			c.mu.Unlock()
+		case websocket.BinaryMessage:
+			p, err := osc.ParsePacket(data, nil)
+			if err != nil {
+				continue
+			}
+			c.deliver(p)
+		}
+	}
+}
+
+// deliver sends the arguments of the messages in a packet to their subscriptions.
+func (c *Client) deliver(p osc.Packet) {
+	switch x := p.(type) {
+	case osc.Message:
+		c.mu.Lock()
+		for sub := range c.subs[x.Address] {
+			select {
+			case sub.c <- x.Arguments:
+			default:
+			}
+		}
+		c.mu.Unlock()
+	case osc.Bundle:
+		for _, p := range x.Packets {
+			c.deliver(p)
+		}
+	}
+}
+
+// closeSubscriptions closes every subscription of a WebSocket connection that went away.
+func (c *Client) closeSubscriptions(ws *websocket.Conn) {
+	_ = ws.Close()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.ws != ws {
+		return
+	}
+	c.ws = nil
+	for address, subs := range c.subs {
+		for sub := range subs {
+			close(sub.c)
+		}
+		delete(c.subs, address)
+	}
+}
+
+// writeCommand sends a command over WebSocket.
+func writeCommand(ws *websocket.Conn, command, path string) error {
+	data, _ := json.Marshal(map[string]string{"COMMAND": command, "DATA": path})
+	return errors.Wrap(ws.WriteMessage(websocket.TextMessage, data), "send "+command)
+}
+
+// arguments converts values to the type of the method and checks them against its range.
+func (n *Node) arguments(values []interface{}) (osc.Arguments, error) {
+	if len(values) != len(n.Type) {
+		return nil, errors.Errorf("expected %d values for type %q, got %d", len(n.Type), n.Type, len(values))
+	}
+	args := make(osc.Arguments, len(values))
+	for i, v := range values {
+		arg, err := convert(n.Type[i], v)
+		if err != nil {
+			return nil, errors.Wrapf(err, "value %d", i)
+		}
+		if i < len(n.Range) {
+			if err := checkRange(n.Range[i], arg); err != nil {
+				return nil, errors.Wrapf(err, "value %d", i)
+			}
+		}
+		args[i] = arg
+	}
+	return args, nil
+}
+
+// convert converts a value to an argument with the typetag.
+func convert(tt byte, v interface{}) (osc.Argument, error) {
+	if arg, ok := v.(osc.Argument); ok {
+		v = jsonValue(arg)
+		if b, ok := arg.(osc.Blob); ok {
+			v = []byte(b)
+		}
+	}
+	switch tt {
+	case osc.TypetagInt:
+		if f, ok := number(v); ok && f == math.Trunc(f) && f >= math.MinInt32 && f <= math.MaxInt32 {
+			return osc.Int(int32(f)), nil
+		}
+	case osc.TypetagFloat:
+		if f, ok := number(v); ok {
+			return osc.Float(float32(f)), nil
+		}
+	case osc.TypetagDouble:
+		if f, ok := number(v); ok {
+			return osc.Double(f), nil
+		}
+	case osc.TypetagString:
+		if s, ok := v.(string); ok {
+			return osc.String(s), nil
+		}
+	case osc.TypetagTrue, osc.TypetagFalse:
+		if b, ok := v.(bool); ok {
+			return osc.Bool(b), nil
+		}
+	case osc.TypetagBlob:
+		if b, ok := v.([]byte); ok {
+			return osc.Blob(b), nil
+		}
+	default:
+		return nil, errors.Errorf("unsupported typetag %c", tt)
+	}
+	return nil, errors.Errorf("can not convert %#v to typetag %c", v, tt)
+}
+
+// checkRange returns an error if arg is outside of a range.
+func checkRange(r Range, arg osc.Argument) error {
+	if len(r.Vals) > 0 {
+		for _, val := range r.Vals {
+			if val.Equal(arg) {
+				return nil
+			}
+		}
+		return errors.Errorf("%s is not one of %s", osc.FormatArgument(arg), formatArguments(r.Vals))
+	}
+	f, ok := number(jsonValue(arg))
+	if !ok {
+		return nil
+	}
+	if min, ok := number(jsonValueOf(r.Min)); ok && f < min {
+		return errors.Errorf("%s is less than the minimum %s", osc.FormatArgument(arg), osc.FormatArgument(r.Min))
+	}
+	if max, ok := number(jsonValueOf(r.Max)); ok && f > max {
+		return errors.Errorf("%s is greater than the maximum %s", osc.FormatArgument(arg), osc.FormatArgument(r.Max))
+	}
+	return nil
+}
+
+// jsonValueOf returns the JSON value of arg, or nil if arg is nil.
+func jsonValueOf(arg osc.Argument) interface{} {
+	if arg == nil {
+		return nil
+	}
+	return jsonValue(arg)
+}
+
+// number returns a Go number as a float64.
+func number(v interface{}) (float64, bool) {
+	switch x := v.(type) {
+	case int:
+		return float64(x), true
+	case int8:
+		return float64(x), true
+	case int16:
+		return float64(x), true
+	case int32:
+		return float64(x), true
+	case int64:
+		return float64(x), true
+	case uint:
+		return float64(x), true
+	case uint8:
+		return float64(x), true
+	case uint16:
+		return float64(x), true
+	case uint32:
+		return float64(x), true
+	case uint64:
+		return float64(x), true
+	case float32:
+		return float64(x), true
+	case float64:
+		return x, true
+	default:
+		return 0, false
+	}
+}
+
+// formatArguments returns the text of args, separated by commas.
+func formatArguments(args osc.Arguments) string {
+	s := make([]string, len(args))
+	for i, arg := range args {
+		s[i] = osc.FormatArgument(arg)
+	}
+	return "[" + strings.Join(s, ", ") + "]"
+}
@@ -0,0 +1,327 @@
+package oscquery
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/scgolang/osc"
+	"github.com/scgolang/osc/internal/websocket"
+)
+
+// testClient returns a client of a test server, whose OSC server and
+// HTTP server listen on loopback, and a function that closes them.
+func testClient(t *testing.T, received chan osc.Message) (*Client, *Server, func()) {
+	s := testServer(t, received)
+
+	oscConn, err := osc.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() { _ = oscConn.Serve(1, s) }()
+
+	if err := s.SetOSCAddr(oscConn.LocalAddr()); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(s)
+
+	c, err := NewClient(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := osc.DialUDP("udp", nil, oscConn.LocalAddr().(*net.UDPAddr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.SetConn(conn)
+
+	cleanup := func() {
+		_ = conn.Close()
+		_ = c.Close()
+		srv.Close()
+		_ = oscConn.Close()
+		_ = s.Close()
+	}
+	return c, s, cleanup
+}
+
+func TestClientNamespace(t *testing.T) {
+	c, _, cleanup := testClient(t, nil)
+	defer cleanup()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	root, err := c.Namespace(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	_ = root.Walk(func(n *Node) error {
+		paths = append(paths, n.FullPath)
+		return nil
+	})
+	if expected, got := "[/ /meter /synth /synth/freq /synth/wave]", fmtStrings(paths); expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+	for i, testcase := range []struct {
+		Path   string
+		Type   string
+		Value  string
+		Range  string
+		Access Access
+	}{
+		{Path: "/synth", Value: "[]", Range: "[]", Access: NoAccess},
+		{Path: "/synth/freq", Type: "f", Value: "[440.0]", Range: "[20.0 20000.0 []]", Access: ReadWrite},
+		{Path: "/synth/wave/", Type: "s", Value: `["sine"]`, Range: `[<nil> <nil> ["sine" "saw"]]`, Access: ReadWrite},
+		{Path: "meter", Type: "f", Value: "[0.5]", Range: "[]", Access: ReadOnly},
+	} {
+		n := root.Lookup(testcase.Path)
+		if n == nil {
+			t.Fatalf("(testcase %d) expected a node at %s", i, testcase.Path)
+		}
+		if expected, got := testcase.Type, n.Type; expected != got {
+			t.Fatalf("(testcase %d) expected type %s, got %s", i, expected, got)
+		}
+		if expected, got := testcase.Value, fmtArguments(n.Value); expected != got {
+			t.Fatalf("(testcase %d) expected value %s, got %s", i, expected, got)
+		}
+		if expected, got := testcase.Range, fmtRanges(n.Range); expected != got {
+			t.Fatalf("(testcase %d) expected range %s, got %s", i, expected, got)
+		}
+		if expected, got := testcase.Access, n.Access; expected != got {
+			t.Fatalf("(testcase %d) expected access %d, got %d", i, expected, got)
+		}
+	}
+	if n := root.Lookup("/synth/gain"); n != nil {
+		t.Fatalf("expected nil, got %#v", n)
+	}
+	if _, err := c.Node(ctx, "/synth/gain"); errors.Cause(err) != ErrNotFound {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	info, err := c.HostInfo(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := "test synth", info.Name; expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+	if expected, got := "UDP", info.OSCTransport; expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+}
+
+func TestClientSetErrors(t *testing.T) {
+	c, _, cleanup := testClient(t, nil)
+	defer cleanup()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	for i, testcase := range []struct {
+		Address string
+		Values  []interface{}
+		Err     string
+	}{
+		{Address: "/synth/gain", Values: []interface{}{1}, Err: "/synth/gain: method not found"},
+		{Address: "/synth", Values: []interface{}{1}, Err: "/synth: method not found"},
+		{Address: "/meter", Values: []interface{}{1}, Err: "/meter: method is not writable"},
+		{Address: "/synth/freq", Values: []interface{}{1, 2}, Err: `/synth/freq: expected 1 values for type "f", got 2`},
+		{Address: "/synth/freq", Values: []interface{}{"high"}, Err: `/synth/freq: value 0: can not convert "high" to typetag f`},
+		{Address: "/synth/freq", Values: []interface{}{10}, Err: "/synth/freq: value 0: 10.0 is less than the minimum 20.0"},
+		{Address: "/synth/freq", Values: []interface{}{osc.Double(20001)}, Err: "/synth/freq: value 0: 20001.0 is greater than the maximum 20000.0"},
+		{Address: "/synth/wave", Values: []interface{}{"square"}, Err: `/synth/wave: value 0: "square" is not one of ["sine", "saw"]`},
+	} {
+		err := c.Set(ctx, testcase.Address, testcase.Values...)
+		if err == nil {
+			t.Fatalf("(testcase %d) expected error, got nil", i)
+		}
+		if expected, got := testcase.Err, err.Error(); expected != got {
+			t.Fatalf("(testcase %d) expected %s, got %s", i, expected, got)
+		}
+	}
+	c.SetConn(nil)
+	if err := c.SetFloat(ctx, "/synth/freq", 880); err != ErrNoConn {
+		t.Fatalf("expected ErrNoConn, got %v", err)
+	}
+}
+
+func TestClientSetNoArguments(t *testing.T) {
+	var (
+		received      = make(chan osc.Message, 1)
+		c, s, cleanup = testClient(t, received)
+	)
+	defer cleanup()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := s.Handle("/reset", Method{
+		Handler: osc.Method(func(msg osc.Message) error {
+			received <- msg
+			return nil
+		}),
+		Access: WriteOnly,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := c.Node(ctx, "/reset")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !n.IsMethod() {
+		t.Fatal("expected /reset to be a method")
+	}
+	if err := c.Set(ctx, "/reset"); err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := "/reset", osc.Format(<-received); expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+	if err := c.Set(ctx, "/reset", 1); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+}
+
+func TestClientSetSubscribe(t *testing.T) {
+	var (
+		received      = make(chan osc.Message, 1)
+		c, s, cleanup = testClient(t, received)
+	)
+	defer cleanup()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	sub, err := c.Subscribe(ctx, "/synth/freq")
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitListening(t, s, "/synth/freq", true)
+
+	// Ints are converted to the type of the method.
+	if err := c.SetInt(ctx, "/synth/freq", 880); err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := "/synth/freq ,f 880.0", osc.Format(<-received); expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+	expectValue(t, sub, "[880.0]")
+
+	if err := s.SetValue("/synth/freq", osc.Float(220)); err != nil {
+		t.Fatal(err)
+	}
+	expectValue(t, sub, "[220.0]")
+
+	if err := sub.Close(); err != nil {
+		t.Fatal(err)
+	}
+	waitListening(t, s, "/synth/freq", false)
+
+	if _, ok := <-sub.C; ok {
+		t.Fatal("expected the subscription to be closed")
+	}
+	// Closing the client closes its subscriptions.
+	sub, err = c.Subscribe(ctx, "/meter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for the subscription to be closed")
+	case _, ok := <-sub.C:
+		if ok {
+			t.Fatal("expected the subscription to be closed")
+		}
+	}
+}
+
+func TestClientSubscribeMalformed(t *testing.T) {
+	// The server streams a malformed packet before a value.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Upgrade") == "" {
+			http.NotFound(w, r)
+			return
+		}
+		ws, err := websocket.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer func() { _ = ws.Close() }()
+
+		if _, _, err := ws.ReadMessage(); err != nil {
+			return
+		}
+		_ = ws.WriteMessage(websocket.BinaryMessage, []byte("/synth/freq\x00,b\x00\x00\x8a000"))
+		_ = ws.WriteMessage(websocket.BinaryMessage, osc.Message{Address: "/synth/freq", Arguments: osc.Arguments{osc.Float(440)}}.Bytes())
+
+		_, _, _ = ws.ReadMessage()
+	}))
+	defer srv.Close()
+
+	c, err := NewClient(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = c.Close() }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	sub, err := c.Subscribe(ctx, "/synth/freq")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectValue(t, sub, "[440.0]")
+}
+
+// expectValue waits for the next value of a subscription and compares its text to expected.
+func expectValue(t *testing.T, sub *Subscription, expected string) {
+	select {
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timeout waiting for %s", expected)
+	case args := <-sub.C:
+		if got := fmtArguments(args); expected != got {
+			t.Fatalf("expected %s, got %s", expected, got)
+		}
+	}
+}
+
+// fmtArguments returns the text of args, e.g. [440.0].
+func fmtArguments(args osc.Arguments) string {
+	s := make([]string, len(args))
+	for i, arg := range args {
+		s[i] = osc.FormatArgument(arg)
+	}
+	return fmtStrings(s)
+}
+
+// fmtRanges returns the text of ranges, e.g. [20.0 20000.0 []].
+func fmtRanges(ranges []Range) string {
+	s := []string{}
+	for _, r := range ranges {
+		for _, arg := range []osc.Argument{r.Min, r.Max} {
+			if arg == nil {
+				s = append(s, "<nil>")
+			} else {
+				s = append(s, osc.FormatArgument(arg))
+			}
+		}
+		s = append(s, fmtArguments(r.Vals))
+	}
+	return fmtStrings(s)
+}
+
+// fmtStrings returns the strings between brackets, separated by spaces.
+func fmtStrings(s []string) string {
+	return "[" + strings.Join(s, " ") + "]"
+}
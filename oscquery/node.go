@@ -0,0 +1,201 @@
+package oscquery
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/scgolang/osc"
+)
+
+// Node is a container or a method of the address space of a remote server.
+type Node struct {
+	FullPath    string
+	Contents    map[string]*Node
+	Type        string
+	Value       osc.Arguments
+	Range       []Range
+	Access      Access
+	Description string
+
+	method bool // The node has a TYPE or an ACCESS.
+}
+
+// HostInfo describes a server.
+type HostInfo struct {
+	Name       string          `json:"NAME"`
+	Extensions map[string]bool `json:"EXTENSIONS"`
+
+	// The address of the OSC server, empty if it is the address of the HTTP server.
+	OSCIP        string `json:"OSC_IP"`
+	OSCPort      int    `json:"OSC_PORT"`
+	OSCTransport string `json:"OSC_TRANSPORT"`
+
+	// The address of the WebSocket server, empty if it is the address of the HTTP server.
+	WSIP   string `json:"WS_IP"`
+	WSPort int    `json:"WS_PORT"`
+}
+
+// IsMethod returns true if the node is a method, i.e. it has a type or an access.
+// Methods without arguments have no type.
+func (n *Node) IsMethod() bool {
+	return n.method || n.Type != ""
+}
+
+// Lookup returns the node at the path, relative to n, or nil.
+func (n *Node) Lookup(path string) *Node {
+	for _, part := range strings.Split(strings.Trim(path, "/"), "/") {
+		if part == "" {
+			continue
+		}
+		if n = n.Contents[part]; n == nil {
+			return nil
+		}
+	}
+	return n
+}
+
+// Walk calls fn for n and every node in it, in the order of their paths.
+func (n *Node) Walk(fn func(*Node) error) error {
+	if err := fn(n); err != nil {
+		return err
+	}
+	names := make([]string, 0, len(n.Contents))
+	for name := range n.Contents {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if err := n.Contents[name].Walk(fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UnmarshalJSON parses the JSON of a node. Values and ranges are decoded with the
+// typetags of the node. Values of types that are not supported are left out,
+// and methods without ACCESS are readable and writable.
+func (n *Node) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		FullPath string            `json:"FULL_PATH"`
+		Contents map[string]*Node  `json:"CONTENTS"`
+		Type     string            `json:"TYPE"`
+		Value    []json.RawMessage `json:"VALUE"`
+		Range    []*struct {
+			Min  json.RawMessage   `json:"MIN"`
+			Max  json.RawMessage   `json:"MAX"`
+			Vals []json.RawMessage `json:"VALS"`
+		} `json:"RANGE"`
+		Access      *Access `json:"ACCESS"`
+		Description string  `json:"DESCRIPTION"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*n = Node{
+		FullPath:    raw.FullPath,
+		Contents:    raw.Contents,
+		Type:        raw.Type,
+		Access:      ReadWrite,
+		Description: raw.Description,
+		method:      raw.Type != "" || raw.Access != nil,
+	}
+	if raw.Access != nil {
+		n.Access = *raw.Access
+	} else if raw.Type == "" {
+		n.Access = NoAccess
+	}
+	if values, err := decodeValues(raw.Type, raw.Value); err == nil {
+		n.Value = values
+	}
+	for i, r := range raw.Range {
+		var rng Range
+		if r != nil && i < len(raw.Type) {
+			tt := raw.Type[i]
+			rng.Min, _ = decodeValue(tt, r.Min)
+			rng.Max, _ = decodeValue(tt, r.Max)
+
+			for _, v := range r.Vals {
+				if arg, err := decodeValue(tt, v); err == nil && arg != nil {
+					rng.Vals = append(rng.Vals, arg)
+				}
+			}
+		}
+		n.Range = append(n.Range, rng)
+	}
+	return nil
+}
+
+// decodeValues decodes the JSON values of a method with the typetags.
+func decodeValues(typetags string, raw []json.RawMessage) (osc.Arguments, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	if len(raw) != len(typetags) {
+		return nil, errors.Errorf("%d values for type %q", len(raw), typetags)
+	}
+	args := make(osc.Arguments, len(raw))
+	for i, v := range raw {
+		arg, err := decodeValue(typetags[i], v)
+		if err != nil {
+			return nil, err
+		}
+		if arg == nil {
+			return nil, errors.Errorf("missing value %d", i)
+		}
+		args[i] = arg
+	}
+	return args, nil
+}
+
+// decodeValue decodes a JSON value with a typetag. It returns nil for a missing value.
+func decodeValue(tt byte, raw json.RawMessage) (osc.Argument, error) {
+	if len(raw) == 0 || bytes.Equal(raw, []byte("null")) {
+		return nil, nil
+	}
+	arg, err := unmarshalValue(tt, raw)
+	if err != nil {
+		return nil, errors.Wrapf(err, "decode %s value", raw)
+	}
+	return arg, nil
+}
+
+// unmarshalValue unmarshals a JSON value with a typetag.
+func unmarshalValue(tt byte, raw json.RawMessage) (osc.Argument, error) {
+	switch tt {
+	case osc.TypetagInt:
+		var i int32
+		err := json.Unmarshal(raw, &i)
+		return osc.Int(i), err
+	case osc.TypetagFloat:
+		var f float32
+		err := json.Unmarshal(raw, &f)
+		return osc.Float(f), err
+	case osc.TypetagDouble:
+		var d float64
+		err := json.Unmarshal(raw, &d)
+		return osc.Double(d), err
+	case osc.TypetagString:
+		var s string
+		err := json.Unmarshal(raw, &s)
+		return osc.String(s), err
+	case osc.TypetagTrue, osc.TypetagFalse:
+		var b bool
+		err := json.Unmarshal(raw, &b)
+		return osc.Bool(b), err
+	case osc.TypetagBlob:
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return nil, err
+		}
+		b, err := base64.StdEncoding.DecodeString(s)
+		return osc.Blob(b), err
+	default:
+		return nil, errors.Errorf("unsupported typetag %c", tt)
+	}
+}
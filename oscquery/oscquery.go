@@ -0,0 +1,193 @@
+// Package oscquery implements the OSCQuery protocol, which lets clients like
+// TouchOSC, Chataigne and Vezér discover the OSC methods of a server,
+// see https://github.com/Vidvox/OSCQueryProposal
+//
+// A Server is an osc.Dispatcher whose methods are described with types, ranges,
+// values and descriptions. It serves the description as JSON over HTTP,
+// and streams value changes over WebSocket to the clients that listen to them:
+//
+//	server := oscquery.NewServer("synth")
+//	err := server.Handle("/synth/freq", oscquery.Method{
+//		Handler: osc.Method(setFreq),
+//		Type:    "f",
+//		Value:   osc.Arguments{osc.Float(440)},
+//		Range:   []oscquery.Range{{Min: osc.Float(20), Max: osc.Float(20000)}},
+//		Access:  oscquery.ReadWrite,
+//	})
+//	...
+//	go func() { _ = conn.Serve(1, server) }()
+//	err = http.ListenAndServe("127.0.0.1:8080", server)
+//
+// A Client queries the description of a remote server, sends messages that
+// set its methods over an osc.Conn, and subscribes to their values:
+//
+//	client, err := oscquery.NewClient("http://127.0.0.1:8080")
+//	client.SetConn(conn)
+//	err = client.SetFloat(ctx, "/synth/freq", 880)
+//	sub, err := client.Subscribe(ctx, "/synth/freq")
+//	for value := range sub.C {
+//		...
+//	}
+package oscquery
+
+import (
+	"encoding/base64"
+	"math"
+
+	"github.com/scgolang/osc"
+)
+
+// Access says whether clients can read and write the value of a method.
+type Access int
+
+// Access values.
+const (
+	NoAccess  Access = 0
+	ReadOnly  Access = 1
+	WriteOnly Access = 2
+	ReadWrite Access = 3
+)
+
+// Readable returns true if clients can read the value.
+func (a Access) Readable() bool {
+	return a&ReadOnly != 0
+}
+
+// Writable returns true if clients can send messages to the method.
+func (a Access) Writable() bool {
+	return a&WriteOnly != 0
+}
+
+// Range is the range of one argument of a method.
+// Min and Max can be nil, and so can Vals, the list of allowed values.
+type Range struct {
+	Min  osc.Argument
+	Max  osc.Argument
+	Vals osc.Arguments
+}
+
+// Method describes an OSC method of the address space.
+type Method struct {
+	// Handler handles the messages that clients send to the method.
+	// It can be nil for methods that only have a value.
+	Handler osc.MessageHandler
+
+	// Type are the typetags of the arguments, without the leading comma, e.g. "ff".
+	// If it is empty the typetags of Value are used.
+	Type string
+
+	// Value is the current value of the method. Messages sent to the method
+	// and SetValue change it.
+	Value osc.Arguments
+
+	// Range has the range of every argument.
+	Range []Range
+
+	// Access says whether clients can read the value and send messages to the method.
+	// Messages are only delivered to methods that are writable.
+	Access Access
+
+	// Description is a human readable description of the method.
+	Description string
+}
+
+// typetags returns the typetags of the method.
+func (m Method) typetags() string {
+	if m.Type != "" || len(m.Value) == 0 {
+		return m.Type
+	}
+	return typetags(m.Value)
+}
+
+// typetags returns the typetags of args.
+// Bools are T whatever their value, so the type of a method does not change with its value.
+func typetags(args osc.Arguments) string {
+	tt := make([]byte, len(args))
+	for i, arg := range args {
+		if tt[i] = arg.Typetag(); tt[i] == osc.TypetagFalse {
+			tt[i] = osc.TypetagTrue
+		}
+	}
+	return string(tt)
+}
+
+// sameTypes returns true if two typetag strings have the same types, i.e. T and F are the same.
+func sameTypes(a, b string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := 0; i < len(a); i++ {
+		if a[i] != b[i] && !(isBool(a[i]) && isBool(b[i])) {
+			return false
+		}
+	}
+	return true
+}
+
+// isBool returns true if tt is the typetag of a bool.
+func isBool(tt byte) bool {
+	return tt == osc.TypetagTrue || tt == osc.TypetagFalse
+}
+
+// Attributes of nodes.
+const (
+	attrFullPath    = "FULL_PATH"
+	attrContents    = "CONTENTS"
+	attrType        = "TYPE"
+	attrValue       = "VALUE"
+	attrRange       = "RANGE"
+	attrAccess      = "ACCESS"
+	attrDescription = "DESCRIPTION"
+	attrHostInfo    = "HOST_INFO"
+)
+
+// jsonValue returns the value of an argument as it is written in JSON.
+// Floats that JSON can not represent are null, and blobs are base64 strings.
+func jsonValue(arg osc.Argument) interface{} {
+	switch x := arg.(type) {
+	case osc.Int:
+		return int32(x)
+	case osc.Float:
+		if f := float64(x); math.IsNaN(f) || math.IsInf(f, 0) {
+			return nil
+		}
+		return float32(x)
+	case osc.Double:
+		if f := float64(x); math.IsNaN(f) || math.IsInf(f, 0) {
+			return nil
+		}
+		return float64(x)
+	case osc.String:
+		return string(x)
+	case osc.Bool:
+		return bool(x)
+	case osc.Blob:
+		return base64.StdEncoding.EncodeToString(x)
+	default:
+		return nil
+	}
+}
+
+// jsonValues returns the values of args as they are written in JSON.
+func jsonValues(args osc.Arguments) []interface{} {
+	values := make([]interface{}, len(args))
+	for i, arg := range args {
+		values[i] = jsonValue(arg)
+	}
+	return values
+}
+
+// jsonRange returns a range as it is written in JSON.
+func jsonRange(r Range) map[string]interface{} {
+	m := map[string]interface{}{}
+	if r.Min != nil {
+		m["MIN"] = jsonValue(r.Min)
+	}
+	if r.Max != nil {
+		m["MAX"] = jsonValue(r.Max)
+	}
+	if len(r.Vals) > 0 {
+		m["VALS"] = jsonValues(r.Vals)
+	}
+	return m
+}
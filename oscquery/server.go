@@ -0,0 +1,554 @@
+package oscquery
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/scgolang/osc"
+	"github.com/scgolang/osc/internal/websocket"
+)
+
+// Common errors.
+var (
+	ErrDuplicateMethod = errors.New("method already exists")
+	ErrNotFound        = errors.New("method not found")
+)
+
+// Commands of WebSocket text messages.
+const (
+	commandListen      = "LISTEN"
+	commandIgnore      = "IGNORE"
+	commandPathAdded   = "PATH_ADDED"
+	commandPathRemoved = "PATH_REMOVED"
+)
+
+// Server serves the description of its OSC methods with the OSCQuery protocol.
+// It is an osc.Dispatcher for the OSC side and an http.Handler for the HTTP
+// and WebSocket side.
+type Server struct {
+	name string
+
+	mu           sync.Mutex
+	root         *node
+	oscAddr      net.Addr
+	clients      map[*wsClient]struct{}
+	errorHandler func(error)
+	checkOrigin  func(*http.Request) bool
+}
+
+// node is a container or a method in the address space.
+type node struct {
+	path     string
+	children map[string]*node
+	method   *Method // nil for containers.
+}
+
+// wsClient is a WebSocket connection and the paths it listens to.
+type wsClient struct {
+	conn   *websocket.Conn
+	listen map[string]struct{}
+}
+
+// NewServer returns a server whose HOST_INFO has the name.
+func NewServer(name string) *Server {
+	return &Server{
+		name:    name,
+		root:    &node{path: "/", children: map[string]*node{}},
+		clients: map[*wsClient]struct{}{},
+	}
+}
+
+// SetOSCAddr sets the address that clients send OSC messages to, which is
+// reported in HOST_INFO. It is a *net.UDPAddr or a *net.TCPAddr.
+// If it is not set, clients assume the address of the HTTP server.
+func (s *Server) SetOSCAddr(addr net.Addr) error {
+	switch addr.(type) {
+	case *net.UDPAddr, *net.TCPAddr:
+	default:
+		return errors.Errorf("unsupported osc address type %T", addr)
+	}
+	s.mu.Lock()
+	s.oscAddr = addr
+	s.mu.Unlock()
+	return nil
+}
+
+// SetErrorHandler sets a function that is called with the errors of methods
+// that handle messages sent over WebSocket. They are ignored by default.
+func (s *Server) SetErrorHandler(handler func(error)) {
+	s.mu.Lock()
+	s.errorHandler = handler
+	s.mu.Unlock()
+}
+
+// SetCheckOrigin sets a function that returns true if a WebSocket connection
+// may be accepted, usually by checking the Origin header of the request.
+// The default is the same as for osc.WSServer, see its SetCheckOrigin.
+func (s *Server) SetCheckOrigin(checkOrigin func(r *http.Request) bool) {
+	s.mu.Lock()
+	s.checkOrigin = checkOrigin
+	s.mu.Unlock()
+}
+
+// Handle adds a method to the address space. The containers of the address are created as needed.
+// Clients that are connected over WebSocket are notified with PATH_ADDED.
+func (s *Server) Handle(address string, m Method) error {
+	if err := osc.ValidateAddress(address); err != nil {
+		return errors.Wrap(err, address)
+	}
+	if !strings.HasPrefix(address, "/") || strings.HasSuffix(address, "/") || strings.Contains(address, "//") {
+		return errors.Wrap(osc.ErrInvalidAddress, address)
+	}
+	m.Value = append(osc.Arguments(nil), m.Value...)
+
+	s.mu.Lock()
+	n := s.root
+	for _, part := range strings.Split(address[1:], "/") {
+		child, ok := n.children[part]
+		if !ok {
+			child = &node{path: strings.TrimSuffix(n.path, "/") + "/" + part, children: map[string]*node{}}
+			n.children[part] = child
+		}
+		n = child
+	}
+	if n.method != nil {
+		s.mu.Unlock()
+		return errors.Wrap(ErrDuplicateMethod, address)
+	}
+	n.method = &m
+	s.mu.Unlock()
+
+	s.broadcastCommand(commandPathAdded, address)
+	return nil
+}
+
+// Remove removes a method or a container, with everything in it, from the address space.
+// Clients that are connected over WebSocket are notified with PATH_REMOVED.
+func (s *Server) Remove(path string) error {
+	path = strings.TrimSuffix(path, "/")
+	if !strings.HasPrefix(path, "/") {
+		return errors.Wrap(ErrNotFound, path)
+	}
+	s.mu.Lock()
+	parent := s.lookup(path[:strings.LastIndex(path, "/")+1])
+	name := path[strings.LastIndex(path, "/")+1:]
+
+	if parent == nil || parent.children[name] == nil {
+		s.mu.Unlock()
+		return errors.Wrap(ErrNotFound, path)
+	}
+	delete(parent.children, name)
+
+	// Remove the containers that became empty.
+	for parent != s.root && len(parent.children) == 0 && parent.method == nil {
+		i := strings.LastIndex(parent.path, "/")
+		grandparent := s.lookup(parent.path[:i+1])
+		delete(grandparent.children, parent.path[i+1:])
+		parent = grandparent
+	}
+	s.mu.Unlock()
+
+	s.broadcastCommand(commandPathRemoved, path)
+	return nil
+}
+
+// SetValue sets the value of a method and sends it to the clients that listen to it.
+func (s *Server) SetValue(address string, args ...osc.Argument) error {
+	s.mu.Lock()
+	n := s.lookup(address)
+	if n == nil || n.method == nil {
+		s.mu.Unlock()
+		return errors.Wrap(ErrNotFound, address)
+	}
+	n.method.Value = append(osc.Arguments(nil), args...)
+	s.mu.Unlock()
+
+	s.sendValue(address, args)
+	return nil
+}
+
+// Value returns the value of a method.
+func (s *Server) Value(address string) (osc.Arguments, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := s.lookup(address)
+	if n == nil || n.method == nil {
+		return nil, errors.Wrap(ErrNotFound, address)
+	}
+	return append(osc.Arguments(nil), n.method.Value...), nil
+}
+
+// Dispatch invokes the messages of a bundle when its timetag is due.
+func (s *Server) Dispatch(b osc.Bundle, exactMatch bool) error {
+	if d := time.Until(b.Timetag.Time()); d > 0 {
+		time.Sleep(d)
+	}
+	for _, p := range b.Packets {
+		var err error
+
+		switch x := p.(type) {
+		case osc.Message:
+			err = s.Invoke(x, exactMatch)
+		case osc.Bundle:
+			err = s.Dispatch(x, exactMatch)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Invoke delivers a message to every writable method that its address matches.
+// If the method is readable and the arguments have its type, they become
+// its value and are sent to the clients that listen to it.
+func (s *Server) Invoke(msg osc.Message, exactMatch bool) error {
+	type match struct {
+		address string
+		method  Method
+	}
+	var matches []match
+
+	s.mu.Lock()
+	err := s.root.walk(func(n *node) error {
+		if n.method == nil || !n.method.Access.Writable() {
+			return nil
+		}
+		matched, err := msg.Match(n.path, exactMatch)
+		if err != nil || !matched {
+			return err
+		}
+		matches = append(matches, match{address: n.path, method: *n.method})
+		return nil
+	})
+	s.mu.Unlock()
+
+	if err != nil {
+		return err
+	}
+	for _, m := range matches {
+		if m.method.Handler != nil {
+			if err := m.method.Handler.Handle(msg); err != nil {
+				return err
+			}
+		}
+		if !m.method.Access.Readable() {
+			continue
+		}
+		if tt := m.method.typetags(); tt != "" && !sameTypes(tt, typetags(msg.Arguments)) {
+			continue
+		}
+		if err := s.SetValue(m.address, msg.Arguments...); err != nil && errors.Cause(err) != ErrNotFound {
+			return err
+		}
+	}
+	return nil
+}
+
+// ServeHTTP serves the JSON description of the address space,
+// and upgrades WebSocket requests.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if websocket.IsUpgrade(r) {
+		s.serveWebSocket(w, r)
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, status, err := s.describe(r.URL.Path, r.URL.RawQuery)
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+	if status == http.StatusNoContent {
+		w.WriteHeader(status)
+		return
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(data)
+}
+
+// Close closes every WebSocket connection.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	clients := s.clients
+	s.clients = map[*wsClient]struct{}{}
+	s.mu.Unlock()
+
+	for c := range clients {
+		_ = c.conn.Close()
+	}
+	return nil
+}
+
+// describe returns the JSON description of a path, or one of its attributes, and the HTTP status.
+func (s *Server) describe(path, attr string) (interface{}, int, error) {
+	if attr == attrHostInfo {
+		return s.hostInfo(), http.StatusOK, nil
+	}
+	if attr != "" && !validAttribute(attr) {
+		return nil, http.StatusBadRequest, errors.Errorf("unknown attribute %s", attr)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := s.lookup(path)
+	if n == nil {
+		return nil, http.StatusNotFound, errors.Errorf("no such path %s", path)
+	}
+	attrs := n.json()
+	if attr == "" {
+		return attrs, http.StatusOK, nil
+	}
+	value, ok := attrs[attr]
+	if !ok {
+		return nil, http.StatusNoContent, nil
+	}
+	return map[string]interface{}{attr: value}, http.StatusOK, nil
+}
+
+// hostInfo returns the HOST_INFO of the server.
+func (s *Server) hostInfo() map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	info := map[string]interface{}{
+		"NAME": s.name,
+		"EXTENSIONS": map[string]bool{
+			attrAccess:         true,
+			attrValue:          true,
+			attrRange:          true,
+			attrDescription:    true,
+			commandListen:      true,
+			commandPathAdded:   true,
+			commandPathRemoved: true,
+			"PATH_CHANGED":     false,
+			"PATH_RENAMED":     false,
+			"TAGS":             false,
+			"CLIPMODE":         false,
+			"UNIT":             false,
+			"CRITICAL":         false,
+			"EXTENDED_TYPE":    false,
+		},
+	}
+	switch addr := s.oscAddr.(type) {
+	case *net.UDPAddr:
+		info["OSC_IP"], info["OSC_PORT"], info["OSC_TRANSPORT"] = addr.IP.String(), addr.Port, "UDP"
+	case *net.TCPAddr:
+		info["OSC_IP"], info["OSC_PORT"], info["OSC_TRANSPORT"] = addr.IP.String(), addr.Port, "TCP"
+	}
+	return info
+}
+
+// serveWebSocket reads the commands and the OSC packets of a WebSocket client
+// until it goes away.
+func (s *Server) serveWebSocket(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	checkOrigin := s.checkOrigin
+	s.mu.Unlock()
+
+	conn, err := websocket.Upgrade(w, r, checkOrigin)
+	if err != nil {
+		return
+	}
+	c := &wsClient{conn: conn, listen: map[string]struct{}{}}
+
+	s.mu.Lock()
+	s.clients[c] = struct{}{}
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.clients, c)
+		s.mu.Unlock()
+		_ = conn.Close()
+	}()
+	for {
+		typ, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		switch typ {
+		case websocket.TextMessage:
+			s.handleCommand(c, data)
+		case websocket.BinaryMessage:
+			s.handlePacket(data)
+		}
+	}
+}
+
+// handleCommand handles a LISTEN or an IGNORE command. Other commands are ignored.
+func (s *Server) handleCommand(c *wsClient, data []byte) {
+	var cmd struct {
+		Command string `json:"COMMAND"`
+		Data    string `json:"DATA"`
+	}
+	if err := json.Unmarshal(data, &cmd); err != nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch cmd.Command {
+	case commandListen:
+		c.listen[cmd.Data] = struct{}{}
+	case commandIgnore:
+		delete(c.listen, cmd.Data)
+	}
+}
+
+// handlePacket dispatches an OSC packet that a WebSocket client sent.
+func (s *Server) handlePacket(data []byte) {
+	p, err := osc.ParsePacket(data, nil)
+	if err == nil {
+		switch x := p.(type) {
+		case osc.Message:
+			err = s.Invoke(x, false)
+		case osc.Bundle:
+			err = s.Dispatch(x, false)
+		}
+	}
+	if err == nil {
+		return
+	}
+	s.mu.Lock()
+	handler := s.errorHandler
+	s.mu.Unlock()
+
+	if handler != nil {
+		handler(err)
+	}
+}
+
+// sendValue sends the value of a method to the clients that listen to it.
+func (s *Server) sendValue(address string, args osc.Arguments) {
+	var conns []*websocket.Conn
+
+	s.mu.Lock()
+	for c := range s.clients {
+		if _, ok := c.listen[address]; ok {
+			conns = append(conns, c.conn)
+		}
+	}
+	s.mu.Unlock()
+
+	if len(conns) == 0 {
+		return
+	}
+	data := osc.Message{Address: address, Arguments: args}.Bytes()
+	for _, conn := range conns {
+		if err := conn.WriteMessage(websocket.BinaryMessage, data); err != nil {
+			_ = conn.Close()
+		}
+	}
+}
+
+// broadcastCommand sends a command to every WebSocket client.
+func (s *Server) broadcastCommand(command, path string) {
+	data, _ := json.Marshal(map[string]string{"COMMAND": command, "DATA": path})
+
+	s.mu.Lock()
+	conns := make([]*websocket.Conn, 0, len(s.clients))
+	for c := range s.clients {
+		conns = append(conns, c.conn)
+	}
+	s.mu.Unlock()
+
+	for _, conn := range conns {
+		if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
+			_ = conn.Close()
+		}
+	}
+}
+
+// lookup returns the node at a path, or nil. The caller must hold s.mu.
+func (s *Server) lookup(path string) *node {
+	n := s.root
+	for _, part := range strings.Split(strings.Trim(path, "/"), "/") {
+		if part == "" {
+			continue
+		}
+		if n = n.children[part]; n == nil {
+			return nil
+		}
+	}
+	return n
+}
+
+// walk calls fn for the node and everything in it, in the order of their paths.
+func (n *node) walk(fn func(*node) error) error {
+	if err := fn(n); err != nil {
+		return err
+	}
+	names := make([]string, 0, len(n.children))
+	for name := range n.children {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if err := n.children[name].walk(fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// json returns the attributes of the node and everything in it.
+func (n *node) json() map[string]interface{} {
+	attrs := map[string]interface{}{attrFullPath: n.path}
+
+	if len(n.children) > 0 {
+		contents := make(map[string]interface{}, len(n.children))
+		for name, child := range n.children {
+			contents[name] = child.json()
+		}
+		attrs[attrContents] = contents
+	}
+	m := n.method
+	if m == nil {
+		return attrs
+	}
+	attrs[attrAccess] = m.Access
+
+	if tt := m.typetags(); tt != "" {
+		attrs[attrType] = tt
+	}
+	if m.Access.Readable() && len(m.Value) > 0 {
+		attrs[attrValue] = jsonValues(m.Value)
+	}
+	if len(m.Range) > 0 {
+		ranges := make([]map[string]interface{}, len(m.Range))
+		for i, r := range m.Range {
+			ranges[i] = jsonRange(r)
+		}
+		attrs[attrRange] = ranges
+	}
+	if m.Description != "" {
+		attrs[attrDescription] = m.Description
+	}
+	return attrs
+}
+
+// validAttribute returns true for the attributes that can be queried.
+func validAttribute(attr string) bool {
+	switch attr {
+	case attrFullPath, attrContents, attrType, attrValue, attrRange, attrAccess, attrDescription:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,391 @@
+package oscquery
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/scgolang/osc"
+	"github.com/scgolang/osc/internal/websocket"
+)
+
+// testServer returns a server with a synth and a read only meter.
+// Messages that are delivered to the freq method are sent to received.
+// The caller closes it.
+func testServer(t *testing.T, received chan osc.Message) *Server {
+	s := NewServer("test synth")
+
+	for address, m := range map[string]Method{
+		"/synth/freq": {
+			Handler: osc.Method(func(msg osc.Message) error {
+				received <- msg
+				return nil
+			}),
+			Type:        "f",
+			Value:       osc.Arguments{osc.Float(440)},
+			Range:       []Range{{Min: osc.Float(20), Max: osc.Float(20000)}},
+			Access:      ReadWrite,
+			Description: "Frequency in Hz",
+		},
+		"/synth/wave": {
+			Value:  osc.Arguments{osc.String("sine")},
+			Range:  []Range{{Vals: osc.Arguments{osc.String("sine"), osc.String("saw")}}},
+			Access: ReadWrite,
+		},
+		"/meter": {
+			Type:   "f",
+			Value:  osc.Arguments{osc.Float(0.5)},
+			Access: ReadOnly,
+		},
+	} {
+		if err := s.Handle(address, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return s
+}
+
+// get returns the status and the body of a GET request.
+func get(t *testing.T, h http.Handler, target string) (int, string) {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+	return rec.Code, strings.TrimSpace(rec.Body.String())
+}
+
+func TestServerJSON(t *testing.T) {
+	s := testServer(t, nil)
+	defer func() { _ = s.Close() }()
+
+	for i, testcase := range []struct {
+		Target string
+		Status int
+		Body   string
+	}{
+		{
+			Target: "/",
+			Status: http.StatusOK,
+			Body: `{"CONTENTS":{` +
+				`"meter":{"ACCESS":1,"FULL_PATH":"/meter","TYPE":"f","VALUE":[0.5]},` +
+				`"synth":{"CONTENTS":{` +
+				`"freq":{"ACCESS":3,"DESCRIPTION":"Frequency in Hz","FULL_PATH":"/synth/freq","RANGE":[{"MAX":20000,"MIN":20}],"TYPE":"f","VALUE":[440]},` +
+				`"wave":{"ACCESS":3,"FULL_PATH":"/synth/wave","RANGE":[{"VALS":["sine","saw"]}],"TYPE":"s","VALUE":["sine"]}` +
+				`},"FULL_PATH":"/synth"}` +
+				`},"FULL_PATH":"/"}`,
+		},
+		{
+			Target: "/synth/freq?VALUE",
+			Status: http.StatusOK,
+			Body:   `{"VALUE":[440]}`,
+		},
+		{
+			Target: "/synth/freq/?RANGE",
+			Status: http.StatusOK,
+			Body:   `{"RANGE":[{"MAX":20000,"MIN":20}]}`,
+		},
+		{
+			Target: "/meter?DESCRIPTION",
+			Status: http.StatusNoContent,
+		},
+		{
+			Target: "/meter?COLOR",
+			Status: http.StatusBadRequest,
+			Body:   "unknown attribute COLOR",
+		},
+		{
+			Target: "/synth/gain",
+			Status: http.StatusNotFound,
+			Body:   "no such path /synth/gain",
+		},
+	} {
+		status, body := get(t, s, testcase.Target)
+		if expected, got := testcase.Status, status; expected != got {
+			t.Fatalf("(testcase %d) expected status %d, got %d", i, expected, got)
+		}
+		if expected, got := testcase.Body, body; expected != got {
+			t.Fatalf("(testcase %d) expected\n%s\ngot\n%s", i, expected, got)
+		}
+	}
+}
+
+func TestServerHostInfo(t *testing.T) {
+	s := testServer(t, nil)
+	defer func() { _ = s.Close() }()
+
+	if err := s.SetOSCAddr(&net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 9000}); err != nil {
+		t.Fatal(err)
+	}
+	status, body := get(t, s, "/?HOST_INFO")
+	if expected, got := http.StatusOK, status; expected != got {
+		t.Fatalf("expected status %d, got %d", expected, got)
+	}
+	for _, expected := range []string{`"NAME":"test synth"`, `"OSC_IP":"127.0.0.1"`, `"OSC_PORT":9000`, `"OSC_TRANSPORT":"UDP"`, `"LISTEN":true`} {
+		if !strings.Contains(body, expected) {
+			t.Fatalf("expected %s in %s", expected, body)
+		}
+	}
+	if err := s.SetOSCAddr(&net.UnixAddr{Name: "/tmp/osc.sock", Net: "unixgram"}); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+}
+
+func TestServerHandleErrors(t *testing.T) {
+	s := testServer(t, nil)
+	defer func() { _ = s.Close() }()
+
+	for i, testcase := range []struct {
+		Address string
+		Err     string
+	}{
+		{Address: "/synth/freq", Err: "/synth/freq: method already exists"},
+		{Address: "/synth/*", Err: "/synth/*: invalid OSC address"},
+		{Address: "synth", Err: "synth: invalid OSC address"},
+		{Address: "/synth//freq", Err: "/synth//freq: invalid OSC address"},
+	} {
+		err := s.Handle(testcase.Address, Method{})
+		if err == nil {
+			t.Fatalf("(testcase %d) expected error, got nil", i)
+		}
+		if expected, got := testcase.Err, err.Error(); expected != got {
+			t.Fatalf("(testcase %d) expected %s, got %s", i, expected, got)
+		}
+	}
+}
+
+func TestServerInvoke(t *testing.T) {
+	var (
+		received = make(chan osc.Message, 1)
+		s        = testServer(t, received)
+	)
+	defer func() { _ = s.Close() }()
+
+	if err := s.Invoke(osc.Message{Address: "/synth/fr?q", Arguments: osc.Arguments{osc.Float(880)}}, false); err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := "/synth/fr?q ,f 880.0", osc.Format(<-received); expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+	value, err := s.Value("/synth/freq")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := "880.0", osc.FormatArgument(value[0]); expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+	// Arguments of the wrong type are delivered, but do not change the value.
+	if err := s.Invoke(osc.Message{Address: "/synth/freq", Arguments: osc.Arguments{osc.Int(1)}}, false); err != nil {
+		t.Fatal(err)
+	}
+	<-received
+	if value, _ := s.Value("/synth/freq"); osc.FormatArgument(value[0]) != "880.0" {
+		t.Fatalf("expected 880.0, got %s", value)
+	}
+	// Read only methods do not receive messages.
+	if err := s.Invoke(osc.Message{Address: "/meter", Arguments: osc.Arguments{osc.Float(1)}}, false); err != nil {
+		t.Fatal(err)
+	}
+	if value, _ := s.Value("/meter"); osc.FormatArgument(value[0]) != "0.5" {
+		t.Fatalf("expected 0.5, got %s", value)
+	}
+	if _, err := s.Value("/synth/gain"); errors.Cause(err) != ErrNotFound {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestServerInvokeBool(t *testing.T) {
+	s := NewServer("test synth")
+	defer func() { _ = s.Close() }()
+
+	for address, m := range map[string]Method{
+		"/mute": {Type: "T", Value: osc.Arguments{osc.Bool(true)}, Access: ReadWrite},
+		"/loop": {Value: osc.Arguments{osc.Bool(false)}, Access: ReadWrite},
+	} {
+		if err := s.Handle(address, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Bools can be toggled, whether the method has a type or not.
+	for i, testcase := range []struct {
+		Address string
+		Value   bool
+	}{
+		{Address: "/mute", Value: false},
+		{Address: "/mute", Value: true},
+		{Address: "/loop", Value: true},
+		{Address: "/loop", Value: false},
+	} {
+		if err := s.Invoke(osc.Message{Address: testcase.Address, Arguments: osc.Arguments{osc.Bool(testcase.Value)}}, false); err != nil {
+			t.Fatalf("(testcase %d) %s", i, err)
+		}
+		value, err := s.Value(testcase.Address)
+		if err != nil {
+			t.Fatalf("(testcase %d) %s", i, err)
+		}
+		if expected, got := osc.Bool(testcase.Value), value[0]; !expected.Equal(got) {
+			t.Fatalf("(testcase %d) expected %s, got %s", i, expected, got)
+		}
+		// The type does not change with the value.
+		if expected, got := `{"TYPE":"T"}`, body(t, s, testcase.Address+"?TYPE"); expected != got {
+			t.Fatalf("(testcase %d) expected %s, got %s", i, expected, got)
+		}
+	}
+}
+
+func TestServerRemove(t *testing.T) {
+	s := testServer(t, nil)
+	defer func() { _ = s.Close() }()
+
+	if err := s.Remove("/synth/freq"); err != nil {
+		t.Fatal(err)
+	}
+	if status, _ := get(t, s, "/synth/freq"); status != http.StatusNotFound {
+		t.Fatalf("expected status %d, got %d", http.StatusNotFound, status)
+	}
+	if err := s.Remove("/synth/wave"); err != nil {
+		t.Fatal(err)
+	}
+	// The empty container is removed too.
+	if expected, got := `{"CONTENTS":{"meter":{"ACCESS":1,"FULL_PATH":"/meter","TYPE":"f","VALUE":[0.5]}},"FULL_PATH":"/"}`, body(t, s, "/"); expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+	if err := s.Remove("/synth"); errors.Cause(err) != ErrNotFound {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+// body returns the body of a GET request.
+func body(t *testing.T, h http.Handler, target string) string {
+	_, body := get(t, h, target)
+	return body
+}
+
+func TestServerWebSocketOrigin(t *testing.T) {
+	s := NewServer("test synth")
+	defer func() { _ = s.Close() }()
+
+	upgrade := func() int {
+		req := httptest.NewRequest(http.MethodGet, "http://synth.local/", nil)
+		req.Header = http.Header{
+			"Connection":            {"Upgrade"},
+			"Upgrade":               {"websocket"},
+			"Sec-Websocket-Version": {"13"},
+			"Sec-Websocket-Key":     {"dGhlIHNhbXBsZSBub25jZQ=="},
+			"Origin":                {"https://example.com"},
+		}
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	if expected, got := http.StatusForbidden, upgrade(); expected != got {
+		t.Fatalf("expected %d, got %d", expected, got)
+	}
+	s.SetCheckOrigin(func(r *http.Request) bool { return true })
+
+	// The recorder can't be hijacked, so the upgrade fails after the origin check.
+	if expected, got := http.StatusInternalServerError, upgrade(); expected != got {
+		t.Fatalf("expected %d, got %d", expected, got)
+	}
+}
+
+func TestServerWebSocket(t *testing.T) {
+	var (
+		received = make(chan osc.Message, 1)
+		s        = testServer(t, received)
+		srv      = httptest.NewServer(s)
+	)
+	defer func() { _ = s.Close() }()
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	ws, err := websocket.Dial(ctx, "ws"+strings.TrimPrefix(srv.URL, "http"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = ws.Close() }()
+
+	if err := ws.WriteMessage(websocket.TextMessage, []byte(`{"COMMAND":"LISTEN","DATA":"/synth/freq"}`)); err != nil {
+		t.Fatal(err)
+	}
+	waitListening(t, s, "/synth/freq", true)
+
+	// Messages from the client are dispatched, and the new value is streamed back.
+	if err := ws.WriteMessage(websocket.BinaryMessage, osc.Message{Address: "/synth/freq", Arguments: osc.Arguments{osc.Float(220)}}.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	<-received
+	expectMessage(t, ws, websocket.BinaryMessage, "/synth/freq ,f 220.0")
+
+	if err := s.SetValue("/synth/wave", osc.String("saw")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetValue("/synth/freq", osc.Float(110)); err != nil {
+		t.Fatal(err)
+	}
+	expectMessage(t, ws, websocket.BinaryMessage, "/synth/freq ,f 110.0")
+
+	if err := s.Handle("/synth/gain", Method{Type: "f", Access: ReadWrite}); err != nil {
+		t.Fatal(err)
+	}
+	expectMessage(t, ws, websocket.TextMessage, `{"COMMAND":"PATH_ADDED","DATA":"/synth/gain"}`)
+
+	if err := ws.WriteMessage(websocket.TextMessage, []byte(`{"COMMAND":"IGNORE","DATA":"/synth/freq"}`)); err != nil {
+		t.Fatal(err)
+	}
+	waitListening(t, s, "/synth/freq", false)
+
+	if err := s.SetValue("/synth/freq", osc.Float(55)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Remove("/synth/gain"); err != nil {
+		t.Fatal(err)
+	}
+	expectMessage(t, ws, websocket.TextMessage, `{"COMMAND":"PATH_REMOVED","DATA":"/synth/gain"}`)
+}
+
+// waitListening waits until a WebSocket client of the server listens to the path, or ignores it.
+func waitListening(t *testing.T, s *Server, path string, listening bool) {
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		s.mu.Lock()
+		found := false
+		for c := range s.clients {
+			if _, ok := c.listen[path]; ok {
+				found = true
+			}
+		}
+		s.mu.Unlock()
+
+		if found == listening {
+			return
+		}
+	}
+	t.Fatalf("timeout waiting for a client to listen to %s: %t", path, listening)
+}
+
+// expectMessage reads the next WebSocket message and compares it to expected,
+// the text of an OSC packet for binary messages.
+func expectMessage(t *testing.T, ws *websocket.Conn, typ websocket.MessageType, expected string) {
+	_ = ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	gotType, data, err := ws.ReadMessage()
+	if err != nil && err != io.EOF {
+		t.Fatal(err)
+	}
+	got := string(data)
+	if gotType == websocket.BinaryMessage {
+		p, err := osc.ParsePacket(data, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = osc.Format(p)
+	}
+	if typ != gotType || expected != got {
+		t.Fatalf("expected %d message %s, got %d message %s", typ, expected, gotType, got)
+	}
+}
@@ -0,0 +1,223 @@
+// Package pcap reads and writes packet captures of OSC traffic
+// in the pcap and pcapng formats used by tcpdump and Wireshark.
+//
+// Only UDP datagrams over IPv4 and IPv6 are extracted, all other traffic
+// in a capture is skipped. No libpcap is needed.
+package pcap
+
+import (
+	"encoding/binary"
+	"net"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/scgolang/osc"
+)
+
+// Format is a capture file format.
+type Format int
+
+// Capture file formats.
+const (
+	// PCAP is the classic libpcap format.
+	PCAP Format = iota
+
+	// PCAPNG is the pcap next generation format.
+	PCAPNG
+)
+
+// String returns the name of the format.
+func (f Format) String() string {
+	switch f {
+	case PCAP:
+		return "pcap"
+	case PCAPNG:
+		return "pcapng"
+	default:
+		return "unknown format"
+	}
+}
+
+// Link types, see https://www.tcpdump.org/linktypes.html
+const (
+	linkTypeNull     = 0
+	linkTypeEthernet = 1
+	linkTypeRaw      = 101
+	linkTypeLoop     = 108
+	linkTypeLinuxSLL = 113
+	linkTypeIPv4     = 228
+	linkTypeIPv6     = 229
+	linkTypeSLL2     = 276
+)
+
+// Protocol numbers.
+const (
+	etherTypeIPv4 = 0x0800
+	etherTypeIPv6 = 0x86DD
+	etherTypeVLAN = 0x8100
+	etherTypeQinQ = 0x88A8
+	protocolUDP   = 17
+)
+
+// snapLen is the maximum number of bytes captured per packet in written captures.
+const snapLen = 262144
+
+// Common errors.
+var (
+	ErrInvalidCapture = errors.New("invalid capture")
+)
+
+// Datagram is a UDP datagram in a capture.
+type Datagram struct {
+	// Time is when the datagram was captured.
+	Time time.Time
+
+	// Src is the address of the sender.
+	Src *net.UDPAddr
+
+	// Dst is the address of the receiver.
+	Dst *net.UDPAddr
+
+	// Payload is the data of the datagram.
+	Payload []byte
+}
+
+// Record parses the payload of the datagram as an OSC packet.
+// The record's Sender is the source address of the datagram.
+func (d Datagram) Record() (osc.Record, error) {
+	p, err := osc.ParsePacket(d.Payload, d.Src)
+	if err != nil {
+		return osc.Record{}, err
+	}
+	return osc.Record{Time: d.Time, Sender: d.Src, Packet: p}, nil
+}
+
+// decodeLink returns the UDP datagram in a captured frame,
+// and false if the frame doesn't contain a complete UDP datagram.
+func decodeLink(linkType uint32, frame []byte) (Datagram, bool) {
+	switch linkType {
+	case linkTypeEthernet:
+		if len(frame) < 14 {
+			return Datagram{}, false
+		}
+		etherType, data := binary.BigEndian.Uint16(frame[12:]), frame[14:]
+		for etherType == etherTypeVLAN || etherType == etherTypeQinQ {
+			if len(data) < 4 {
+				return Datagram{}, false
+			}
+			etherType, data = binary.BigEndian.Uint16(data[2:]), data[4:]
+		}
+		if etherType != etherTypeIPv4 && etherType != etherTypeIPv6 {
+			return Datagram{}, false
+		}
+		return decodeIP(data)
+	case linkTypeNull, linkTypeLoop:
+		// The address family is in host byte order, so just look at the IP version.
+		if len(frame) < 4 {
+			return Datagram{}, false
+		}
+		return decodeIP(frame[4:])
+	case linkTypeLinuxSLL:
+		if len(frame) < 16 {
+			return Datagram{}, false
+		}
+		return decodeIP(frame[16:])
+	case linkTypeSLL2:
+		if len(frame) < 20 {
+			return Datagram{}, false
+		}
+		return decodeIP(frame[20:])
+	case linkTypeRaw, linkTypeIPv4, linkTypeIPv6:
+		return decodeIP(frame)
+	default:
+		return Datagram{}, false
+	}
+}
+
+// decodeIP returns the UDP datagram in an IPv4 or IPv6 packet.
+func decodeIP(data []byte) (Datagram, bool) {
+	if len(data) == 0 {
+		return Datagram{}, false
+	}
+	switch data[0] >> 4 {
+	case 4:
+		return decodeIPv4(data)
+	case 6:
+		return decodeIPv6(data)
+	default:
+		return Datagram{}, false
+	}
+}
+
+// decodeIPv4 returns the UDP datagram in an IPv4 packet.
+// Fragmented datagrams are not reassembled.
+func decodeIPv4(data []byte) (Datagram, bool) {
+	if len(data) < 20 {
+		return Datagram{}, false
+	}
+	var (
+		headerLen = int(data[0]&0x0F) * 4
+		totalLen  = int(binary.BigEndian.Uint16(data[2:]))
+		fragment  = binary.BigEndian.Uint16(data[6:])
+	)
+	if headerLen < 20 || totalLen < headerLen || totalLen > len(data) {
+		return Datagram{}, false
+	}
+	// More fragments flag or a fragment offset.
+	if fragment&0x3FFF != 0 || data[9] != protocolUDP {
+		return Datagram{}, false
+	}
+	src, dst := net.IP(data[12:16]), net.IP(data[16:20])
+	return decodeUDP(data[headerLen:totalLen], src, dst)
+}
+
+// decodeIPv6 returns the UDP datagram in an IPv6 packet.
+// Fragmented datagrams are not reassembled.
+func decodeIPv6(data []byte) (Datagram, bool) {
+	if len(data) < 40 {
+		return Datagram{}, false
+	}
+	var (
+		payloadLen = int(binary.BigEndian.Uint16(data[4:]))
+		next       = data[6]
+		src, dst   = net.IP(data[8:24]), net.IP(data[24:40])
+	)
+	if 40+payloadLen > len(data) {
+		return Datagram{}, false
+	}
+	payload := data[40 : 40+payloadLen]
+
+	for {
+		switch next {
+		case protocolUDP:
+			return decodeUDP(payload, src, dst)
+		case 0, 43, 60: // Hop-by-hop, routing and destination options.
+			if len(payload) < 8 {
+				return Datagram{}, false
+			}
+			l := (int(payload[1]) + 1) * 8
+			if l > len(payload) {
+				return Datagram{}, false
+			}
+			next, payload = payload[0], payload[l:]
+		default:
+			return Datagram{}, false
+		}
+	}
+}
+
+// decodeUDP returns the datagram in a UDP packet.
+func decodeUDP(data []byte, src, dst net.IP) (Datagram, bool) {
+	if len(data) < 8 {
+		return Datagram{}, false
+	}
+	l := int(binary.BigEndian.Uint16(data[4:]))
+	if l < 8 || l > len(data) {
+		return Datagram{}, false
+	}
+	return Datagram{
+		Src:     &net.UDPAddr{IP: append(net.IP{}, src...), Port: int(binary.BigEndian.Uint16(data))},
+		Dst:     &net.UDPAddr{IP: append(net.IP{}, dst...), Port: int(binary.BigEndian.Uint16(data[2:]))},
+		Payload: append([]byte{}, data[8:l]...),
+	}, true
+}
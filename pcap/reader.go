@@ -0,0 +1,340 @@
+package pcap
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"math"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/scgolang/osc"
+)
+
+// Magic numbers.
+const (
+	magicMicroseconds = 0xA1B2C3D4
+	magicNanoseconds  = 0xA1B23C4D
+	magicByteOrder    = 0x1A2B3C4D
+)
+
+// pcapng block types.
+const (
+	blockSectionHeader     = 0x0A0D0D0A
+	blockInterface         = 0x00000001
+	blockSimplePacket      = 0x00000003
+	blockEnhancedPacket    = 0x00000006
+	optionEndOfOptions     = 0
+	optionTimestampResolve = 9
+)
+
+// maxBlockSize limits the memory used for a single frame or block.
+const maxBlockSize = 16 << 20
+
+// iface is a capture interface of a pcapng section.
+type iface struct {
+	linkType uint32
+	snapLen  uint32
+	units    uint64 // Number of timestamp units per second.
+}
+
+// Reader reads UDP datagrams from a pcap or pcapng capture.
+type Reader struct {
+	r      *bufio.Reader
+	format Format
+	order  binary.ByteOrder
+
+	// pcap
+	linkType uint32
+	nanos    bool
+
+	// pcapng
+	ifaces []iface
+}
+
+// NewReader returns a reader for the capture in r.
+// The format is detected from the first bytes of the capture.
+func NewReader(r io.Reader) (*Reader, error) {
+	rd := &Reader{r: bufio.NewReader(r)}
+
+	magic, err := rd.r.Peek(4)
+	if err != nil {
+		return nil, errors.Wrap(ErrInvalidCapture, "missing header")
+	}
+	if binary.BigEndian.Uint32(magic) == blockSectionHeader {
+		rd.format = PCAPNG
+		return rd, nil
+	}
+	var header [24]byte
+	if _, err := io.ReadFull(rd.r, header[:]); err != nil {
+		return nil, errors.Wrap(ErrInvalidCapture, "missing header")
+	}
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		switch order.Uint32(header[:]) {
+		case magicMicroseconds:
+			rd.order = order
+		case magicNanoseconds:
+			rd.order, rd.nanos = order, true
+		default:
+			continue
+		}
+		// The upper bits of the link type can hold FCS information.
+		rd.linkType = order.Uint32(header[20:]) & 0xFFFF
+		return rd, nil
+	}
+	return nil, errors.Wrap(ErrInvalidCapture, "not a pcap or pcapng capture")
+}
+
+// Format returns the format of the capture.
+func (rd *Reader) Format() Format {
+	return rd.format
+}
+
+// ReadDatagram reads the next UDP datagram.
+// Frames that don't contain a complete UDP datagram, like other protocols,
+// IP fragments and truncated frames, are skipped.
+// It returns io.EOF at the end of the capture.
+func (rd *Reader) ReadDatagram() (Datagram, error) {
+	for {
+		var (
+			linkType uint32
+			t        time.Time
+			frame    []byte
+			err      error
+		)
+		if rd.format == PCAPNG {
+			linkType, t, frame, err = rd.nextBlockFrame()
+		} else {
+			linkType, t, frame, err = rd.nextRecordFrame()
+		}
+		if err != nil {
+			return Datagram{}, err
+		}
+		d, ok := decodeLink(linkType, frame)
+		if !ok {
+			continue
+		}
+		d.Time = t
+		return d, nil
+	}
+}
+
+// ReadRecord reads the next UDP datagram and parses its payload as an OSC packet.
+// The record's Sender is the source address of the datagram.
+// Datagrams that aren't OSC packets result in a *ParseError,
+// after which reading can continue.
+func (rd *Reader) ReadRecord() (osc.Record, error) {
+	d, err := rd.ReadDatagram()
+	if err != nil {
+		return osc.Record{}, err
+	}
+	r, err := d.Record()
+	if err != nil {
+		return osc.Record{}, &ParseError{Datagram: d, Err: err}
+	}
+	return r, nil
+}
+
+// ParseError is returned by ReadRecord for datagrams that are not OSC packets.
+type ParseError struct {
+	Datagram Datagram
+	Err      error
+}
+
+// Cause returns the underlying error.
+func (e *ParseError) Cause() error {
+	return e.Err
+}
+
+// Error returns a description of the error.
+func (e *ParseError) Error() string {
+	return "parse datagram from " + e.Datagram.Src.String() + ": " + e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// nextRecordFrame reads the next frame of a pcap capture.
+func (rd *Reader) nextRecordFrame() (uint32, time.Time, []byte, error) {
+	var header [16]byte
+
+	if _, err := io.ReadFull(rd.r, header[:]); err != nil {
+		return 0, time.Time{}, nil, err
+	}
+	var (
+		sec      = int64(rd.order.Uint32(header[0:]))
+		frac     = int64(rd.order.Uint32(header[4:]))
+		capLen   = rd.order.Uint32(header[8:])
+		nanosecs = frac * 1000
+	)
+	if rd.nanos {
+		nanosecs = frac
+	}
+	if capLen > maxBlockSize {
+		return 0, time.Time{}, nil, errors.Wrapf(ErrInvalidCapture, "frame of %d bytes", capLen)
+	}
+	frame := make([]byte, capLen)
+	if _, err := io.ReadFull(rd.r, frame); err != nil {
+		return 0, time.Time{}, nil, unexpectedEOF(err)
+	}
+	return rd.linkType, time.Unix(sec, nanosecs).UTC(), frame, nil
+}
+
+// nextBlockFrame reads blocks of a pcapng capture until it finds a packet block.
+func (rd *Reader) nextBlockFrame() (uint32, time.Time, []byte, error) {
+	for {
+		blockType, body, err := rd.readBlock()
+		if err != nil {
+			return 0, time.Time{}, nil, err
+		}
+		switch blockType {
+		case blockSectionHeader:
+			rd.ifaces = rd.ifaces[:0]
+		case blockInterface:
+			if len(body) < 8 {
+				return 0, time.Time{}, nil, errors.Wrap(ErrInvalidCapture, "short interface description block")
+			}
+			ifc := iface{
+				linkType: uint32(rd.order.Uint16(body)),
+				snapLen:  rd.order.Uint32(body[4:]),
+				units:    1e6,
+			}
+			rd.readOptions(body[8:], func(code uint16, value []byte) {
+				if code == optionTimestampResolve && len(value) == 1 {
+					ifc.units = timestampUnits(value[0])
+				}
+			})
+			rd.ifaces = append(rd.ifaces, ifc)
+		case blockEnhancedPacket:
+			if len(body) < 20 {
+				return 0, time.Time{}, nil, errors.Wrap(ErrInvalidCapture, "short enhanced packet block")
+			}
+			id := rd.order.Uint32(body)
+			if int(id) >= len(rd.ifaces) {
+				return 0, time.Time{}, nil, errors.Wrapf(ErrInvalidCapture, "unknown interface %d", id)
+			}
+			var (
+				ifc    = rd.ifaces[id]
+				ts     = uint64(rd.order.Uint32(body[4:]))<<32 | uint64(rd.order.Uint32(body[8:]))
+				capLen = rd.order.Uint32(body[12:])
+			)
+			if uint64(capLen) > uint64(len(body)-20) {
+				return 0, time.Time{}, nil, errors.Wrap(ErrInvalidCapture, "enhanced packet block is shorter than its packet")
+			}
+			return ifc.linkType, timestamp(ts, ifc.units), body[20 : 20+capLen], nil
+		case blockSimplePacket:
+			if len(body) < 4 || len(rd.ifaces) == 0 {
+				return 0, time.Time{}, nil, errors.Wrap(ErrInvalidCapture, "invalid simple packet block")
+			}
+			var (
+				ifc    = rd.ifaces[0]
+				capLen = uint64(rd.order.Uint32(body))
+			)
+			if ifc.snapLen > 0 && capLen > uint64(ifc.snapLen) {
+				capLen = uint64(ifc.snapLen)
+			}
+			if capLen > uint64(len(body)-4) {
+				return 0, time.Time{}, nil, errors.Wrap(ErrInvalidCapture, "simple packet block is shorter than its packet")
+			}
+			return ifc.linkType, time.Time{}, body[4 : 4+capLen], nil
+		}
+	}
+}
+
+// readBlock reads a pcapng block and returns its type and body.
+// The byte order of a section is determined by its section header block.
+func (rd *Reader) readBlock() (uint32, []byte, error) {
+	var header [8]byte
+
+	if _, err := io.ReadFull(rd.r, header[:]); err != nil {
+		return 0, nil, err
+	}
+	if binary.BigEndian.Uint32(header[:]) == blockSectionHeader {
+		magic, err := rd.r.Peek(4)
+		if err != nil {
+			return 0, nil, unexpectedEOF(err)
+		}
+		switch {
+		case binary.BigEndian.Uint32(magic) == magicByteOrder:
+			rd.order = binary.BigEndian
+		case binary.LittleEndian.Uint32(magic) == magicByteOrder:
+			rd.order = binary.LittleEndian
+		default:
+			return 0, nil, errors.Wrap(ErrInvalidCapture, "invalid byte-order magic")
+		}
+	}
+	if rd.order == nil {
+		return 0, nil, errors.Wrap(ErrInvalidCapture, "missing section header block")
+	}
+	var (
+		blockType = rd.order.Uint32(header[:])
+		totalLen  = rd.order.Uint32(header[4:])
+	)
+	if totalLen < 12 || totalLen%4 != 0 || totalLen > maxBlockSize {
+		return 0, nil, errors.Wrapf(ErrInvalidCapture, "block of %d bytes", totalLen)
+	}
+	// The body is followed by another copy of the total length.
+	body := make([]byte, totalLen-8)
+	if _, err := io.ReadFull(rd.r, body); err != nil {
+		return 0, nil, unexpectedEOF(err)
+	}
+	return blockType, body[:len(body)-4], nil
+}
+
+// readOptions calls fn with the code and value of every option in data.
+func (rd *Reader) readOptions(data []byte, fn func(code uint16, value []byte)) {
+	for len(data) >= 4 {
+		var (
+			code = rd.order.Uint16(data)
+			l    = int(rd.order.Uint16(data[2:]))
+		)
+		if code == optionEndOfOptions || 4+l > len(data) {
+			return
+		}
+		fn(code, data[4:4+l])
+
+		data = data[4+l:]
+		if pad := (4 - l%4) % 4; pad <= len(data) {
+			data = data[pad:]
+		}
+	}
+}
+
+// timestampUnits returns the number of timestamp units per second
+// for the value of an if_tsresol option.
+func timestampUnits(resolution byte) uint64 {
+	var (
+		base     uint64 = 10
+		exponent        = resolution
+	)
+	if resolution&0x80 != 0 {
+		base, exponent = 2, resolution&0x7F
+	}
+	units := uint64(1)
+	for i := byte(0); i < exponent; i++ {
+		if units > math.MaxUint64/base {
+			return math.MaxUint64
+		}
+		units *= base
+	}
+	return units
+}
+
+// timestamp converts a pcapng timestamp to a time.
+func timestamp(ts, units uint64) time.Time {
+	var (
+		sec  = ts / units
+		frac = ts % units
+	)
+	return time.Unix(int64(sec), int64(float64(frac)*1e9/float64(units))).UTC()
+}
+
+// unexpectedEOF turns io.EOF into io.ErrUnexpectedEOF.
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,265 @@
+package pcap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/scgolang/osc"
+)
+
+var (
+	src = &net.UDPAddr{IP: net.IPv4(192, 168, 1, 10), Port: 52311}
+	dst = &net.UDPAddr{IP: net.IPv4(192, 168, 1, 20), Port: 57110}
+)
+
+// byteOrder is implemented by binary.LittleEndian and binary.BigEndian.
+type byteOrder interface {
+	binary.ByteOrder
+	binary.AppendByteOrder
+}
+
+// ethernetFrame returns an Ethernet frame with the given ether types
+// followed by payload. All but the last ether type are VLAN tags.
+func ethernetFrame(payload []byte, etherTypes ...uint16) []byte {
+	frame := make([]byte, 12)
+	for i, et := range etherTypes {
+		frame = binary.BigEndian.AppendUint16(frame, et)
+		if i < len(etherTypes)-1 {
+			frame = append(frame, 0, 1) // VLAN ID.
+		}
+	}
+	return append(frame, payload...)
+}
+
+// ipPacket returns the IP packet of a datagram.
+func ipPacket(t *testing.T, d Datagram) []byte {
+	packet, err := appendIP(nil, d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return packet
+}
+
+// classicCapture returns a pcap capture with microsecond timestamps in the given byte order.
+func classicCapture(order byteOrder, linkType uint32, t time.Time, frames ...[]byte) []byte {
+	buf := order.AppendUint32(nil, magicMicroseconds)
+	buf = order.AppendUint16(buf, 2)
+	buf = order.AppendUint16(buf, 4)
+	buf = order.AppendUint32(buf, 0)
+	buf = order.AppendUint32(buf, 0)
+	buf = order.AppendUint32(buf, 65535)
+	buf = order.AppendUint32(buf, linkType)
+
+	for _, frame := range frames {
+		buf = order.AppendUint32(buf, uint32(t.Unix()))
+		buf = order.AppendUint32(buf, uint32(t.Nanosecond()/1000))
+		buf = order.AppendUint32(buf, uint32(len(frame)))
+		buf = order.AppendUint32(buf, uint32(len(frame)))
+		buf = append(buf, frame...)
+	}
+	return buf
+}
+
+func TestReaderEthernet(t *testing.T) {
+	var (
+		now     = time.Date(2024, 1, 1, 12, 0, 0, 123456000, time.UTC)
+		msg     = osc.Message{Address: "/synth/freq", Arguments: osc.Arguments{osc.Float(440)}}
+		packet  = ipPacket(t, Datagram{Src: src, Dst: dst, Payload: msg.Bytes()})
+		tcp     = append([]byte{}, packet...)
+		frag    = append([]byte{}, packet...)
+		padding = make([]byte, 6)
+	)
+	tcp[9] = 6
+	frag[6] |= 0x20 // More fragments.
+
+	for _, order := range []byteOrder{binary.LittleEndian, binary.BigEndian} {
+		capture := classicCapture(order, linkTypeEthernet, now,
+			ethernetFrame(tcp, etherTypeIPv4),
+			ethernetFrame(frag, etherTypeIPv4),
+			ethernetFrame([]byte{1, 2, 3}, 0x0806),
+			ethernetFrame(append(packet, padding...), etherTypeVLAN, etherTypeIPv4),
+		)
+		rd, err := NewReader(bytes.NewReader(capture))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected, got := PCAP, rd.Format(); expected != got {
+			t.Fatalf("expected %s, got %s", expected, got)
+		}
+		r, err := rd.ReadRecord()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !msg.Equal(r.Packet) {
+			t.Fatalf("expected %s, got %s", msg, r.Packet)
+		}
+		if expected, got := src.String(), r.Sender.String(); expected != got {
+			t.Fatalf("expected %s, got %s", expected, got)
+		}
+		if expected, got := now, r.Time; !expected.Equal(got) {
+			t.Fatalf("expected %s, got %s", expected, got)
+		}
+		if _, err := rd.ReadRecord(); err != io.EOF {
+			t.Fatalf("expected io.EOF, got %+v", err)
+		}
+	}
+}
+
+func TestReaderLinkTypes(t *testing.T) {
+	var (
+		payload = osc.Message{Address: "/foo"}.Bytes()
+		packet  = ipPacket(t, Datagram{Src: src, Dst: dst, Payload: payload})
+		sll     = append(make([]byte, 14), 0x08, 0x00)
+		sll2    = append([]byte{0x08, 0x00}, make([]byte, 18)...)
+	)
+	for i, testcase := range []struct {
+		LinkType uint32
+		Frame    []byte
+	}{
+		{LinkType: linkTypeNull, Frame: append([]byte{2, 0, 0, 0}, packet...)},
+		{LinkType: linkTypeLoop, Frame: append([]byte{0, 0, 0, 2}, packet...)},
+		{LinkType: linkTypeLinuxSLL, Frame: append(sll, packet...)},
+		{LinkType: linkTypeSLL2, Frame: append(sll2, packet...)},
+		{LinkType: linkTypeRaw, Frame: packet},
+		{LinkType: linkTypeIPv4, Frame: packet},
+	} {
+		rd, err := NewReader(bytes.NewReader(classicCapture(binary.LittleEndian, testcase.LinkType, time.Unix(0, 0), testcase.Frame)))
+		if err != nil {
+			t.Fatalf("(testcase %d) %s", i, err)
+		}
+		d, err := rd.ReadDatagram()
+		if err != nil {
+			t.Fatalf("(testcase %d) %s", i, err)
+		}
+		if !bytes.Equal(payload, d.Payload) {
+			t.Fatalf("(testcase %d) expected %q, got %q", i, payload, d.Payload)
+		}
+		if expected, got := dst.String(), d.Dst.String(); expected != got {
+			t.Fatalf("(testcase %d) expected %s, got %s", i, expected, got)
+		}
+	}
+}
+
+func TestReaderPCAPNG(t *testing.T) {
+	// A big-endian capture with microsecond timestamps, a simple packet block,
+	// an unknown block and an IPv6 datagram with a hop-by-hop options header.
+	var (
+		order   byteOrder = binary.BigEndian
+		payload           = osc.Message{Address: "/foo"}.Bytes()
+		src6              = &net.UDPAddr{IP: net.ParseIP("fe80::1"), Port: 1000}
+		dst6              = &net.UDPAddr{IP: net.ParseIP("fe80::2"), Port: 2000}
+		packet            = ipPacket(t, Datagram{Src: src6, Dst: dst6, Payload: payload})
+		hbh               = []byte{protocolUDP, 0, 0, 0, 0, 0, 0, 0}
+	)
+	// Insert the options header after the IPv6 header.
+	withOptions := append(append(append([]byte{}, packet[:40]...), hbh...), packet[40:]...)
+	withOptions[6] = 0
+	binary.BigEndian.PutUint16(withOptions[4:], uint16(len(withOptions)-40))
+
+	block := func(blockType uint32, body []byte) []byte {
+		for len(body)%4 != 0 {
+			body = append(body, 0)
+		}
+		buf := order.AppendUint32(nil, blockType)
+		buf = order.AppendUint32(buf, uint32(12+len(body)))
+		buf = append(buf, body...)
+		return order.AppendUint32(buf, uint32(12+len(body)))
+	}
+	shb := order.AppendUint32(nil, magicByteOrder)
+	shb = order.AppendUint16(shb, 1)
+	shb = order.AppendUint16(shb, 0)
+	shb = order.AppendUint64(shb, ^uint64(0))
+
+	idb := order.AppendUint16(nil, linkTypeRaw)
+	idb = order.AppendUint16(idb, 0)
+	idb = order.AppendUint32(idb, 0)
+
+	epb := order.AppendUint32(nil, 0)
+	ts := uint64(1500000) // 1.5 seconds in microseconds.
+	epb = order.AppendUint32(epb, uint32(ts>>32))
+	epb = order.AppendUint32(epb, uint32(ts))
+	epb = order.AppendUint32(epb, uint32(len(withOptions)))
+	epb = order.AppendUint32(epb, uint32(len(withOptions)))
+	epb = append(epb, withOptions...)
+
+	spb := order.AppendUint32(nil, uint32(len(packet)))
+	spb = append(spb, packet...)
+
+	var capture []byte
+	capture = append(capture, block(blockSectionHeader, shb)...)
+	capture = append(capture, block(blockInterface, idb)...)
+	capture = append(capture, block(0x0BAD, []byte{1, 2, 3, 4})...)
+	capture = append(capture, block(blockEnhancedPacket, epb)...)
+	capture = append(capture, block(blockSimplePacket, spb)...)
+
+	rd, err := NewReader(bytes.NewReader(capture))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := PCAPNG, rd.Format(); expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+	for i, expected := range []time.Time{time.Unix(1, 5e8), {}} {
+		d, err := rd.ReadDatagram()
+		if err != nil {
+			t.Fatalf("(datagram %d) %s", i, err)
+		}
+		if !expected.Equal(d.Time) {
+			t.Fatalf("(datagram %d) expected %s, got %s", i, expected, d.Time)
+		}
+		if !bytes.Equal(payload, d.Payload) {
+			t.Fatalf("(datagram %d) expected %q, got %q", i, payload, d.Payload)
+		}
+		if expected, got := src6.String(), d.Src.String(); expected != got {
+			t.Fatalf("(datagram %d) expected %s, got %s", i, expected, got)
+		}
+	}
+	if _, err := rd.ReadDatagram(); err != io.EOF {
+		t.Fatalf("expected io.EOF, got %+v", err)
+	}
+}
+
+func TestReaderErrors(t *testing.T) {
+	valid := classicCapture(binary.LittleEndian, linkTypeRaw, time.Unix(0, 0), ipPacket(t, Datagram{Src: src, Dst: dst, Payload: []byte("foo")}))
+
+	for i, testcase := range []struct {
+		Input []byte
+		Err   string
+	}{
+		{
+			Input: []byte{1, 2},
+			Err:   "missing header: invalid capture",
+		},
+		{
+			Input: make([]byte, 24),
+			Err:   "not a pcap or pcapng capture: invalid capture",
+		},
+		{
+			Input: valid[:len(valid)-1],
+			Err:   io.ErrUnexpectedEOF.Error(),
+		},
+		{
+			Input: valid,
+			Err:   "parse datagram from 192.168.1.10:52311: packet should never start with f: error parsing message",
+		},
+		{
+			Input: []byte{0x0A, 0x0D, 0x0D, 0x0A, 0, 0, 0, 28, 0, 0, 0, 0},
+			Err:   "invalid byte-order magic: invalid capture",
+		},
+	} {
+		rd, err := NewReader(bytes.NewReader(testcase.Input))
+		if err == nil {
+			_, err = rd.ReadRecord()
+		}
+		if err == nil {
+			t.Fatalf("(testcase %d) expected error, got nil", i)
+		}
+		if expected, got := testcase.Err, err.Error(); expected != got {
+			t.Fatalf("(testcase %d) expected %s, got %s", i, expected, got)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package pcap
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/scgolang/osc"
+)
+
+// Writer writes UDP datagrams to a capture.
+// The datagrams are written as raw IP packets with nanosecond timestamps.
+// It is safe to call the methods of a Writer from multiple goroutines.
+type Writer struct {
+	mu     sync.Mutex
+	w      io.Writer
+	format Format
+	buf    []byte
+}
+
+// NewWriter writes the header of a capture in the given format to w
+// and returns a writer that writes the datagrams after it.
+// A capture without any datagram is still a valid capture.
+func NewWriter(w io.Writer, format Format) (*Writer, error) {
+	if format != PCAP && format != PCAPNG {
+		return nil, errors.Errorf("unsupported format %d", format)
+	}
+	wr := &Writer{w: w, format: format}
+
+	if _, err := w.Write(wr.appendHeader(nil)); err != nil {
+		return nil, errors.Wrap(err, "write capture header")
+	}
+	return wr, nil
+}
+
+// WriteDatagram writes a UDP datagram.
+// Src and Dst must both be IPv4 or both be IPv6 addresses.
+func (w *Writer) WriteDatagram(d Datagram) error {
+	if d.Src == nil || d.Dst == nil {
+		return errors.New("datagram needs a source and a destination address")
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	frame, err := appendIP(nil, d)
+	if err != nil {
+		return err
+	}
+	var (
+		buf = w.buf[:0]
+		ns  = d.Time.UnixNano()
+	)
+	switch w.format {
+	case PCAP:
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(ns/1e9))
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(ns%1e9))
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(len(frame)))
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(len(frame)))
+		buf = append(buf, frame...)
+	case PCAPNG:
+		body := make([]byte, 0, 20+len(frame)+3)
+		body = binary.LittleEndian.AppendUint32(body, 0) // Interface ID.
+		body = binary.LittleEndian.AppendUint32(body, uint32(uint64(ns)>>32))
+		body = binary.LittleEndian.AppendUint32(body, uint32(uint64(ns)))
+		body = binary.LittleEndian.AppendUint32(body, uint32(len(frame)))
+		body = binary.LittleEndian.AppendUint32(body, uint32(len(frame)))
+		body = append(body, frame...)
+		buf = appendBlock(buf, blockEnhancedPacket, body)
+	default:
+		return errors.Errorf("unsupported format %d", w.format)
+	}
+	w.buf = buf
+
+	_, err = w.w.Write(buf)
+	return err
+}
+
+// WritePacket writes the OSC packet of a record as a UDP datagram to dst.
+// The time of the datagram is r.Time and its source is r.Sender,
+// which must be a *net.UDPAddr.
+func (w *Writer) WritePacket(r osc.Record, dst *net.UDPAddr) error {
+	src, ok := r.Sender.(*net.UDPAddr)
+	if !ok {
+		return errors.Errorf("sender %v is not a UDP address", r.Sender)
+	}
+	return w.WriteDatagram(Datagram{Time: r.Time, Src: src, Dst: dst, Payload: r.Packet.Bytes()})
+}
+
+// appendHeader appends the header of the capture to buf.
+func (w *Writer) appendHeader(buf []byte) []byte {
+	switch w.format {
+	case PCAP:
+		buf = binary.LittleEndian.AppendUint32(buf, magicNanoseconds)
+		buf = binary.LittleEndian.AppendUint16(buf, 2) // Major version.
+		buf = binary.LittleEndian.AppendUint16(buf, 4) // Minor version.
+		buf = binary.LittleEndian.AppendUint32(buf, 0) // Time zone.
+		buf = binary.LittleEndian.AppendUint32(buf, 0) // Timestamp accuracy.
+		buf = binary.LittleEndian.AppendUint32(buf, snapLen)
+		buf = binary.LittleEndian.AppendUint32(buf, linkTypeRaw)
+	case PCAPNG:
+		shb := binary.LittleEndian.AppendUint32(nil, magicByteOrder)
+		shb = binary.LittleEndian.AppendUint16(shb, 1) // Major version.
+		shb = binary.LittleEndian.AppendUint16(shb, 0) // Minor version.
+		shb = binary.LittleEndian.AppendUint64(shb, ^uint64(0))
+		buf = appendBlock(buf, blockSectionHeader, shb)
+
+		idb := binary.LittleEndian.AppendUint16(nil, linkTypeRaw)
+		idb = binary.LittleEndian.AppendUint16(idb, 0) // Reserved.
+		idb = binary.LittleEndian.AppendUint32(idb, snapLen)
+		idb = binary.LittleEndian.AppendUint16(idb, optionTimestampResolve)
+		idb = binary.LittleEndian.AppendUint16(idb, 1)
+		idb = append(idb, 9, 0, 0, 0) // Nanoseconds, padded to 32 bits.
+		idb = binary.LittleEndian.AppendUint32(idb, optionEndOfOptions)
+		buf = appendBlock(buf, blockInterface, idb)
+	}
+	return buf
+}
+
+// appendBlock appends a pcapng block to buf.
+func appendBlock(buf []byte, blockType uint32, body []byte) []byte {
+	var (
+		pad      = (4 - len(body)%4) % 4
+		totalLen = uint32(12 + len(body) + pad)
+	)
+	buf = binary.LittleEndian.AppendUint32(buf, blockType)
+	buf = binary.LittleEndian.AppendUint32(buf, totalLen)
+	buf = append(buf, body...)
+	buf = append(buf, make([]byte, pad)...)
+	return binary.LittleEndian.AppendUint32(buf, totalLen)
+}
+
+// appendIP appends an IP packet that contains the UDP datagram d to buf.
+func appendIP(buf []byte, d Datagram) ([]byte, error) {
+	udpLen := 8 + len(d.Payload)
+	if udpLen > 0xFFFF-40 {
+		return nil, errors.Errorf("payload of %d bytes is too large", len(d.Payload))
+	}
+	var (
+		src4, dst4 = d.Src.IP.To4(), d.Dst.IP.To4()
+		udp        = appendUDP(nil, d)
+		pseudo     []byte
+	)
+	switch {
+	case src4 != nil && dst4 != nil:
+		buf = append(buf,
+			0x45, 0, // Version, header length and DSCP.
+			byte((20+udpLen)>>8), byte(20+udpLen),
+			0, 0, 0x40, 0, // ID and flags, don't fragment.
+			64, protocolUDP, 0, 0, // TTL, protocol and checksum.
+		)
+		buf = append(buf, src4...)
+		buf = append(buf, dst4...)
+
+		header := buf[len(buf)-20:]
+		binary.BigEndian.PutUint16(header[10:], checksum(0, header))
+
+		pseudo = append(append(append([]byte{}, src4...), dst4...), 0, protocolUDP, byte(udpLen>>8), byte(udpLen))
+	case src4 == nil && dst4 == nil && len(d.Src.IP) == net.IPv6len && len(d.Dst.IP) == net.IPv6len:
+		buf = append(buf,
+			0x60, 0, 0, 0, // Version, traffic class and flow label.
+			byte(udpLen>>8), byte(udpLen),
+			protocolUDP, 64, // Next header and hop limit.
+		)
+		buf = append(buf, d.Src.IP...)
+		buf = append(buf, d.Dst.IP...)
+
+		pseudo = append(append(append([]byte{}, d.Src.IP...), d.Dst.IP...), 0, 0, byte(udpLen>>8), byte(udpLen), 0, 0, 0, protocolUDP)
+	default:
+		return nil, errors.Errorf("addresses %s and %s are not of the same IP version", d.Src.IP, d.Dst.IP)
+	}
+	sum := checksum(checksumAdd(0, pseudo), udp)
+	if sum == 0 {
+		sum = 0xFFFF
+	}
+	binary.BigEndian.PutUint16(udp[6:], sum)
+
+	return append(buf, udp...), nil
+}
+
+// appendUDP appends a UDP datagram without a checksum to buf.
+func appendUDP(buf []byte, d Datagram) []byte {
+	buf = binary.BigEndian.AppendUint16(buf, uint16(d.Src.Port))
+	buf = binary.BigEndian.AppendUint16(buf, uint16(d.Dst.Port))
+	buf = binary.BigEndian.AppendUint16(buf, uint16(8+len(d.Payload)))
+	buf = binary.BigEndian.AppendUint16(buf, 0)
+	return append(buf, d.Payload...)
+}
+
+// checksumAdd adds data to the one's complement sum of 16-bit words.
+func checksumAdd(sum uint32, data []byte) uint32 {
+	for i := 0; i+1 < len(data); i += 2 {
+		sum += uint32(data[i])<<8 | uint32(data[i+1])
+	}
+	if len(data)%2 == 1 {
+		sum += uint32(data[len(data)-1]) << 8
+	}
+	return sum
+}
+
+// checksum returns the internet checksum of data, see RFC 1071.
+// sum is the sum of any data that precedes it, like a pseudo header.
+func checksum(sum uint32, data []byte) uint16 {
+	sum = checksumAdd(sum, data)
+	for sum>>16 != 0 {
+		sum = sum&0xFFFF + sum>>16
+	}
+	return ^uint16(sum)
+}
@@ -0,0 +1,129 @@
+package pcap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/scgolang/osc"
+)
+
+func TestWriterRoundTrip(t *testing.T) {
+	var (
+		now  = time.Date(2024, 1, 1, 12, 0, 0, 123456789, time.UTC)
+		src6 = &net.UDPAddr{IP: net.ParseIP("2001:db8::1"), Port: 1000}
+		dst6 = &net.UDPAddr{IP: net.ParseIP("2001:db8::2"), Port: 57110}
+	)
+	for _, format := range []Format{PCAP, PCAPNG} {
+		var (
+			buf      = &bytes.Buffer{}
+			expected = []Datagram{
+				{Time: now, Src: src, Dst: dst, Payload: osc.Message{Address: "/foo", Arguments: osc.Arguments{osc.Int(1)}}.Bytes()},
+				{Time: now.Add(time.Second), Src: src6, Dst: dst6, Payload: osc.Message{Address: "/odd"}.Bytes()[:7]},
+			}
+		)
+		w, err := NewWriter(buf, format)
+		if err != nil {
+			t.Fatalf("(%s) %s", format, err)
+		}
+		for _, d := range expected {
+			if err := w.WriteDatagram(d); err != nil {
+				t.Fatalf("(%s) %s", format, err)
+			}
+		}
+		rd, err := NewReader(buf)
+		if err != nil {
+			t.Fatalf("(%s) %s", format, err)
+		}
+		if got := rd.Format(); format != got {
+			t.Fatalf("expected %s, got %s", format, got)
+		}
+		for i, exp := range expected {
+			got, err := rd.ReadDatagram()
+			if err != nil {
+				t.Fatalf("(%s datagram %d) %s", format, i, err)
+			}
+			if !exp.Time.Equal(got.Time) {
+				t.Fatalf("(%s datagram %d) expected %s, got %s", format, i, exp.Time, got.Time)
+			}
+			if exp.Src.String() != got.Src.String() || exp.Dst.String() != got.Dst.String() {
+				t.Fatalf("(%s datagram %d) expected %s > %s, got %s > %s", format, i, exp.Src, exp.Dst, got.Src, got.Dst)
+			}
+			if !bytes.Equal(exp.Payload, got.Payload) {
+				t.Fatalf("(%s datagram %d) expected %q, got %q", format, i, exp.Payload, got.Payload)
+			}
+		}
+	}
+}
+
+func TestWriterChecksums(t *testing.T) {
+	for i, d := range []Datagram{
+		{Src: src, Dst: dst, Payload: []byte("/foo\x00\x00\x00\x00,\x00\x00\x00")},
+		{Src: src, Dst: dst, Payload: []byte("odd")},
+		{Src: &net.UDPAddr{IP: net.ParseIP("::1"), Port: 1}, Dst: &net.UDPAddr{IP: net.ParseIP("::1"), Port: 2}, Payload: []byte("odd")},
+	} {
+		packet := ipPacket(t, d)
+
+		// A valid checksum makes the sum over the checksummed data zero.
+		var pseudo []byte
+		udp := packet
+		if packet[0]>>4 == 4 {
+			if got := checksum(0, packet[:20]); got != 0 {
+				t.Fatalf("(testcase %d) invalid IPv4 header checksum %#04x", i, got)
+			}
+			udp = packet[20:]
+			pseudo = append(append([]byte{}, packet[12:20]...), 0, protocolUDP, udp[4], udp[5])
+		} else {
+			udp = packet[40:]
+			pseudo = append(append([]byte{}, packet[8:40]...), 0, 0, udp[4], udp[5], 0, 0, 0, protocolUDP)
+		}
+		if got := checksum(checksumAdd(0, pseudo), udp); got != 0 {
+			t.Fatalf("(testcase %d) invalid UDP checksum %#04x", i, got)
+		}
+		if expected, got := 8+len(d.Payload), int(binary.BigEndian.Uint16(udp[4:])); expected != got {
+			t.Fatalf("(testcase %d) expected UDP length %d, got %d", i, expected, got)
+		}
+	}
+}
+
+func TestWriterEmpty(t *testing.T) {
+	for _, format := range []Format{PCAP, PCAPNG} {
+		buf := &bytes.Buffer{}
+		if _, err := NewWriter(buf, format); err != nil {
+			t.Fatalf("(%s) %s", format, err)
+		}
+		rd, err := NewReader(buf)
+		if err != nil {
+			t.Fatalf("(%s) %s", format, err)
+		}
+		if _, err := rd.ReadDatagram(); err != io.EOF {
+			t.Fatalf("(%s) expected io.EOF, got %+v", format, err)
+		}
+	}
+}
+
+func TestWriterErrors(t *testing.T) {
+	if _, err := NewWriter(&bytes.Buffer{}, Format(9)); err == nil {
+		t.Fatal("expected error for an unsupported format, got nil")
+	}
+	w, err := NewWriter(&bytes.Buffer{}, PCAP)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.WritePacket(osc.Record{Packet: osc.Message{Address: "/foo"}}, dst); err == nil {
+		t.Fatal("expected error for a record without a UDP sender, got nil")
+	}
+	if err := w.WriteDatagram(Datagram{Src: src, Dst: &net.UDPAddr{IP: net.ParseIP("::1")}}); err == nil {
+		t.Fatal("expected error for mixed IP versions, got nil")
+	}
+	if err := w.WriteDatagram(Datagram{Src: src}); err == nil {
+		t.Fatal("expected error for a datagram without a destination, got nil")
+	}
+	if err := w.WritePacket(osc.Record{Sender: src, Packet: osc.Message{Address: "/foo"}}, dst); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,285 @@
+package osc
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Recording file format.
+//
+// A recording starts with a header of 12 bytes: the 8 bytes of RecordingMagic
+// followed by the format version, currently 1, as a 32-bit big-endian integer.
+//
+// The header is followed by one record per packet, in the order they arrived.
+// All integers are big-endian:
+//
+//	int64     arrival time in nanoseconds since the Unix epoch
+//	uint16    length of the sender
+//	[]byte    sender, its network and address separated by a space,
+//	          e.g. "udp 127.0.0.1:57120", or empty if it is unknown
+//	uint32    length of the packet
+//	[]byte    the binary representation of the packet
+//
+// The file ends after the last record.
+
+// RecordingMagic identifies a recording file.
+const RecordingMagic = "#oscrec\x00"
+
+// recordingVersion is the version of the recording file format.
+const recordingVersion = 1
+
+// Common errors.
+var (
+	ErrInvalidRecording = errors.New("invalid recording")
+)
+
+// Record is a packet in a recording.
+type Record struct {
+	// Time is when the packet arrived.
+	Time time.Time
+
+	// Sender is the address the packet came from. It can be nil.
+	Sender net.Addr
+
+	// Packet is the recorded packet.
+	Packet Packet
+}
+
+// recordedAddr is the address of the sender of a recorded packet.
+type recordedAddr struct {
+	network string
+	address string
+}
+
+// Network returns the name of the network.
+func (a recordedAddr) Network() string { return a.network }
+
+// String returns the address.
+func (a recordedAddr) String() string { return a.address }
+
+// RecordWriter writes records to a recording.
+// It is safe to call Write from multiple goroutines.
+type RecordWriter struct {
+	mu  sync.Mutex
+	w   io.Writer
+	buf []byte
+}
+
+// NewRecordWriter writes the header of a recording to w and returns
+// a RecordWriter that writes the records after it.
+// A recording without any record is still a valid recording.
+func NewRecordWriter(w io.Writer) (*RecordWriter, error) {
+	header := byteOrder.AppendUint32([]byte(RecordingMagic), recordingVersion)
+
+	if _, err := w.Write(header); err != nil {
+		return nil, errors.Wrap(err, "write recording header")
+	}
+	return &RecordWriter{w: w}, nil
+}
+
+// Write writes a record with a single call to the underlying writer.
+func (rw *RecordWriter) Write(r Record) error {
+	var sender string
+	if r.Sender != nil {
+		sender = r.Sender.Network() + " " + r.Sender.String()
+	}
+	if len(sender) > 0xFFFF {
+		return errors.Errorf("sender %q is too long", sender)
+	}
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+
+	buf := byteOrder.AppendUint64(rw.buf[:0], uint64(r.Time.UnixNano()))
+	buf = byteOrder.AppendUint16(buf, uint16(len(sender)))
+	buf = append(buf, sender...)
+
+	// Reserve space for the size of the packet.
+	sizeIdx := len(buf)
+	buf = append(buf, 0, 0, 0, 0)
+	buf = appendPacket(buf, r.Packet)
+	byteOrder.PutUint32(buf[sizeIdx:], uint32(len(buf)-sizeIdx-4))
+
+	rw.buf = buf
+
+	_, err := rw.w.Write(buf)
+	return err
+}
+
+// RecordReader reads records from a recording.
+type RecordReader struct {
+	r          *bufio.Reader
+	readHeader bool
+}
+
+// NewRecordReader returns a RecordReader that reads a recording from r.
+func NewRecordReader(r io.Reader) *RecordReader {
+	return &RecordReader{r: bufio.NewReader(r)}
+}
+
+// Read reads the next record.
+// It returns io.EOF when there are no more records,
+// and io.ErrUnexpectedEOF if the recording ends in the middle of a record.
+func (rr *RecordReader) Read() (Record, error) {
+	if !rr.readHeader {
+		if err := rr.header(); err != nil {
+			return Record{}, err
+		}
+		rr.readHeader = true
+	}
+	var head [10]byte
+
+	if _, err := io.ReadFull(rr.r, head[:]); err != nil {
+		return Record{}, err
+	}
+	var (
+		t      = time.Unix(0, int64(byteOrder.Uint64(head[:8])))
+		sender = make([]byte, byteOrder.Uint16(head[8:]))
+	)
+	if _, err := io.ReadFull(rr.r, sender); err != nil {
+		return Record{}, unexpectedEOF(err)
+	}
+	var size [4]byte
+	if _, err := io.ReadFull(rr.r, size[:]); err != nil {
+		return Record{}, unexpectedEOF(err)
+	}
+	data := make([]byte, byteOrder.Uint32(size[:]))
+	if _, err := io.ReadFull(rr.r, data); err != nil {
+		return Record{}, unexpectedEOF(err)
+	}
+	r := Record{Time: t}
+
+	if len(sender) > 0 {
+		network, address, ok := strings.Cut(string(sender), " ")
+		if !ok {
+			return Record{}, errors.Wrapf(ErrInvalidRecording, "sender %q", sender)
+		}
+		r.Sender = recordedAddr{network: network, address: address}
+	}
+	p, err := ParsePacket(data, r.Sender)
+	if err != nil {
+		return Record{}, errors.Wrap(err, "parse recorded packet")
+	}
+	r.Packet = p
+	return r, nil
+}
+
+// header reads and checks the header of the recording.
+func (rr *RecordReader) header() error {
+	var header [len(RecordingMagic) + 4]byte
+
+	if _, err := io.ReadFull(rr.r, header[:]); err != nil {
+		if err == io.EOF {
+			return errors.Wrap(ErrInvalidRecording, "missing header")
+		}
+		return unexpectedEOF(err)
+	}
+	if string(header[:len(RecordingMagic)]) != RecordingMagic {
+		return errors.Wrap(ErrInvalidRecording, "not a recording")
+	}
+	if v := byteOrder.Uint32(header[len(RecordingMagic):]); v != recordingVersion {
+		return errors.Wrapf(ErrInvalidRecording, "unsupported version %d", v)
+	}
+	return nil
+}
+
+// unexpectedEOF turns io.EOF into io.ErrUnexpectedEOF.
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// Recorder is a Dispatcher that records every packet before it passes
+// the packet on to another Dispatcher.
+type Recorder struct {
+	dispatcher Dispatcher
+	w          *RecordWriter
+	now        func() time.Time
+}
+
+// NewRecorder writes the header of a recording to w and returns a Recorder
+// that writes the records after it.
+// dispatcher can be nil if the packets only need to be recorded.
+func NewRecorder(dispatcher Dispatcher, w io.Writer) (*Recorder, error) {
+	rw, err := NewRecordWriter(w)
+	if err != nil {
+		return nil, err
+	}
+	return &Recorder{
+		dispatcher: dispatcher,
+		w:          rw,
+		now:        time.Now,
+	}, nil
+}
+
+// Dispatch records a bundle and dispatches it.
+func (r *Recorder) Dispatch(b Bundle, exactMatch bool) error {
+	if err := r.w.Write(Record{Time: r.now(), Sender: b.Sender, Packet: b}); err != nil {
+		return errors.Wrap(err, "record bundle")
+	}
+	if r.dispatcher == nil {
+		return nil
+	}
+	return r.dispatcher.Dispatch(b, exactMatch)
+}
+
+// Invoke records a message and invokes it.
+func (r *Recorder) Invoke(msg Message, exactMatch bool) error {
+	if err := r.w.Write(Record{Time: r.now(), Sender: msg.Sender, Packet: msg}); err != nil {
+		return errors.Wrap(err, "record message")
+	}
+	if r.dispatcher == nil {
+		return nil
+	}
+	return r.dispatcher.Invoke(msg, exactMatch)
+}
+
+// Replay reads every record from rr and calls send with it,
+// keeping the time between records the same as when they were recorded.
+// speed scales the timing: 2 replays twice as fast, 0.5 at half speed,
+// and 0 or less sends every record as soon as it is read.
+// Replay stops at the end of the recording, when ctx is done
+// or when send returns an error.
+func Replay(ctx context.Context, rr *RecordReader, speed float64, send func(Record) error) error {
+	var (
+		first time.Time
+		start time.Time
+	)
+	for i := 0; ; i++ {
+		r, err := rr.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return errors.Wrapf(err, "read record %d", i)
+		}
+		if i == 0 {
+			first, start = r.Time, time.Now()
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if speed > 0 {
+			due := start.Add(time.Duration(float64(r.Time.Sub(first)) / speed))
+
+			timer := time.NewTimer(time.Until(due))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return ctx.Err()
+			case <-timer.C:
+			}
+		}
+		if err := send(r); err != nil {
+			return errors.Wrapf(err, "send record %d", i)
+		}
+	}
+}
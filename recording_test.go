@@ -0,0 +1,207 @@
+package osc
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math/rand"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestRecording(t *testing.T) {
+	var (
+		buf     = &bytes.Buffer{}
+		r       = rand.New(rand.NewSource(3))
+		start   = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		sender  = &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 5000}
+		records []Record
+	)
+	rw, err := NewRecordWriter(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		rec := Record{
+			Time:   start.Add(time.Duration(i) * time.Millisecond),
+			Packet: randomPacket(r, 2),
+		}
+		if i%2 == 0 {
+			rec.Sender = sender
+		}
+		if err := rw.Write(rec); err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, rec)
+	}
+	rr := NewRecordReader(bytes.NewReader(buf.Bytes()))
+
+	for i, expected := range records {
+		got, err := rr.Read()
+		if err != nil {
+			t.Fatalf("(record %d) %s", i, err)
+		}
+		if !expected.Time.Equal(got.Time) {
+			t.Fatalf("(record %d) expected time %s, got %s", i, expected.Time, got.Time)
+		}
+		if !bytes.Equal(expected.Packet.Bytes(), got.Packet.Bytes()) {
+			t.Fatalf("(record %d) expected %s, got %s", i, expected.Packet, got.Packet)
+		}
+		if expected.Sender == nil {
+			if got.Sender != nil {
+				t.Fatalf("(record %d) expected no sender, got %s", i, got.Sender)
+			}
+			continue
+		}
+		if got.Sender == nil || got.Sender.Network() != "udp" || got.Sender.String() != "127.0.0.1:5000" {
+			t.Fatalf("(record %d) expected sender %s, got %v", i, expected.Sender, got.Sender)
+		}
+	}
+	if _, err := rr.Read(); err != io.EOF {
+		t.Fatalf("expected io.EOF, got %+v", err)
+	}
+}
+
+func TestRecordReaderErrors(t *testing.T) {
+	var recording bytes.Buffer
+	rw, err := NewRecordWriter(&recording)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rw.Write(Record{Packet: Message{Address: "/foo"}}); err != nil {
+		t.Fatal(err)
+	}
+	for i, testcase := range []struct {
+		Input []byte
+		Err   string
+	}{
+		{
+			Input: []byte{},
+			Err:   "missing header: invalid recording",
+		},
+		{
+			Input: []byte("#oscrec\x00\x00\x00\x00\x02"),
+			Err:   "unsupported version 2: invalid recording",
+		},
+		{
+			Input: []byte("not a recording"),
+			Err:   "not a recording: invalid recording",
+		},
+		{
+			Input: recording.Bytes()[:recording.Len()-1],
+			Err:   io.ErrUnexpectedEOF.Error(),
+		},
+	} {
+		_, err := NewRecordReader(bytes.NewReader(testcase.Input)).Read()
+		if err == nil {
+			t.Fatalf("(testcase %d) expected error, got nil", i)
+		}
+		if expected, got := testcase.Err, err.Error(); expected != got {
+			t.Fatalf("(testcase %d) expected %s, got %s", i, expected, got)
+		}
+	}
+}
+
+func TestRecorder(t *testing.T) {
+	var (
+		buf     = &bytes.Buffer{}
+		invoked = 0
+	)
+	rec, err := NewRecorder(PatternMatching{
+		"/foo": Method(func(msg Message) error {
+			invoked++
+			return nil
+		}),
+	}, buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec.now = func() time.Time { return time.Unix(1, 0) }
+
+	if err := rec.Invoke(Message{Address: "/foo"}, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := rec.Dispatch(Bundle{Timetag: Immediately, Packets: []Packet{Message{Address: "/foo"}}}, false); err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := 2, invoked; expected != got {
+		t.Fatalf("expected %d invocations, got %d", expected, got)
+	}
+	rr := NewRecordReader(buf)
+
+	for i := 0; i < 2; i++ {
+		r, err := rr.Read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected, got := time.Unix(1, 0), r.Time; !expected.Equal(got) {
+			t.Fatalf("expected %s, got %s", expected, got)
+		}
+	}
+	if _, err := rr.Read(); err != io.EOF {
+		t.Fatalf("expected io.EOF, got %+v", err)
+	}
+}
+
+func TestRecorderWriteError(t *testing.T) {
+	if _, err := NewRecorder(nil, &errWriter{erridx: 1}); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	// The header is the first write, the record the second.
+	rec, err := NewRecorder(nil, &errWriter{erridx: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rec.Invoke(Message{Address: "/foo"}, false); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+}
+
+func TestReplay(t *testing.T) {
+	var (
+		buf   = &bytes.Buffer{}
+		start = time.Unix(0, 0)
+	)
+	rw, err := NewRecordWriter(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A recording without records replays nothing.
+	err = Replay(context.Background(), NewRecordReader(bytes.NewReader(buf.Bytes())), 1, func(Record) error {
+		t.Fatal("unexpected record")
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := rw.Write(Record{Time: start.Add(time.Duration(i) * 100 * time.Millisecond), Packet: Message{Address: "/foo"}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var (
+		sent  []time.Time
+		begin = time.Now()
+	)
+	err = Replay(context.Background(), NewRecordReader(bytes.NewReader(buf.Bytes())), 2, func(r Record) error {
+		sent = append(sent, time.Now())
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := 3, len(sent); expected != got {
+		t.Fatalf("expected %d records, got %d", expected, got)
+	}
+	// At twice the speed the last record is sent after 100ms.
+	if elapsed := sent[2].Sub(begin); elapsed < 100*time.Millisecond || elapsed > time.Second {
+		t.Fatalf("expected the last record after 100ms, got %s", elapsed)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = Replay(ctx, NewRecordReader(bytes.NewReader(buf.Bytes())), 1, func(Record) error { return nil })
+	if err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %+v", err)
+	}
+}
@@ -0,0 +1,363 @@
+package osc
+
+import (
+	"hash/fnv"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Rewrite changes the address of a message that is forwarded by a Relay.
+type Rewrite func(address string) string
+
+// AddPrefix returns a Rewrite that puts prefix in front of every address.
+func AddPrefix(prefix string) Rewrite {
+	prefix = strings.TrimSuffix(prefix, "/")
+	return func(address string) string {
+		return prefix + address
+	}
+}
+
+// StripPrefix returns a Rewrite that removes prefix from the addresses that start with it.
+// Only whole parts of an address are removed, so /a turns /a/b into /b
+// but leaves /ab and /a alone.
+func StripPrefix(prefix string) Rewrite {
+	prefix = strings.TrimSuffix(prefix, "/")
+	return func(address string) string {
+		if strings.HasPrefix(address, prefix+"/") {
+			return address[len(prefix):]
+		}
+		return address
+	}
+}
+
+// RegexpRewrite returns a Rewrite that replaces the matches of re in an address
+// with replacement, which can refer to submatches as described by regexp.Regexp.Expand.
+func RegexpRewrite(re *regexp.Regexp, replacement string) Rewrite {
+	return func(address string) string {
+		return re.ReplaceAllString(address, replacement)
+	}
+}
+
+// Relay is a Dispatcher that forwards the packets it receives to a list of destinations.
+// Messages can be filtered by their address, have their address rewritten and
+// have their arguments converted to other types on the way.
+// Bundles are forwarded with their timetag, without waiting for it,
+// and with only the messages that pass the filter.
+//
+// Packets are never sent back to the address they came from, and packets from
+// the relay's own destination sockets are dropped, so that a relay that is
+// configured to send to itself does not loop. See SetLoopWindow for loops
+// that go through other relays.
+//
+// The methods that configure a relay must be called before it starts serving.
+type Relay struct {
+	destinations []net.Conn
+	filter       []*regexp.Regexp
+	rewrites     []Rewrite
+	conversions  map[byte]byte
+	errorHandler func(error)
+
+	loopWindow time.Duration
+	now        func() time.Time
+	mu         sync.Mutex
+	sent       map[uint64]time.Time
+}
+
+// NewRelay returns a relay that forwards packets to destinations.
+// The destinations should be datagram connections, like the ones returned
+// by net.DialUDP or DialUDP, since the packets are written without framing.
+func NewRelay(destinations ...net.Conn) *Relay {
+	return &Relay{
+		destinations: destinations,
+		conversions:  map[byte]byte{},
+		now:          time.Now,
+		sent:         map[uint64]time.Time{},
+	}
+}
+
+// AddFilter makes the relay forward messages whose address matches pattern.
+// Without any filters every message is forwarded.
+func (r *Relay) AddFilter(pattern string) error {
+	re, err := GetRegex(pattern)
+	if err != nil {
+		return errors.Wrapf(err, "filter %s", pattern)
+	}
+	r.filter = append(r.filter, re)
+	return nil
+}
+
+// AddRewrite adds a rewrite for the addresses of forwarded messages.
+// Rewrites are applied in the order they were added, after filtering.
+func (r *Relay) AddRewrite(rw Rewrite) {
+	r.rewrites = append(r.rewrites, rw)
+}
+
+// Convert makes the relay convert arguments with the typetag from to the type of
+// the typetag to, e.g. TypetagInt to TypetagFloat for receivers that only accept floats.
+// Ints, floats, doubles and bools (TypetagTrue and TypetagFalse) can be converted
+// to ints, floats, doubles and strings. Bools become 1 or 0, and floats and doubles
+// are truncated to ints.
+func (r *Relay) Convert(from, to byte) error {
+	switch from {
+	case TypetagInt, TypetagFloat, TypetagDouble, TypetagTrue, TypetagFalse:
+	default:
+		return errors.Wrapf(ErrInvalidTypeTag, "convert from %q", string(from))
+	}
+	switch to {
+	case TypetagInt, TypetagFloat, TypetagDouble, TypetagString:
+	default:
+		return errors.Wrapf(ErrInvalidTypeTag, "convert to %q", string(to))
+	}
+	r.conversions[from] = to
+	return nil
+}
+
+// SetErrorHandler sets a function that is called with the errors
+// that happen while forwarding packets, like failed sends.
+// These errors are not returned by Dispatch and Invoke, since that
+// would stop the server that the relay is serving, so they are
+// dropped if there is no error handler.
+func (r *Relay) SetErrorHandler(handler func(error)) {
+	r.errorHandler = handler
+}
+
+// SetLoopWindow makes the relay drop incoming packets that are identical
+// to a packet it has forwarded within the window, which breaks loops
+// that go through other relays. This also drops packets that are
+// legitimately repeated within the window, so it is disabled by default.
+func (r *Relay) SetLoopWindow(window time.Duration) {
+	r.loopWindow = window
+}
+
+// Serve forwards the packets received by every conn until one of them stops serving,
+// and returns the error it stopped with.
+func (r *Relay) Serve(numWorkers int, conns ...Conn) error {
+	if len(conns) == 0 {
+		return errors.New("relay needs at least one conn to serve")
+	}
+	errs := make(chan error, len(conns))
+
+	for _, conn := range conns {
+		go func(conn Conn) {
+			errs <- conn.Serve(numWorkers, r)
+		}(conn)
+	}
+	return <-errs
+}
+
+// Accept implements MessageFilter, so that messages the relay
+// doesn't forward are not parsed.
+func (r *Relay) Accept(view MessageView, exactMatch bool) (bool, error) {
+	return r.match(string(view.Address())), nil
+}
+
+// Dispatch forwards a bundle.
+func (r *Relay) Dispatch(b Bundle, exactMatch bool) error {
+	r.forward(b, b.Sender)
+	return nil
+}
+
+// Invoke forwards a message.
+func (r *Relay) Invoke(msg Message, exactMatch bool) error {
+	r.forward(msg, msg.Sender)
+	return nil
+}
+
+// forward sends the packet to every destination, except the one it came from.
+func (r *Relay) forward(p Packet, sender net.Addr) {
+	for _, dst := range r.destinations {
+		if sameAddr(sender, dst.LocalAddr()) {
+			return
+		}
+	}
+	if r.looped(p.Bytes()) {
+		return
+	}
+	p, ok, err := r.packet(p)
+	if err != nil {
+		r.handleError(err)
+		return
+	}
+	if !ok {
+		return
+	}
+	data := p.Bytes()
+	r.remember(data)
+
+	for _, dst := range r.destinations {
+		if sameAddr(sender, dst.RemoteAddr()) {
+			continue
+		}
+		if _, err := dst.Write(data); err != nil {
+			r.handleError(errors.Wrapf(err, "forward to %s", dst.RemoteAddr()))
+		}
+	}
+}
+
+// looped returns true if data is a packet that was forwarded within the loop window.
+// The incoming packet is compared with the forwarded one, after its rewrites and
+// conversions, since that is what comes back when it goes around a loop.
+func (r *Relay) looped(data []byte) bool {
+	if r.loopWindow <= 0 {
+		return false
+	}
+	var (
+		sum = hashPacket(data)
+		now = r.now()
+	)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for k, t := range r.sent {
+		if now.Sub(t) > r.loopWindow {
+			delete(r.sent, k)
+		}
+	}
+	_, ok := r.sent[sum]
+	return ok
+}
+
+// remember remembers that data is being forwarded now.
+func (r *Relay) remember(data []byte) {
+	if r.loopWindow <= 0 {
+		return
+	}
+	sum := hashPacket(data)
+
+	r.mu.Lock()
+	r.sent[sum] = r.now()
+	r.mu.Unlock()
+}
+
+// hashPacket returns the hash of the binary representation of a packet.
+func hashPacket(data []byte) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write(data)
+	return h.Sum64()
+}
+
+// packet returns the packet as it should be forwarded, and false if it should not be forwarded.
+func (r *Relay) packet(p Packet) (Packet, bool, error) {
+	switch x := p.(type) {
+	case Message:
+		if !r.match(x.Address) {
+			return nil, false, nil
+		}
+		msg, err := r.message(x)
+		if err != nil {
+			return nil, false, err
+		}
+		return msg, true, nil
+	case Bundle:
+		b := Bundle{Timetag: x.Timetag, Sender: x.Sender}
+		for _, p := range x.Packets {
+			fp, ok, err := r.packet(p)
+			if err != nil {
+				return nil, false, err
+			}
+			if ok {
+				b.Packets = append(b.Packets, fp)
+			}
+		}
+		return b, len(b.Packets) > 0, nil
+	default:
+		return nil, false, errors.Errorf("unsupported type for relay: %T", p)
+	}
+}
+
+// match returns true if the relay forwards messages with the address.
+func (r *Relay) match(address string) bool {
+	if len(r.filter) == 0 {
+		return true
+	}
+	for _, re := range r.filter {
+		if re.MatchString(address) {
+			return true
+		}
+	}
+	return false
+}
+
+// message rewrites the address of msg and converts its arguments.
+func (r *Relay) message(msg Message) (Message, error) {
+	address := msg.Address
+	for _, rw := range r.rewrites {
+		address = rw(address)
+	}
+	if len(address) == 0 || address[0] != MessageChar {
+		return Message{}, errors.Wrapf(ErrInvalidAddress, "rewrite %s to %q", msg.Address, address)
+	}
+	if err := ValidateAddress(address); err != nil {
+		return Message{}, errors.Wrapf(err, "rewrite %s to %q", msg.Address, address)
+	}
+	out := Message{Address: address, Arguments: msg.Arguments, Sender: msg.Sender}
+
+	if len(r.conversions) == 0 {
+		return out, nil
+	}
+	out.Arguments = make(Arguments, len(msg.Arguments))
+	for i, a := range msg.Arguments {
+		out.Arguments[i] = a
+		if to, ok := r.conversions[a.Typetag()]; ok {
+			out.Arguments[i] = convertArgument(a, to)
+		}
+	}
+	return out, nil
+}
+
+// handleError passes err to the error handler, if there is one.
+func (r *Relay) handleError(err error) {
+	if r.errorHandler != nil {
+		r.errorHandler(err)
+	}
+}
+
+// convertArgument converts an Int, Float, Double or Bool to the type of typetag.
+// Other arguments are returned unchanged.
+func convertArgument(a Argument, typetag byte) Argument {
+	var (
+		f float64
+		s string
+	)
+	switch x := a.(type) {
+	case Int:
+		f, s = float64(x), strconv.Itoa(int(x))
+	case Float:
+		f, s = float64(x), strconv.FormatFloat(float64(x), 'g', -1, 32)
+	case Double:
+		f, s = float64(x), strconv.FormatFloat(float64(x), 'g', -1, 64)
+	case Bool:
+		s = strconv.FormatBool(bool(x))
+		if x {
+			f = 1
+		}
+	default:
+		return a
+	}
+	switch typetag {
+	case TypetagInt:
+		return Int(int32(f))
+	case TypetagFloat:
+		return Float(float32(f))
+	case TypetagDouble:
+		return Double(f)
+	case TypetagString:
+		return String(s)
+	default:
+		return a
+	}
+}
+
+// sameAddr returns true if a and b are the same address.
+func sameAddr(a, b net.Addr) bool {
+	if a == nil || b == nil {
+		return false
+	}
+	return a.Network() == b.Network() && a.String() == b.String()
+}
@@ -0,0 +1,300 @@
+package osc
+
+import (
+	"net"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testDestination returns a UDP socket to receive forwarded packets
+// and a connection that sends to it. The caller closes both.
+func testDestination(t *testing.T) (net.PacketConn, net.Conn) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := net.Dial("udp", pc.LocalAddr().String())
+	if err != nil {
+		_ = pc.Close()
+		t.Fatal(err)
+	}
+	return pc, conn
+}
+
+// receivePacket reads a packet from pc, and returns nil if none arrives in time.
+func receivePacket(t *testing.T, pc net.PacketConn, timeout time.Duration) Packet {
+	if err := pc.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, bufSize)
+
+	n, _, err := pc.ReadFrom(buf)
+	if err != nil {
+		if ne, ok := err.(net.Error); ok && ne.Timeout() {
+			return nil
+		}
+		t.Fatal(err)
+	}
+	p, err := ParsePacket(buf[:n], nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestRewrites(t *testing.T) {
+	for i, testcase := range []struct {
+		Rewrite  Rewrite
+		Input    string
+		Expected string
+	}{
+		{Rewrite: AddPrefix("/studio/"), Input: "/synth/freq", Expected: "/studio/synth/freq"},
+		{Rewrite: StripPrefix("/studio"), Input: "/studio/synth/freq", Expected: "/synth/freq"},
+		{Rewrite: StripPrefix("/studio"), Input: "/studioA/freq", Expected: "/studioA/freq"},
+		{Rewrite: StripPrefix("/studio"), Input: "/studio", Expected: "/studio"},
+		{Rewrite: RegexpRewrite(regexp.MustCompile(`^/track/(\d+)/`), "/mixer/ch$1/"), Input: "/track/3/volume", Expected: "/mixer/ch3/volume"},
+	} {
+		if got := testcase.Rewrite(testcase.Input); testcase.Expected != got {
+			t.Fatalf("(testcase %d) expected %s, got %s", i, testcase.Expected, got)
+		}
+	}
+}
+
+func TestRelayForward(t *testing.T) {
+	var (
+		pc1, dst1 = testDestination(t)
+		pc2, dst2 = testDestination(t)
+		relay     = NewRelay(dst1, dst2)
+		sender    = &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 9}
+		tt        = FromTime(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	)
+	defer func() { _ = pc1.Close() }()
+	defer func() { _ = dst1.Close() }()
+	defer func() { _ = pc2.Close() }()
+	defer func() { _ = dst2.Close() }()
+
+	if err := relay.AddFilter("/synth/*"); err != nil {
+		t.Fatal(err)
+	}
+	relay.AddRewrite(StripPrefix("/synth"))
+	relay.AddRewrite(AddPrefix("/sc"))
+	if err := relay.Convert(TypetagInt, TypetagFloat); err != nil {
+		t.Fatal(err)
+	}
+	if err := relay.Convert(TypetagTrue, TypetagInt); err != nil {
+		t.Fatal(err)
+	}
+	if err := relay.Convert(TypetagDouble, TypetagFloat); err != nil {
+		t.Fatal(err)
+	}
+	for i, testcase := range []struct {
+		Input    Packet
+		Expected Packet
+	}{
+		{
+			Input:    Message{Address: "/synth/freq", Arguments: Arguments{Int(440), String("sine"), Bool(true), Bool(false), Double(0.25)}, Sender: sender},
+			Expected: Message{Address: "/sc/freq", Arguments: Arguments{Float(440), String("sine"), Int(1), Bool(false), Float(0.25)}},
+		},
+		{
+			Input: Bundle{
+				Timetag: tt,
+				Sender:  sender,
+				Packets: []Packet{
+					Message{Address: "/synth/gate", Arguments: Arguments{Int(1)}},
+					Message{Address: "/status"},
+					Bundle{Timetag: Immediately, Packets: []Packet{Message{Address: "/synth/amp", Arguments: Arguments{Float(0.5)}}}},
+				},
+			},
+			Expected: Bundle{
+				Timetag: tt,
+				Packets: []Packet{
+					Message{Address: "/sc/gate", Arguments: Arguments{Float(1)}},
+					Bundle{Timetag: Immediately, Packets: []Packet{Message{Address: "/sc/amp", Arguments: Arguments{Float(0.5)}}}},
+				},
+			},
+		},
+	} {
+		var err error
+		switch x := testcase.Input.(type) {
+		case Message:
+			err = relay.Invoke(x, false)
+		case Bundle:
+			err = relay.Dispatch(x, false)
+		}
+		if err != nil {
+			t.Fatalf("(testcase %d) %s", i, err)
+		}
+		for _, pc := range []net.PacketConn{pc1, pc2} {
+			got := receivePacket(t, pc, time.Second)
+			if got == nil {
+				t.Fatalf("(testcase %d) expected a packet to be forwarded to %s", i, pc.LocalAddr())
+			}
+			if !testcase.Expected.Equal(got) {
+				t.Fatalf("(testcase %d) expected %s, got %s", i, testcase.Expected, got)
+			}
+		}
+	}
+	if err := relay.Invoke(Message{Address: "/status"}, false); err != nil {
+		t.Fatal(err)
+	}
+	if got := receivePacket(t, pc1, 50*time.Millisecond); got != nil {
+		t.Fatalf("expected /status to be left out, got %s", got)
+	}
+}
+
+func TestRelayLoops(t *testing.T) {
+	var (
+		pc1, dst1 = testDestination(t)
+		pc2, dst2 = testDestination(t)
+		relay     = NewRelay(dst1, dst2)
+		now       = time.Unix(0, 0)
+		msg       = Message{Address: "/foo"}
+	)
+	defer func() { _ = pc1.Close() }()
+	defer func() { _ = dst1.Close() }()
+	defer func() { _ = pc2.Close() }()
+	defer func() { _ = dst2.Close() }()
+
+	relay.now = func() time.Time { return now }
+
+	// Packets are not sent back to where they came from.
+	msg.Sender = dst1.RemoteAddr()
+	if err := relay.Invoke(msg, false); err != nil {
+		t.Fatal(err)
+	}
+	if got := receivePacket(t, pc2, time.Second); got == nil || !msg.Equal(got) {
+		t.Fatalf("expected %s, got %v", msg, got)
+	}
+	if got := receivePacket(t, pc1, 50*time.Millisecond); got != nil {
+		t.Fatalf("expected packet not to be sent back to its sender, got %s", got)
+	}
+
+	// Packets from the relay's own destination sockets are dropped.
+	msg.Sender = dst2.LocalAddr()
+	if err := relay.Invoke(msg, false); err != nil {
+		t.Fatal(err)
+	}
+	if got := receivePacket(t, pc1, 50*time.Millisecond); got != nil {
+		t.Fatalf("expected packet from the relay itself to be dropped, got %s", got)
+	}
+
+	// Packets that were just forwarded are dropped within the loop window.
+	relay.SetLoopWindow(time.Second)
+	msg.Sender = nil
+	for i, expected := range []bool{true, false, true} {
+		if i == 2 {
+			now = now.Add(2 * time.Second)
+		}
+		if err := relay.Invoke(msg, false); err != nil {
+			t.Fatal(err)
+		}
+		if got := receivePacket(t, pc1, 50*time.Millisecond); expected != (got != nil) {
+			t.Fatalf("(packet %d) expected forwarded to be %t, got %v", i, expected, got)
+		}
+	}
+	_ = receivePacket(t, pc2, 50*time.Millisecond)
+	_ = receivePacket(t, pc2, 50*time.Millisecond)
+
+	// Rewritten packets are recognized when they come back.
+	relay.AddRewrite(AddPrefix("/relay"))
+	for i, testcase := range []struct {
+		Input    Message
+		Expected Packet
+	}{
+		{Input: Message{Address: "/bar"}, Expected: Message{Address: "/relay/bar"}},
+		{Input: Message{Address: "/relay/bar"}},
+		{Input: Message{Address: "/bar"}, Expected: Message{Address: "/relay/bar"}},
+	} {
+		if err := relay.Invoke(testcase.Input, false); err != nil {
+			t.Fatal(err)
+		}
+		got := receivePacket(t, pc2, 50*time.Millisecond)
+		if testcase.Expected == nil {
+			if got != nil {
+				t.Fatalf("(testcase %d) expected looped packet to be dropped, got %s", i, got)
+			}
+			continue
+		}
+		if got == nil || !testcase.Expected.Equal(got) {
+			t.Fatalf("(testcase %d) expected %s, got %v", i, testcase.Expected, got)
+		}
+	}
+}
+
+func TestRelayErrors(t *testing.T) {
+	pc, dst := testDestination(t)
+	defer func() { _ = pc.Close() }()
+	defer func() { _ = dst.Close() }()
+
+	var (
+		relay = NewRelay(dst)
+		errs  []string
+	)
+	relay.SetErrorHandler(func(err error) {
+		errs = append(errs, err.Error())
+	})
+	relay.AddRewrite(RegexpRewrite(regexp.MustCompile(`^/`), ""))
+
+	if err := relay.Invoke(Message{Address: "/foo"}, false); err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := `rewrite /foo to "foo": invalid OSC address`, strings.Join(errs, "; "); expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+	for i, tt := range [][2]byte{
+		{TypetagString, TypetagInt},
+		{TypetagInt, TypetagBlob},
+	} {
+		if err := relay.Convert(tt[0], tt[1]); err == nil {
+			t.Fatalf("(testcase %d) expected error, got nil", i)
+		}
+	}
+	if err := relay.AddFilter("/foo/[a-"); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	if err := relay.Serve(1); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+}
+
+func TestRelayServe(t *testing.T) {
+	pc, dst := testDestination(t)
+	defer func() { _ = pc.Close() }()
+	defer func() { _ = dst.Close() }()
+
+	laddr, err := net.ResolveUDPAddr("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := ListenUDP("udp", laddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	relay := NewRelay(dst)
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- relay.Serve(1, server)
+	}()
+	client, err := DialUDP("udp", nil, server.LocalAddr().(*net.UDPAddr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = client.Close() }()
+
+	msg := Message{Address: "/foo", Arguments: Arguments{String("bar")}}
+	if err := client.Send(msg); err != nil {
+		t.Fatal(err)
+	}
+	if got := receivePacket(t, pc, time.Second); got == nil || !msg.Equal(got) {
+		t.Fatalf("expected %s, got %v", msg, got)
+	}
+	if err := server.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-errs; err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,126 @@
+package scsynth
+
+import (
+	"github.com/pkg/errors"
+	"github.com/scgolang/osc"
+)
+
+// ArgReader reads the arguments of a command or a reply one by one,
+// and checks their types. After the first error every read returns
+// the zero value, so the error only needs to be checked at the end:
+//
+//	var (
+//		r      = scsynth.NewArgReader(msg)
+//		bufnum = r.ReadInt32()
+//		frames = r.ReadInt32()
+//	)
+//	if err := r.Err(); err != nil {
+//		return err
+//	}
+type ArgReader struct {
+	msg   osc.Message
+	i     int
+	err   error
+	cause error // Wrapped by every error along with the address, if it is not nil.
+}
+
+// NewArgReader returns a reader for the arguments of msg.
+func NewArgReader(msg osc.Message) *ArgReader {
+	return &ArgReader{msg: msg}
+}
+
+// Err returns the first error that happened while reading the arguments.
+func (r *ArgReader) Err() error {
+	return r.err
+}
+
+// More returns true if there are arguments left to read and there was no error.
+func (r *ArgReader) More() bool {
+	return r.err == nil && r.i < len(r.msg.Arguments)
+}
+
+// ReadInt32 reads an int.
+func (r *ArgReader) ReadInt32() int32 {
+	arg := r.next("an int", osc.TypetagInt)
+	if arg == nil {
+		return 0
+	}
+	i, _ := arg.ReadInt32()
+	return i
+}
+
+// ReadFloat32 reads a float.
+func (r *ArgReader) ReadFloat32() float32 {
+	arg := r.next("a float", osc.TypetagFloat)
+	if arg == nil {
+		return 0
+	}
+	f, _ := arg.ReadFloat32()
+	return f
+}
+
+// ReadFloat64 reads a double, or a float.
+func (r *ArgReader) ReadFloat64() float64 {
+	arg := r.next("a double", osc.TypetagDouble, osc.TypetagFloat)
+	switch x := arg.(type) {
+	case osc.Double:
+		return float64(x)
+	case osc.Float:
+		return float64(x)
+	default:
+		return 0
+	}
+}
+
+// ReadString reads a string.
+func (r *ArgReader) ReadString() string {
+	arg := r.next("a string", osc.TypetagString)
+	if arg == nil {
+		return ""
+	}
+	s, _ := arg.ReadString()
+	return s
+}
+
+// ReadBlob reads a blob.
+func (r *ArgReader) ReadBlob() []byte {
+	arg := r.next("a blob", osc.TypetagBlob)
+	if arg == nil {
+		return nil
+	}
+	b, _ := arg.ReadBlob()
+	return b
+}
+
+// next returns the next argument if it has one of the typetags.
+func (r *ArgReader) next(name string, typetags ...byte) osc.Argument {
+	if r.err != nil {
+		return nil
+	}
+	if r.i >= len(r.msg.Arguments) {
+		r.fail("missing argument %d", r.i)
+		return nil
+	}
+	arg := r.msg.Arguments[r.i]
+	if arg == nil {
+		r.fail("argument %d is nil", r.i)
+		return nil
+	}
+	for _, tt := range typetags {
+		if arg.Typetag() == tt {
+			r.i++
+			return arg
+		}
+	}
+	r.fail("argument %d should be %s, got %s", r.i, name, osc.FormatArgument(arg))
+	return nil
+}
+
+// fail sets the error of the reader.
+func (r *ArgReader) fail(format string, args ...interface{}) {
+	if r.cause != nil {
+		r.err = errors.Wrapf(r.cause, "%s: "+format, append([]interface{}{r.msg.Address}, args...)...)
+		return
+	}
+	r.err = errors.Errorf(format, args...)
+}
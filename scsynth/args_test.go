@@ -0,0 +1,56 @@
+package scsynth
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/scgolang/osc"
+)
+
+func TestArgReader(t *testing.T) {
+	r := NewArgReader(osc.Message{
+		Address:   "/b_alloc",
+		Arguments: osc.Arguments{osc.Int(3), osc.Float(0.5), osc.Float(44100), osc.String("a"), osc.Blob{1, 2}},
+	})
+	var (
+		i = r.ReadInt32()
+		f = r.ReadFloat32()
+		d = r.ReadFloat64()
+		s = r.ReadString()
+	)
+	if !r.More() {
+		t.Fatal("expected more arguments")
+	}
+	b := r.ReadBlob()
+
+	if err := r.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if r.More() {
+		t.Fatal("expected no more arguments")
+	}
+	if i != 3 || f != 0.5 || d != 44100 || s != "a" || !bytes.Equal(b, []byte{1, 2}) {
+		t.Fatalf("unexpected arguments %d %f %f %q %v", i, f, d, s, b)
+	}
+	for i, testcase := range []struct {
+		Args osc.Arguments
+		Err  string
+	}{
+		{Args: osc.Arguments{osc.Float(1)}, Err: "argument 0 should be an int, got 1.0"},
+		{Args: osc.Arguments{nil}, Err: "argument 0 is nil"},
+		{Args: osc.Arguments{}, Err: "missing argument 0"},
+	} {
+		r := NewArgReader(osc.Message{Address: "/sync", Arguments: testcase.Args})
+
+		// Reads after the first error return the zero value.
+		if got := r.ReadInt32() + r.ReadInt32(); got != 0 {
+			t.Fatalf("(testcase %d) expected 0, got %d", i, got)
+		}
+		if r.Err() == nil {
+			t.Fatalf("(testcase %d) expected error, got nil", i)
+		}
+		if expected, got := testcase.Err, r.Err().Error(); expected != got {
+			t.Fatalf("(testcase %d) expected %s, got %s", i, expected, got)
+		}
+	}
+}
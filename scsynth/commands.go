@@ -0,0 +1,212 @@
+package scsynth
+
+import (
+	"github.com/scgolang/osc"
+)
+
+// Quit makes the server quit, /quit.
+func Quit() osc.Message {
+	return osc.Message{Address: "/quit"}
+}
+
+// Notify registers the client to receive notifications from the server,
+// like /n_go and /n_end, or unregisters it, /notify.
+func Notify(on bool) osc.Message {
+	return osc.Message{Address: "/notify", Arguments: osc.Arguments{boolInt(on)}}
+}
+
+// Status queries the status of the server, which replies with /status.reply.
+func Status() osc.Message {
+	return osc.Message{Address: "/status"}
+}
+
+// DumpOSC makes the server print incoming messages, /dumpOSC.
+// code is 0 to turn printing off, 1 to print parsed messages,
+// 2 to print hex dumps and 3 for both.
+func DumpOSC(code int32) osc.Message {
+	return osc.Message{Address: "/dumpOSC", Arguments: osc.Arguments{osc.Int(code)}}
+}
+
+// Sync asks the server to reply with /synced id when every asynchronous
+// command it received before has completed, /sync.
+func Sync(id int32) osc.Message {
+	return osc.Message{Address: "/sync", Arguments: osc.Arguments{osc.Int(id)}}
+}
+
+// ClearSched removes every bundle from the server's scheduling queue, /clearSched.
+func ClearSched() osc.Message {
+	return osc.Message{Address: "/clearSched"}
+}
+
+// Version queries the version of the server, which replies with /version.reply.
+func Version() osc.Message {
+	return osc.Message{Address: "/version"}
+}
+
+// DRecv sends the data of a synth definition file to the server, /d_recv.
+func DRecv(data []byte, completion osc.Packet) osc.Message {
+	return osc.Message{
+		Address:   "/d_recv",
+		Arguments: appendCompletion(osc.Arguments{osc.Blob(data)}, completion),
+	}
+}
+
+// DLoad loads the synth definitions in the files that match a path pattern, /d_load.
+func DLoad(path string, completion osc.Packet) osc.Message {
+	return osc.Message{
+		Address:   "/d_load",
+		Arguments: appendCompletion(osc.Arguments{osc.String(path)}, completion),
+	}
+}
+
+// DLoadDir loads every synth definition file in a directory, /d_loadDir.
+func DLoadDir(dir string, completion osc.Packet) osc.Message {
+	return osc.Message{
+		Address:   "/d_loadDir",
+		Arguments: appendCompletion(osc.Arguments{osc.String(dir)}, completion),
+	}
+}
+
+// DFree frees synth definitions, /d_free.
+func DFree(names ...string) osc.Message {
+	args := make(osc.Arguments, len(names))
+	for i, name := range names {
+		args[i] = osc.String(name)
+	}
+	return osc.Message{Address: "/d_free", Arguments: args}
+}
+
+// NFree frees nodes, /n_free.
+func NFree(ids ...int32) osc.Message {
+	return osc.Message{Address: "/n_free", Arguments: ints(ids)}
+}
+
+// NRun turns a node on or off, /n_run.
+func NRun(id int32, run bool) osc.Message {
+	return osc.Message{Address: "/n_run", Arguments: osc.Arguments{osc.Int(id), boolInt(run)}}
+}
+
+// NSet sets controls of a node, /n_set.
+// Setting the controls of a group sets them for every synth in it.
+func NSet(id int32, controls ...Control) osc.Message {
+	return osc.Message{Address: "/n_set", Arguments: appendControls(osc.Arguments{osc.Int(id)}, controls)}
+}
+
+// NQuery queries nodes, the server replies with /n_info for each of them.
+func NQuery(ids ...int32) osc.Message {
+	return osc.Message{Address: "/n_query", Arguments: ints(ids)}
+}
+
+// SNew creates a synth from the synth definition defName, /s_new.
+// Use AutoNodeID to let the server pick the ID.
+func SNew(defName string, id int32, action AddAction, target int32, controls ...Control) osc.Message {
+	args := osc.Arguments{osc.String(defName), osc.Int(id), osc.Int(action), osc.Int(target)}
+	return osc.Message{Address: "/s_new", Arguments: appendControls(args, controls)}
+}
+
+// GNew creates a group, /g_new.
+func GNew(id int32, action AddAction, target int32) osc.Message {
+	return osc.Message{Address: "/g_new", Arguments: osc.Arguments{osc.Int(id), osc.Int(action), osc.Int(target)}}
+}
+
+// PNew creates a parallel group, /p_new.
+func PNew(id int32, action AddAction, target int32) osc.Message {
+	return osc.Message{Address: "/p_new", Arguments: osc.Arguments{osc.Int(id), osc.Int(action), osc.Int(target)}}
+}
+
+// GHead moves a node to the head of a group, /g_head.
+func GHead(group, node int32) osc.Message {
+	return osc.Message{Address: "/g_head", Arguments: osc.Arguments{osc.Int(group), osc.Int(node)}}
+}
+
+// GTail moves a node to the tail of a group, /g_tail.
+func GTail(group, node int32) osc.Message {
+	return osc.Message{Address: "/g_tail", Arguments: osc.Arguments{osc.Int(group), osc.Int(node)}}
+}
+
+// GFreeAll frees every node in groups, /g_freeAll.
+func GFreeAll(groups ...int32) osc.Message {
+	return osc.Message{Address: "/g_freeAll", Arguments: ints(groups)}
+}
+
+// GDeepFree frees every synth in groups and their sub-groups, /g_deepFree.
+func GDeepFree(groups ...int32) osc.Message {
+	return osc.Message{Address: "/g_deepFree", Arguments: ints(groups)}
+}
+
+// GQueryTree queries the tree of nodes in a group,
+// the server replies with /g_queryTree.reply.
+// With controls set the reply includes the controls of every synth.
+func GQueryTree(group int32, controls bool) osc.Message {
+	return osc.Message{Address: "/g_queryTree", Arguments: osc.Arguments{osc.Int(group), boolInt(controls)}}
+}
+
+// BAlloc allocates a buffer, /b_alloc.
+func BAlloc(bufnum, frames, channels int32, completion osc.Packet) osc.Message {
+	args := osc.Arguments{osc.Int(bufnum), osc.Int(frames), osc.Int(channels)}
+	return osc.Message{Address: "/b_alloc", Arguments: appendCompletion(args, completion)}
+}
+
+// BAllocRead allocates a buffer and reads a sound file into it, /b_allocRead.
+// numFrames is -1 or 0 to read the whole file.
+func BAllocRead(bufnum int32, path string, startFrame, numFrames int32, completion osc.Packet) osc.Message {
+	args := osc.Arguments{osc.Int(bufnum), osc.String(path), osc.Int(startFrame), osc.Int(numFrames)}
+	return osc.Message{Address: "/b_allocRead", Arguments: appendCompletion(args, completion)}
+}
+
+// BRead reads a sound file into an allocated buffer, /b_read.
+// numFrames is -1 to read the whole file. With leaveOpen set,
+// the file is left open for use with the DiskIn UGen.
+func BRead(bufnum int32, path string, fileStartFrame, numFrames, bufStartFrame int32, leaveOpen bool, completion osc.Packet) osc.Message {
+	args := osc.Arguments{
+		osc.Int(bufnum),
+		osc.String(path),
+		osc.Int(fileStartFrame),
+		osc.Int(numFrames),
+		osc.Int(bufStartFrame),
+		boolInt(leaveOpen),
+	}
+	return osc.Message{Address: "/b_read", Arguments: appendCompletion(args, completion)}
+}
+
+// BWrite writes a buffer to a sound file, /b_write.
+// headerFormat is a file type like "aiff" or "wav", and sampleFormat
+// a sample type like "int16" or "float". numFrames is -1 to write the whole buffer.
+// With leaveOpen set, the file is left open for use with the DiskOut UGen.
+func BWrite(bufnum int32, path, headerFormat, sampleFormat string, numFrames, startFrame int32, leaveOpen bool, completion osc.Packet) osc.Message {
+	args := osc.Arguments{
+		osc.Int(bufnum),
+		osc.String(path),
+		osc.String(headerFormat),
+		osc.String(sampleFormat),
+		osc.Int(numFrames),
+		osc.Int(startFrame),
+		boolInt(leaveOpen),
+	}
+	return osc.Message{Address: "/b_write", Arguments: appendCompletion(args, completion)}
+}
+
+// BFree frees a buffer, /b_free.
+func BFree(bufnum int32, completion osc.Packet) osc.Message {
+	return osc.Message{Address: "/b_free", Arguments: appendCompletion(osc.Arguments{osc.Int(bufnum)}, completion)}
+}
+
+// BZero sets every sample of a buffer to zero, /b_zero.
+func BZero(bufnum int32, completion osc.Packet) osc.Message {
+	return osc.Message{Address: "/b_zero", Arguments: appendCompletion(osc.Arguments{osc.Int(bufnum)}, completion)}
+}
+
+// BQuery queries buffers, the server replies with /b_info.
+func BQuery(bufnums ...int32) osc.Message {
+	return osc.Message{Address: "/b_query", Arguments: ints(bufnums)}
+}
+
+// CSet sets a control bus to a value, /c_set.
+func CSet(index int32, value float32) osc.Message {
+	return osc.Message{Address: "/c_set", Arguments: osc.Arguments{osc.Int(index), osc.Float(value)}}
+}
+
+// CGet queries the values of control buses, the server replies with /c_set.
+func CGet(indexes ...int32) osc.Message {
+	return osc.Message{Address: "/c_get", Arguments: ints(indexes)}
+}
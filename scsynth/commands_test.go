@@ -0,0 +1,112 @@
+package scsynth
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/scgolang/osc"
+)
+
+// The golden byte sequences are written as groups of 4 bytes,
+// the address, then the typetags, then one group per argument.
+func TestCommandsGolden(t *testing.T) {
+	for i, testcase := range []struct {
+		Message  osc.Message
+		Expected string
+	}{
+		{
+			Message:  Quit(),
+			Expected: "2f717569 74000000 2c000000",
+		},
+		{
+			Message:  Status(),
+			Expected: "2f737461 74757300 2c000000",
+		},
+		{
+			Message:  Notify(true),
+			Expected: "2f6e6f74 69667900 2c690000 00000001",
+		},
+		{
+			Message:  Sync(42),
+			Expected: "2f73796e 63000000 2c690000 0000002a",
+		},
+		{
+			Message: SNew("default", AutoNodeID, AddToHead, DefaultGroup, Control{Name: "freq", Value: 440}, Control{Index: 3, Value: 0.5}),
+			Expected: "2f735f6e 65770000 2c736969 69736669 66000000" +
+				" 64656661 756c7400 ffffffff 00000000 00000001" +
+				" 66726571 00000000 43dc0000 00000003 3f000000",
+		},
+		{
+			Message:  NSet(1000, Control{Name: "gate", Value: 0}),
+			Expected: "2f6e5f73 65740000 2c697366 00000000 000003e8 67617465 00000000 00000000",
+		},
+		{
+			Message:  NFree(1000, 1001),
+			Expected: "2f6e5f66 72656500 2c696900 000003e8 000003e9",
+		},
+		{
+			Message:  GNew(2, AddToTail, RootNode),
+			Expected: "2f675f6e 65770000 2c696969 00000000 00000002 00000001 00000000",
+		},
+		{
+			Message: BAlloc(0, 44100, 2, Sync(1)),
+			Expected: "2f625f61 6c6c6f63 00000000 2c696969 62000000 00000000 0000ac44 00000002" +
+				" 00000010 2f73796e 63000000 2c690000 00000001",
+		},
+		{
+			Message:  BRead(1, "a.wav", 0, -1, 0, false, nil),
+			Expected: "2f625f72 65616400 2c697369 69696900 00000001 612e7761 76000000 00000000 ffffffff 00000000 00000000",
+		},
+		{
+			Message:  DRecv([]byte{1, 2, 3}, nil),
+			Expected: "2f645f72 65637600 2c620000 00000003 01020300",
+		},
+		{
+			Message:  CSet(5, 0.25),
+			Expected: "2f635f73 65740000 2c696600 00000005 3e800000",
+		},
+	} {
+		expected, err := hex.DecodeString(strings.ReplaceAll(testcase.Expected, " ", ""))
+		if err != nil {
+			t.Fatalf("(testcase %d) %s", i, err)
+		}
+		if got := testcase.Message.Bytes(); !bytes.Equal(expected, got) {
+			t.Fatalf("(testcase %d) %s: expected\n%s\ngot\n%s", i, testcase.Message.Address, hex.Dump(expected), hex.Dump(got))
+		}
+	}
+}
+
+func TestCommands(t *testing.T) {
+	for i, testcase := range []struct {
+		Message  osc.Message
+		Expected string
+	}{
+		{Message: Notify(false), Expected: "/notify ,i 0"},
+		{Message: DumpOSC(1), Expected: "/dumpOSC ,i 1"},
+		{Message: ClearSched(), Expected: "/clearSched"},
+		{Message: Version(), Expected: "/version"},
+		{Message: DLoad("/synthdefs/*.scsyndef", nil), Expected: `/d_load ,s "/synthdefs/*.scsyndef"`},
+		{Message: DLoadDir("/synthdefs", Sync(1)), Expected: `/d_loadDir ,sb "/synthdefs" b"L3N5bmMAAAAsaQAAAAAAAQ=="`},
+		{Message: DFree("sine", "saw"), Expected: `/d_free ,ss "sine" "saw"`},
+		{Message: NRun(1000, false), Expected: "/n_run ,ii 1000 0"},
+		{Message: NQuery(1000), Expected: "/n_query ,i 1000"},
+		{Message: PNew(3, AddAfter, 2), Expected: "/p_new ,iii 3 3 2"},
+		{Message: GHead(1, 1000), Expected: "/g_head ,ii 1 1000"},
+		{Message: GTail(1, 1000), Expected: "/g_tail ,ii 1 1000"},
+		{Message: GFreeAll(1), Expected: "/g_freeAll ,i 1"},
+		{Message: GDeepFree(1, 2), Expected: "/g_deepFree ,ii 1 2"},
+		{Message: GQueryTree(RootNode, true), Expected: "/g_queryTree ,ii 0 1"},
+		{Message: BAllocRead(2, "a.wav", 0, -1, nil), Expected: `/b_allocRead ,isii 2 "a.wav" 0 -1`},
+		{Message: BWrite(2, "b.wav", "wav", "int16", -1, 0, true, nil), Expected: `/b_write ,isssiii 2 "b.wav" "wav" "int16" -1 0 1`},
+		{Message: BFree(2, nil), Expected: "/b_free ,i 2"},
+		{Message: BZero(2, nil), Expected: "/b_zero ,i 2"},
+		{Message: BQuery(1, 2), Expected: "/b_query ,ii 1 2"},
+		{Message: CGet(0, 1), Expected: "/c_get ,ii 0 1"},
+	} {
+		if expected, got := testcase.Expected, osc.Format(testcase.Message); expected != got {
+			t.Fatalf("(testcase %d) expected %s, got %s", i, expected, got)
+		}
+	}
+}
@@ -0,0 +1,296 @@
+package scsynth
+
+import (
+	"github.com/pkg/errors"
+	"github.com/scgolang/osc"
+)
+
+// Common errors.
+var (
+	ErrInvalidReply = errors.New("invalid reply")
+)
+
+// StatusReply is the reply to /status, /status.reply.
+type StatusReply struct {
+	UGens             int32
+	Synths            int32
+	Groups            int32
+	SynthDefs         int32
+	AvgCPU            float32
+	PeakCPU           float32
+	NominalSampleRate float64
+	ActualSampleRate  float64
+}
+
+// VersionReply is the reply to /version, /version.reply.
+type VersionReply struct {
+	Program string
+	Major   int32
+	Minor   int32
+	Patch   string
+	Branch  string
+	Commit  string
+}
+
+// NodeEvent is a notification about a node, like /n_go or /n_end,
+// or the reply to /n_query, /n_info.
+type NodeEvent struct {
+	// Command is the address of the notification, e.g. /n_go.
+	Command string
+	ID      int32
+	Parent  int32
+	Prev    int32 // -1 if there is no previous node.
+	Next    int32 // -1 if there is no next node.
+	IsGroup bool
+	Head    int32 // Only set for groups, -1 for empty groups.
+	Tail    int32 // Only set for groups, -1 for empty groups.
+}
+
+// nodeCommands are the addresses of node notifications.
+var nodeCommands = map[string]bool{
+	"/n_go":   true,
+	"/n_end":  true,
+	"/n_off":  true,
+	"/n_on":   true,
+	"/n_move": true,
+	"/n_info": true,
+}
+
+// BufferInfo is the reply to /b_query, /b_info.
+type BufferInfo struct {
+	Bufnum     int32
+	Frames     int32
+	Channels   int32
+	SampleRate float32
+}
+
+// Trigger is sent by the SendTrig UGen, /tr.
+type Trigger struct {
+	Node  int32
+	ID    int32
+	Value float32
+}
+
+// Done is the completion of an asynchronous command, /done.
+type Done struct {
+	// Command is the address of the command, e.g. /b_alloc.
+	Command string
+
+	// Arguments are the other arguments of the reply, e.g. the buffer number.
+	Arguments osc.Arguments
+}
+
+// Error is the failure of a command, /fail.
+type Error struct {
+	// Command is the address of the command, e.g. /b_alloc.
+	Command string
+
+	// Message is the error message of the server.
+	Message string
+
+	// Arguments are the other arguments of the reply, e.g. the buffer number.
+	Arguments osc.Arguments
+}
+
+// Error returns the command and the error message of the server.
+func (e *Error) Error() string {
+	return e.Command + " failed: " + e.Message
+}
+
+// ParseStatusReply parses a /status.reply message.
+func ParseStatusReply(msg osc.Message) (StatusReply, error) {
+	var (
+		r     = newArgReader(msg, "/status.reply")
+		_     = r.ReadInt32() // Unused.
+		reply = StatusReply{
+			UGens:             r.ReadInt32(),
+			Synths:            r.ReadInt32(),
+			Groups:            r.ReadInt32(),
+			SynthDefs:         r.ReadInt32(),
+			AvgCPU:            r.ReadFloat32(),
+			PeakCPU:           r.ReadFloat32(),
+			NominalSampleRate: r.ReadFloat64(),
+			ActualSampleRate:  r.ReadFloat64(),
+		}
+	)
+	return reply, r.Err()
+}
+
+// ParseVersionReply parses a /version.reply message.
+func ParseVersionReply(msg osc.Message) (VersionReply, error) {
+	var (
+		r     = newArgReader(msg, "/version.reply")
+		reply = VersionReply{
+			Program: r.ReadString(),
+			Major:   r.ReadInt32(),
+			Minor:   r.ReadInt32(),
+			Patch:   r.ReadString(),
+			Branch:  r.ReadString(),
+			Commit:  r.ReadString(),
+		}
+	)
+	return reply, r.Err()
+}
+
+// ParseNodeEvent parses a node notification, one of /n_go, /n_end, /n_off,
+// /n_on, /n_move and /n_info.
+func ParseNodeEvent(msg osc.Message) (NodeEvent, error) {
+	if !nodeCommands[msg.Address] {
+		return NodeEvent{}, errors.Wrapf(ErrInvalidReply, "%s is not a node notification", msg.Address)
+	}
+	var (
+		r  = newArgReader(msg, msg.Address)
+		ev = NodeEvent{
+			Command: msg.Address,
+			ID:      r.ReadInt32(),
+			Parent:  r.ReadInt32(),
+			Prev:    r.ReadInt32(),
+			Next:    r.ReadInt32(),
+			IsGroup: r.ReadInt32() == 1,
+		}
+	)
+	if ev.IsGroup {
+		ev.Head, ev.Tail = r.ReadInt32(), r.ReadInt32()
+	}
+	return ev, r.Err()
+}
+
+// ParseBufferInfo parses a /b_info message, which has the information
+// of every buffer that was queried.
+func ParseBufferInfo(msg osc.Message) ([]BufferInfo, error) {
+	if len(msg.Arguments)%4 != 0 {
+		return nil, errors.Wrapf(ErrInvalidReply, "/b_info with %d arguments", len(msg.Arguments))
+	}
+	var (
+		r     = newArgReader(msg, "/b_info")
+		infos = make([]BufferInfo, 0, len(msg.Arguments)/4)
+	)
+	for r.More() {
+		infos = append(infos, BufferInfo{
+			Bufnum:     r.ReadInt32(),
+			Frames:     r.ReadInt32(),
+			Channels:   r.ReadInt32(),
+			SampleRate: r.ReadFloat32(),
+		})
+	}
+	return infos, r.Err()
+}
+
+// ParseTrigger parses a /tr message.
+func ParseTrigger(msg osc.Message) (Trigger, error) {
+	var (
+		r  = newArgReader(msg, "/tr")
+		tr = Trigger{Node: r.ReadInt32(), ID: r.ReadInt32(), Value: r.ReadFloat32()}
+	)
+	return tr, r.Err()
+}
+
+// ParseSynced parses a /synced message and returns the ID that was sent with /sync.
+func ParseSynced(msg osc.Message) (int32, error) {
+	var (
+		r  = newArgReader(msg, "/synced")
+		id = r.ReadInt32()
+	)
+	return id, r.Err()
+}
+
+// ParseDone parses a /done message.
+func ParseDone(msg osc.Message) (Done, error) {
+	var (
+		r    = newArgReader(msg, "/done")
+		done = Done{Command: r.ReadString()}
+	)
+	if err := r.Err(); err != nil {
+		return Done{}, err
+	}
+	done.Arguments = msg.Arguments[1:]
+	return done, nil
+}
+
+// ParseFail parses a /fail message.
+func ParseFail(msg osc.Message) (*Error, error) {
+	var (
+		r = newArgReader(msg, "/fail")
+		e = &Error{Command: r.ReadString(), Message: r.ReadString()}
+	)
+	if err := r.Err(); err != nil {
+		return nil, err
+	}
+	e.Arguments = msg.Arguments[2:]
+	return e, nil
+}
+
+// MatchCompletion returns a function for osc.Client.Call that matches the /done
+// or the /fail reply to an asynchronous command. The arguments of the reply
+// that follow the command, and the error message for /fail, must start with args:
+//
+//	reply, err := client.Call(ctx, scsynth.BAlloc(3, 1024, 1, nil), scsynth.MatchCompletion("/b_alloc", osc.Int(3)))
+//	if err == nil {
+//		err = scsynth.CompletionError(reply)
+//	}
+//
+// The server doesn't always send the arguments of the command with /fail,
+// and such a /fail matches every call to the command, whatever its args.
+// If several calls to the same command are outstanding, osc.Client passes it
+// to the one that was made first, which is the one it answers as long as
+// the server completes the commands in the order they were sent.
+// Wait for each call to return before making the next one if that matters.
+func MatchCompletion(command string, args ...osc.Argument) func(osc.Message) bool {
+	return func(msg osc.Message) bool {
+		var rest osc.Arguments
+
+		switch msg.Address {
+		case "/done":
+			d, err := ParseDone(msg)
+			if err != nil || d.Command != command {
+				return false
+			}
+			rest = d.Arguments
+		case "/fail":
+			e, err := ParseFail(msg)
+			if err != nil || e.Command != command {
+				return false
+			}
+			// Failures don't always include the arguments of the command,
+			// see the documentation above.
+			if len(e.Arguments) == 0 {
+				return true
+			}
+			rest = e.Arguments
+		default:
+			return false
+		}
+		if len(rest) < len(args) {
+			return false
+		}
+		for i, arg := range args {
+			if !arg.Equal(rest[i]) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// CompletionError returns the *Error of a /fail message,
+// and nil for any other message.
+func CompletionError(msg osc.Message) error {
+	if msg.Address != "/fail" {
+		return nil
+	}
+	e, err := ParseFail(msg)
+	if err != nil {
+		return err
+	}
+	return e
+}
+
+// newArgReader returns a reader for the arguments of a reply that should have the address command.
+// Its errors wrap ErrInvalidReply.
+func newArgReader(msg osc.Message, command string) *ArgReader {
+	r := &ArgReader{msg: msg, cause: ErrInvalidReply}
+	if msg.Address != command {
+		r.err = errors.Wrapf(ErrInvalidReply, "expected %s, got %s", command, msg.Address)
+	}
+	return r
+}
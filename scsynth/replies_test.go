@@ -0,0 +1,243 @@
+package scsynth
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/scgolang/osc"
+)
+
+func TestParseStatusReply(t *testing.T) {
+	msg := osc.Message{
+		Address: "/status.reply",
+		Arguments: osc.Arguments{
+			osc.Int(1),
+			osc.Int(12),
+			osc.Int(3),
+			osc.Int(2),
+			osc.Int(40),
+			osc.Float(1.5),
+			osc.Float(4.25),
+			osc.Double(48000),
+			osc.Double(47999.5),
+		},
+	}
+	expected := StatusReply{
+		UGens:             12,
+		Synths:            3,
+		Groups:            2,
+		SynthDefs:         40,
+		AvgCPU:            1.5,
+		PeakCPU:           4.25,
+		NominalSampleRate: 48000,
+		ActualSampleRate:  47999.5,
+	}
+	got, err := ParseStatusReply(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected != got {
+		t.Fatalf("expected %+v, got %+v", expected, got)
+	}
+
+	// Some servers send the sample rates as floats.
+	msg.Arguments[7], msg.Arguments[8] = osc.Float(48000), osc.Float(47999.5)
+
+	got, err = ParseStatusReply(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected != got {
+		t.Fatalf("expected %+v, got %+v", expected, got)
+	}
+}
+
+func TestParseVersionReply(t *testing.T) {
+	msg, err := osc.ParseText(`/version.reply ,siisss "scsynth" 3 13 ".0" "Version-3.13.0" "3188503"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := VersionReply{Program: "scsynth", Major: 3, Minor: 13, Patch: ".0", Branch: "Version-3.13.0", Commit: "3188503"}
+
+	got, err := ParseVersionReply(msg.(osc.Message))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected != got {
+		t.Fatalf("expected %+v, got %+v", expected, got)
+	}
+}
+
+func TestParseNodeEvent(t *testing.T) {
+	for i, testcase := range []struct {
+		Input    string
+		Expected NodeEvent
+	}{
+		{
+			Input:    `/n_go ,iiiii 1000 1 -1 1001 0`,
+			Expected: NodeEvent{Command: "/n_go", ID: 1000, Parent: 1, Prev: -1, Next: 1001},
+		},
+		{
+			Input:    `/n_end ,iiiii 1000 1 -1 -1 0`,
+			Expected: NodeEvent{Command: "/n_end", ID: 1000, Parent: 1, Prev: -1, Next: -1},
+		},
+		{
+			Input:    `/n_info ,iiiiiii 1 0 -1 -1 1 1000 1001`,
+			Expected: NodeEvent{Command: "/n_info", ID: 1, Parent: 0, Prev: -1, Next: -1, IsGroup: true, Head: 1000, Tail: 1001},
+		},
+		{
+			Input:    `/n_move ,iiiiiii 2 1 1000 -1 1 -1 -1`,
+			Expected: NodeEvent{Command: "/n_move", ID: 2, Parent: 1, Prev: 1000, Next: -1, IsGroup: true, Head: -1, Tail: -1},
+		},
+	} {
+		msg, err := osc.ParseText(testcase.Input)
+		if err != nil {
+			t.Fatalf("(testcase %d) %s", i, err)
+		}
+		got, err := ParseNodeEvent(msg.(osc.Message))
+		if err != nil {
+			t.Fatalf("(testcase %d) %s", i, err)
+		}
+		if expected := testcase.Expected; expected != got {
+			t.Fatalf("(testcase %d) expected %+v, got %+v", i, expected, got)
+		}
+	}
+}
+
+func TestParseBufferInfo(t *testing.T) {
+	msg, err := osc.ParseText(`/b_info ,iiifiiif 0 44100 2 44100.0 1 1024 1 48000.0`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []BufferInfo{
+		{Bufnum: 0, Frames: 44100, Channels: 2, SampleRate: 44100},
+		{Bufnum: 1, Frames: 1024, Channels: 1, SampleRate: 48000},
+	}
+	got, err := ParseBufferInfo(msg.(osc.Message))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(expected, got) {
+		t.Fatalf("expected %+v, got %+v", expected, got)
+	}
+}
+
+func TestParseDoneFail(t *testing.T) {
+	done, err := ParseDone(osc.Message{Address: "/done", Arguments: osc.Arguments{osc.String("/b_alloc"), osc.Int(3)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := "/b_alloc", done.Command; expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+	if expected, got := (osc.Arguments{osc.Int(3)}), done.Arguments; !reflect.DeepEqual(expected, got) {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+	e, err := ParseFail(osc.Message{Address: "/fail", Arguments: osc.Arguments{osc.String("/s_new"), osc.String("SynthDef not found")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := "/s_new failed: SynthDef not found", e.Error(); expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for i, testcase := range []struct {
+		Parse func(osc.Message) error
+		Input string
+		Err   string
+	}{
+		{
+			Parse: func(msg osc.Message) error { _, err := ParseStatusReply(msg); return err },
+			Input: `/status ,i 1`,
+			Err:   `expected /status.reply, got /status: invalid reply`,
+		},
+		{
+			Parse: func(msg osc.Message) error { _, err := ParseStatusReply(msg); return err },
+			Input: `/status.reply ,iiiii 1 0 0 0 0`,
+			Err:   `/status.reply: missing argument 5: invalid reply`,
+		},
+		{
+			Parse: func(msg osc.Message) error { _, err := ParseNodeEvent(msg); return err },
+			Input: `/n_go ,iiiis 1000 1 -1 -1 "no"`,
+			Err:   `/n_go: argument 4 should be an int, got "no": invalid reply`,
+		},
+		{
+			Parse: func(msg osc.Message) error { _, err := ParseNodeEvent(msg); return err },
+			Input: `/n_free ,i 1000`,
+			Err:   `/n_free is not a node notification: invalid reply`,
+		},
+		{
+			Parse: func(msg osc.Message) error { _, err := ParseBufferInfo(msg); return err },
+			Input: `/b_info ,iii 0 1 2`,
+			Err:   `/b_info with 3 arguments: invalid reply`,
+		},
+		{
+			Parse: func(msg osc.Message) error { _, err := ParseFail(msg); return err },
+			Input: `/fail ,s "/b_alloc"`,
+			Err:   `/fail: missing argument 1: invalid reply`,
+		},
+	} {
+		msg, err := osc.ParseText(testcase.Input)
+		if err != nil {
+			t.Fatalf("(testcase %d) %s", i, err)
+		}
+		err = testcase.Parse(msg.(osc.Message))
+		if err == nil {
+			t.Fatalf("(testcase %d) expected error, got nil", i)
+		}
+		if expected, got := testcase.Err, err.Error(); expected != got {
+			t.Fatalf("(testcase %d) expected %s, got %s", i, expected, got)
+		}
+		if errors.Cause(err) != ErrInvalidReply {
+			t.Fatalf("(testcase %d) expected ErrInvalidReply, got %s", i, err)
+		}
+	}
+}
+
+func TestMatchCompletion(t *testing.T) {
+	match := MatchCompletion("/b_alloc", osc.Int(3))
+
+	for i, testcase := range []struct {
+		Input    string
+		Expected bool
+		Err      string
+	}{
+		{Input: `/done ,si "/b_alloc" 3`, Expected: true},
+		{Input: `/done ,si "/b_alloc" 4`, Expected: false},
+		{Input: `/done ,s "/b_alloc"`, Expected: false},
+		{Input: `/done ,si "/b_free" 3`, Expected: false},
+		{Input: `/fail ,ssi "/b_alloc" "out of memory" 3`, Expected: true, Err: "/b_alloc failed: out of memory"},
+		{Input: `/fail ,ssi "/b_alloc" "out of memory" 4`, Expected: false},
+		{Input: `/fail ,ss "/b_alloc" "out of memory"`, Expected: true, Err: "/b_alloc failed: out of memory"},
+		{Input: `/synced ,i 3`, Expected: false},
+	} {
+		p, err := osc.ParseText(testcase.Input)
+		if err != nil {
+			t.Fatalf("(testcase %d) %s", i, err)
+		}
+		msg := p.(osc.Message)
+
+		if expected, got := testcase.Expected, match(msg); expected != got {
+			t.Fatalf("(testcase %d) expected %t, got %t", i, expected, got)
+		}
+		if !testcase.Expected {
+			continue
+		}
+		err = CompletionError(msg)
+		if testcase.Err == "" {
+			if err != nil {
+				t.Fatalf("(testcase %d) expected nil, got %s", i, err)
+			}
+			continue
+		}
+		if _, ok := err.(*Error); !ok {
+			t.Fatalf("(testcase %d) expected *Error, got %T", i, err)
+		}
+		if expected, got := testcase.Err, err.Error(); expected != got {
+			t.Fatalf("(testcase %d) expected %s, got %s", i, expected, got)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package scsynth
+
+import (
+	"github.com/scgolang/osc"
+)
+
+// Router is an osc.Dispatcher that parses the replies and notifications of the server
+// and sends them to channels. Replies are dropped if their channel is nil,
+// and so are messages that have no channel, like /c_set.
+//
+// Sends block until the value is received, so the channels should be
+// buffered or drained by another goroutine:
+//
+//	router := &scsynth.Router{Nodes: make(chan scsynth.NodeEvent, 64)}
+//	client.SetDispatcher(router)
+//
+// Invoke returns the error of replies that can not be parsed.
+type Router struct {
+	Status   chan StatusReply
+	Version  chan VersionReply
+	Nodes    chan NodeEvent
+	Buffers  chan BufferInfo
+	Triggers chan Trigger
+	Synced   chan int32
+	Done     chan Done
+	Fail     chan *Error
+}
+
+// Dispatch invokes every message in the bundle, immediately.
+func (r *Router) Dispatch(b osc.Bundle, exactMatch bool) error {
+	for _, p := range b.Packets {
+		var err error
+
+		switch x := p.(type) {
+		case osc.Message:
+			err = r.Invoke(x, exactMatch)
+		case osc.Bundle:
+			err = r.Dispatch(x, exactMatch)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Invoke parses the message and sends it to its channel.
+func (r *Router) Invoke(msg osc.Message, exactMatch bool) error {
+	switch msg.Address {
+	case "/status.reply":
+		if r.Status == nil {
+			return nil
+		}
+		reply, err := ParseStatusReply(msg)
+		if err != nil {
+			return err
+		}
+		r.Status <- reply
+	case "/version.reply":
+		if r.Version == nil {
+			return nil
+		}
+		reply, err := ParseVersionReply(msg)
+		if err != nil {
+			return err
+		}
+		r.Version <- reply
+	case "/n_go", "/n_end", "/n_off", "/n_on", "/n_move", "/n_info":
+		if r.Nodes == nil {
+			return nil
+		}
+		ev, err := ParseNodeEvent(msg)
+		if err != nil {
+			return err
+		}
+		r.Nodes <- ev
+	case "/b_info":
+		if r.Buffers == nil {
+			return nil
+		}
+		infos, err := ParseBufferInfo(msg)
+		if err != nil {
+			return err
+		}
+		for _, info := range infos {
+			r.Buffers <- info
+		}
+	case "/tr":
+		if r.Triggers == nil {
+			return nil
+		}
+		tr, err := ParseTrigger(msg)
+		if err != nil {
+			return err
+		}
+		r.Triggers <- tr
+	case "/synced":
+		if r.Synced == nil {
+			return nil
+		}
+		id, err := ParseSynced(msg)
+		if err != nil {
+			return err
+		}
+		r.Synced <- id
+	case "/done":
+		if r.Done == nil {
+			return nil
+		}
+		done, err := ParseDone(msg)
+		if err != nil {
+			return err
+		}
+		r.Done <- done
+	case "/fail":
+		if r.Fail == nil {
+			return nil
+		}
+		e, err := ParseFail(msg)
+		if err != nil {
+			return err
+		}
+		r.Fail <- e
+	}
+	return nil
+}
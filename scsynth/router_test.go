@@ -0,0 +1,181 @@
+package scsynth
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/scgolang/osc"
+)
+
+func TestRouter(t *testing.T) {
+	r := &Router{
+		Status:  make(chan StatusReply, 1),
+		Nodes:   make(chan NodeEvent, 2),
+		Buffers: make(chan BufferInfo, 2),
+		Synced:  make(chan int32, 1),
+		Done:    make(chan Done, 1),
+		Fail:    make(chan *Error, 1),
+	}
+	p, err := osc.ParseText(`#bundle immediately [
+		/n_go ,iiiii 1000 1 -1 -1 0
+		/n_end ,iiiii 1000 1 -1 -1 0
+		/b_info ,iiifiiif 0 8 1 48000.0 1 16 2 48000.0
+		/synced ,i 7
+		/done ,s "/d_recv"
+		/fail ,ss "/s_new" "SynthDef not found"
+		/tr ,iif 1000 0 0.5
+		/c_set ,if 0 0.5
+	]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Dispatch(p.(osc.Bundle), false); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{"/n_go", "/n_end"} {
+		if got := (<-r.Nodes).Command; expected != got {
+			t.Fatalf("expected %s, got %s", expected, got)
+		}
+	}
+	for _, expected := range []int32{8, 16} {
+		if got := (<-r.Buffers).Frames; expected != got {
+			t.Fatalf("expected %d, got %d", expected, got)
+		}
+	}
+	if expected, got := int32(7), <-r.Synced; expected != got {
+		t.Fatalf("expected %d, got %d", expected, got)
+	}
+	if expected, got := "/d_recv", (<-r.Done).Command; expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+	if expected, got := "/s_new failed: SynthDef not found", (<-r.Fail).Error(); expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+	if err := r.Invoke(osc.Message{Address: "/status.reply"}, false); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+}
+
+// TestCompletionClient checks that calls with MatchCompletion return
+// the /done or the /fail reply of a server.
+func TestCompletionClient(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = pc.Close() }()
+
+	go func() {
+		buf := make([]byte, 1024)
+		for {
+			n, addr, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			msg, err := osc.ParseMessage(buf[:n], addr)
+			if err != nil {
+				continue
+			}
+			reply := osc.Message{Address: "/done", Arguments: append(osc.Arguments{osc.String(msg.Address)}, msg.Arguments[0])}
+			if msg.Address == "/b_free" {
+				reply = osc.Message{Address: "/fail", Arguments: osc.Arguments{osc.String(msg.Address), osc.String("buffer not allocated")}}
+			}
+			_, _ = pc.WriteTo(reply.Bytes(), addr)
+		}
+	}()
+	conn, err := osc.DialUDP("udp", nil, pc.LocalAddr().(*net.UDPAddr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := osc.NewClient(conn)
+	defer func() { _ = c.Close() }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	reply, err := c.Call(ctx, BAlloc(3, 1024, 1, nil), MatchCompletion("/b_alloc", osc.Int(3)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := CompletionError(reply); err != nil {
+		t.Fatal(err)
+	}
+	reply, err = c.Call(ctx, BFree(3, nil), MatchCompletion("/b_free", osc.Int(3)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := "/b_free failed: buffer not allocated", CompletionError(reply); got == nil || expected != got.Error() {
+		t.Fatalf("expected %s, got %v", expected, got)
+	}
+}
+
+// TestCompletionClientAmbiguousFail checks that a /fail without the arguments
+// of the command answers the call that was made first.
+func TestCompletionClientAmbiguousFail(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = pc.Close() }()
+
+	received := make(chan struct{}, 2)
+	go func() {
+		buf := make([]byte, 1024)
+		for i := 0; i < 2; i++ {
+			n, addr, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			received <- struct{}{}
+
+			if i == 0 {
+				continue
+			}
+			msg, err := osc.ParseMessage(buf[:n], addr)
+			if err != nil {
+				return
+			}
+			for _, reply := range []osc.Message{
+				{Address: "/fail", Arguments: osc.Arguments{osc.String(msg.Address), osc.String("out of memory")}},
+				{Address: "/done", Arguments: osc.Arguments{osc.String(msg.Address), msg.Arguments[0]}},
+			} {
+				_, _ = pc.WriteTo(reply.Bytes(), addr)
+			}
+		}
+	}()
+	conn, err := osc.DialUDP("udp", nil, pc.LocalAddr().(*net.UDPAddr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := osc.NewClient(conn)
+	defer func() { _ = c.Close() }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	replies := make([]chan osc.Message, 2)
+	for i := range replies {
+		replies[i] = make(chan osc.Message, 1)
+
+		go func(i int) {
+			reply, err := c.Call(ctx, BAlloc(int32(3+i), 1024, 1, nil), MatchCompletion("/b_alloc", osc.Int(int32(3+i))))
+			if err != nil {
+				t.Error(err)
+			}
+			replies[i] <- reply
+		}(i)
+
+		select {
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout waiting for the server to receive the call")
+		case <-received:
+		}
+	}
+	for i, expected := range []string{`/fail ,ss "/b_alloc" "out of memory"`, `/done ,si "/b_alloc" 4`} {
+		if got := osc.Format(<-replies[i]); expected != got {
+			t.Fatalf("(call %d) expected %s, got %s", i, expected, got)
+		}
+	}
+}
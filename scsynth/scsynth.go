@@ -0,0 +1,102 @@
+// Package scsynth builds the messages of the SuperCollider server command reference,
+// see https://doc.sccode.org/Reference/Server-Command-Reference.html
+//
+// Every command has a constructor that takes typed parameters and returns
+// an osc.Message with the argument types that scsynth expects:
+//
+//	msg := scsynth.SNew("default", scsynth.AutoNodeID, scsynth.AddToHead, scsynth.DefaultGroup,
+//		scsynth.Control{Name: "freq", Value: 440},
+//	)
+//
+// Asynchronous commands, like /b_alloc and /d_recv, take a completion packet
+// that the server executes when the command is done. It can be nil.
+//
+// Replies and notifications of the server are parsed by functions like
+// ParseStatusReply and ParseNodeEvent, or routed to channels by a Router.
+// A /fail reply is an *Error.
+package scsynth
+
+import (
+	"github.com/scgolang/osc"
+)
+
+// Node IDs with a special meaning.
+const (
+	// AutoNodeID makes the server pick the ID of a new synth.
+	AutoNodeID int32 = -1
+
+	// RootNode is the ID of the root group.
+	RootNode int32 = 0
+
+	// DefaultGroup is the ID of the group that sclang creates
+	// in the root group and puts new nodes in by default.
+	DefaultGroup int32 = 1
+)
+
+// AddAction says where a new node is added, relative to its target.
+type AddAction int32
+
+// Add actions.
+const (
+	// AddToHead adds the node to the head of the target group.
+	AddToHead AddAction = iota
+
+	// AddToTail adds the node to the tail of the target group.
+	AddToTail
+
+	// AddBefore adds the node just before the target node.
+	AddBefore
+
+	// AddAfter adds the node just after the target node.
+	AddAfter
+
+	// AddReplace replaces the target node, which is freed.
+	AddReplace
+)
+
+// Control sets a control of a synth to a value.
+// The control is named by Name, or by its Index if Name is empty.
+type Control struct {
+	Name  string
+	Index int32
+	Value float32
+}
+
+// appendControls appends the name or index and the value of every control to args.
+func appendControls(args osc.Arguments, controls []Control) osc.Arguments {
+	for _, c := range controls {
+		if c.Name != "" {
+			args = append(args, osc.String(c.Name))
+		} else {
+			args = append(args, osc.Int(c.Index))
+		}
+		args = append(args, osc.Float(c.Value))
+	}
+	return args
+}
+
+// appendCompletion appends the completion packet of an asynchronous command to args,
+// if there is one.
+func appendCompletion(args osc.Arguments, completion osc.Packet) osc.Arguments {
+	if completion == nil {
+		return args
+	}
+	return append(args, osc.Blob(completion.Bytes()))
+}
+
+// boolInt returns 1 for true and 0 for false.
+func boolInt(b bool) osc.Int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// ints returns the values as Int arguments.
+func ints(values []int32) osc.Arguments {
+	args := make(osc.Arguments, len(values))
+	for i, v := range values {
+		args[i] = osc.Int(v)
+	}
+	return args
+}
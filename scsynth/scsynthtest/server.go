@@ -0,0 +1,414 @@
+// Package scsynthtest provides a fake SuperCollider server, for testing
+// clients of the scsynth package end to end without running scsynth.
+//
+// The server answers /status, /sync, /notify, /s_new, /g_new, /n_free,
+// /b_alloc and /d_recv with replies shaped like the ones of scsynth.
+// It keeps a tree of nodes, but does not make any sound:
+//
+//	server, err := scsynthtest.NewServer()
+//	if err != nil {
+//		t.Fatal(err)
+//	}
+//	defer server.Close()
+//
+//	server.AddSynthDef("default")
+//	conn, err := osc.DialUDP("udp", nil, server.Addr())
+package scsynthtest
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/scgolang/osc"
+	"github.com/scgolang/osc/scsynth"
+)
+
+// SampleRate is the sample rate that the server reports.
+const SampleRate = 48000
+
+// Server is a fake scsynth that listens on a local UDP port.
+type Server struct {
+	conn     *osc.UDPConn
+	serveErr chan error
+
+	mu         sync.Mutex
+	synthDefs  map[string]synthDef
+	nodes      map[int32]*node
+	buffers    map[int32]scsynth.BufferInfo
+	clients    map[string]client
+	nextID     int32
+	nextClient int32
+}
+
+// client is registered to receive notifications with /notify.
+type client struct {
+	addr net.Addr
+	id   int32
+}
+
+// NewServer starts a server on a free UDP port of the loopback interface.
+func NewServer() (*Server, error) {
+	conn, err := osc.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		return nil, errors.Wrap(err, "listen")
+	}
+	s := &Server{
+		conn:      conn,
+		serveErr:  make(chan error, 1),
+		synthDefs: map[string]synthDef{},
+		nodes:     map[int32]*node{scsynth.RootNode: {id: scsynth.RootNode, isGroup: true}},
+		buffers:   map[int32]scsynth.BufferInfo{},
+		clients:   map[string]client{},
+		nextID:    -2,
+	}
+	// A single worker handles the commands in the order they arrive, like scsynth.
+	go func() { s.serveErr <- conn.Serve(1, s.dispatcher()) }()
+
+	return s, nil
+}
+
+// Addr returns the address that the server listens on.
+func (s *Server) Addr() *net.UDPAddr {
+	return s.conn.LocalAddr().(*net.UDPAddr)
+}
+
+// Close stops the server. It returns the error that stopped it early, if there is one.
+func (s *Server) Close() error {
+	if err := s.conn.Close(); err != nil {
+		return err
+	}
+	return <-s.serveErr
+}
+
+// AddSynthDef adds synth definitions to the server, as if they were received with /d_recv.
+// They have no UGens.
+func (s *Server) AddSynthDef(names ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, name := range names {
+		s.synthDefs[name] = synthDef{Name: name}
+	}
+}
+
+// SynthDefs returns the sorted names of the synth definitions of the server.
+func (s *Server) SynthDefs() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	names := make([]string, 0, len(s.synthDefs))
+	for name := range s.synthDefs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Node returns a node of the server as the server would describe it in /n_info.
+func (s *Server) Node(id int32) (scsynth.NodeEvent, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n, ok := s.nodes[id]
+	if !ok {
+		return scsynth.NodeEvent{}, false
+	}
+	return n.event("/n_info"), true
+}
+
+// Buffer returns a buffer of the server.
+func (s *Server) Buffer(bufnum int32) (scsynth.BufferInfo, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	info, ok := s.buffers[bufnum]
+	return info, ok
+}
+
+// dispatcher returns the methods of the server.
+func (s *Server) dispatcher() osc.PatternMatching {
+	return osc.PatternMatching{
+		"/status":  osc.Method(s.status),
+		"/sync":    osc.Method(s.sync),
+		"/notify":  osc.Method(s.notify),
+		"/s_new":   osc.Method(s.sNew),
+		"/g_new":   osc.Method(s.gNew),
+		"/n_free":  osc.Method(s.nFree),
+		"/b_alloc": osc.Method(s.bAlloc),
+		"/d_recv":  osc.Method(s.dRecv),
+	}
+}
+
+// status replies with /status.reply.
+func (s *Server) status(msg osc.Message) error {
+	s.mu.Lock()
+	var ugens, synths, groups int32
+	for _, n := range s.nodes {
+		if n.isGroup {
+			groups++
+			continue
+		}
+		synths++
+		ugens += s.synthDefs[n.defName].NumUGens
+	}
+	numDefs := int32(len(s.synthDefs))
+	s.mu.Unlock()
+
+	return s.conn.SendTo(msg.Sender, osc.Message{
+		Address: "/status.reply",
+		Arguments: osc.Arguments{
+			osc.Int(1),
+			osc.Int(ugens),
+			osc.Int(synths),
+			osc.Int(groups),
+			osc.Int(numDefs),
+			osc.Float(0),
+			osc.Float(0),
+			osc.Double(SampleRate),
+			osc.Double(SampleRate),
+		},
+	})
+}
+
+// sync replies with /synced. Every command is done by the time it returns,
+// so there is nothing to wait for.
+func (s *Server) sync(msg osc.Message) error {
+	args := scsynth.NewArgReader(msg)
+	id := args.ReadInt32()
+	if err := args.Err(); err != nil {
+		return s.fail(msg, err.Error())
+	}
+	return s.conn.SendTo(msg.Sender, osc.Message{Address: "/synced", Arguments: osc.Arguments{osc.Int(id)}})
+}
+
+// notify registers or unregisters the sender for notifications.
+// The reply has the ID of the client.
+func (s *Server) notify(msg osc.Message) error {
+	args := scsynth.NewArgReader(msg)
+	on := args.ReadInt32() != 0
+	if err := args.Err(); err != nil {
+		return s.fail(msg, err.Error())
+	}
+	s.mu.Lock()
+	c, ok := s.clients[msg.Sender.String()]
+	if !ok {
+		c = client{addr: msg.Sender, id: s.nextClient}
+	}
+	switch {
+	case on && !ok:
+		s.clients[msg.Sender.String()] = c
+		s.nextClient++
+	case !on:
+		delete(s.clients, msg.Sender.String())
+	}
+	s.mu.Unlock()
+
+	return s.done(msg, osc.Int(c.id))
+}
+
+// sNew creates a synth.
+func (s *Server) sNew(msg osc.Message) error {
+	var (
+		args    = scsynth.NewArgReader(msg)
+		defName = args.ReadString()
+		id      = args.ReadInt32()
+		action  = scsynth.AddAction(args.ReadInt32())
+		target  = args.ReadInt32()
+	)
+	if err := args.Err(); err != nil {
+		return s.fail(msg, err.Error())
+	}
+	s.mu.Lock()
+	if _, ok := s.synthDefs[defName]; !ok {
+		s.mu.Unlock()
+		return s.fail(msg, "SynthDef not found")
+	}
+	events, err := s.add(&node{id: id, defName: defName}, action, target)
+	s.mu.Unlock()
+
+	if err != nil {
+		return s.fail(msg, err.Error())
+	}
+	s.sendNotifications(events)
+	return nil
+}
+
+// gNew creates groups, the arguments are repeated for each group.
+func (s *Server) gNew(msg osc.Message) error {
+	args := scsynth.NewArgReader(msg)
+
+	for args.More() {
+		var (
+			id     = args.ReadInt32()
+			action = scsynth.AddAction(args.ReadInt32())
+			target = args.ReadInt32()
+		)
+		if err := args.Err(); err != nil {
+			return s.fail(msg, err.Error())
+		}
+		s.mu.Lock()
+		events, err := s.add(&node{id: id, isGroup: true}, action, target)
+		s.mu.Unlock()
+
+		if err != nil {
+			if err := s.fail(msg, err.Error()); err != nil {
+				return err
+			}
+			continue
+		}
+		s.sendNotifications(events)
+	}
+	return nil
+}
+
+// nFree frees nodes. Freeing a group frees every node in it.
+func (s *Server) nFree(msg osc.Message) error {
+	args := scsynth.NewArgReader(msg)
+
+	for args.More() {
+		id := args.ReadInt32()
+		if err := args.Err(); err != nil {
+			return s.fail(msg, err.Error())
+		}
+		s.mu.Lock()
+		n, ok := s.nodes[id]
+		if !ok || id == scsynth.RootNode {
+			s.mu.Unlock()
+			if err := s.fail(msg, fmt.Sprintf("Node %d not found", id)); err != nil {
+				return err
+			}
+			continue
+		}
+		events := s.free(n)
+		s.mu.Unlock()
+
+		s.sendNotifications(events)
+	}
+	return nil
+}
+
+// bAlloc allocates a buffer.
+func (s *Server) bAlloc(msg osc.Message) error {
+	var (
+		args       = scsynth.NewArgReader(msg)
+		bufnum     = args.ReadInt32()
+		frames     = args.ReadInt32()
+		channels   = args.ReadInt32()
+		completion = optionalBlob(args)
+	)
+	if err := args.Err(); err != nil {
+		return s.fail(msg, err.Error())
+	}
+	if frames < 0 || channels < 1 {
+		return s.fail(msg, "invalid buffer size", osc.Int(bufnum))
+	}
+	s.mu.Lock()
+	s.buffers[bufnum] = scsynth.BufferInfo{Bufnum: bufnum, Frames: frames, Channels: channels, SampleRate: SampleRate}
+	s.mu.Unlock()
+
+	if err := s.complete(msg, completion); err != nil {
+		return err
+	}
+	return s.done(msg, osc.Int(bufnum))
+}
+
+// dRecv adds the synth definitions in the data of a synth definition file.
+func (s *Server) dRecv(msg osc.Message) error {
+	var (
+		args       = scsynth.NewArgReader(msg)
+		data       = args.ReadBlob()
+		completion = optionalBlob(args)
+	)
+	if err := args.Err(); err != nil {
+		return s.fail(msg, err.Error())
+	}
+	defs, err := readSynthDefs(data)
+	if err != nil {
+		return s.fail(msg, err.Error())
+	}
+	s.mu.Lock()
+	for _, def := range defs {
+		s.synthDefs[def.Name] = def
+	}
+	s.mu.Unlock()
+
+	if err := s.complete(msg, completion); err != nil {
+		return err
+	}
+	return s.done(msg)
+}
+
+// optionalBlob reads a blob if there is an argument left, like the completion
+// message of an asynchronous command.
+func optionalBlob(r *scsynth.ArgReader) []byte {
+	if !r.More() {
+		return nil
+	}
+	return r.ReadBlob()
+}
+
+// complete performs the completion packet of an asynchronous command,
+// as if it was sent by the sender of the command.
+func (s *Server) complete(msg osc.Message, completion []byte) error {
+	if len(completion) == 0 {
+		return nil
+	}
+	p, err := osc.ParsePacket(completion, msg.Sender)
+	if err != nil {
+		return s.fail(msg, "invalid completion message: "+err.Error())
+	}
+	switch x := p.(type) {
+	case osc.Message:
+		return s.dispatcher().Invoke(x, false)
+	case osc.Bundle:
+		return s.dispatcher().Dispatch(x, false)
+	}
+	return nil
+}
+
+// done replies with /done for the command of msg.
+func (s *Server) done(msg osc.Message, args ...osc.Argument) error {
+	return s.conn.SendTo(msg.Sender, osc.Message{
+		Address:   "/done",
+		Arguments: append(osc.Arguments{osc.String(msg.Address)}, args...),
+	})
+}
+
+// fail replies with /fail for the command of msg.
+func (s *Server) fail(msg osc.Message, message string, args ...osc.Argument) error {
+	return s.conn.SendTo(msg.Sender, osc.Message{
+		Address:   "/fail",
+		Arguments: append(osc.Arguments{osc.String(msg.Address), osc.String(message)}, args...),
+	})
+}
+
+// sendNotifications sends node notifications to every registered client.
+// Errors are ignored, a client that went away should not stop the server.
+func (s *Server) sendNotifications(events []scsynth.NodeEvent) {
+	s.mu.Lock()
+	addrs := make([]net.Addr, 0, len(s.clients))
+	for _, c := range s.clients {
+		addrs = append(addrs, c.addr)
+	}
+	s.mu.Unlock()
+
+	for _, ev := range events {
+		msg := notification(ev)
+		for _, addr := range addrs {
+			_ = s.conn.SendTo(addr, msg)
+		}
+	}
+}
+
+// notification returns the message of a node event.
+func notification(ev scsynth.NodeEvent) osc.Message {
+	args := osc.Arguments{osc.Int(ev.ID), osc.Int(ev.Parent), osc.Int(ev.Prev), osc.Int(ev.Next), osc.Int(0)}
+	if ev.IsGroup {
+		args[4] = osc.Int(1)
+		args = append(args, osc.Int(ev.Head), osc.Int(ev.Tail))
+	}
+	return osc.Message{Address: ev.Command, Arguments: args}
+}
@@ -0,0 +1,219 @@
+package scsynthtest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/scgolang/osc"
+	"github.com/scgolang/osc/scsynth"
+)
+
+// testEnv is a server and a client that is connected to it.
+type testEnv struct {
+	server *Server
+	conn   osc.Conn
+	client *osc.Client
+	router *scsynth.Router
+}
+
+// newTestEnv returns a server and a client that is connected to it,
+// with a router for the notifications of the server.
+// The caller closes it with close.
+func newTestEnv(t *testing.T) testEnv {
+	server, err := NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := osc.DialUDP("udp", nil, server.Addr())
+	if err != nil {
+		_ = server.Close()
+		t.Fatal(err)
+	}
+	var (
+		c      = osc.NewClient(conn)
+		router = &scsynth.Router{Nodes: make(chan scsynth.NodeEvent, 16)}
+	)
+	c.SetDispatcher(router)
+
+	return testEnv{server: server, conn: conn, client: c, router: router}
+}
+
+// close closes the client and the server.
+func (env testEnv) close(t *testing.T) {
+	_ = env.client.Close()
+
+	if err := env.server.Close(); err != nil {
+		t.Error(err)
+	}
+}
+
+// call sends msg and returns the reply that matches.
+func (env testEnv) call(t *testing.T, msg osc.Message, matchReply func(osc.Message) bool) osc.Message {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	reply, err := env.client.Call(ctx, msg, matchReply)
+	if err != nil {
+		t.Fatalf("%s: %s", msg.Address, err)
+	}
+	return reply
+}
+
+// send sends msg and waits until the server has handled it.
+func (env testEnv) send(t *testing.T, msg osc.Message) {
+	t.Helper()
+
+	if err := env.conn.Send(msg); err != nil {
+		t.Fatalf("%s: %s", msg.Address, err)
+	}
+	env.call(t, scsynth.Sync(99), osc.MatchReply("/synced", osc.Int(99)))
+}
+
+// expectEvents checks the next node notifications.
+func (env testEnv) expectEvents(t *testing.T, expected ...scsynth.NodeEvent) {
+	t.Helper()
+
+	for _, ev := range expected {
+		select {
+		case got := <-env.router.Nodes:
+			if ev != got {
+				t.Fatalf("expected %+v, got %+v", ev, got)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timeout waiting for %+v", ev)
+		}
+	}
+}
+
+func TestServer(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.close(t)
+
+	reply := env.call(t, scsynth.Notify(true), scsynth.MatchCompletion("/notify"))
+	if expected, got := `/done ,si "/notify" 0`, osc.Format(reply); expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+	// The completion message runs before the reply.
+	var (
+		completion = scsynth.SNew("sine", 1000, scsynth.AddToHead, scsynth.RootNode)
+		data       = encodeSynthDefs(2, testSynthDefs...)
+	)
+	reply = env.call(t, scsynth.DRecv(data, completion), scsynth.MatchCompletion("/d_recv"))
+	if err := scsynth.CompletionError(reply); err != nil {
+		t.Fatal(err)
+	}
+	env.expectEvents(t, scsynth.NodeEvent{Command: "/n_go", ID: 1000, Parent: 0, Prev: -1, Next: -1})
+
+	if expected, got := []string{"noise", "sine"}, env.server.SynthDefs(); len(expected) != len(got) || expected[0] != got[0] || expected[1] != got[1] {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+	env.send(t, scsynth.GNew(1, scsynth.AddToTail, scsynth.RootNode))
+	env.expectEvents(t, scsynth.NodeEvent{Command: "/n_go", ID: 1, Parent: 0, Prev: 1000, Next: -1, IsGroup: true, Head: -1, Tail: -1})
+
+	env.send(t, scsynth.SNew("noise", scsynth.AutoNodeID, scsynth.AddToHead, 1))
+	env.expectEvents(t, scsynth.NodeEvent{Command: "/n_go", ID: -2, Parent: 1, Prev: -1, Next: -1})
+
+	env.send(t, scsynth.SNew("sine", 1001, scsynth.AddBefore, -2))
+	env.expectEvents(t, scsynth.NodeEvent{Command: "/n_go", ID: 1001, Parent: 1, Prev: -1, Next: -2})
+
+	if expected, got := (scsynth.NodeEvent{Command: "/n_info", ID: 1, Parent: 0, Prev: 1000, Next: -1, IsGroup: true, Head: 1001, Tail: -2}), mustNode(t, env.server, 1); expected != got {
+		t.Fatalf("expected %+v, got %+v", expected, got)
+	}
+	status, err := scsynth.ParseStatusReply(env.call(t, scsynth.Status(), osc.MatchReply("/status.reply")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedStatus := scsynth.StatusReply{
+		UGens:             4 + 4 + 2,
+		Synths:            3,
+		Groups:            2,
+		SynthDefs:         2,
+		NominalSampleRate: SampleRate,
+		ActualSampleRate:  SampleRate,
+	}
+	if expectedStatus != status {
+		t.Fatalf("expected %+v, got %+v", expectedStatus, status)
+	}
+	// Freeing a group frees the nodes in it first.
+	env.send(t, scsynth.NFree(1))
+	env.expectEvents(t,
+		scsynth.NodeEvent{Command: "/n_end", ID: 1001, Parent: 1, Prev: -1, Next: -2},
+		scsynth.NodeEvent{Command: "/n_end", ID: -2, Parent: 1, Prev: -1, Next: -1},
+		scsynth.NodeEvent{Command: "/n_end", ID: 1, Parent: 0, Prev: 1000, Next: -1, IsGroup: true, Head: -1, Tail: -1},
+	)
+	if _, ok := env.server.Node(-2); ok {
+		t.Fatal("expected node -2 to be freed")
+	}
+	env.send(t, scsynth.SNew("noise", 1002, scsynth.AddReplace, 1000))
+	env.expectEvents(t,
+		scsynth.NodeEvent{Command: "/n_go", ID: 1002, Parent: 0, Prev: -1, Next: -1},
+		scsynth.NodeEvent{Command: "/n_end", ID: 1000, Parent: 0, Prev: -1, Next: -1},
+	)
+	reply = env.call(t, scsynth.BAlloc(3, 1024, 2, nil), scsynth.MatchCompletion("/b_alloc", osc.Int(3)))
+	if err := scsynth.CompletionError(reply); err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := (scsynth.BufferInfo{Bufnum: 3, Frames: 1024, Channels: 2, SampleRate: SampleRate}), mustBuffer(t, env.server, 3); expected != got {
+		t.Fatalf("expected %+v, got %+v", expected, got)
+	}
+}
+
+func TestServerFail(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.close(t)
+	env.server.AddSynthDef("default")
+
+	for i, testcase := range []struct {
+		Msg osc.Message
+		Err string
+	}{
+		{Msg: scsynth.SNew("sine", 1000, scsynth.AddToHead, scsynth.RootNode), Err: "/s_new failed: SynthDef not found"},
+		{Msg: scsynth.SNew("default", 1000, scsynth.AddToHead, 5), Err: "/s_new failed: Node 5 not found"},
+		{Msg: scsynth.SNew("default", 1000, scsynth.AddAfter, scsynth.RootNode), Err: "/s_new failed: can not add a node before or after the root group"},
+		{Msg: scsynth.SNew("default", 1000, scsynth.AddAction(7), scsynth.RootNode), Err: "/s_new failed: invalid add action 7"},
+		{Msg: osc.Message{Address: "/s_new", Arguments: osc.Arguments{osc.Int(1)}}, Err: "/s_new failed: argument 0 should be a string, got 1"},
+		{Msg: scsynth.NFree(1000), Err: "/n_free failed: Node 1000 not found"},
+		{Msg: scsynth.BAlloc(0, 1024, 0, nil), Err: "/b_alloc failed: invalid buffer size"},
+		{Msg: scsynth.DRecv([]byte("SCgf"), nil), Err: "/d_recv failed: read synthdef file header: unexpected EOF"},
+		{Msg: osc.Message{Address: "/sync"}, Err: "/sync failed: missing argument 0"},
+	} {
+		reply := env.call(t, testcase.Msg, scsynth.MatchCompletion(testcase.Msg.Address))
+		err := scsynth.CompletionError(reply)
+		if err == nil {
+			t.Fatalf("(testcase %d) expected error, got nil", i)
+		}
+		if expected, got := testcase.Err, err.Error(); expected != got {
+			t.Fatalf("(testcase %d) expected %s, got %s", i, expected, got)
+		}
+	}
+	// Node IDs must be unique.
+	env.send(t, scsynth.SNew("default", 1000, scsynth.AddToHead, scsynth.RootNode))
+
+	reply := env.call(t, scsynth.SNew("default", 1000, scsynth.AddToHead, scsynth.RootNode), scsynth.MatchCompletion("/s_new"))
+	if expected, got := "/s_new failed: duplicate node ID", scsynth.CompletionError(reply); got == nil || expected != got.Error() {
+		t.Fatalf("expected %s, got %v", expected, got)
+	}
+}
+
+func mustNode(t *testing.T, server *Server, id int32) scsynth.NodeEvent {
+	t.Helper()
+
+	ev, ok := server.Node(id)
+	if !ok {
+		t.Fatalf("node %d not found", id)
+	}
+	return ev
+}
+
+func mustBuffer(t *testing.T, server *Server, bufnum int32) scsynth.BufferInfo {
+	t.Helper()
+
+	info, ok := server.Buffer(bufnum)
+	if !ok {
+		t.Fatalf("buffer %d not found", bufnum)
+	}
+	return info
+}
@@ -0,0 +1,153 @@
+package scsynthtest
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// synthDef is the part of a synth definition that the server keeps.
+type synthDef struct {
+	Name     string
+	NumUGens int32
+}
+
+// readSynthDefs reads the synth definitions in the data of a synth definition file,
+// version 1 or 2 of the SCgf format.
+func readSynthDefs(data []byte) ([]synthDef, error) {
+	r := &defReader{r: bytes.NewReader(data)}
+
+	if magic := r.bytes(4); r.err == nil && string(magic) != "SCgf" {
+		return nil, errors.Errorf("invalid synthdef file magic %q", magic)
+	}
+	r.version = r.int32()
+	if r.err == nil && r.version != 1 && r.version != 2 {
+		return nil, errors.Errorf("unsupported synthdef file version %d", r.version)
+	}
+	numDefs := r.int16()
+	if r.err != nil {
+		return nil, errors.Wrap(r.err, "read synthdef file header")
+	}
+	if numDefs < 0 {
+		return nil, errors.Errorf("negative synthdef count %d", numDefs)
+	}
+	defs := make([]synthDef, 0, numDefs)
+
+	for i := 0; i < int(numDefs); i++ {
+		def, err := r.synthDef()
+		if err != nil {
+			return nil, errors.Wrapf(err, "read synthdef %d", i)
+		}
+		defs = append(defs, def)
+	}
+	return defs, nil
+}
+
+// defReader reads the values of a synth definition file.
+// After the first error every read returns the zero value.
+type defReader struct {
+	r       *bytes.Reader
+	version int32
+	err     error
+}
+
+// synthDef reads a synth definition.
+func (r *defReader) synthDef() (synthDef, error) {
+	def := synthDef{Name: r.pstring()}
+
+	r.skip(4 * int64(r.count())) // Constants.
+
+	numParams := r.count()
+	r.skip(4 * int64(numParams)) // Initial parameter values.
+
+	for i, n := 0, r.count(); i < int(n) && r.err == nil; i++ {
+		_ = r.pstring() // Parameter name.
+		_ = r.index()   // Parameter index.
+	}
+	def.NumUGens = r.count()
+
+	for i := 0; i < int(def.NumUGens) && r.err == nil; i++ {
+		_ = r.pstring() // Class name.
+		r.skip(1)       // Rate.
+		var (
+			numInputs  = r.count()
+			numOutputs = r.count()
+		)
+		r.skip(2) // Special index.
+
+		for j := 0; j < int(numInputs) && r.err == nil; j++ {
+			_, _ = r.index(), r.index() // UGen index, -1 for constants, and output or constant index.
+		}
+		r.skip(int64(numOutputs)) // Output rates.
+	}
+	for i, n := 0, r.int16(); i < int(n) && r.err == nil; i++ { // Variants.
+		_ = r.pstring()              // Variant name.
+		r.skip(4 * int64(numParams)) // Variant parameter values.
+	}
+	return def, r.err
+}
+
+// count reads a count, which can not be negative.
+func (r *defReader) count() int32 {
+	n := r.index()
+	if n < 0 && r.err == nil {
+		r.err = errors.Errorf("negative count %d", n)
+	}
+	return n
+}
+
+// index reads a count or an index, which is an int16 in version 1 and an int32 in version 2.
+func (r *defReader) index() int32 {
+	if r.version == 1 {
+		return int32(r.int16())
+	}
+	return r.int32()
+}
+
+// int16 reads a big endian int16.
+func (r *defReader) int16() int16 {
+	b := r.bytes(2)
+	if b == nil {
+		return 0
+	}
+	return int16(binary.BigEndian.Uint16(b))
+}
+
+// int32 reads a big endian int32.
+func (r *defReader) int32() int32 {
+	b := r.bytes(4)
+	if b == nil {
+		return 0
+	}
+	return int32(binary.BigEndian.Uint32(b))
+}
+
+// pstring reads a string that is prefixed by its length in one byte.
+func (r *defReader) pstring() string {
+	n := r.bytes(1)
+	if n == nil {
+		return ""
+	}
+	return string(r.bytes(int64(n[0])))
+}
+
+// skip skips n bytes.
+func (r *defReader) skip(n int64) {
+	_ = r.bytes(n)
+}
+
+// bytes reads n bytes.
+func (r *defReader) bytes(n int64) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n > int64(r.r.Len()) {
+		r.err = io.ErrUnexpectedEOF
+		return nil
+	}
+	b := make([]byte, n)
+	_, _ = r.r.Read(b)
+	return b
+}
@@ -0,0 +1,158 @@
+package scsynthtest
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// testUGen is a UGen of a test synth definition.
+type testUGen struct {
+	Class   string
+	Inputs  [][2]int32
+	Outputs int
+}
+
+// testSynthDef is a synth definition for tests.
+type testSynthDef struct {
+	Name   string
+	Params []string
+	UGens  []testUGen
+}
+
+// encodeSynthDefs returns the data of a synth definition file with the definitions,
+// in version 1 or 2 of the SCgf format.
+func encodeSynthDefs(version int32, defs ...testSynthDef) []byte {
+	var (
+		buf   bytes.Buffer
+		write = func(v interface{}) { _ = binary.Write(&buf, binary.BigEndian, v) }
+		count = func(n int) {
+			if version == 1 {
+				write(int16(n))
+			} else {
+				write(int32(n))
+			}
+		}
+		pstring = func(s string) {
+			buf.WriteByte(byte(len(s)))
+			buf.WriteString(s)
+		}
+	)
+	buf.WriteString("SCgf")
+	write(version)
+	write(int16(len(defs)))
+
+	for _, def := range defs {
+		pstring(def.Name)
+		count(1) // Constants.
+		write(float32(0.5))
+		count(len(def.Params))
+		for range def.Params {
+			write(float32(0))
+		}
+		count(len(def.Params))
+		for i, name := range def.Params {
+			pstring(name)
+			count(i)
+		}
+		count(len(def.UGens))
+		for _, u := range def.UGens {
+			pstring(u.Class)
+			write(int8(2))
+			count(len(u.Inputs))
+			count(u.Outputs)
+			write(int16(0))
+			for _, in := range u.Inputs {
+				count(int(in[0]))
+				count(int(in[1]))
+			}
+			for i := 0; i < u.Outputs; i++ {
+				write(int8(2))
+			}
+		}
+		write(int16(1)) // Variants.
+		pstring("alt")
+		for range def.Params {
+			write(float32(1))
+		}
+	}
+	return buf.Bytes()
+}
+
+// testSynthDefs are the definitions of a sine and a noise synth.
+var testSynthDefs = []testSynthDef{
+	{
+		Name:   "sine",
+		Params: []string{"freq", "amp"},
+		UGens: []testUGen{
+			{Class: "Control", Outputs: 2},
+			{Class: "SinOsc", Inputs: [][2]int32{{0, 0}, {-1, 0}}, Outputs: 1},
+			{Class: "BinaryOpUGen", Inputs: [][2]int32{{1, 0}, {0, 1}}, Outputs: 1},
+			{Class: "Out", Inputs: [][2]int32{{-1, 0}, {2, 0}}},
+		},
+	},
+	{
+		Name:  "noise",
+		UGens: []testUGen{{Class: "WhiteNoise", Outputs: 1}, {Class: "Out", Inputs: [][2]int32{{-1, 0}, {0, 0}}}},
+	},
+}
+
+func TestReadSynthDefs(t *testing.T) {
+	for _, version := range []int32{1, 2} {
+		defs, err := readSynthDefs(encodeSynthDefs(version, testSynthDefs...))
+		if err != nil {
+			t.Fatalf("(version %d) %s", version, err)
+		}
+		if expected, got := 2, len(defs); expected != got {
+			t.Fatalf("(version %d) expected %d synthdefs, got %d", version, expected, got)
+		}
+		for i, expected := range []synthDef{{Name: "sine", NumUGens: 4}, {Name: "noise", NumUGens: 2}} {
+			if got := defs[i]; expected != got {
+				t.Fatalf("(version %d) expected %+v, got %+v", version, expected, got)
+			}
+		}
+	}
+}
+
+func TestReadSynthDefsErrors(t *testing.T) {
+	valid := encodeSynthDefs(2, testSynthDefs[0])
+
+	for i, testcase := range []struct {
+		Data []byte
+		Err  string
+	}{
+		{
+			Data: []byte("SCg"),
+			Err:  "read synthdef file header: unexpected EOF",
+		},
+		{
+			Data: append([]byte("SCgF"), valid[4:]...),
+			Err:  `invalid synthdef file magic "SCgF"`,
+		},
+		{
+			Data: append([]byte("SCgf\x00\x00\x00\x03"), valid[8:]...),
+			Err:  "unsupported synthdef file version 3",
+		},
+		{
+			Data: valid[:len(valid)-1],
+			Err:  "read synthdef 0: unexpected EOF",
+		},
+		{
+			Data: []byte("SCgf\x00\x00\x00\x02\xff\xff"),
+			Err:  "negative synthdef count -1",
+		},
+		{
+			// The number of constants is negative.
+			Data: append(append([]byte{}, valid[:15]...), 0xff, 0xff, 0xff, 0xff),
+			Err:  "read synthdef 0: negative count -1",
+		},
+	} {
+		_, err := readSynthDefs(testcase.Data)
+		if err == nil {
+			t.Fatalf("(testcase %d) expected error, got nil", i)
+		}
+		if expected, got := testcase.Err, err.Error(); expected != got {
+			t.Fatalf("(testcase %d) expected %s, got %s", i, expected, got)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package scsynthtest
+
+import (
+	"github.com/pkg/errors"
+	"github.com/scgolang/osc/scsynth"
+)
+
+// node is a synth or a group in the tree of the server.
+type node struct {
+	id       int32
+	defName  string // Empty for groups.
+	isGroup  bool
+	parent   *node
+	children []*node
+}
+
+// event returns the notification about the node, with its current place in the tree.
+func (n *node) event(command string) scsynth.NodeEvent {
+	ev := scsynth.NodeEvent{Command: command, ID: n.id, Parent: -1, Prev: -1, Next: -1, IsGroup: n.isGroup}
+
+	if p := n.parent; p != nil {
+		ev.Parent = p.id
+		i := p.index(n)
+		if i > 0 {
+			ev.Prev = p.children[i-1].id
+		}
+		if i < len(p.children)-1 {
+			ev.Next = p.children[i+1].id
+		}
+	}
+	if n.isGroup {
+		ev.Head, ev.Tail = -1, -1
+		if len(n.children) > 0 {
+			ev.Head, ev.Tail = n.children[0].id, n.children[len(n.children)-1].id
+		}
+	}
+	return ev
+}
+
+// index returns the index of a child of the group.
+func (n *node) index(child *node) int {
+	for i, c := range n.children {
+		if c == child {
+			return i
+		}
+	}
+	return -1
+}
+
+// insert inserts a child in the group at index i.
+func (n *node) insert(child *node, i int) {
+	n.children = append(n.children, nil)
+	copy(n.children[i+1:], n.children[i:])
+	n.children[i] = child
+	child.parent = n
+}
+
+// remove removes a child from the group.
+func (n *node) remove(child *node) {
+	i := n.index(child)
+	n.children = append(n.children[:i], n.children[i+1:]...)
+	child.parent = nil
+}
+
+// add adds a node to the tree relative to the target node, and returns the notifications.
+// The caller must hold s.mu.
+func (s *Server) add(n *node, action scsynth.AddAction, targetID int32) ([]scsynth.NodeEvent, error) {
+	if n.id == scsynth.AutoNodeID {
+		n.id = s.autoID()
+	}
+	if _, ok := s.nodes[n.id]; ok {
+		return nil, errors.New("duplicate node ID")
+	}
+	target, ok := s.nodes[targetID]
+	if !ok {
+		return nil, errors.Errorf("Node %d not found", targetID)
+	}
+	var events []scsynth.NodeEvent
+
+	switch action {
+	case scsynth.AddToHead, scsynth.AddToTail:
+		if !target.isGroup {
+			return nil, errors.Errorf("Group %d not found", targetID)
+		}
+		i := 0
+		if action == scsynth.AddToTail {
+			i = len(target.children)
+		}
+		target.insert(n, i)
+	case scsynth.AddBefore, scsynth.AddAfter:
+		if target.parent == nil {
+			return nil, errors.New("can not add a node before or after the root group")
+		}
+		i := target.parent.index(target)
+		if action == scsynth.AddAfter {
+			i++
+		}
+		target.parent.insert(n, i)
+	case scsynth.AddReplace:
+		if target.parent == nil {
+			return nil, errors.New("can not replace the root group")
+		}
+		parent, i := target.parent, target.parent.index(target)
+		events = s.free(target)
+		parent.insert(n, i)
+	default:
+		return nil, errors.Errorf("invalid add action %d", action)
+	}
+	s.nodes[n.id] = n
+
+	return append([]scsynth.NodeEvent{n.event("/n_go")}, events...), nil
+}
+
+// free removes a node from the tree, and every node in it if it is a group,
+// and returns the notifications. The caller must hold s.mu.
+func (s *Server) free(n *node) []scsynth.NodeEvent {
+	var events []scsynth.NodeEvent
+
+	for len(n.children) > 0 {
+		events = append(events, s.free(n.children[0])...)
+	}
+	events = append(events, n.event("/n_end"))
+	n.parent.remove(n)
+	delete(s.nodes, n.id)
+
+	return events
+}
+
+// autoID returns an unused negative node ID. The caller must hold s.mu.
+func (s *Server) autoID() int32 {
+	for {
+		id := s.nextID
+		s.nextID--
+		if _, ok := s.nodes[id]; !ok {
+			return id
+		}
+	}
+}
@@ -0,0 +1,495 @@
+package osc
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/pkg/errors"
+)
+
+// Text representation of OSC packets.
+//
+// A message is written as its address, optionally followed by its type tags
+// and one value per argument:
+//
+//	/synth/freq ,fs 440.0 "sine"
+//
+// When the type tags are left out they are inferred from the values:
+//
+//	/synth/freq 440.0 "sine"
+//
+// Values are written as follows:
+//
+//	i  an integer               -3
+//	f  a number with a point    0.5, 1e-3, NaN, +Inf
+//	s  a quoted string          "sine", "say \"hi\""
+//	b  base64 with a b prefix   b"AQID"
+//	T  true
+//	F  false
+//
+// When the type tags are given, strings don't have to be quoted and
+// true and false can be left out, since T and F carry no data.
+//
+// A bundle is written as #bundle, a timetag and a list of packets in brackets:
+//
+//	#bundle 2024-01-01T00:00:00Z [ /foo ,i 1 #bundle immediately [ /bar ] ]
+//
+// The timetag is either immediately, an RFC 3339 time or the raw 64-bit
+// NTP timestamp in hexadecimal, e.g. 0xE9A1A6800000000.
+// Brackets are separate tokens, so they have to be separated from addresses
+// by whitespace unless they are part of an address pattern.
+
+// ParseText parses the text representation of a packet.
+func ParseText(s string) (Packet, error) {
+	p := &textParser{tokens: lexText(s), end: len(s)}
+
+	packet, err := p.packet()
+	if err != nil {
+		return nil, err
+	}
+	if tok, ok := p.peek(); ok {
+		return nil, p.errorf(tok, "unexpected %q after packet", tok.text)
+	}
+	return packet, nil
+}
+
+// Format returns the text representation of a packet.
+// Packets other than Message and Bundle are parsed from their bytes first.
+func Format(p Packet) string {
+	var sb strings.Builder
+	textFormatter{sb: &sb}.packet(p, 0)
+	return sb.String()
+}
+
+// FormatIndent is like Format but puts each packet of a bundle on a new line.
+// Each line after the first begins with prefix followed by one copy of indent
+// for every level of bundle nesting.
+func FormatIndent(p Packet, prefix, indent string) string {
+	var sb strings.Builder
+	textFormatter{sb: &sb, prefix: prefix, indent: indent, multiline: true}.packet(p, 0)
+	return sb.String()
+}
+
+// String returns the text representation of the message.
+// See Format.
+func (msg Message) String() string {
+	return Format(msg)
+}
+
+// String returns the text representation of the bundle.
+// See Format.
+func (b Bundle) String() string {
+	return Format(b)
+}
+
+// textFormatter writes the text representation of packets.
+type textFormatter struct {
+	sb        *strings.Builder
+	prefix    string
+	indent    string
+	multiline bool
+}
+
+// separator writes the whitespace that precedes a line at the given depth.
+func (f textFormatter) separator(depth int) {
+	if !f.multiline {
+		f.sb.WriteByte(' ')
+		return
+	}
+	f.sb.WriteByte('\n')
+	f.sb.WriteString(f.prefix)
+	for i := 0; i < depth; i++ {
+		f.sb.WriteString(f.indent)
+	}
+}
+
+// packet writes a packet that is nested depth bundles deep.
+func (f textFormatter) packet(p Packet, depth int) {
+	switch x := p.(type) {
+	case Message:
+		f.sb.WriteString(x.Address)
+		if len(x.Arguments) == 0 {
+			return
+		}
+		f.sb.WriteString(" ,")
+		for _, a := range x.Arguments {
+			f.sb.WriteByte(a.Typetag())
+		}
+		for _, a := range x.Arguments {
+			f.sb.WriteByte(' ')
+			f.sb.WriteString(FormatArgument(a))
+		}
+	case Bundle:
+		f.sb.WriteString(BundleTag)
+		f.sb.WriteByte(' ')
+		f.sb.WriteString(formatTimetag(x.Timetag))
+		f.sb.WriteString(" [")
+		if len(x.Packets) == 0 {
+			f.sb.WriteString(" ]")
+			return
+		}
+		for _, p := range x.Packets {
+			f.separator(depth + 1)
+			f.packet(p, depth+1)
+		}
+		f.separator(depth)
+		f.sb.WriteByte(']')
+	default:
+		parsed, err := ParsePacket(p.Bytes(), nil)
+		if err != nil {
+			fmt.Fprintf(f.sb, "!(%T %s)", p, err)
+			return
+		}
+		f.packet(parsed, depth)
+	}
+}
+
+// FormatArgument returns the text representation of an argument's value.
+func FormatArgument(a Argument) string {
+	switch a.Typetag() {
+	case TypetagInt:
+		i, _ := a.ReadInt32()
+		return strconv.FormatInt(int64(i), 10)
+	case TypetagFloat:
+		f, _ := a.ReadFloat32()
+		s := strconv.FormatFloat(float64(f), 'g', -1, 32)
+		if !strings.ContainsAny(s, ".eIN") {
+			s += ".0" // So that the value is not inferred to be an int.
+		}
+		return s
+	case TypetagDouble:
+		d, _ := a.(Double)
+		s := strconv.FormatFloat(float64(d), 'g', -1, 64)
+		if !strings.ContainsAny(s, ".eIN") {
+			s += ".0"
+		}
+		return s
+	case TypetagString:
+		s, _ := a.ReadString()
+		return strconv.Quote(s)
+	case TypetagBlob:
+		b, _ := a.ReadBlob()
+		return `b"` + base64.StdEncoding.EncodeToString(b) + `"`
+	case TypetagTrue:
+		return "true"
+	case TypetagFalse:
+		return "false"
+	default:
+		return a.String()
+	}
+}
+
+// formatTimetag returns the text representation of a timetag.
+func formatTimetag(tt Timetag) string {
+	if tt == Immediately {
+		return "immediately"
+	}
+	// Only use RFC 3339 if it doesn't lose any precision.
+	if t := tt.Time(); FromTime(t) == tt && t.Year() >= 1970 {
+		return t.Format(time.RFC3339Nano)
+	}
+	return fmt.Sprintf("0x%016X", uint64(tt))
+}
+
+// parseTimetag parses the text representation of a timetag.
+func parseTimetag(s string) (Timetag, error) {
+	if s == "immediately" {
+		return Immediately, nil
+	}
+	if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X") {
+		tt, err := strconv.ParseUint(s[2:], 16, 64)
+		return Timetag(tt), err
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return 0, err
+	}
+	return FromTime(t), nil
+}
+
+// textToken is a token of the text representation of a packet.
+type textToken struct {
+	text   string
+	offset int
+	quoted bool // A quoted string. text is the unquoted value.
+	blob   bool // A blob. text is the base64 value.
+	err    error
+}
+
+// lexText splits s into tokens.
+func lexText(s string) []textToken {
+	var tokens []textToken
+
+	for i := 0; i < len(s); {
+		c := s[i]
+
+		switch {
+		case unicode.IsSpace(rune(c)):
+			i++
+		case c == '[' || c == ']':
+			tokens = append(tokens, textToken{text: s[i : i+1], offset: i})
+			i++
+		case c == '"' || (c == 'b' && i+1 < len(s) && s[i+1] == '"'):
+			start, blob := i, c == 'b'
+			if blob {
+				i++
+			}
+			end := quotedEnd(s, i)
+			tok := textToken{offset: start, quoted: !blob, blob: blob}
+			if end == -1 {
+				tok.err = errors.New("unterminated string")
+				i = len(s)
+			} else {
+				tok.text, tok.err = strconv.Unquote(s[i:end])
+				i = end
+			}
+			tokens = append(tokens, tok)
+		default:
+			start := i
+			if c == MessageChar {
+				i = addressEnd(s, i)
+			} else {
+				for i < len(s) && !unicode.IsSpace(rune(s[i])) && s[i] != ']' {
+					i++
+				}
+			}
+			tokens = append(tokens, textToken{text: s[start:i], offset: start})
+		}
+	}
+	return tokens
+}
+
+// quotedEnd returns the index just past the closing quote of the
+// quoted string that starts at s[start], or -1 if it is not terminated.
+func quotedEnd(s string, start int) int {
+	for i := start + 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1
+		}
+	}
+	return -1
+}
+
+// addressEnd returns the index just past the address that starts at s[start].
+// An address ends at whitespace or at a ']' that doesn't close a '['.
+func addressEnd(s string, start int) int {
+	depth := 0
+	for i := start; i < len(s); i++ {
+		switch c := s[i]; {
+		case unicode.IsSpace(rune(c)):
+			return i
+		case c == '[':
+			depth++
+		case c == ']':
+			if depth == 0 {
+				return i
+			}
+			depth--
+		}
+	}
+	return len(s)
+}
+
+// textParser parses tokens into packets.
+type textParser struct {
+	tokens []textToken
+	pos    int
+	end    int
+}
+
+func (p *textParser) peek() (textToken, bool) {
+	if p.pos >= len(p.tokens) {
+		return textToken{offset: p.end}, false
+	}
+	return p.tokens[p.pos], true
+}
+
+func (p *textParser) next() (textToken, bool) {
+	tok, ok := p.peek()
+	if ok {
+		p.pos++
+	}
+	return tok, ok
+}
+
+func (p *textParser) errorf(tok textToken, format string, args ...interface{}) error {
+	return errors.Errorf("parse text at offset %d: %s", tok.offset, fmt.Sprintf(format, args...))
+}
+
+// packet parses a message or a bundle.
+func (p *textParser) packet() (Packet, error) {
+	tok, ok := p.next()
+	if !ok {
+		return nil, p.errorf(tok, "expected a packet")
+	}
+	if tok.err != nil {
+		return nil, p.errorf(tok, "%s", tok.err)
+	}
+	switch {
+	case !tok.quoted && !tok.blob && tok.text == BundleTag:
+		return p.bundle()
+	case !tok.quoted && !tok.blob && strings.HasPrefix(tok.text, string(MessageChar)):
+		return p.message(tok.text)
+	default:
+		return nil, p.errorf(tok, "expected an address or %s, got %q", BundleTag, tok.text)
+	}
+}
+
+// bundle parses the timetag and the packets of a bundle.
+func (p *textParser) bundle() (Packet, error) {
+	tok, _ := p.next()
+	tt, err := parseTimetag(tok.text)
+	if err != nil {
+		return nil, p.errorf(tok, "invalid timetag %q", tok.text)
+	}
+	b := Bundle{Timetag: tt}
+
+	if tok, _ := p.next(); tok.quoted || tok.text != "[" {
+		return nil, p.errorf(tok, "expected [ after timetag")
+	}
+	for {
+		tok, ok := p.peek()
+		if !ok {
+			return nil, p.errorf(tok, "expected ] at the end of the bundle")
+		}
+		if !tok.quoted && tok.text == "]" {
+			p.pos++
+			return b, nil
+		}
+		packet, err := p.packet()
+		if err != nil {
+			return nil, err
+		}
+		b.Packets = append(b.Packets, packet)
+	}
+}
+
+// message parses the arguments of a message.
+func (p *textParser) message(address string) (Packet, error) {
+	msg := Message{Address: address}
+
+	tok, ok := p.peek()
+	if !ok || tok.quoted || tok.blob || !strings.HasPrefix(tok.text, string(TypetagPrefix)) {
+		return p.inferArguments(msg)
+	}
+	p.pos++
+
+	for _, tt := range []byte(tok.text[1:]) {
+		arg, err := p.argument(tt)
+		if err != nil {
+			return nil, err
+		}
+		msg.Arguments = append(msg.Arguments, arg)
+	}
+	return msg, nil
+}
+
+// argument parses an argument with the given typetag.
+func (p *textParser) argument(tt byte) (Argument, error) {
+	if tt == TypetagTrue || tt == TypetagFalse {
+		// The value is optional.
+		if tok, ok := p.peek(); ok && !tok.quoted && (tok.text == "true" || tok.text == "false") {
+			p.pos++
+			if (tok.text == "true") != (tt == TypetagTrue) {
+				return nil, p.errorf(tok, "typetag %c does not match value %s", tt, tok.text)
+			}
+		}
+		return Bool(tt == TypetagTrue), nil
+	}
+	tok, ok := p.next()
+	if !ok || (!tok.quoted && !tok.blob && tok.text == "]") {
+		return nil, p.errorf(tok, "missing value for typetag %c", tt)
+	}
+	if tok.err != nil {
+		return nil, p.errorf(tok, "%s", tok.err)
+	}
+	switch tt {
+	case TypetagInt:
+		i, err := strconv.ParseInt(tok.text, 10, 32)
+		if err != nil || tok.quoted || tok.blob {
+			return nil, p.errorf(tok, "invalid int %q", tok.text)
+		}
+		return Int(i), nil
+	case TypetagFloat:
+		f, err := strconv.ParseFloat(tok.text, 32)
+		if err != nil || tok.quoted || tok.blob {
+			return nil, p.errorf(tok, "invalid float %q", tok.text)
+		}
+		return Float(f), nil
+	case TypetagDouble:
+		d, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil || tok.quoted || tok.blob {
+			return nil, p.errorf(tok, "invalid double %q", tok.text)
+		}
+		return Double(d), nil
+	case TypetagString:
+		if tok.blob {
+			return nil, p.errorf(tok, "invalid string b%q", tok.text)
+		}
+		return String(tok.text), nil
+	case TypetagBlob:
+		b, err := base64.StdEncoding.DecodeString(tok.text)
+		if err != nil || tok.quoted {
+			return nil, p.errorf(tok, "invalid blob %q", tok.text)
+		}
+		return Blob(b), nil
+	default:
+		return nil, p.errorf(tok, "invalid typetag %q", string(tt))
+	}
+}
+
+// inferArguments parses arguments whose types are inferred from their values.
+// The arguments end at the next packet, at the end of a bundle or at the end of the text.
+func (p *textParser) inferArguments(msg Message) (Packet, error) {
+	for {
+		tok, ok := p.peek()
+		if !ok {
+			return msg, nil
+		}
+		if !tok.quoted && !tok.blob && (tok.text == "]" || tok.text == BundleTag || strings.HasPrefix(tok.text, string(MessageChar))) {
+			return msg, nil
+		}
+		p.pos++
+
+		if tok.err != nil {
+			return nil, p.errorf(tok, "%s", tok.err)
+		}
+		arg, err := inferArgument(tok)
+		if err != nil {
+			return nil, p.errorf(tok, "%s", err)
+		}
+		msg.Arguments = append(msg.Arguments, arg)
+	}
+}
+
+// inferArgument infers the type of an argument from its text.
+func inferArgument(tok textToken) (Argument, error) {
+	switch {
+	case tok.quoted:
+		return String(tok.text), nil
+	case tok.blob:
+		b, err := base64.StdEncoding.DecodeString(tok.text)
+		if err != nil {
+			return nil, errors.Errorf("invalid blob %q", tok.text)
+		}
+		return Blob(b), nil
+	case tok.text == "true":
+		return Bool(true), nil
+	case tok.text == "false":
+		return Bool(false), nil
+	}
+	if i, err := strconv.ParseInt(tok.text, 10, 32); err == nil {
+		return Int(i), nil
+	}
+	if f, err := strconv.ParseFloat(tok.text, 32); err == nil {
+		return Float(f), nil
+	}
+	return nil, errors.Errorf("can not infer the type of %q, strings must be quoted", tok.text)
+}
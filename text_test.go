@@ -0,0 +1,222 @@
+package osc
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestParseText(t *testing.T) {
+	for i, testcase := range []struct {
+		Input    string
+		Expected Packet
+	}{
+		{
+			Input:    `/synth/freq ,fs 440.0 "sine"`,
+			Expected: Message{Address: "/synth/freq", Arguments: Arguments{Float(440), String("sine")}},
+		},
+		{
+			Input:    `/synth/freq ,fs 440 sine`,
+			Expected: Message{Address: "/synth/freq", Arguments: Arguments{Float(440), String("sine")}},
+		},
+		{
+			Input:    `/synth/freq 440.0 "sine"`,
+			Expected: Message{Address: "/synth/freq", Arguments: Arguments{Float(440), String("sine")}},
+		},
+		{
+			Input:    `/foo -3 1e-3 true false b"AQID" "say \"hi\""`,
+			Expected: Message{Address: "/foo", Arguments: Arguments{Int(-3), Float(1e-3), Bool(true), Bool(false), Blob{1, 2, 3}, String(`say "hi"`)}},
+		},
+		{
+			Input:    `/status.reply ,dd 48000 0.1`,
+			Expected: Message{Address: "/status.reply", Arguments: Arguments{Double(48000), Double(0.1)}},
+		},
+		{
+			Input:    `/foo ,TFi 1`,
+			Expected: Message{Address: "/foo", Arguments: Arguments{Bool(true), Bool(false), Int(1)}},
+		},
+		{
+			Input:    `  /path/m[aei]thod  `,
+			Expected: Message{Address: "/path/m[aei]thod"},
+		},
+		{
+			Input: "#bundle 2024-01-01T00:00:00Z [\n  /foo ,i 1\n  #bundle immediately [ /bar \"baz\"]\n]",
+			Expected: Bundle{
+				Timetag: FromTime(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)),
+				Packets: []Packet{
+					Message{Address: "/foo", Arguments: Arguments{Int(1)}},
+					Bundle{
+						Timetag: Immediately,
+						Packets: []Packet{
+							Message{Address: "/bar", Arguments: Arguments{String("baz")}},
+						},
+					},
+				},
+			},
+		},
+		{
+			Input:    `#bundle 0x0000000000000002 [/a[bc]]`,
+			Expected: Bundle{Timetag: 2, Packets: []Packet{Message{Address: "/a[bc]"}}},
+		},
+	} {
+		p, err := ParseText(testcase.Input)
+		if err != nil {
+			t.Fatalf("(testcase %d) %s", i, err)
+		}
+		if !testcase.Expected.Equal(p) {
+			t.Fatalf("(testcase %d) expected %s, got %s", i, testcase.Expected, p)
+		}
+	}
+}
+
+func TestParseTextErrors(t *testing.T) {
+	for i, testcase := range []struct {
+		Input string
+		Err   string
+	}{
+		{
+			Input: ``,
+			Err:   `parse text at offset 0: expected a packet`,
+		},
+		{
+			Input: `foo`,
+			Err:   `parse text at offset 0: expected an address or #bundle, got "foo"`,
+		},
+		{
+			Input: `/foo bar`,
+			Err:   `parse text at offset 5: can not infer the type of "bar", strings must be quoted`,
+		},
+		{
+			Input: `/foo ,i 1.5`,
+			Err:   `parse text at offset 8: invalid int "1.5"`,
+		},
+		{
+			Input: `/foo ,if 1`,
+			Err:   `parse text at offset 10: missing value for typetag f`,
+		},
+		{
+			Input: `/foo ,T false`,
+			Err:   `parse text at offset 8: typetag T does not match value false`,
+		},
+		{
+			Input: `/foo ,x 1`,
+			Err:   `parse text at offset 8: invalid typetag "x"`,
+		},
+		{
+			Input: `/foo "bar`,
+			Err:   `parse text at offset 5: unterminated string`,
+		},
+		{
+			Input: `/foo ,i 1 2`,
+			Err:   `parse text at offset 10: unexpected "2" after packet`,
+		},
+		{
+			Input: `#bundle tomorrow [ ]`,
+			Err:   `parse text at offset 8: invalid timetag "tomorrow"`,
+		},
+		{
+			Input: `#bundle immediately [ /foo`,
+			Err:   `parse text at offset 26: expected ] at the end of the bundle`,
+		},
+	} {
+		_, err := ParseText(testcase.Input)
+		if err == nil {
+			t.Fatalf("(testcase %d) expected error, got nil", i)
+		}
+		if expected, got := testcase.Err, err.Error(); expected != got {
+			t.Fatalf("(testcase %d) expected %s, got %s", i, expected, got)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	for i, testcase := range []struct {
+		Input    Packet
+		Expected string
+	}{
+		{
+			Input:    Message{Address: "/synth/freq", Arguments: Arguments{Float(440), String("sine")}},
+			Expected: `/synth/freq ,fs 440.0 "sine"`,
+		},
+		{
+			Input:    Message{Address: "/foo", Arguments: Arguments{Int(-3), Float(0.25), Bool(true), Bool(false), Blob{1, 2, 3}, String("a\"b")}},
+			Expected: `/foo ,ifTFbs -3 0.25 true false b"AQID" "a\"b"`,
+		},
+		{
+			Input:    Message{Address: "/foo", Arguments: Arguments{Float(float32(math.Inf(-1))), Float(1e20)}},
+			Expected: `/foo ,ff -Inf 1e+20`,
+		},
+		{
+			Input:    Message{Address: "/status.reply", Arguments: Arguments{Double(48000), Double(47999.5)}},
+			Expected: `/status.reply ,dd 48000.0 47999.5`,
+		},
+		{
+			Input:    Message{Address: "/foo"},
+			Expected: `/foo`,
+		},
+		{
+			Input: Bundle{
+				Timetag: FromTime(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)),
+				Packets: []Packet{
+					Message{Address: "/foo", Arguments: Arguments{Int(1)}},
+					Bundle{Timetag: Immediately},
+				},
+			},
+			Expected: `#bundle 2024-01-01T00:00:00Z [ /foo ,i 1 #bundle immediately [ ] ]`,
+		},
+		{
+			Input:    Bundle{Timetag: 2},
+			Expected: `#bundle 0x0000000000000002 [ ]`,
+		},
+	} {
+		if expected, got := testcase.Expected, Format(testcase.Input); expected != got {
+			t.Fatalf("(testcase %d) expected %s, got %s", i, expected, got)
+		}
+	}
+}
+
+func TestTextRandomPackets(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 500; i++ {
+		p := randomPacket(r, 3)
+
+		text := Format(p)
+		got, err := ParseText(text)
+		if err != nil {
+			t.Fatalf("(packet %d) parse %s: %s", i, text, err)
+		}
+		if !p.Equal(got) {
+			t.Fatalf("(packet %d) expected %s, got %s", i, p, got)
+		}
+	}
+}
+
+func TestFormatIndent(t *testing.T) {
+	p := Bundle{
+		Timetag: Immediately,
+		Packets: []Packet{
+			Message{Address: "/foo", Arguments: Arguments{Int(1)}},
+			Bundle{Timetag: Immediately, Packets: []Packet{Message{Address: "/bar"}}},
+			Bundle{Timetag: Immediately},
+		},
+	}
+	expected := "#bundle immediately [\n" +
+		">  /foo ,i 1\n" +
+		">  #bundle immediately [\n" +
+		">    /bar\n" +
+		">  ]\n" +
+		">  #bundle immediately [ ]\n" +
+		">]"
+	if got := FormatIndent(p, ">", "  "); expected != got {
+		t.Fatalf("expected %q, got %q", expected, got)
+	}
+	parsed, err := ParseText(FormatIndent(p, "", "\t"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.Equal(parsed) {
+		t.Fatalf("expected %s, got %s", p, parsed)
+	}
+}
@@ -31,11 +31,14 @@ const (
 // significant bit is a special case meaning "immediately."
 type Timetag uint64
 
+// AppendTo appends the binary representation of the timetag to dst.
+func (tt Timetag) AppendTo(dst []byte) []byte {
+	return byteOrder.AppendUint64(dst, uint64(tt))
+}
+
 // Bytes converts the timetag to a slice of bytes.
 func (tt Timetag) Bytes() []byte {
-	bs := make([]byte, 8)
-	byteOrder.PutUint64(bs, uint64(tt))
-	return bs
+	return tt.AppendTo(make([]byte, 0, TimetagSize))
 }
 
 func (tt Timetag) String() string {
@@ -97,13 +97,17 @@ func (conn *UDPConn) read(data []byte) (int, net.Addr, error) {
 
 // Send sends an OSC message over UDP.
 func (conn *UDPConn) Send(p Packet) error {
-	_, err := conn.Write(p.Bytes())
+	buf := encodePacket(p)
+	defer bufPool.Put(buf)
+	_, err := conn.Write(*buf)
 	return err
 }
 
 // SendTo sends a packet to the given address.
 func (conn *UDPConn) SendTo(addr net.Addr, p Packet) error {
-	_, err := conn.WriteTo(p.Bytes(), addr)
+	buf := encodePacket(p)
+	defer bufPool.Put(buf)
+	_, err := conn.WriteTo(*buf, addr)
 	return err
 }
 
@@ -97,13 +97,17 @@ func (conn *UnixConn) read(data []byte) (int, net.Addr, error) {
 
 // Send sends a Packet.
 func (conn *UnixConn) Send(p Packet) error {
-	_, err := conn.Write(p.Bytes())
+	buf := encodePacket(p)
+	defer bufPool.Put(buf)
+	_, err := conn.Write(*buf)
 	return err
 }
 
 // SendTo sends a Packet to the provided net.Addr.
 func (conn *UnixConn) SendTo(addr net.Addr, p Packet) error {
-	_, err := conn.WriteTo(p.Bytes(), addr)
+	buf := encodePacket(p)
+	defer bufPool.Put(buf)
+	_, err := conn.WriteTo(*buf, addr)
 	return err
 }
 
@@ -0,0 +1,253 @@
+package osc
+
+import (
+	"bytes"
+	"math"
+	"net"
+
+	"github.com/pkg/errors"
+)
+
+// MessageView is a read-only view of an OSC message in its binary form.
+// The address and type tags can be inspected without copying or
+// decoding any of the arguments, which makes it cheap to decide
+// whether a message is interesting before parsing it with ParseMessage.
+// A MessageView refers to the byte slice it was created from,
+// so the slice must not be modified while the view is in use.
+type MessageView struct {
+	data     []byte
+	address  []byte
+	typetags []byte
+	args     []byte
+}
+
+// NewMessageView creates a view of the OSC message contained in data.
+// Only the address and the type tags are checked, arguments
+// are not decoded until they are read with an ArgumentIterator.
+func NewMessageView(data []byte) (MessageView, error) {
+	address, rest, err := sliceString(data)
+	if err != nil {
+		return MessageView{}, errors.Wrap(err, "read address")
+	}
+	if len(address) == 0 || address[0] != MessageChar {
+		return MessageView{}, errors.Wrapf(ErrParse, "address %q", address)
+	}
+	v := MessageView{data: data, address: address, args: rest}
+
+	// Type tags are optional, see the "OSC Type Tag String" section of the spec.
+	if len(rest) == 0 || rest[0] != TypetagPrefix {
+		return v, nil
+	}
+	typetags, rest, err := sliceString(rest)
+	if err != nil {
+		return MessageView{}, errors.Wrap(err, "read typetags")
+	}
+	v.typetags, v.args = typetags[1:], rest
+
+	return v, nil
+}
+
+// Address returns the address of the message.
+// The returned slice refers to the data of the view and must not be modified.
+func (v MessageView) Address() []byte {
+	return v.address
+}
+
+// Typetags returns the type tags of the message without the leading ','
+// and without any padding.
+// The returned slice refers to the data of the view and must not be modified.
+func (v MessageView) Typetags() []byte {
+	return v.typetags
+}
+
+// Arguments returns an iterator over the message's arguments.
+func (v MessageView) Arguments() *ArgumentIterator {
+	return &ArgumentIterator{typetags: v.typetags, data: v.args}
+}
+
+// Bytes returns the data the view was created from.
+func (v MessageView) Bytes() []byte {
+	return v.data
+}
+
+// Match returns true if the address of the message matches the given address.
+// See Message.Match for details.
+// Exact matches are performed without allocating.
+func (v MessageView) Match(address string, exactMatch bool) (bool, error) {
+	if exactMatch {
+		return string(v.address) == address, nil
+	}
+	return Message{Address: string(v.address)}.Match(address, false)
+}
+
+// Message decodes all the arguments and returns the message.
+func (v MessageView) Message(sender net.Addr) (Message, error) {
+	msg := Message{Address: string(v.address), Sender: sender}
+	args, err := ReadArguments(v.typetags, v.args)
+	if err != nil {
+		return Message{}, errors.Wrap(err, "parse message")
+	}
+	msg.Arguments = args
+	return msg, nil
+}
+
+// ArgumentIterator decodes the arguments of a MessageView on demand.
+// Call Next to advance to the next argument, then one of the Read methods
+// to decode it. Iteration stops at the end of the arguments or at the
+// first malformed argument, which is reported by Err.
+//
+//	args := view.Arguments()
+//	for args.Next() {
+//		switch args.Typetag() {
+//		case osc.TypetagInt:
+//			i, _ := args.ReadInt32()
+//			...
+//		}
+//	}
+//	if err := args.Err(); err != nil {
+//		...
+//	}
+type ArgumentIterator struct {
+	typetags []byte
+	data     []byte
+	idx      int
+	tt       byte
+	raw      []byte
+	err      error
+}
+
+// Next advances the iterator to the next argument.
+// It returns false when there are no more arguments or when an error occurred.
+func (it *ArgumentIterator) Next() bool {
+	if it.err != nil || it.idx >= len(it.typetags) {
+		return false
+	}
+	tt := it.typetags[it.idx]
+
+	n, err := argumentSize(tt, it.data)
+	if err != nil {
+		it.err = errors.Wrapf(err, "read argument %d", it.idx)
+		return false
+	}
+	it.tt, it.raw, it.data = tt, it.data[:n], it.data[n:]
+	it.idx++
+
+	return true
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (it *ArgumentIterator) Err() error {
+	return it.err
+}
+
+// Index returns the index of the current argument.
+func (it *ArgumentIterator) Index() int {
+	return it.idx - 1
+}
+
+// Typetag returns the type tag of the current argument.
+func (it *ArgumentIterator) Typetag() byte {
+	return it.tt
+}
+
+// Argument decodes the current argument.
+func (it *ArgumentIterator) Argument() (Argument, error) {
+	arg, _, err := ReadArgument(it.tt, it.raw)
+	return arg, err
+}
+
+// ReadInt32 reads a 32-bit integer from the current argument.
+func (it *ArgumentIterator) ReadInt32() (int32, error) {
+	if it.tt != TypetagInt {
+		return 0, ErrInvalidTypeTag
+	}
+	return int32(byteOrder.Uint32(it.raw)), nil
+}
+
+// ReadFloat32 reads a 32-bit float from the current argument.
+func (it *ArgumentIterator) ReadFloat32() (float32, error) {
+	if it.tt != TypetagFloat {
+		return 0, ErrInvalidTypeTag
+	}
+	return math.Float32frombits(byteOrder.Uint32(it.raw)), nil
+}
+
+// ReadFloat64 reads a 64-bit float from the current argument.
+func (it *ArgumentIterator) ReadFloat64() (float64, error) {
+	if it.tt != TypetagDouble {
+		return 0, ErrInvalidTypeTag
+	}
+	return math.Float64frombits(byteOrder.Uint64(it.raw)), nil
+}
+
+// ReadBool reads a boolean from the current argument.
+func (it *ArgumentIterator) ReadBool() (bool, error) {
+	switch it.tt {
+	case TypetagTrue:
+		return true, nil
+	case TypetagFalse:
+		return false, nil
+	default:
+		return false, ErrInvalidTypeTag
+	}
+}
+
+// ReadString reads a string from the current argument.
+func (it *ArgumentIterator) ReadString() (string, error) {
+	if it.tt != TypetagString {
+		return "", ErrInvalidTypeTag
+	}
+	return string(it.raw[:bytes.IndexByte(it.raw, 0)]), nil
+}
+
+// ReadBlob reads a slice of bytes from the current argument.
+// The returned slice refers to the data of the view and must not be modified.
+func (it *ArgumentIterator) ReadBlob() ([]byte, error) {
+	if it.tt != TypetagBlob {
+		return nil, ErrInvalidTypeTag
+	}
+	return it.raw[4 : 4+byteOrder.Uint32(it.raw)], nil
+}
+
+// sliceString slices a null-terminated, padded OSC string off the front of data.
+// The returned string does not include the null terminator or the padding.
+func sliceString(data []byte) ([]byte, []byte, error) {
+	nullidx := bytes.IndexByte(data, 0)
+	if nullidx == -1 {
+		return nil, nil, errors.Wrap(ErrParse, "string is not null-terminated")
+	}
+	n := padSize(nullidx + 1)
+	if n > len(data) {
+		n = len(data)
+	}
+	return data[:nullidx], data[n:], nil
+}
+
+// argumentSize returns the number of bytes occupied by an argument
+// with the given type tag at the start of data.
+func argumentSize(tt byte, data []byte) (int, error) {
+	var n int
+
+	switch tt {
+	case TypetagInt, TypetagFloat:
+		n = 4
+	case TypetagDouble:
+		n = 8
+	case TypetagTrue, TypetagFalse:
+		return 0, nil
+	case TypetagString:
+		_, rest, err := sliceString(data)
+		return len(data) - len(rest), err
+	case TypetagBlob:
+		if len(data) < 4 {
+			return 0, errors.Wrap(ErrParse, "blob is missing its size")
+		}
+		n = 4 + padSize(int(byteOrder.Uint32(data)))
+	default:
+		return 0, errors.Wrapf(ErrInvalidTypeTag, "typetag %q", string(tt))
+	}
+	if n > len(data) {
+		return 0, errors.Wrapf(ErrParse, "argument needs %d bytes, only %d left", n, len(data))
+	}
+	return n, nil
+}
@@ -0,0 +1,232 @@
+package osc
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestMessageView(t *testing.T) {
+	msg := Message{
+		Address: "/foo/bar",
+		Arguments: Arguments{
+			Int(-3),
+			Float(3.14),
+			Double(48000.5),
+			Bool(true),
+			Bool(false),
+			String("baz"),
+			Blob("blob!"),
+		},
+	}
+	view, err := NewMessageView(msg.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := []byte("/foo/bar"), view.Address(); !bytes.Equal(expected, got) {
+		t.Fatalf("expected %q, got %q", expected, got)
+	}
+	if expected, got := []byte("ifdTFsb"), view.Typetags(); !bytes.Equal(expected, got) {
+		t.Fatalf("expected %q, got %q", expected, got)
+	}
+	args := view.Arguments()
+	for args.Next() {
+		var (
+			i        = args.Index()
+			expected = msg.Arguments[i]
+		)
+		if expected, got := expected.Typetag(), args.Typetag(); expected != got {
+			t.Fatalf("(argument %d) expected %c, got %c", i, expected, got)
+		}
+		switch args.Typetag() {
+		case TypetagInt:
+			got, err := args.ReadInt32()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !expected.Equal(Int(got)) {
+				t.Fatalf("(argument %d) expected %s, got %d", i, expected, got)
+			}
+		case TypetagFloat:
+			got, err := args.ReadFloat32()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !expected.Equal(Float(got)) {
+				t.Fatalf("(argument %d) expected %s, got %f", i, expected, got)
+			}
+		case TypetagDouble:
+			got, err := args.ReadFloat64()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !expected.Equal(Double(got)) {
+				t.Fatalf("(argument %d) expected %s, got %f", i, expected, got)
+			}
+		case TypetagTrue, TypetagFalse:
+			got, err := args.ReadBool()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !expected.Equal(Bool(got)) {
+				t.Fatalf("(argument %d) expected %s, got %t", i, expected, got)
+			}
+		case TypetagString:
+			got, err := args.ReadString()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !expected.Equal(String(got)) {
+				t.Fatalf("(argument %d) expected %s, got %s", i, expected, got)
+			}
+		case TypetagBlob:
+			got, err := args.ReadBlob()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !expected.Equal(Blob(got)) {
+				t.Fatalf("(argument %d) expected %s, got %q", i, expected, got)
+			}
+		}
+		if _, err := args.ReadInt32(); args.Typetag() != TypetagInt && err != ErrInvalidTypeTag {
+			t.Fatalf("(argument %d) expected ErrInvalidTypeTag, got %+v", i, err)
+		}
+		if _, err := args.ReadFloat64(); args.Typetag() != TypetagDouble && err != ErrInvalidTypeTag {
+			t.Fatalf("(argument %d) expected ErrInvalidTypeTag, got %+v", i, err)
+		}
+	}
+	if err := args.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := len(msg.Arguments)-1, args.Index(); expected != got {
+		t.Fatalf("expected index %d, got %d", expected, got)
+	}
+	parsed, err := view.Message(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := msg.Address, parsed.Address; expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+	if expected, got := len(msg.Arguments), len(parsed.Arguments); expected != got {
+		t.Fatalf("expected %d arguments, got %d", expected, got)
+	}
+}
+
+func TestMessageViewErrors(t *testing.T) {
+	for i, testcase := range []struct {
+		Input []byte
+		Err   string
+	}{
+		{
+			Input: []byte{'/', 'f', 'o', 'o'},
+			Err:   "read address: string is not null-terminated: error parsing message",
+		},
+		{
+			Input: []byte{'f', 'o', 'o', 0},
+			Err:   `address "foo": error parsing message`,
+		},
+		{
+			Input: []byte{'/', 'f', 'o', 'o', 0, 0, 0, 0, ',', 'i', 'i', 'i'},
+			Err:   "read typetags: string is not null-terminated: error parsing message",
+		},
+	} {
+		_, err := NewMessageView(testcase.Input)
+		if err == nil {
+			t.Fatalf("(testcase %d) expected error, got nil", i)
+		}
+		if expected, got := testcase.Err, err.Error(); expected != got {
+			t.Fatalf("(testcase %d) expected %s, got %s", i, expected, got)
+		}
+	}
+}
+
+func TestArgumentIteratorErrors(t *testing.T) {
+	for i, testcase := range []struct {
+		Input []byte
+		Err   string
+	}{
+		{
+			Input: []byte{'/', 'f', 'o', 'o', 0, 0, 0, 0, ',', 'i', 0, 0, 0, 0},
+			Err:   "read argument 0: argument needs 4 bytes, only 2 left: error parsing message",
+		},
+		{
+			Input: []byte{'/', 'f', 'o', 'o', 0, 0, 0, 0, ',', 'b', 0, 0, 0, 0, 0, 8, 1, 2, 3, 4},
+			Err:   "read argument 0: argument needs 12 bytes, only 8 left: error parsing message",
+		},
+		{
+			Input: []byte{'/', 'f', 'o', 'o', 0, 0, 0, 0, ',', 'T', 'Q', 0},
+			Err:   `read argument 1: typetag "Q": invalid type tag`,
+		},
+	} {
+		view, err := NewMessageView(testcase.Input)
+		if err != nil {
+			t.Fatalf("(testcase %d) %s", i, err)
+		}
+		args := view.Arguments()
+		for args.Next() {
+		}
+		if args.Err() == nil {
+			t.Fatalf("(testcase %d) expected error, got nil", i)
+		}
+		if expected, got := testcase.Err, args.Err().Error(); expected != got {
+			t.Fatalf("(testcase %d) expected %s, got %s", i, expected, got)
+		}
+	}
+}
+
+func TestMessageViewMatch(t *testing.T) {
+	view, err := NewMessageView(Message{Address: "/path/to/meth?d"}.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	matched, err := view.Match("/path/to/method", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !matched {
+		t.Fatal("expected pattern to match")
+	}
+	matched, err = view.Match("/path/to/method", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if matched {
+		t.Fatal("expected exact match to fail")
+	}
+}
+
+func BenchmarkMessageView(b *testing.B) {
+	data := Message{
+		Address:   "/synth/freq",
+		Arguments: []Argument{Int(1000), Float(440), String("sine")},
+	}.Bytes()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		view, err := NewMessageView(data)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if matched, _ := view.Match("/synth/freq", true); !matched {
+			b.Fatal("expected match")
+		}
+	}
+}
+
+func BenchmarkParseMessage(b *testing.B) {
+	data := Message{
+		Address:   "/synth/freq",
+		Arguments: []Argument{Int(1000), Float(440), String("sine")},
+	}.Bytes()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := ParseMessage(data, nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -19,35 +19,54 @@ func (w worker) run() {
 
 DataLoop:
 	for incoming := range w.DataChan {
-		data := incoming.Data
-
-		switch data[0] {
-		case BundleTag[0]:
-			bundle, err := ParseBundle(data, incoming.Sender)
-			if err != nil {
-				w.ErrChan <- err
-			}
-			if err := w.Dispatcher.Dispatch(bundle, w.ExactMatch); err != nil {
+		accepted, err := w.accept(incoming.Data)
+		if err != nil {
+			w.ErrChan <- err
+			continue DataLoop
+		}
+		if !accepted {
+			w.Ready <- w
+			continue DataLoop
+		}
+		p, err := ParsePacket(incoming.Data, incoming.Sender)
+		if err != nil {
+			w.ErrChan <- err
+			continue DataLoop
+		}
+		switch x := p.(type) {
+		case Bundle:
+			if err := w.Dispatcher.Dispatch(x, w.ExactMatch); err != nil {
 				w.ErrChan <- errors.Wrap(err, "dispatch bundle")
-			}
-		case MessageChar:
-			msg, err := ParseMessage(data, incoming.Sender)
-			if err != nil {
-				w.ErrChan <- err
 				continue DataLoop
 			}
-			if err := ValidateAddress(msg.Address); err != nil {
+		case Message:
+			if err := ValidateAddress(x.Address); err != nil {
 				w.ErrChan <- err
 				continue DataLoop
 			}
-			if err := w.Dispatcher.Invoke(msg, w.ExactMatch); err != nil {
+			if err := w.Dispatcher.Invoke(x, w.ExactMatch); err != nil {
 				w.ErrChan <- errors.Wrap(err, "dispatch message")
 				continue DataLoop
 			}
-		default:
-			w.ErrChan <- ErrParse
 		}
 		// Announce the worker is ready again.
 		w.Ready <- w
 	}
 }
+
+// accept returns true if the worker's dispatcher wants the message in data.
+// Bundles, and every message if the dispatcher doesn't implement MessageFilter, are accepted.
+func (w worker) accept(data []byte) (bool, error) {
+	filter, ok := w.Dispatcher.(MessageFilter)
+	if !ok || len(data) == 0 || data[0] != MessageChar {
+		return true, nil
+	}
+	view, err := NewMessageView(data)
+	if err != nil {
+		return false, err
+	}
+	if err := ValidateAddress(string(view.Address())); err != nil {
+		return false, err
+	}
+	return filter.Accept(view, w.ExactMatch)
+}
@@ -51,6 +51,42 @@ func TestWorkerRun(t *testing.T) {
 	}
 }
 
+func TestWorkerRunParseError(t *testing.T) {
+	var (
+		data  = make(chan Incoming)
+		errch = make(chan error)
+		ready = make(chan worker)
+	)
+	wrk := worker{
+		DataChan:   data,
+		Dispatcher: errorDispatcher{},
+		ErrChan:    errch,
+		Ready:      ready,
+	}
+	defer close(data)
+
+	go wrk.run()
+
+	select {
+	case <-ready:
+	case <-time.After(1 * time.Second):
+		t.Fatal("timeout receiving on ready chan")
+	}
+	select {
+	case data <- Incoming{Data: []byte{'x', 0, 0, 0}}:
+	case <-time.After(1 * time.Second):
+		t.Fatal("timeout sending on data chan")
+	}
+	select {
+	case err := <-errch:
+		if errors.Cause(err) != ErrParse {
+			t.Fatalf("expected cause %s, got %v", ErrParse, err)
+		}
+	case <-time.After(1 * time.Second):
+		t.Fatal("timeout receiving on error chan")
+	}
+}
+
 type errorDispatcher struct {
 }
 
@@ -61,3 +97,58 @@ func (d errorDispatcher) Dispatch(bundle Bundle, exactMatch bool) error {
 func (d errorDispatcher) Invoke(msg Message, exactMatch bool) error {
 	return errors.New("fake Invoke error")
 }
+
+func TestWorkerRunFilter(t *testing.T) {
+	var (
+		data  = make(chan Incoming)
+		errch = make(chan error)
+		ready = make(chan worker)
+	)
+	wrk := worker{
+		DataChan:   data,
+		Dispatcher: filterDispatcher{},
+		ErrChan:    errch,
+		Ready:      ready,
+	}
+	defer close(data)
+
+	go wrk.run()
+
+	for _, msg := range []Message{
+		{Address: "/ignored"},
+		{Address: "/foo"},
+	} {
+		select {
+		case <-ready:
+		case <-time.After(1 * time.Second):
+			t.Fatal("timeout receiving on ready chan")
+		}
+		select {
+		case data <- Incoming{Data: msg.Bytes()}:
+		case <-time.After(1 * time.Second):
+			t.Fatal("timeout sending on data chan")
+		}
+	}
+	// Only the accepted message reaches Invoke.
+	select {
+	case err := <-errch:
+		if expected, got := "dispatch message: fake Invoke error /foo", err.Error(); expected != got {
+			t.Fatalf("expected %s, got %s", expected, got)
+		}
+	case <-time.After(1 * time.Second):
+		t.Fatal("timeout receiving on error chan")
+	}
+}
+
+// filterDispatcher only accepts messages sent to /foo.
+type filterDispatcher struct {
+	errorDispatcher
+}
+
+func (d filterDispatcher) Accept(view MessageView, exactMatch bool) (bool, error) {
+	return view.Match("/foo", true)
+}
+
+func (d filterDispatcher) Invoke(msg Message, exactMatch bool) error {
+	return errors.New("fake Invoke error " + msg.Address)
+}
@@ -0,0 +1,310 @@
+package osc
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/scgolang/osc/internal/websocket"
+)
+
+// WSConn is an OSC connection over WebSocket.
+// Every packet is sent in a binary message, and text messages are ignored.
+type WSConn struct {
+	ws *websocket.Conn
+
+	closeChan  chan struct{}
+	closeOnce  sync.Once
+	ctx        context.Context
+	exactMatch bool
+}
+
+// DialWS opens an OSC connection to a ws:// or wss:// URL.
+func DialWS(rawURL string) (*WSConn, error) {
+	return DialWSContext(context.Background(), rawURL)
+}
+
+// DialWSContext opens an OSC connection to a ws:// or wss:// URL that can be canceled with the provided context.
+func DialWSContext(ctx context.Context, rawURL string) (*WSConn, error) {
+	ws, err := websocket.Dial(ctx, rawURL)
+	if err != nil {
+		return nil, err
+	}
+	return newWSConn(ctx, ws), nil
+}
+
+// newWSConn returns an OSC connection over a WebSocket connection.
+func newWSConn(ctx context.Context, ws *websocket.Conn) *WSConn {
+	ws.SetMaxMessageLen(bufSize)
+
+	return &WSConn{
+		ws:        ws,
+		closeChan: make(chan struct{}),
+		ctx:       ctx,
+	}
+}
+
+// Close closes the connection.
+func (conn *WSConn) Close() error {
+	conn.closeOnce.Do(func() { close(conn.closeChan) })
+	return conn.ws.Close()
+}
+
+// CloseChan returns a channel that is closed when the connection gets closed.
+func (conn *WSConn) CloseChan() <-chan struct{} {
+	return conn.closeChan
+}
+
+// Context returns the context associated with the conn.
+func (conn *WSConn) Context() context.Context {
+	return conn.ctx
+}
+
+// LocalAddr returns the local network address.
+func (conn *WSConn) LocalAddr() net.Addr {
+	return conn.ws.LocalAddr()
+}
+
+// RemoteAddr returns the remote network address.
+func (conn *WSConn) RemoteAddr() net.Addr {
+	return conn.ws.RemoteAddr()
+}
+
+// preservesMessages implements messageConn, since every Write is sent
+// in a WebSocket message of its own.
+func (conn *WSConn) preservesMessages() {}
+
+// Read reads the next binary message into data.
+// If data is too small the rest of the message is discarded.
+func (conn *WSConn) Read(data []byte) (int, error) {
+	n, _, err := conn.read(data)
+	return n, err
+}
+
+// read reads the next binary message and returns the net.Addr of the peer.
+// When the peer closes the connection the conn is closed too.
+func (conn *WSConn) read(data []byte) (int, net.Addr, error) {
+	for {
+		typ, msg, err := conn.ws.ReadMessage()
+		if err != nil {
+			if _, ok := err.(*websocket.CloseError); ok {
+				_ = conn.Close()
+				return 0, nil, net.ErrClosed
+			}
+			return 0, nil, err
+		}
+		if typ == websocket.BinaryMessage {
+			return copy(data, msg), conn.RemoteAddr(), nil
+		}
+	}
+}
+
+// Write sends data in a binary message.
+func (conn *WSConn) Write(data []byte) (int, error) {
+	if err := conn.ws.WriteMessage(websocket.BinaryMessage, data); err != nil {
+		return 0, err
+	}
+	return len(data), nil
+}
+
+// Send sends an OSC packet in a binary message.
+func (conn *WSConn) Send(p Packet) error {
+	buf := encodePacket(p)
+	defer bufPool.Put(buf)
+	_, err := conn.Write(*buf)
+	return err
+}
+
+// SendTo sends a packet to the given address, which must be the address of the peer.
+func (conn *WSConn) SendTo(addr net.Addr, p Packet) error {
+	if !sameAddr(addr, conn.RemoteAddr()) {
+		return errors.Wrap(ErrUnknownDestination, addr.String())
+	}
+	return conn.Send(p)
+}
+
+// Serve starts dispatching OSC.
+// Any errors returned from a dispatched method will be returned.
+// Note that this means that errors returned from a dispatcher method will kill your server.
+// It returns nil when the connection is closed by either side.
+func (conn *WSConn) Serve(numWorkers int, dispatcher Dispatcher) error {
+	return serve(conn, numWorkers, conn.exactMatch, dispatcher)
+}
+
+// SetContext sets the context associated with the conn.
+func (conn *WSConn) SetContext(ctx context.Context) {
+	conn.ctx = ctx
+}
+
+// SetDeadline sets the read and write deadlines.
+func (conn *WSConn) SetDeadline(t time.Time) error {
+	if err := conn.ws.SetReadDeadline(t); err != nil {
+		return err
+	}
+	return conn.ws.SetWriteDeadline(t)
+}
+
+// SetReadDeadline sets the read deadline.
+func (conn *WSConn) SetReadDeadline(t time.Time) error {
+	return conn.ws.SetReadDeadline(t)
+}
+
+// SetWriteDeadline sets the write deadline.
+func (conn *WSConn) SetWriteDeadline(t time.Time) error {
+	return conn.ws.SetWriteDeadline(t)
+}
+
+// SetExactMatch changes the behavior of the Serve method so that
+// messages will only be dispatched to methods whose addresses
+// match the message's address exactly.
+// This should provide some performance improvement.
+func (conn *WSConn) SetExactMatch(value bool) {
+	conn.exactMatch = value
+}
+
+// WSServer is an http.Handler that accepts WebSocket connections
+// and serves the packets of each one through a Dispatcher, in the order they arrive.
+// The Sender of messages is the address of the client, so methods can
+// reply with SendTo.
+type WSServer struct {
+	dispatcher Dispatcher
+
+	mu           sync.Mutex
+	ctx          context.Context
+	conns        map[*WSConn]struct{}
+	errorHandler func(error)
+	exactMatch   bool
+	checkOrigin  func(*http.Request) bool
+}
+
+// NewWSServer creates a WebSocket server that dispatches packets to dispatcher.
+func NewWSServer(dispatcher Dispatcher) *WSServer {
+	return &WSServer{
+		dispatcher: dispatcher,
+		ctx:        context.Background(),
+		conns:      map[*WSConn]struct{}{},
+	}
+}
+
+// Conns returns the connections of the clients.
+func (s *WSServer) Conns() []*WSConn {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	conns := make([]*WSConn, 0, len(s.conns))
+	for conn := range s.conns {
+		conns = append(conns, conn)
+	}
+	return conns
+}
+
+// Close closes the connections of the clients.
+func (s *WSServer) Close() error {
+	var err error
+	for _, conn := range s.Conns() {
+		if cerr := conn.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// Send sends a packet to every client.
+// If sending to some clients fails the returned error is a *BroadcastError.
+func (s *WSServer) Send(p Packet) error {
+	be := &BroadcastError{Errors: map[string]error{}}
+	for _, conn := range s.Conns() {
+		if err := conn.Send(p); err != nil {
+			be.Errors[conn.RemoteAddr().String()] = err
+		}
+	}
+	if len(be.Errors) > 0 {
+		return be
+	}
+	return nil
+}
+
+// SendTo sends a packet to the client with the given address.
+func (s *WSServer) SendTo(addr net.Addr, p Packet) error {
+	for _, conn := range s.Conns() {
+		if sameAddr(addr, conn.RemoteAddr()) {
+			return conn.Send(p)
+		}
+	}
+	return errors.Wrap(ErrUnknownDestination, addr.String())
+}
+
+// ServeHTTP accepts a WebSocket connection and serves it until it is closed.
+func (s *WSServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	checkOrigin := s.checkOrigin
+	s.mu.Unlock()
+
+	ws, err := websocket.Upgrade(w, r, checkOrigin)
+	if err != nil {
+		s.handleError(errors.Wrap(err, "upgrade"))
+		return
+	}
+	s.mu.Lock()
+	conn := newWSConn(s.ctx, ws)
+	conn.SetExactMatch(s.exactMatch)
+	s.conns[conn] = struct{}{}
+	s.mu.Unlock()
+
+	if err := conn.Serve(1, s.dispatcher); err != nil {
+		s.handleError(errors.Wrap(err, conn.RemoteAddr().String()))
+	}
+	_ = conn.Close()
+
+	s.mu.Lock()
+	delete(s.conns, conn)
+	s.mu.Unlock()
+}
+
+// SetContext sets the context of the connections that are accepted afterwards.
+// When it is canceled they stop serving.
+func (s *WSServer) SetContext(ctx context.Context) {
+	s.mu.Lock()
+	s.ctx = ctx
+	s.mu.Unlock()
+}
+
+// SetErrorHandler sets a function that handles errors of connections,
+// e.g. failed handshakes and dispatcher errors, which close the connection.
+// Errors are ignored by default.
+func (s *WSServer) SetErrorHandler(handler func(error)) {
+	s.mu.Lock()
+	s.errorHandler = handler
+	s.mu.Unlock()
+}
+
+// SetCheckOrigin sets a function that returns true if a connection may be accepted,
+// usually by checking the Origin header of the request.
+// By default requests from the same host as the server are accepted, and so are
+// requests without an Origin header, which come from clients that are not browsers.
+func (s *WSServer) SetCheckOrigin(checkOrigin func(r *http.Request) bool) {
+	s.mu.Lock()
+	s.checkOrigin = checkOrigin
+	s.mu.Unlock()
+}
+
+// SetExactMatch sets the exact match behavior of the connections that are accepted afterwards.
+func (s *WSServer) SetExactMatch(value bool) {
+	s.mu.Lock()
+	s.exactMatch = value
+	s.mu.Unlock()
+}
+
+// handleError passes an error to the error handler.
+func (s *WSServer) handleError(err error) {
+	s.mu.Lock()
+	handler := s.errorHandler
+	s.mu.Unlock()
+
+	if handler != nil {
+		handler(err)
+	}
+}
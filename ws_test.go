@@ -0,0 +1,264 @@
+package osc
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// testWSServer returns a WebSocket server mounted at /osc of an HTTP server,
+// that answers /ping with /pong. The caller closes both.
+func testWSServer() (*WSServer, *httptest.Server) {
+	var s *WSServer
+	s = NewWSServer(PatternMatching{
+		"/ping": Method(func(msg Message) error {
+			return s.SendTo(msg.Sender, Message{Address: "/pong", Arguments: msg.Arguments})
+		}),
+	})
+	mux := http.NewServeMux()
+	mux.Handle("/osc", s)
+
+	return s, httptest.NewServer(mux)
+}
+
+// dialWS returns a client connection to the server of testWSServer that sends
+// the packets it receives to packets, and a channel that emits the error
+// returned from its Serve method. The caller closes it with closeWS.
+func dialWS(t *testing.T, srv *httptest.Server, packets chan Packet) (*WSConn, chan error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	conn, err := DialWSContext(ctx, "ws"+strings.TrimPrefix(srv.URL, "http")+"/osc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.SetContext(context.Background())
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- conn.Serve(1, PatternMatching{
+			"/pong": Method(func(msg Message) error {
+				packets <- msg
+				return nil
+			}),
+			"/meter": Method(func(msg Message) error {
+				packets <- msg
+				return nil
+			}),
+		})
+	}()
+	return conn, errs
+}
+
+// closeWS closes a connection of dialWS and checks the error returned from its Serve method.
+func closeWS(t *testing.T, conn *WSConn, errs chan error) {
+	_ = conn.Close()
+	if err := <-errs; err != nil {
+		t.Error(err)
+	}
+}
+
+// expectPacket waits for a packet and compares its text to expected.
+func expectPacket(t *testing.T, packets chan Packet, expected string) {
+	select {
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timeout waiting for %s", expected)
+	case p := <-packets:
+		if got := Format(p); expected != got {
+			t.Fatalf("expected %s, got %s", expected, got)
+		}
+	}
+}
+
+func TestWSConn(t *testing.T) {
+	var (
+		s, srv     = testWSServer()
+		packets    = make(chan Packet, 1)
+		conn, errs = dialWS(t, srv, packets)
+	)
+	defer srv.Close()
+	defer func() { _ = s.Close() }()
+	defer closeWS(t, conn, errs)
+
+	if err := conn.Send(Message{Address: "/ping", Arguments: Arguments{Int(1)}}); err != nil {
+		t.Fatal(err)
+	}
+	expectPacket(t, packets, "/pong ,i 1")
+
+	if err := conn.Send(Bundle{Timetag: Immediately, Packets: []Packet{Message{Address: "/ping", Arguments: Arguments{String("bundled")}}}}); err != nil {
+		t.Fatal(err)
+	}
+	expectPacket(t, packets, `/pong ,s "bundled"`)
+
+	if expected, got := 1, len(s.Conns()); expected != got {
+		t.Fatalf("expected %d connections, got %d", expected, got)
+	}
+	if err := s.Send(Message{Address: "/meter", Arguments: Arguments{Float(0.5)}}); err != nil {
+		t.Fatal(err)
+	}
+	expectPacket(t, packets, "/meter ,f 0.5")
+
+	if err := conn.SendTo(conn.LocalAddr(), Message{Address: "/ping"}); errors.Cause(err) != ErrUnknownDestination {
+		t.Fatalf("expected ErrUnknownDestination, got %v", err)
+	}
+	if err := s.SendTo(conn.RemoteAddr(), Message{Address: "/meter"}); errors.Cause(err) != ErrUnknownDestination {
+		t.Fatalf("expected ErrUnknownDestination, got %v", err)
+	}
+	// Closing the client removes its connection from the server.
+	if err := conn.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for deadline := time.Now().Add(5 * time.Second); len(s.Conns()) > 0; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("timeout waiting for the server to close the connection")
+		}
+	}
+}
+
+func TestWSConnBroadcast(t *testing.T) {
+	var (
+		s, srv     = testWSServer()
+		packets    = make(chan Packet, 1)
+		conn, errs = dialWS(t, srv, packets)
+		b          = NewBroadcaster()
+	)
+	defer srv.Close()
+	defer func() { _ = s.Close() }()
+	defer closeWS(t, conn, errs)
+	defer func() { _ = b.Close() }()
+
+	// Packets are sent without framing, in a WebSocket message each.
+	if err := b.AddConn(conn); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Send(Message{Address: "/ping", Arguments: Arguments{Int(2)}}); err != nil {
+		t.Fatal(err)
+	}
+	expectPacket(t, packets, "/pong ,i 2")
+}
+
+func TestWSServerClose(t *testing.T) {
+	var (
+		s, srv     = testWSServer()
+		packets    = make(chan Packet, 1)
+		conn, errs = dialWS(t, srv, packets)
+	)
+	defer srv.Close()
+	defer func() { _ = s.Close() }()
+	defer closeWS(t, conn, errs)
+
+	if err := conn.Send(Message{Address: "/ping"}); err != nil {
+		t.Fatal(err)
+	}
+	expectPacket(t, packets, "/pong")
+
+	// Serve returns nil when the server closes the connection.
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for the connection to be closed")
+	case <-conn.CloseChan():
+	}
+}
+
+func TestWSServerErrors(t *testing.T) {
+	var (
+		errs = make(chan error, 1)
+		s    = NewWSServer(PatternMatching{
+			"/fail": Method(func(msg Message) error {
+				return errors.New("oops")
+			}),
+		})
+		srv = httptest.NewServer(s)
+	)
+	defer srv.Close()
+
+	s.SetErrorHandler(func(err error) { errs <- err })
+
+	// Requests that are not WebSocket handshakes are rejected.
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+
+	if expected, got := http.StatusBadRequest, resp.StatusCode; expected != got {
+		t.Fatalf("expected status %d, got %d", expected, got)
+	}
+	if expected, got := "upgrade: missing websocket upgrade headers", (<-errs).Error(); expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+	// Dispatcher errors close the connection.
+	conn, err := DialWS("ws" + strings.TrimPrefix(srv.URL, "http"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	if err := conn.Send(Message{Address: "/fail"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-errs; !strings.HasSuffix(err.Error(), "dispatch message: oops") {
+		t.Fatalf("expected dispatch error, got %v", err)
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := conn.Read(make([]byte, 16)); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+}
+
+func TestWSServerOrigin(t *testing.T) {
+	var (
+		errs = make(chan error, 1)
+		s    = NewWSServer(PatternMatching{})
+		srv  = httptest.NewServer(s)
+	)
+	defer srv.Close()
+	defer func() { _ = s.Close() }()
+
+	s.SetErrorHandler(func(err error) {
+		select {
+		case errs <- err:
+		default:
+		}
+	})
+	// Browsers on other sites can't connect, unless their origin is allowed.
+	req, err := http.NewRequest(http.MethodGet, srv.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header = http.Header{
+		"Connection":            {"Upgrade"},
+		"Upgrade":               {"websocket"},
+		"Sec-Websocket-Version": {"13"},
+		"Sec-Websocket-Key":     {"dGhlIHNhbXBsZSBub25jZQ=="},
+		"Origin":                {"https://example.com"},
+	}
+	for i, expected := range []int{http.StatusForbidden, http.StatusSwitchingProtocols} {
+		if i == 1 {
+			s.SetCheckOrigin(func(r *http.Request) bool { return r.Header.Get("Origin") == "https://example.com" })
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+
+		if got := resp.StatusCode; expected != got {
+			t.Fatalf("(request %d) expected status %d, got %d", i, expected, got)
+		}
+		if i > 0 {
+			continue
+		}
+		if expected, got := "upgrade: origin https://example.com is not allowed", (<-errs).Error(); expected != got {
+			t.Fatalf("expected %s, got %s", expected, got)
+		}
+	}
+}